@@ -0,0 +1,59 @@
+// Package clientip resolves the client IP address of a request behind trusted proxies, for the middlewares keying
+// or logging requests by client.
+package clientip
+
+import (
+	"net"
+	"net/http"
+	"net/netip"
+	"strings"
+)
+
+// FromRequest returns the client IP address of the request. When the direct peer (http.Request RemoteAddr) is one of
+// the trusted proxies, the X-Forwarded-For header is walked from right to left and the first address that is not a
+// trusted proxy is used instead. Without trusted proxies X-Forwarded-For is never consulted, as it can be freely set
+// by any client.
+func FromRequest(r *http.Request, trustedProxies []netip.Prefix) (netip.Addr, bool) {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+
+	addr, err := netip.ParseAddr(host)
+	if err != nil {
+		return netip.Addr{}, false
+	}
+	addr = addr.Unmap()
+
+	if !isTrusted(addr, trustedProxies) {
+		return addr, true
+	}
+
+	forwarded := r.Header.Values("X-Forwarded-For")
+	for idx := len(forwarded) - 1; idx >= 0; idx-- {
+		hops := strings.Split(forwarded[idx], ",")
+		for hop := len(hops) - 1; hop >= 0; hop-- {
+			hopAddr, hopErr := netip.ParseAddr(strings.TrimSpace(hops[hop]))
+			if hopErr != nil {
+				return addr, true
+			}
+
+			addr = hopAddr.Unmap()
+			if !isTrusted(addr, trustedProxies) {
+				return addr, true
+			}
+		}
+	}
+
+	return addr, true
+}
+
+func isTrusted(addr netip.Addr, trustedProxies []netip.Prefix) bool {
+	for _, prefix := range trustedProxies {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+
+	return false
+}
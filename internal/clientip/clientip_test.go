@@ -0,0 +1,41 @@
+package clientip
+
+import (
+	"net/http/httptest"
+	"net/netip"
+	"testing"
+)
+
+func TestFromRequest(t *testing.T) {
+	t.Parallel()
+
+	proxies := []netip.Prefix{netip.MustParsePrefix("10.0.0.0/8")}
+
+	tests := []struct {
+		remoteAddr, forwarded string
+		trusted               []netip.Prefix
+		want                  string
+		ok                    bool
+	}{
+		{"192.0.2.1:1234", "", nil, "192.0.2.1", true},
+		{"[::ffff:192.0.2.1]:1234", "", nil, "192.0.2.1", true},
+		{"192.0.2.1:1234", "198.51.100.1", nil, "192.0.2.1", true},
+		{"10.0.0.1:1234", "198.51.100.1, 10.0.0.2", proxies, "198.51.100.1", true},
+		{"10.0.0.1:1234", "garbage, 10.0.0.2", proxies, "10.0.0.2", true},
+		{"10.0.0.1:1234", "", proxies, "10.0.0.1", true},
+		{"pipe", "", nil, "", false},
+	}
+
+	for _, tt := range tests {
+		r := httptest.NewRequest("GET", "/", nil)
+		r.RemoteAddr = tt.remoteAddr
+		if tt.forwarded != "" {
+			r.Header.Set("X-Forwarded-For", tt.forwarded)
+		}
+
+		addr, ok := FromRequest(r, tt.trusted)
+		if ok != tt.ok || (ok && addr.String() != tt.want) {
+			t.Errorf("%s %q: expected %s %v, got %s %v", tt.remoteAddr, tt.forwarded, tt.want, tt.ok, addr, ok)
+		}
+	}
+}
@@ -0,0 +1,215 @@
+// Package fields resolves the encoded fields of structs from their tags, following the rules of encoding/json, for
+// the binary codecs.
+package fields
+
+import (
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Field is an encoded struct field.
+type Field struct {
+	Name      string
+	Index     []int
+	OmitEmpty bool
+
+	// folded is the lower case Name, for case-insensitive matching.
+	folded string
+	tagged bool
+}
+
+// Struct holds the encoded fields of a struct type.
+type Struct struct {
+	Fields []Field
+
+	byName map[string]int
+	byFold map[string]int
+}
+
+// Lookup returns the field with the given name, matched exactly first and case-insensitively otherwise, like
+// encoding/json does.
+func (s *Struct) Lookup(name string) (*Field, bool) {
+	if idx, ok := s.byName[name]; ok {
+		return &s.Fields[idx], true
+	}
+	if idx, ok := s.byFold[strings.ToLower(name)]; ok {
+		return &s.Fields[idx], true
+	}
+	return nil, false
+}
+
+type cacheKey struct {
+	t   reflect.Type
+	tag string
+}
+
+var cache sync.Map
+
+// Of returns the encoded fields of the struct type t, named after the tag key (falling back to the json tag). The
+// tag value has the form "name,omitempty", "-" skips the field. Exported fields of embedded structs are promoted,
+// following the same precedence rules as encoding/json.
+func Of(t reflect.Type, tag string) *Struct {
+	key := cacheKey{t: t, tag: tag}
+	if s, ok := cache.Load(key); ok {
+		return s.(*Struct)
+	}
+
+	s := build(t, tag)
+	cache.Store(key, s)
+	return s
+}
+
+func build(t reflect.Type, tag string) *Struct {
+	var all []Field
+	collect(t, tag, nil, map[reflect.Type]bool{}, &all)
+
+	// the shallowest field wins, then the tagged one, fields conflicting at the same level are all dropped
+	sort.SliceStable(all, func(i, j int) bool {
+		if all[i].Name != all[j].Name {
+			return all[i].Name < all[j].Name
+		}
+		if len(all[i].Index) != len(all[j].Index) {
+			return len(all[i].Index) < len(all[j].Index)
+		}
+		return all[i].tagged && !all[j].tagged
+	})
+
+	var fields []Field
+	for i := 0; i < len(all); {
+		j := i + 1
+		for j < len(all) && all[j].Name == all[i].Name {
+			j++
+		}
+
+		dominant := all[i]
+		conflict := j > i+1 && len(all[i+1].Index) == len(dominant.Index) && all[i+1].tagged == dominant.tagged
+		if !conflict {
+			fields = append(fields, dominant)
+		}
+
+		i = j
+	}
+
+	// fields are encoded in declaration order, like encoding/json
+	sort.Slice(fields, func(i, j int) bool {
+		a, b := fields[i].Index, fields[j].Index
+		for k := 0; k < len(a) && k < len(b); k++ {
+			if a[k] != b[k] {
+				return a[k] < b[k]
+			}
+		}
+		return len(a) < len(b)
+	})
+
+	s := &Struct{
+		Fields: fields,
+		byName: make(map[string]int, len(fields)),
+		byFold: make(map[string]int, len(fields)),
+	}
+	for idx := range fields {
+		fields[idx].folded = strings.ToLower(fields[idx].Name)
+		s.byName[fields[idx].Name] = idx
+		if _, ok := s.byFold[fields[idx].folded]; !ok {
+			s.byFold[fields[idx].folded] = idx
+		}
+	}
+
+	return s
+}
+
+func collect(t reflect.Type, tag string, index []int, visited map[reflect.Type]bool, all *[]Field) {
+	if visited[t] {
+		return
+	}
+	visited[t] = true
+	defer delete(visited, t)
+
+	for i := range t.NumField() {
+		sf := t.Field(i)
+
+		value, ok := sf.Tag.Lookup(tag)
+		if !ok {
+			value, ok = sf.Tag.Lookup("json")
+		}
+		if value == "-" {
+			continue
+		}
+
+		name, options, _ := strings.Cut(value, ",")
+
+		fieldType := sf.Type
+		if sf.Anonymous {
+			if fieldType.Kind() == reflect.Pointer {
+				fieldType = fieldType.Elem()
+			}
+
+			// untagged embedded structs are flattened, even when unexported
+			if name == "" && fieldType.Kind() == reflect.Struct {
+				collect(fieldType, tag, append(append([]int(nil), index...), i), visited, all)
+				continue
+			}
+			if !sf.IsExported() {
+				continue
+			}
+		} else if !sf.IsExported() {
+			continue
+		}
+
+		tagged := name != ""
+		if !tagged {
+			name = sf.Name
+		}
+
+		*all = append(*all, Field{
+			Name:      name,
+			Index:     append(append([]int(nil), index...), i),
+			OmitEmpty: hasOption(options, "omitempty"),
+			tagged:    tagged && ok,
+		})
+	}
+}
+
+func hasOption(options, option string) bool {
+	for o := range strings.SplitSeq(options, ",") {
+		if o == option {
+			return true
+		}
+	}
+	return false
+}
+
+// Value returns the field of the struct value v. Nil embedded pointers are allocated when alloc is set, otherwise
+// false is returned.
+func (f *Field) Value(v reflect.Value, alloc bool) (reflect.Value, bool) {
+	for idx, i := range f.Index {
+		if idx > 0 && v.Kind() == reflect.Pointer {
+			if v.IsNil() {
+				if !alloc || !v.CanSet() {
+					return reflect.Value{}, false
+				}
+				v.Set(reflect.New(v.Type().Elem()))
+			}
+			v = v.Elem()
+		}
+		v = v.Field(i)
+	}
+	return v, true
+}
+
+// IsEmpty reports whether the value is empty for omitempty, like encoding/json.
+func IsEmpty(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Array, reflect.Map, reflect.Slice, reflect.String:
+		return v.Len() == 0
+	case reflect.Bool,
+		reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr,
+		reflect.Float32, reflect.Float64,
+		reflect.Interface, reflect.Pointer:
+		return v.IsZero()
+	default:
+		return false
+	}
+}
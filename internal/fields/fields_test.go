@@ -0,0 +1,79 @@
+package fields
+
+import (
+	"reflect"
+	"testing"
+)
+
+type embedded struct {
+	ID    int
+	Inner string `test:"inner"`
+}
+
+type Embedded struct {
+	Shadowed string
+}
+
+type testStruct struct {
+	embedded
+	*Embedded
+
+	Name     string `test:"name,omitempty"`
+	JSON     string `json:"json_name"`
+	Skipped  string `test:"-"`
+	Shadowed string
+	private  string //nolint:unused
+	ID       string `test:"id"`
+}
+
+func TestOf(t *testing.T) {
+	t.Parallel()
+
+	s := Of(reflect.TypeFor[testStruct](), "test")
+
+	var names []string
+	for _, f := range s.Fields {
+		names = append(names, f.Name)
+	}
+
+	want := []string{"ID", "inner", "name", "json_name", "Shadowed", "id"}
+	if !reflect.DeepEqual(names, want) {
+		t.Errorf("expected %v, got %v", want, names)
+	}
+
+	if f, ok := s.Lookup("NAME"); !ok || !f.OmitEmpty {
+		t.Errorf("expected case-insensitive lookup of name with omitempty, got %+v", f)
+	}
+	if f, ok := s.Lookup("ID"); !ok || len(f.Index) != 2 {
+		t.Errorf("expected the promoted ID, got %+v", f)
+	}
+
+	if s != Of(reflect.TypeFor[testStruct](), "test") {
+		t.Errorf("expected the struct fields to be cached")
+	}
+}
+
+func TestField_Value(t *testing.T) {
+	t.Parallel()
+
+	type outer struct {
+		*Embedded
+	}
+
+	s := Of(reflect.TypeFor[outer](), "test")
+	f, _ := s.Lookup("Shadowed")
+
+	var v outer
+	if _, ok := f.Value(reflect.ValueOf(&v).Elem(), false); ok {
+		t.Errorf("expected nil embedded pointer not to be allocated")
+	}
+
+	field, ok := f.Value(reflect.ValueOf(&v).Elem(), true)
+	if !ok || v.Embedded == nil {
+		t.Fatalf("expected embedded pointer to be allocated")
+	}
+	field.SetString("set")
+	if v.Shadowed != "set" {
+		t.Errorf("expected %q, got %q", "set", v.Shadowed)
+	}
+}
@@ -0,0 +1,46 @@
+// Package recorder wraps an http.ResponseWriter to record the status code and size of the response, for the
+// middlewares observing responses.
+package recorder
+
+import (
+	"net/http"
+)
+
+// test that ResponseWriter implements http.ResponseWriter.
+var _ http.ResponseWriter = &ResponseWriter{}
+
+// ResponseWriter records the status code and the number of bytes written to the wrapped http.ResponseWriter. Optional
+// interfaces (http.Flusher, http.Hijacker, ...) are reachable through Unwrap with http.ResponseController.
+type ResponseWriter struct {
+	http.ResponseWriter
+
+	// Status is the first status code written, zero until the response is started.
+	Status int
+
+	// Bytes is the number of body bytes written.
+	Bytes int64
+}
+
+func (w *ResponseWriter) WriteHeader(status int) {
+	if w.Status == 0 {
+		w.Status = status
+	}
+
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *ResponseWriter) Write(b []byte) (int, error) {
+	if w.Status == 0 {
+		w.Status = http.StatusOK
+	}
+
+	n, err := w.ResponseWriter.Write(b)
+	w.Bytes += int64(n)
+
+	return n, err
+}
+
+// Unwrap returns the wrapped http.ResponseWriter, as used by http.ResponseController.
+func (w *ResponseWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
@@ -0,0 +1,28 @@
+package recorder
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestResponseWriter(t *testing.T) {
+	t.Parallel()
+
+	rec := httptest.NewRecorder()
+	w := &ResponseWriter{ResponseWriter: rec}
+
+	_, _ = w.Write([]byte("hello"))
+	w.WriteHeader(http.StatusTeapot)
+	_, _ = w.Write([]byte(" world"))
+
+	if w.Status != http.StatusOK || w.Bytes != 11 {
+		t.Errorf("expected status %d and %d bytes, got %d and %d", http.StatusOK, 11, w.Status, w.Bytes)
+	}
+	if err := http.NewResponseController(w).Flush(); err != nil {
+		t.Errorf("expected Flush through Unwrap, got %v", err)
+	}
+	if !rec.Flushed {
+		t.Errorf("expected recorder to be flushed")
+	}
+}
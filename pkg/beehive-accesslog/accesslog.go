@@ -0,0 +1,281 @@
+package beehive_accesslog
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"math/rand/v2"
+	"net/netip"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"go.sdls.io/beehive/internal/clientip"
+	"go.sdls.io/beehive/internal/recorder"
+	"go.sdls.io/beehive/pkg/beehive"
+	beehiveRequestID "go.sdls.io/beehive/pkg/beehive-requestid"
+)
+
+// Format is the output format of the access log.
+type Format int
+
+const (
+	// FormatSlog emits every request as a slog.Record on Config.Logger.
+	FormatSlog Format = iota
+
+	// FormatJSON writes every request as a JSON object on its own line to Config.Output.
+	FormatJSON
+
+	// FormatCombined writes every request in the Apache Combined Log Format to Config.Output.
+	FormatCombined
+)
+
+// Entry is a single access log entry.
+type Entry struct {
+	Time      time.Time
+	Method    string
+	Route     string
+	Path      string
+	Proto     string
+	Status    int
+	Bytes     int64
+	Duration  time.Duration
+	RequestID string
+	RemoteIP  string
+	Referer   string
+	UserAgent string
+}
+
+// Config describes the access log middleware.
+type Config struct {
+	// Format is the output format, FormatSlog by default.
+	Format Format
+
+	// Logger is used with FormatSlog. If nil, slog.Default is used.
+	Logger *slog.Logger
+
+	// Output is used with FormatJSON and FormatCombined. Every entry is written with a single call to Write. If nil,
+	// os.Stdout is used.
+	Output io.Writer
+
+	// Level returns the slog.Level of the entry for the given status code, used with FormatSlog and FormatJSON. If
+	// nil, server errors are logged as slog.LevelError and everything else as slog.LevelInfo.
+	Level func(status int) slog.Level
+
+	// Skip lists the routes (as returned by beehive.Context.Route) that are never logged, such as health checks.
+	Skip []string
+
+	// Sample decides whether a handled request is logged. If nil, every request is logged. See SampleRate.
+	Sample func(ctx *beehive.Context, status int) bool
+
+	// RequestID returns the request ID of the request. If nil, the ID stored by the beehive_requestid middleware is
+	// used, falling back to the X-Request-Id request header.
+	RequestID func(ctx *beehive.Context) string
+
+	// TrustedProxies are used to find the client IP address, see beehive_rate.KeyRemoteIP.
+	TrustedProxies []netip.Prefix
+
+	// Now is the clock used to measure the duration. If nil, time.Now is used.
+	Now func() time.Time
+}
+
+// SampleRate returns a Config.Sample function logging the given fraction (between 0 and 1) of the requests. Server
+// errors are always logged.
+func SampleRate(rate float64) func(ctx *beehive.Context, status int) bool {
+	return func(_ *beehive.Context, status int) bool {
+		return status >= 500 || rand.Float64() < rate //nolint:gosec
+	}
+}
+
+// HandlerFunc returns the access log beehive.HandlerFunc. The entry is emitted after the response is sent, including
+// when a handler panics, with the status code and the number of bytes actually written. As a middleware, requests
+// that do not match any route (Router.WhenNotFound) are not logged.
+func (c *Config) HandlerFunc() beehive.HandlerFunc {
+	skip := make(map[string]bool, len(c.Skip))
+	for _, route := range c.Skip {
+		skip[route] = true
+	}
+
+	now := c.Now
+	if now == nil {
+		now = time.Now
+	}
+
+	requestID := c.RequestID
+	if requestID == nil {
+		requestID = func(ctx *beehive.Context) string {
+			if id, ok := beehiveRequestID.FromContext(ctx); ok {
+				return id
+			}
+			return ctx.Request.Header.Get(beehiveRequestID.DefaultHeader)
+		}
+	}
+
+	level := c.Level
+	if level == nil {
+		level = defaultLevel
+	}
+
+	emit := c.emitter(level)
+
+	return func(ctx *beehive.Context) beehive.Responder {
+		if skip[ctx.Route()] {
+			return nil
+		}
+
+		start := now()
+		w := &recorder.ResponseWriter{ResponseWriter: ctx.ResponseWriter}
+		ctx.ResponseWriter = w
+
+		var res beehive.Responder
+		ctx.After(func() {
+			status := w.Status
+			if status == 0 && res != nil {
+				status = res.StatusCode(ctx)
+			}
+			if status == 0 {
+				status = 200
+			}
+
+			if c.Sample != nil && !c.Sample(ctx, status) {
+				return
+			}
+
+			r := ctx.Request
+			entry := Entry{
+				Time:      start,
+				Method:    r.Method,
+				Route:     ctx.Route(),
+				Path:      r.URL.RequestURI(),
+				Proto:     r.Proto,
+				Status:    status,
+				Bytes:     w.Bytes,
+				Duration:  now().Sub(start),
+				RequestID: requestID(ctx),
+				Referer:   r.Referer(),
+				UserAgent: r.UserAgent(),
+			}
+			if addr, ok := clientip.FromRequest(ctx.Request, c.TrustedProxies); ok {
+				entry.RemoteIP = addr.String()
+			}
+
+			emit(ctx, entry)
+		})
+
+		res = ctx.Next()
+		return res
+	}
+}
+
+func defaultLevel(status int) slog.Level {
+	if status >= 500 {
+		return slog.LevelError
+	}
+	return slog.LevelInfo
+}
+
+func (c *Config) emitter(level func(status int) slog.Level) func(ctx context.Context, entry Entry) {
+	output := c.Output
+	if output == nil {
+		output = os.Stdout
+	}
+
+	switch c.Format {
+	case FormatJSON:
+		logger := slog.New(slog.NewJSONHandler(output, &slog.HandlerOptions{Level: slog.LevelDebug}))
+		return func(ctx context.Context, entry Entry) {
+			logger.LogAttrs(ctx, level(entry.Status), "request", entry.attrs()...)
+		}
+	case FormatCombined:
+		var mu sync.Mutex
+		return func(_ context.Context, entry Entry) {
+			line := entry.appendCombined(make([]byte, 0, 256))
+
+			mu.Lock()
+			_, _ = output.Write(line)
+			mu.Unlock()
+		}
+	default:
+		logger := c.Logger
+		return func(ctx context.Context, entry Entry) {
+			l := logger
+			if l == nil {
+				l = slog.Default()
+			}
+			l.LogAttrs(ctx, level(entry.Status), "request", entry.attrs()...)
+		}
+	}
+}
+
+func (e Entry) attrs() []slog.Attr {
+	attrs := []slog.Attr{
+		slog.String("method", e.Method),
+		slog.String("route", e.Route),
+		slog.String("path", e.Path),
+		slog.Int("status", e.Status),
+		slog.Int64("bytes", e.Bytes),
+		slog.Duration("duration", e.Duration),
+		slog.String("remote_ip", e.RemoteIP),
+	}
+	if e.RequestID != "" {
+		attrs = append(attrs, slog.String("request_id", e.RequestID))
+	}
+
+	return attrs
+}
+
+// appendCombined appends the entry in the Apache Combined Log Format:
+// %h %l %u %t "%r" %>s %b "%{Referer}i" "%{User-agent}i".
+func (e Entry) appendCombined(b []byte) []byte {
+	b = appendField(b, e.RemoteIP)
+	b = append(b, " - - ["...)
+	b = e.Time.AppendFormat(b, "02/Jan/2006:15:04:05 -0700")
+	b = append(b, "] \""...)
+	b = append(b, e.Method...)
+	b = append(b, ' ')
+	b = appendEscaped(b, e.Path)
+	b = append(b, ' ')
+	b = append(b, e.Proto...)
+	b = append(b, "\" "...)
+	b = strconv.AppendInt(b, int64(e.Status), 10)
+	b = append(b, ' ')
+	if e.Bytes == 0 {
+		b = append(b, '-')
+	} else {
+		b = strconv.AppendInt(b, e.Bytes, 10)
+	}
+	b = append(b, " \""...)
+	b = appendEscaped(b, e.Referer)
+	b = append(b, "\" \""...)
+	b = appendEscaped(b, e.UserAgent)
+	b = append(b, "\"\n"...)
+
+	return b
+}
+
+func appendField(b []byte, s string) []byte {
+	if s == "" {
+		return append(b, '-')
+	}
+	return append(b, s...)
+}
+
+// appendEscaped appends s, escaping quotes, backslashes and control characters such that the client cannot break the
+// line format.
+func appendEscaped(b []byte, s string) []byte {
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case c == '"' || c == '\\':
+			b = append(b, '\\', c)
+		case c < 0x20 || c == 0x7f:
+			b = append(b, `\x`...)
+			b = append(b, "0123456789abcdef"[c>>4], "0123456789abcdef"[c&0xf])
+		default:
+			b = append(b, c)
+		}
+	}
+
+	return b
+}
@@ -0,0 +1,179 @@
+package beehive_accesslog
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"go.sdls.io/beehive/pkg/beehive"
+	beehiveRequestID "go.sdls.io/beehive/pkg/beehive-requestid"
+)
+
+func newTestRouter(config *Config) *beehive.Router {
+	router := beehive.NewRouter()
+	router.With(config.HandlerFunc())
+
+	router.Handle("GET", "/users/*", func(_ *beehive.Context) beehive.Responder {
+		return &beehive.DefaultResponder{Message: "hello", Status: http.StatusCreated}
+	})
+	router.Handle("GET", "/healthz", func(_ *beehive.Context) beehive.Responder {
+		return &beehive.DefaultResponder{Message: "ok", Status: http.StatusOK}
+	})
+	router.Handle("GET", "/panic", func(_ *beehive.Context) beehive.Responder {
+		panic("boom")
+	})
+	router.Handle("GET", "/empty", func(_ *beehive.Context) beehive.Responder {
+		return nil
+	})
+
+	return router
+}
+
+func testClock() func() time.Time {
+	now := time.Date(2024, 3, 1, 12, 30, 0, 0, time.UTC)
+	return func() time.Time {
+		now = now.Add(5 * time.Millisecond)
+		return now
+	}
+}
+
+func TestConfig_JSON(t *testing.T) {
+	t.Parallel()
+
+	var buf bytes.Buffer
+	router := newTestRouter(&Config{
+		Format: FormatJSON,
+		Output: &buf,
+		Skip:   []string{"/healthz"},
+		Now:    testClock(),
+	})
+
+	for _, path := range []string{"/users/42?page=1", "/healthz", "/panic", "/empty"} {
+		r := httptest.NewRequest("GET", path, nil)
+		r.Header.Set("X-Request-Id", "req-1")
+		router.ServeHTTP(httptest.NewRecorder(), r)
+	}
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 3 {
+		t.Fatalf("expected %d entries, got %d: %s", 3, len(lines), buf.String())
+	}
+
+	want := []map[string]any{
+		{"level": "INFO", "msg": "request", "method": "GET", "route": "/users/*", "path": "/users/42?page=1", "status": 201.0, "bytes": 5.0, "duration": 5e6, "remote_ip": "192.0.2.1", "request_id": "req-1"},
+		{"level": "ERROR", "route": "/panic", "status": 500.0},
+		{"level": "INFO", "route": "/empty", "status": 200.0, "bytes": 0.0},
+	}
+
+	for idx, line := range lines {
+		var got map[string]any
+		if err := json.Unmarshal([]byte(line), &got); err != nil {
+			t.Fatalf("unexpected error %v", err)
+		}
+
+		for k, v := range want[idx] {
+			if got[k] != v {
+				t.Errorf("entry %d: expected %s %v, got %v", idx, k, v, got[k])
+			}
+		}
+	}
+}
+
+func TestConfig_Combined(t *testing.T) {
+	t.Parallel()
+
+	var buf bytes.Buffer
+	router := newTestRouter(&Config{
+		Format: FormatCombined,
+		Output: &buf,
+		Now:    testClock(),
+	})
+
+	r := httptest.NewRequest("GET", "/users/42", nil)
+	r.Header.Set("Referer", "https://example.com/")
+	r.Header.Set("User-Agent", "curl/8.0 \"quoted\"")
+	router.ServeHTTP(httptest.NewRecorder(), r)
+	router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/empty", nil))
+
+	want := `192.0.2.1 - - [01/Mar/2024:12:30:00 +0000] "GET /users/42 HTTP/1.1" 201 5 "https://example.com/" "curl/8.0 \"quoted\""` + "\n" +
+		`192.0.2.1 - - [01/Mar/2024:12:30:00 +0000] "GET /empty HTTP/1.1" 200 - "" ""` + "\n"
+
+	if buf.String() != want {
+		t.Errorf("expected\n%s\ngot\n%s", want, buf.String())
+	}
+}
+
+func TestConfig_Slog(t *testing.T) {
+	t.Parallel()
+
+	var buf bytes.Buffer
+	router := newTestRouter(&Config{
+		Logger: slog.New(slog.NewTextHandler(&buf, nil)),
+		Level: func(_ int) slog.Level {
+			return slog.LevelDebug
+		},
+		RequestID: func(_ *beehive.Context) string {
+			return "custom"
+		},
+	})
+
+	router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/users/42", nil))
+	if buf.Len() != 0 {
+		t.Errorf("expected debug entry to be filtered, got %s", buf.String())
+	}
+
+	router = newTestRouter(&Config{
+		Logger: slog.New(slog.NewTextHandler(&buf, nil)),
+		RequestID: func(_ *beehive.Context) string {
+			return "custom"
+		},
+	})
+
+	router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/users/42", nil))
+	if !strings.Contains(buf.String(), "route=/users/* ") || !strings.Contains(buf.String(), "request_id=custom") {
+		t.Errorf("unexpected entry %s", buf.String())
+	}
+}
+
+func TestConfig_requestID(t *testing.T) {
+	t.Parallel()
+
+	var buf bytes.Buffer
+	config := &Config{Format: FormatJSON, Output: &buf}
+	requestID := &beehiveRequestID.Config{Generate: func() string { return "generated" }}
+
+	router := beehive.NewRouter()
+	router.With(requestID.HandlerFunc(), config.HandlerFunc())
+	router.Handle("GET", "/", func(_ *beehive.Context) beehive.Responder {
+		return &beehive.DefaultResponder{Message: "ok", Status: http.StatusOK}
+	})
+
+	router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+	if !strings.Contains(buf.String(), `"request_id":"generated"`) {
+		t.Errorf("expected generated request ID to be logged, got %s", buf.String())
+	}
+}
+
+func TestConfig_Sample(t *testing.T) {
+	t.Parallel()
+
+	var buf bytes.Buffer
+	router := newTestRouter(&Config{
+		Format: FormatJSON,
+		Output: &buf,
+		Sample: SampleRate(0),
+	})
+
+	router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/users/42", nil))
+	router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/panic", nil))
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 1 || !strings.Contains(lines[0], `"status":500`) {
+		t.Errorf("expected only the server error to be logged, got %s", buf.String())
+	}
+}
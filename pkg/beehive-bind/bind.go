@@ -0,0 +1,146 @@
+package beehive_bind
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"go.sdls.io/beehive/pkg/beehive"
+	beehiveResponder "go.sdls.io/beehive/pkg/beehive-responder"
+)
+
+// Decoder decodes request bodies of a media type, see Binder.
+type Decoder interface {
+	// MediaType returns the Content-Type of the decoded bodies, parameters are ignored.
+	MediaType() string
+
+	// Decode reads the encoding of v from r.
+	Decode(r io.Reader, v any) error
+}
+
+// test that the decoders implement Decoder.
+var (
+	_ Decoder = JSONDecoder{}
+	_ Decoder = XMLDecoder{}
+)
+
+// JSONDecoder decodes application/json bodies with encoding/json.
+type JSONDecoder struct {
+	// DisallowUnknownFields fails the decoding of objects with fields unknown to the destination struct.
+	DisallowUnknownFields bool
+}
+
+func (JSONDecoder) MediaType() string { return "application/json" }
+
+func (d JSONDecoder) Decode(r io.Reader, v any) error {
+	dec := json.NewDecoder(r)
+	if d.DisallowUnknownFields {
+		dec.DisallowUnknownFields()
+	}
+	return dec.Decode(v)
+}
+
+// XMLDecoder decodes application/xml bodies with encoding/xml.
+type XMLDecoder struct{}
+
+func (XMLDecoder) MediaType() string { return "application/xml" }
+
+func (XMLDecoder) Decode(r io.Reader, v any) error {
+	return xml.NewDecoder(r).Decode(v)
+}
+
+// DefaultMaxBytes is the default maximum size of a request body.
+const DefaultMaxBytes = 1 << 20
+
+// Binder decodes request bodies with the Decoder matching their Content-Type.
+type Binder struct {
+	// Decoders are the supported media types.
+	Decoders []Decoder
+
+	// MaxBytes is the maximum size of a body. If zero, DefaultMaxBytes is used, negative values disable the limit.
+	MaxBytes int64
+}
+
+// DefaultBinder decodes JSON and XML bodies, it is used by Bind.
+var DefaultBinder = &Binder{
+	Decoders: []Decoder{JSONDecoder{}, XMLDecoder{}},
+}
+
+// Bind decodes the request body into v with DefaultBinder.
+func Bind(ctx *beehive.Context, v any) error {
+	return DefaultBinder.Bind(ctx, v)
+}
+
+// Bind decodes the request body into v with the Decoder matching its Content-Type. Failures are returned as a
+// *beehive_responder.ProblemError, such that an ErrorMapper answers them with the right status: 415 Unsupported
+// Media Type when no Decoder matches, 413 Content Too Large beyond MaxBytes and 400 Bad Request for bodies that fail
+// to decode.
+func (b *Binder) Bind(ctx *beehive.Context, v any) error {
+	dec := b.Select(ctx.Request.Header.Get("Content-Type"))
+	if dec == nil {
+		return problem(http.StatusUnsupportedMediaType, "unsupported content type "+ctx.Request.Header.Get("Content-Type"))
+	}
+
+	if ctx.Request.Body == nil || ctx.Request.Body == http.NoBody {
+		return problem(http.StatusBadRequest, "empty body")
+	}
+
+	maxBytes := b.MaxBytes
+	if maxBytes == 0 {
+		maxBytes = DefaultMaxBytes
+	}
+
+	body := ctx.Request.Body
+	if maxBytes > 0 {
+		body = http.MaxBytesReader(ctx.ResponseWriter, body, maxBytes)
+	}
+
+	if err := dec.Decode(body, v); err != nil {
+		var maxBytesErr *http.MaxBytesError
+		if errors.As(err, &maxBytesErr) {
+			return problem(http.StatusRequestEntityTooLarge, "body exceeds "+formatBytes(maxBytesErr.Limit))
+		}
+		if errors.Is(err, io.EOF) {
+			return problem(http.StatusBadRequest, "empty body")
+		}
+		return problem(http.StatusBadRequest, err.Error())
+	}
+
+	return nil
+}
+
+// Select returns the Decoder of the Content-Type, or nil.
+func (b *Binder) Select(contentType string) Decoder {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return nil
+	}
+
+	for _, dec := range b.Decoders {
+		decMediaType, _, _ := strings.Cut(dec.MediaType(), ";")
+		if strings.EqualFold(strings.TrimSpace(decMediaType), mediaType) {
+			return dec
+		}
+	}
+
+	return nil
+}
+
+func problem(status int, detail string) error {
+	return &beehiveResponder.ProblemError{Problem: &beehiveResponder.Problem{Status: status, Detail: detail}}
+}
+
+func formatBytes(n int64) string {
+	units := []string{"B", "KiB", "MiB", "GiB"}
+	idx := 0
+	for n >= 1024 && n%1024 == 0 && idx < len(units)-1 {
+		n /= 1024
+		idx++
+	}
+	return strconv.FormatInt(n, 10) + " " + units[idx]
+}
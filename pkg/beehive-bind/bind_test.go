@@ -0,0 +1,109 @@
+package beehive_bind
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"go.sdls.io/beehive/pkg/beehive"
+	beehiveResponder "go.sdls.io/beehive/pkg/beehive-responder"
+)
+
+type testUser struct {
+	Name string `json:"name" xml:"name"`
+	Age  int    `json:"age" xml:"age"`
+}
+
+func newTestRouter(binder *Binder) *beehive.Router {
+	mapper := &beehiveResponder.ErrorMapper{}
+
+	router := beehive.NewRouter()
+	router.Handle("POST", "/users", mapper.Handle(func(ctx *beehive.Context) (beehive.Responder, error) {
+		var user testUser
+		if err := binder.Bind(ctx, &user); err != nil {
+			return nil, err
+		}
+		return &beehiveResponder.JSON{Object: user, Code: http.StatusCreated}, nil
+	}))
+
+	return router
+}
+
+func TestBinder_Bind(t *testing.T) {
+	t.Parallel()
+
+	router := newTestRouter(&Binder{
+		Decoders: []Decoder{JSONDecoder{DisallowUnknownFields: true}, XMLDecoder{}},
+		MaxBytes: 64,
+	})
+
+	tests := []struct {
+		name        string
+		contentType string
+		body        string
+		code        int
+		detail      string
+	}{
+		{"json", "application/json", `{"name":"Jane","age":30}`, http.StatusCreated, ""},
+		{"json charset", "Application/JSON; charset=utf-8", `{"name":"Jane","age":30}`, http.StatusCreated, ""},
+		{"xml", "application/xml", `<user><name>Jane</name><age>30</age></user>`, http.StatusCreated, ""},
+		{"unknown field", "application/json", `{"name":"Jane","admin":true}`, http.StatusBadRequest, `json: unknown field "admin"`},
+		{"syntax", "application/json", `{"name":`, http.StatusBadRequest, "unexpected EOF"},
+		{"empty", "application/json", ``, http.StatusBadRequest, "empty body"},
+		{"too large", "application/json", `{"name":"` + strings.Repeat("a", 100) + `"}`, http.StatusRequestEntityTooLarge, "body exceeds 64 B"},
+		{"unsupported", "text/plain", `Jane`, http.StatusUnsupportedMediaType, "unsupported content type text/plain"},
+		{"no content type", "", `{}`, http.StatusUnsupportedMediaType, "unsupported content type "},
+	}
+
+	for _, test := range tests {
+		r := httptest.NewRequest("POST", "/users", strings.NewReader(test.body))
+		if test.contentType != "" {
+			r.Header.Set("Content-Type", test.contentType)
+		}
+
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, r)
+
+		if w.Code != test.code {
+			t.Errorf("%s: expected %d, got %d: %s", test.name, test.code, w.Code, w.Body.String())
+			continue
+		}
+
+		if test.code == http.StatusCreated {
+			if body := w.Body.String(); body != `{"name":"Jane","age":30}` {
+				t.Errorf("%s: expected %q, got %q", test.name, `{"name":"Jane","age":30}`, body)
+			}
+			continue
+		}
+
+		var problem map[string]any
+		if err := json.Unmarshal(w.Body.Bytes(), &problem); err != nil {
+			t.Fatalf("%s: unexpected error %v", test.name, err)
+		}
+		if problem["detail"] != test.detail {
+			t.Errorf("%s: expected %q, got %q", test.name, test.detail, problem["detail"])
+		}
+	}
+}
+
+func TestBinder_Select(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		contentType string
+		want        Decoder
+	}{
+		{"application/json", JSONDecoder{}},
+		{"application/xml; charset=utf-8", XMLDecoder{}},
+		{"text/xml", nil},
+		{"invalid;;", nil},
+	}
+
+	for _, test := range tests {
+		if got := DefaultBinder.Select(test.contentType); got != test.want {
+			t.Errorf("%q: expected %v, got %v", test.contentType, test.want, got)
+		}
+	}
+}
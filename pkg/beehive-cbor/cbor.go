@@ -0,0 +1,88 @@
+package beehive_cbor
+
+import (
+	"errors"
+	"io"
+	"reflect"
+	"time"
+
+	beehiveBind "go.sdls.io/beehive/pkg/beehive-bind"
+	beehiveResponder "go.sdls.io/beehive/pkg/beehive-responder"
+)
+
+// MediaType is the media type of CBOR.
+const MediaType = "application/cbor"
+
+// Codec encodes responses and decodes request bodies as application/cbor, it plugs into a
+// beehive_responder.Negotiator and a beehive_bind.Binder.
+type Codec struct{}
+
+// test that Codec implements the responder Encoder and the bind Decoder.
+var (
+	_ beehiveResponder.Encoder = Codec{}
+	_ beehiveBind.Decoder      = Codec{}
+)
+
+func (Codec) MediaType() string { return MediaType }
+
+func (Codec) Encode(w io.Writer, v any) error {
+	return NewEncoder(w).Encode(v)
+}
+
+func (Codec) Decode(r io.Reader, v any) error {
+	return NewDecoder(r).Decode(v)
+}
+
+// Marshaler is implemented by types encoding themselves, MarshalCBOR returns a single CBOR data item.
+type Marshaler interface {
+	MarshalCBOR() ([]byte, error)
+}
+
+// Unmarshaler is implemented by types decoding themselves, UnmarshalCBOR receives a single CBOR data item.
+type Unmarshaler interface {
+	UnmarshalCBOR(data []byte) error
+}
+
+// Tag is a tagged data item whose tag number has no built-in meaning, decoded into interface values.
+type Tag struct {
+	Number  uint64
+	Content any
+}
+
+// the major types of RFC 8949 section 3.1.
+const (
+	majorUint = iota
+	majorNegInt
+	majorBytes
+	majorText
+	majorArray
+	majorMap
+	majorTag
+	majorSimple
+)
+
+// tag numbers of date and time, RFC 8949 section 3.4.
+const (
+	tagDateTime = 0
+	tagEpoch    = 1
+)
+
+// maxDepth is the maximum nesting of encoded and decoded values.
+const maxDepth = 10000
+
+var (
+	// ErrTooDeep is returned for values nested deeper than 10000 levels, such as cyclic structures.
+	ErrTooDeep = errors.New("beehive-cbor: maximum nesting depth exceeded")
+
+	// ErrTrailingData is returned by Unmarshal when the data holds more than one data item.
+	ErrTrailingData = errors.New("beehive-cbor: trailing data")
+)
+
+var (
+	marshalerType       = reflect.TypeFor[Marshaler]()
+	unmarshalerType     = reflect.TypeFor[Unmarshaler]()
+	textMarshalerType   = reflect.TypeFor[interface{ MarshalText() ([]byte, error) }]()
+	textUnmarshalerType = reflect.TypeFor[interface{ UnmarshalText([]byte) error }]()
+	timeType            = reflect.TypeFor[time.Time]()
+	tagType             = reflect.TypeFor[Tag]()
+)
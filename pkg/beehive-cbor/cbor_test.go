@@ -0,0 +1,411 @@
+package beehive_cbor
+
+import (
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"go.sdls.io/beehive/pkg/beehive"
+	beehiveBind "go.sdls.io/beehive/pkg/beehive-bind"
+	beehiveResponder "go.sdls.io/beehive/pkg/beehive-responder"
+)
+
+// examples of RFC 8949 appendix A, in preferred serialization.
+var appendixA = []struct {
+	value any
+	hex   string
+}{
+	{0, "00"},
+	{1, "01"},
+	{10, "0a"},
+	{23, "17"},
+	{24, "1818"},
+	{25, "1819"},
+	{100, "1864"},
+	{1000, "1903e8"},
+	{1000000, "1a000f4240"},
+	{uint64(1000000000000), "1b000000e8d4a51000"},
+	{uint64(18446744073709551615), "1bffffffffffffffff"},
+	{-1, "20"},
+	{-10, "29"},
+	{-100, "3863"},
+	{-1000, "3903e7"},
+	{0.0, "f90000"},
+	{math.Copysign(0, -1), "f98000"},
+	{1.0, "f93c00"},
+	{1.1, "fb3ff199999999999a"},
+	{1.5, "f93e00"},
+	{65504.0, "f97bff"},
+	{100000.0, "fa47c35000"},
+	{3.4028234663852886e+38, "fa7f7fffff"},
+	{1.0e+300, "fb7e37e43c8800759c"},
+	{5.960464477539063e-8, "f90001"},
+	{0.00006103515625, "f90400"},
+	{-4.0, "f9c400"},
+	{-4.1, "fbc010666666666666"},
+	{math.Inf(1), "f97c00"},
+	{math.NaN(), "f97e00"},
+	{math.Inf(-1), "f9fc00"},
+	{false, "f4"},
+	{true, "f5"},
+	{nil, "f6"},
+	{Tag{Number: 23, Content: []byte{1, 2, 3, 4}}, "d74401020304"},
+	{Tag{Number: 32, Content: "http://www.example.com"}, "d82076687474703a2f2f7777772e6578616d706c652e636f6d"},
+	{[]byte{}, "40"},
+	{[]byte{1, 2, 3, 4}, "4401020304"},
+	{"", "60"},
+	{"a", "6161"},
+	{"IETF", "6449455446"},
+	{"\"\\", "62225c"},
+	{"ü", "62c3bc"},
+	{"水", "63e6b0b4"},
+	{"\U00010151", "64f0908591"},
+	{[]int{}, "80"},
+	{[]int{1, 2, 3}, "83010203"},
+	{[]any{1, []int{2, 3}, []int{4, 5}}, "8301820203820405"},
+	{map[int]int{}, "a0"},
+	{map[int]int{1: 2, 3: 4}, "a201020304"},
+	{map[string]any{"a": 1, "b": []int{2, 3}}, "a26161016162820203"},
+	{[]any{"a", map[string]string{"b": "c"}}, "826161a161626163"},
+	{map[string]string{"a": "A", "b": "B", "c": "C", "d": "D", "e": "E"}, "a56161614161626142616361436164614461656145"},
+}
+
+func TestMarshal(t *testing.T) {
+	t.Parallel()
+
+	for _, test := range appendixA {
+		data, err := Marshal(test.value)
+		if err != nil {
+			t.Errorf("%v: unexpected error %v", test.value, err)
+			continue
+		}
+		if got := hex.EncodeToString(data); got != test.hex {
+			t.Errorf("%v: expected %s, got %s", test.value, test.hex, got)
+		}
+	}
+
+	data, err := Marshal(time.Date(2013, 3, 21, 20, 4, 0, 0, time.UTC))
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if got, want := hex.EncodeToString(data), "c074323031332d30332d32315432303a30343a30305a"; got != want {
+		t.Errorf("expected %s, got %s", want, got)
+	}
+}
+
+func TestUnmarshal_appendixA(t *testing.T) {
+	t.Parallel()
+
+	for _, test := range appendixA {
+		data, _ := hex.DecodeString(test.hex)
+
+		// the expected value is decoded into a value of the same type
+		want := reflect.ValueOf(test.value)
+		if !want.IsValid() {
+			want = reflect.ValueOf(new(any)).Elem()
+		}
+		got := reflect.New(want.Type())
+		if err := Unmarshal(data, got.Interface()); err != nil {
+			t.Errorf("%s: unexpected error %v", test.hex, err)
+			continue
+		}
+
+		if f, ok := test.value.(float64); ok && math.IsNaN(f) {
+			if !math.IsNaN(got.Elem().Float()) {
+				t.Errorf("%s: expected NaN, got %v", test.hex, got.Elem())
+			}
+			continue
+		}
+
+		switch test.value.(type) {
+		case []any, map[string]any:
+			// nested values are decoded with their generic types
+			continue
+		}
+
+		if !reflect.DeepEqual(got.Elem().Interface(), want.Interface()) {
+			t.Errorf("%s: expected %#v, got %#v", test.hex, want.Interface(), got.Elem().Interface())
+		}
+	}
+}
+
+func TestUnmarshal_any(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		hex  string
+		want any
+	}{
+		{"f6", nil},
+		{"f7", nil},
+		{"1bffffffffffffffff", uint64(math.MaxUint64)},
+		{"3bffffffffffffffff", nil},
+		{"3863", int64(-100)},
+		{"f93e00", 1.5},
+		{"f90001", 5.960464477539063e-8},
+		{"fa47c35000", 100000.0},
+		{"5f42010243030405ff", []byte{1, 2, 3, 4, 5}},
+		{"7f657374726561646d696e67ff", "streaming"},
+		{"9f018202039f0405ffff", []any{int64(1), []any{int64(2), int64(3)}, []any{int64(4), int64(5)}}},
+		{"bf61610161629f0203ffff", map[string]any{"a": int64(1), "b": []any{int64(2), int64(3)}}},
+		{"a201020304", map[any]any{int64(1): int64(2), int64(3): int64(4)}},
+		{"c074323031332d30332d32315432303a30343a30305a", time.Date(2013, 3, 21, 20, 4, 0, 0, time.UTC)},
+		{"c11a514b67b0", time.Date(2013, 3, 21, 20, 4, 0, 0, time.UTC)},
+		{"c1fb41d452d9ec200000", time.Date(2013, 3, 21, 20, 4, 0, 500000000, time.UTC)},
+		{"d74401020304", Tag{Number: 23, Content: []byte{1, 2, 3, 4}}},
+		{"c249010000000000000000", Tag{Number: 2, Content: []byte{1, 0, 0, 0, 0, 0, 0, 0, 0}}},
+	}
+
+	for _, test := range tests {
+		data, _ := hex.DecodeString(test.hex)
+
+		var got any
+		err := Unmarshal(data, &got)
+		if test.hex == "3bffffffffffffffff" {
+			if err == nil || err.Error() != "beehive-cbor: -18446744073709551616 overflows int64" {
+				t.Errorf("%s: expected an overflow, got %v", test.hex, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: unexpected error %v", test.hex, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%s: expected %#v, got %#v", test.hex, test.want, got)
+		}
+	}
+}
+
+func TestUnmarshal_errors(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name   string
+		hex    string
+		target any
+		err    string
+	}{
+		{"overflow", "190100", new(int8), "beehive-cbor: 256 overflows int8"},
+		{"negative unsigned", "20", new(uint), "beehive-cbor: cannot decode negative integer into uint"},
+		{"type mismatch", "6161", new(int), "beehive-cbor: cannot decode text string into int"},
+		{"truncated", "826161", new([]string), "unexpected EOF"},
+		{"forged length", "7bffffffffffffffff", new(string), "unexpected EOF"},
+		{"reserved", "1c", new(any), "beehive-cbor: invalid initial byte 0x1c"},
+		{"indefinite integer", "1f", new(any), "beehive-cbor: invalid initial byte 0x1f"},
+		{"unexpected break", "ff", new(any), "beehive-cbor: unexpected break"},
+		{"break in definite array", "82ff", new(any), "beehive-cbor: unexpected break"},
+		{"invalid chunk", "5f6161ff", new([]byte), "beehive-cbor: invalid chunk in indefinite length byte string"},
+		{"invalid UTF-8", "62c328", new(string), "beehive-cbor: invalid UTF-8 in text string"},
+		{"invalid date", "c06161", new(time.Time), `beehive-cbor: invalid date/time string: parsing time "a" as "2006-01-02T15:04:05.999999999Z07:00": cannot parse "a" as "2006"`},
+		{"unknown tag into time", "d82000", new(time.Time), "beehive-cbor: cannot decode tag 32 into time.Time"},
+		{"trailing", "0000", new(int), ErrTrailingData.Error()},
+		{"unhashable key", "a18000", new(any), "beehive-cbor: unsupported map key type []interface {}"},
+		{"unhashable tag key", "a1d864410101", new(any), "beehive-cbor: unsupported map key type beehive_cbor.Tag"},
+		{"too deep", strings.Repeat("81", maxDepth+2) + "f6", new(any), ErrTooDeep.Error()},
+	}
+
+	for _, test := range tests {
+		data, _ := hex.DecodeString(test.hex)
+		err := Unmarshal(data, test.target)
+		if err == nil || err.Error() != test.err {
+			t.Errorf("%s: expected error %q, got %v", test.name, test.err, err)
+		}
+	}
+
+	if err := Unmarshal([]byte{0}, 0); err == nil {
+		t.Errorf("expected an error for a non pointer")
+	}
+}
+
+type testAddress struct {
+	City string `cbor:"city"`
+}
+
+type testPerson struct {
+	testAddress
+	Name     string            `cbor:"name"`
+	Age      int               `json:"age"`
+	Email    string            `cbor:"email,omitempty"`
+	Tags     []string          `cbor:"tags"`
+	Labels   map[string]string `cbor:"labels,omitempty"`
+	Born     time.Time         `cbor:"born"`
+	Manager  *testPerson       `cbor:"manager,omitempty"`
+	Ignored  string            `cbor:"-"`
+	internal int
+}
+
+func TestRoundTrip(t *testing.T) {
+	t.Parallel()
+
+	in := testPerson{
+		testAddress: testAddress{City: "Paris"},
+		Name:        "Jane",
+		Age:         30,
+		Tags:        []string{"a", "b"},
+		Born:        time.Date(1990, 1, 2, 3, 4, 5, 6, time.UTC),
+		Manager:     &testPerson{Name: "John", Born: time.Unix(0, 0).UTC()},
+		Ignored:     "ignored",
+	}
+
+	data, err := Marshal(in)
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+
+	var out testPerson
+	if err := Unmarshal(data, &out); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+
+	in.Ignored = ""
+	if !reflect.DeepEqual(in, out) {
+		t.Errorf("expected %+v, got %+v", in, out)
+	}
+
+	var generic map[string]any
+	if err := Unmarshal(data, &generic); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if _, ok := generic["email"]; ok {
+		t.Errorf("expected email to be omitted")
+	}
+	if generic["city"] != "Paris" || generic["age"] != int64(30) || !generic["born"].(time.Time).Equal(in.Born) {
+		t.Errorf("unexpected generic value %v", generic)
+	}
+}
+
+func TestMarshal_deterministic(t *testing.T) {
+	t.Parallel()
+
+	value := struct {
+		Long  int `cbor:"aa"`
+		Short int `cbor:"b"`
+	}{1, 2}
+
+	data, err := Marshal(value)
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+
+	// "b" is encoded as 0x61 0x62, shorter than "aa", 0x62 0x61 0x61
+	if got, want := hex.EncodeToString(data), "a261620262616101"; got != want {
+		t.Errorf("expected %s, got %s", want, got)
+	}
+}
+
+type testPoint struct {
+	X, Y int
+}
+
+func (p *testPoint) MarshalCBOR() ([]byte, error) {
+	return Marshal([]int{p.X, p.Y})
+}
+
+func (p *testPoint) UnmarshalCBOR(data []byte) error {
+	var xy []int
+	if err := Unmarshal(data, &xy); err != nil {
+		return err
+	}
+	if len(xy) != 2 {
+		return errors.New("expected two coordinates")
+	}
+	p.X, p.Y = xy[0], xy[1]
+	return nil
+}
+
+func TestMarshaler(t *testing.T) {
+	t.Parallel()
+
+	in := struct {
+		Points []testPoint `cbor:"points"`
+	}{Points: []testPoint{{1, 2}, {3, 4}}}
+
+	data, err := Marshal(&in)
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if got, want := hex.EncodeToString(data), "a166706f696e747382820102820304"; got != want {
+		t.Errorf("expected %s, got %s", want, got)
+	}
+
+	// the indefinite length array is handed to the Unmarshaler as is
+	data, _ = hex.DecodeString("a166706f696e7473829f0102ff820304")
+
+	var out struct {
+		Points []testPoint `cbor:"points"`
+	}
+	if err := Unmarshal(data, &out); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if !reflect.DeepEqual(in, out) {
+		t.Errorf("expected %+v, got %+v", in, out)
+	}
+}
+
+func TestCodec(t *testing.T) {
+	t.Parallel()
+
+	negotiator := &beehiveResponder.Negotiator{
+		Encoders: slices.Concat(beehiveResponder.DefaultNegotiator.Encoders, []beehiveResponder.Encoder{Codec{}}),
+	}
+	binder := &beehiveBind.Binder{
+		Decoders: slices.Concat(beehiveBind.DefaultBinder.Decoders, []beehiveBind.Decoder{Codec{}}),
+	}
+	mapper := &beehiveResponder.ErrorMapper{}
+
+	router := beehive.NewRouter()
+	router.Handle("POST", "/people", mapper.Handle(func(ctx *beehive.Context) (beehive.Responder, error) {
+		var person testPerson
+		if err := binder.Bind(ctx, &person); err != nil {
+			return nil, err
+		}
+		person.Age++
+		return negotiator.Respond(person, http.StatusCreated), nil
+	}))
+
+	body, err := Marshal(map[string]any{"name": "Jane", "age": 30})
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+
+	r := httptest.NewRequest("POST", "/people", bytes.NewReader(body))
+	r.Header.Set("Content-Type", MediaType)
+	r.Header.Set("Accept", MediaType)
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, r)
+
+	if w.Code != http.StatusCreated {
+		t.Fatalf("expected %d, got %d: %s", http.StatusCreated, w.Code, w.Body.String())
+	}
+	if got := w.Header().Get("Content-Type"); got != MediaType {
+		t.Errorf("expected %s, got %s", MediaType, got)
+	}
+
+	var person testPerson
+	if err := Unmarshal(w.Body.Bytes(), &person); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if person.Name != "Jane" || person.Age != 31 {
+		t.Errorf("unexpected response %+v", person)
+	}
+
+	r = httptest.NewRequest("POST", "/people", strings.NewReader("\xa1\x64name"))
+	r.Header.Set("Content-Type", MediaType)
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, r)
+
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("expected %d, got %d", http.StatusBadRequest, w.Code)
+	}
+}
@@ -0,0 +1,796 @@
+package beehive_cbor
+
+import (
+	"bufio"
+	"bytes"
+	"encoding"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"reflect"
+	"time"
+	"unicode/utf8"
+
+	"go.sdls.io/beehive/internal/fields"
+)
+
+// Unmarshal decodes the CBOR data item of data into v, a non-nil pointer. Values are decoded like encoding/json does:
+// map keys are matched to struct fields exactly, then case-insensitively, unknown keys are skipped, and null or
+// undefined set pointers, slices, maps and interfaces to nil. Both definite and indefinite lengths are accepted and
+// text strings must be valid UTF-8. Tags 0 and 1 are decoded into time.Time, other tags are ignored unless decoded
+// into an interface value. Interface values receive nil, bool, int64 (uint64 beyond math.MaxInt64), float64, string,
+// []byte, []any, map[string]any (map[any]any for non string keys), time.Time or Tag.
+func Unmarshal(data []byte, v any) error {
+	r := bytes.NewReader(data)
+	if err := NewDecoder(r).Decode(v); err != nil {
+		return err
+	}
+
+	if r.Len() > 0 {
+		return ErrTrailingData
+	}
+	return nil
+}
+
+// Decoder reads CBOR data items from a stream.
+type Decoder struct {
+	r byteReader
+}
+
+type byteReader interface {
+	io.Reader
+	io.ByteScanner
+}
+
+// NewDecoder returns a Decoder reading from r, buffered unless r implements io.ByteScanner. A buffered Decoder may
+// read past the decoded data items.
+func NewDecoder(r io.Reader) *Decoder {
+	if br, ok := r.(byteReader); ok {
+		return &Decoder{r: br}
+	}
+	return &Decoder{r: bufio.NewReader(r)}
+}
+
+// Decode reads the next data item into v, a non-nil pointer, see Unmarshal.
+func (dec *Decoder) Decode(v any) error {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Pointer || rv.IsNil() {
+		return errors.New("beehive-cbor: decode requires a non-nil pointer")
+	}
+
+	d := decoder{r: dec.r}
+	err := d.decode(rv.Elem(), 0)
+	if errors.Is(err, io.EOF) && d.read {
+		err = io.ErrUnexpectedEOF
+	}
+	return err
+}
+
+type decoder struct {
+	r    byteReader
+	read bool
+	tmp  [8]byte
+
+	// raw holds the bytes read while recording, for Unmarshaler implementations
+	raw       []byte
+	recording bool
+}
+
+func (d *decoder) readByte() (byte, error) {
+	b, err := d.r.ReadByte()
+	if err != nil {
+		return 0, err
+	}
+
+	d.read = true
+	if d.recording {
+		d.raw = append(d.raw, b)
+	}
+	return b, nil
+}
+
+func (d *decoder) peek() (byte, error) {
+	b, err := d.r.ReadByte()
+	if err != nil {
+		return 0, err
+	}
+	return b, d.r.UnreadByte()
+}
+
+func (d *decoder) readFull(b []byte) error {
+	if _, err := io.ReadFull(d.r, b); err != nil {
+		if errors.Is(err, io.EOF) {
+			return io.ErrUnexpectedEOF
+		}
+		return err
+	}
+
+	if d.recording {
+		d.raw = append(d.raw, b...)
+	}
+	return nil
+}
+
+// readN reads n bytes, growing the buffer as the data arrives such that a forged length cannot allocate more than
+// what is actually sent.
+func (d *decoder) readN(n uint64) ([]byte, error) {
+	const chunk = 64 << 10
+
+	buf := make([]byte, 0, min(n, chunk))
+	for uint64(len(buf)) < n {
+		size := min(n-uint64(len(buf)), chunk)
+		start := len(buf)
+		buf = append(buf, make([]byte, size)...)
+		if err := d.readFull(buf[start:]); err != nil {
+			return nil, err
+		}
+	}
+	return buf, nil
+}
+
+// header is the initial byte of a data item with its argument.
+type header struct {
+	major      byte
+	info       byte   // the additional information, the low 5 bits of the initial byte
+	value      uint64 // the argument: integer, length, tag number, simple value or float bits
+	indefinite bool
+}
+
+var majorNames = []string{
+	"unsigned integer", "negative integer", "byte string", "text string", "array", "map", "tag", "simple value",
+}
+
+func (h *header) String() string {
+	if h.major == majorSimple {
+		switch h.info {
+		case 20, 21:
+			return "bool"
+		case 22, 23:
+			return "null"
+		case 25, 26, 27:
+			return "float"
+		}
+	}
+	return majorNames[h.major]
+}
+
+// errBreak is returned by readHeader for the break stop code, which ends indefinite length items.
+var errBreak = errors.New("beehive-cbor: unexpected break")
+
+func (d *decoder) readHeader() (header, error) {
+	b, err := d.readByte()
+	if err != nil {
+		return header{}, err
+	}
+
+	h := header{major: b >> 5, info: b & 0x1f}
+	switch {
+	case h.info < 24:
+		h.value = uint64(h.info)
+	case h.info <= 27:
+		size := 1 << (h.info - 24)
+		if err := d.readFull(d.tmp[:size]); err != nil {
+			return header{}, err
+		}
+		switch size {
+		case 1:
+			h.value = uint64(d.tmp[0])
+		case 2:
+			h.value = uint64(binary.BigEndian.Uint16(d.tmp[:2]))
+		case 4:
+			h.value = uint64(binary.BigEndian.Uint32(d.tmp[:4]))
+		default:
+			h.value = binary.BigEndian.Uint64(d.tmp[:8])
+		}
+	case h.info == 31 && h.major == majorSimple:
+		return header{}, errBreak
+	case h.info == 31 && h.major >= majorBytes && h.major <= majorMap:
+		h.indefinite = true
+	default:
+		return header{}, fmt.Errorf("beehive-cbor: invalid initial byte 0x%02x", b)
+	}
+
+	if h.major == majorSimple && h.info == 24 && h.value < 32 {
+		return header{}, fmt.Errorf("beehive-cbor: invalid simple value %d", h.value)
+	}
+
+	return h, nil
+}
+
+// float returns the value of a float or integer header.
+func (h *header) float() (float64, bool) {
+	switch {
+	case h.major == majorUint:
+		return float64(h.value), true
+	case h.major == majorNegInt:
+		return -1 - float64(h.value), true
+	case h.major != majorSimple:
+		return 0, false
+	}
+
+	switch h.info {
+	case 25:
+		return halfToFloat(uint16(h.value)), true
+	case 26:
+		return float64(math.Float32frombits(uint32(h.value))), true
+	case 27:
+		return math.Float64frombits(h.value), true
+	default:
+		return 0, false
+	}
+}
+
+func halfToFloat(half uint16) float64 {
+	exp := int(half>>10) & 0x1f
+	mant := float64(half & 0x3ff)
+
+	var f float64
+	switch exp {
+	case 0:
+		f = math.Ldexp(mant, -24)
+	case 0x1f:
+		if mant == 0 {
+			f = math.Inf(1)
+		} else {
+			f = math.NaN()
+		}
+	default:
+		f = math.Ldexp(mant+1024, exp-25)
+	}
+
+	if half&0x8000 != 0 {
+		f = -f
+	}
+	return f
+}
+
+// readString reads the content of a byte or text string, concatenating the chunks of indefinite length strings.
+func (d *decoder) readString(h header) ([]byte, error) {
+	if !h.indefinite {
+		data, err := d.readN(h.value)
+		if err != nil {
+			return nil, err
+		}
+		if h.major == majorText && !utf8.Valid(data) {
+			return nil, errors.New("beehive-cbor: invalid UTF-8 in text string")
+		}
+		return data, nil
+	}
+
+	var data []byte
+	for {
+		chunk, err := d.readHeader()
+		if errors.Is(err, errBreak) {
+			return data, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		if chunk.major != h.major || chunk.indefinite {
+			return nil, fmt.Errorf("beehive-cbor: invalid chunk in indefinite length %s", majorNames[h.major])
+		}
+
+		part, err := d.readString(chunk)
+		if err != nil {
+			return nil, err
+		}
+		data = append(data, part...)
+	}
+}
+
+// items calls f for each element of an array or map with the given header, until the break stop code for indefinite
+// lengths.
+func (d *decoder) items(h header, f func() error) error {
+	if !h.indefinite {
+		for range h.value {
+			if err := f(); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	for {
+		b, err := d.peek()
+		if err != nil {
+			return err
+		}
+		if b == 0xff {
+			_, _ = d.readByte()
+			return nil
+		}
+		if err := f(); err != nil {
+			return err
+		}
+	}
+}
+
+func (d *decoder) decode(v reflect.Value, depth int) error {
+	if depth > maxDepth {
+		return ErrTooDeep
+	}
+
+	b, err := d.peek()
+	if err != nil {
+		return err
+	}
+
+	// null and undefined
+	if b == 0xf6 || b == 0xf7 {
+		_, _ = d.readByte()
+		switch v.Kind() {
+		case reflect.Pointer, reflect.Interface, reflect.Map, reflect.Slice:
+			v.SetZero()
+		}
+		return nil
+	}
+
+	if v.Kind() == reflect.Pointer {
+		if v.IsNil() {
+			v.Set(reflect.New(v.Type().Elem()))
+		}
+		return d.decode(v.Elem(), depth+1)
+	}
+
+	if v.CanAddr() {
+		pv := v.Addr()
+		if pv.Type().Implements(unmarshalerType) {
+			raw, err := d.rawItem(depth)
+			if err != nil {
+				return err
+			}
+			return pv.Interface().(Unmarshaler).UnmarshalCBOR(raw)
+		}
+
+		// text is accepted by time.Time as well, while tagged times are decoded below
+		if b>>5 == majorText && pv.Type().Implements(textUnmarshalerType) {
+			h, err := d.readHeader()
+			if err != nil {
+				return err
+			}
+			text, err := d.readString(h)
+			if err != nil {
+				return err
+			}
+			return pv.Interface().(encoding.TextUnmarshaler).UnmarshalText(text)
+		}
+	}
+
+	if v.Kind() == reflect.Interface {
+		if v.NumMethod() == 0 && (v.IsNil() || v.Elem().Kind() != reflect.Pointer) {
+			x, err := d.decodeAny(depth)
+			if err != nil {
+				return err
+			}
+			if x == nil {
+				v.SetZero()
+			} else {
+				v.Set(reflect.ValueOf(x))
+			}
+			return nil
+		}
+		if !v.IsNil() {
+			return d.decode(v.Elem(), depth+1)
+		}
+	}
+
+	h, err := d.readHeader()
+	if err != nil {
+		return err
+	}
+
+	if h.major == majorTag {
+		return d.decodeTagged(v, h, depth)
+	}
+
+	mismatch := func() error {
+		return fmt.Errorf("beehive-cbor: cannot decode %s into %s", h.String(), v.Type())
+	}
+
+	switch v.Kind() {
+	case reflect.Bool:
+		if h.major != majorSimple || (h.info != 20 && h.info != 21) {
+			return mismatch()
+		}
+		v.SetBool(h.info == 21)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		if h.major != majorUint && h.major != majorNegInt {
+			return mismatch()
+		}
+		n, ok := h.int()
+		if !ok || v.OverflowInt(n) {
+			return fmt.Errorf("beehive-cbor: %s overflows %s", h.integer(), v.Type())
+		}
+		v.SetInt(n)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		if h.major != majorUint {
+			return mismatch()
+		}
+		if v.OverflowUint(h.value) {
+			return fmt.Errorf("beehive-cbor: %s overflows %s", h.integer(), v.Type())
+		}
+		v.SetUint(h.value)
+	case reflect.Float32, reflect.Float64:
+		f, ok := h.float()
+		if !ok {
+			return mismatch()
+		}
+		v.SetFloat(f)
+	case reflect.String:
+		if h.major != majorText && h.major != majorBytes {
+			return mismatch()
+		}
+		data, err := d.readString(h)
+		if err != nil {
+			return err
+		}
+		v.SetString(string(data))
+	case reflect.Slice:
+		if v.Type().Elem().Kind() == reflect.Uint8 && (h.major == majorBytes || h.major == majorText) {
+			data, err := d.readString(h)
+			if err != nil {
+				return err
+			}
+			v.SetBytes(data)
+			return nil
+		}
+		if h.major != majorArray {
+			return mismatch()
+		}
+		return d.decodeSlice(v, h, depth)
+	case reflect.Array:
+		if v.Type().Elem().Kind() == reflect.Uint8 && (h.major == majorBytes || h.major == majorText) {
+			data, err := d.readString(h)
+			if err != nil {
+				return err
+			}
+			v.SetZero()
+			reflect.Copy(v, reflect.ValueOf(data))
+			return nil
+		}
+		if h.major != majorArray {
+			return mismatch()
+		}
+		return d.decodeArray(v, h, depth)
+	case reflect.Map:
+		if h.major != majorMap {
+			return mismatch()
+		}
+		return d.decodeMap(v, h, depth)
+	case reflect.Struct:
+		if h.major != majorMap {
+			return mismatch()
+		}
+		return d.decodeStruct(v, h, depth)
+	default:
+		return mismatch()
+	}
+
+	return nil
+}
+
+func (h *header) int() (int64, bool) {
+	if h.value > math.MaxInt64 {
+		return 0, false
+	}
+	if h.major == majorNegInt {
+		return -1 - int64(h.value), true
+	}
+	return int64(h.value), true
+}
+
+// integer formats the value of an integer header, which may exceed the range of int64.
+func (h *header) integer() string {
+	if h.major == majorNegInt {
+		if h.value == math.MaxUint64 {
+			return "-18446744073709551616"
+		}
+		return fmt.Sprintf("-%d", h.value+1)
+	}
+	return fmt.Sprintf("%d", h.value)
+}
+
+// decodeTagged decodes the content of a tag into v, tags other than date and time are ignored.
+func (d *decoder) decodeTagged(v reflect.Value, h header, depth int) error {
+	if v.Type() == timeType && (h.value == tagDateTime || h.value == tagEpoch) {
+		t, err := d.readTime(h.value, depth)
+		if err != nil {
+			return err
+		}
+		v.Set(reflect.ValueOf(t))
+		return nil
+	}
+
+	if v.Type() == tagType {
+		content, err := d.decodeAny(depth + 1)
+		if err != nil {
+			return err
+		}
+		v.Set(reflect.ValueOf(Tag{Number: h.value, Content: content}))
+		return nil
+	}
+
+	if v.Type() == timeType {
+		return fmt.Errorf("beehive-cbor: cannot decode tag %d into %s", h.value, v.Type())
+	}
+
+	return d.decode(v, depth+1)
+}
+
+func (d *decoder) readTime(tag uint64, depth int) (time.Time, error) {
+	content, err := d.decodeAny(depth + 1)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	switch c := content.(type) {
+	case string:
+		if tag == tagDateTime {
+			t, err := time.Parse(time.RFC3339Nano, c)
+			if err != nil {
+				return time.Time{}, fmt.Errorf("beehive-cbor: invalid date/time string: %w", err)
+			}
+			return t, nil
+		}
+	case int64:
+		if tag == tagEpoch {
+			return time.Unix(c, 0).UTC(), nil
+		}
+	case float64:
+		if tag == tagEpoch && !math.IsNaN(c) && !math.IsInf(c, 0) {
+			sec, frac := math.Modf(c)
+			return time.Unix(int64(sec), int64(frac*1e9)).UTC(), nil
+		}
+	}
+
+	return time.Time{}, fmt.Errorf("beehive-cbor: invalid content %T for tag %d", content, tag)
+}
+
+func (d *decoder) decodeSlice(v reflect.Value, h header, depth int) error {
+	// the capacity is bounded, as the length is not trusted
+	n := h.value
+	if h.indefinite {
+		n = 0
+	}
+	slice := reflect.MakeSlice(v.Type(), 0, int(min(n, 1024)))
+
+	err := d.items(h, func() error {
+		elem := reflect.New(v.Type().Elem()).Elem()
+		if err := d.decode(elem, depth+1); err != nil {
+			return err
+		}
+		slice = reflect.Append(slice, elem)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	v.Set(slice)
+	return nil
+}
+
+func (d *decoder) decodeArray(v reflect.Value, h header, depth int) error {
+	i := 0
+	err := d.items(h, func() error {
+		defer func() { i++ }()
+		if i >= v.Len() {
+			return d.skip(depth)
+		}
+		return d.decode(v.Index(i), depth+1)
+	})
+	if err != nil {
+		return err
+	}
+
+	for ; i < v.Len(); i++ {
+		v.Index(i).SetZero()
+	}
+	return nil
+}
+
+func (d *decoder) decodeMap(v reflect.Value, h header, depth int) error {
+	if v.IsNil() {
+		v.Set(reflect.MakeMap(v.Type()))
+	}
+
+	keyType, elemType := v.Type().Key(), v.Type().Elem()
+	return d.items(h, func() error {
+		key := reflect.New(keyType).Elem()
+		if err := d.decode(key, depth+1); err != nil {
+			return err
+		}
+
+		elem := reflect.New(elemType).Elem()
+		if err := d.decode(elem, depth+1); err != nil {
+			return err
+		}
+
+		v.SetMapIndex(key, elem)
+		return nil
+	})
+}
+
+func (d *decoder) decodeStruct(v reflect.Value, h header, depth int) error {
+	s := fields.Of(v.Type(), "cbor")
+
+	return d.items(h, func() error {
+		kh, err := d.readHeader()
+		if err != nil {
+			return err
+		}
+		if kh.major != majorText {
+			return fmt.Errorf("beehive-cbor: cannot decode %s key into %s", kh.String(), v.Type())
+		}
+		name, err := d.readString(kh)
+		if err != nil {
+			return err
+		}
+
+		f, ok := s.Lookup(string(name))
+		if !ok {
+			return d.skip(depth)
+		}
+
+		fv, _ := f.Value(v, true)
+		if !fv.IsValid() || !fv.CanSet() {
+			return d.skip(depth)
+		}
+
+		return d.decode(fv, depth+1)
+	})
+}
+
+func (d *decoder) decodeAny(depth int) (any, error) {
+	if depth > maxDepth {
+		return nil, ErrTooDeep
+	}
+
+	h, err := d.readHeader()
+	if err != nil {
+		return nil, err
+	}
+
+	switch h.major {
+	case majorUint:
+		if h.value > math.MaxInt64 {
+			return h.value, nil
+		}
+		return int64(h.value), nil
+	case majorNegInt:
+		n, ok := h.int()
+		if !ok {
+			return nil, fmt.Errorf("beehive-cbor: %s overflows int64", h.integer())
+		}
+		return n, nil
+	case majorBytes:
+		return d.readString(h)
+	case majorText:
+		data, err := d.readString(h)
+		return string(data), err
+	case majorArray:
+		arr := make([]any, 0, min(h.value, 1024))
+		err := d.items(h, func() error {
+			x, err := d.decodeAny(depth + 1)
+			arr = append(arr, x)
+			return err
+		})
+		if err != nil {
+			return nil, err
+		}
+		return arr, nil
+	case majorMap:
+		return d.decodeAnyMap(h, depth)
+	case majorTag:
+		if h.value == tagDateTime || h.value == tagEpoch {
+			return d.readTime(h.value, depth)
+		}
+		content, err := d.decodeAny(depth + 1)
+		if err != nil {
+			return nil, err
+		}
+		return Tag{Number: h.value, Content: content}, nil
+	}
+
+	switch h.info {
+	case 20, 21:
+		return h.info == 21, nil
+	case 22, 23:
+		return nil, nil
+	}
+
+	if f, ok := h.float(); ok {
+		return f, nil
+	}
+	return nil, fmt.Errorf("beehive-cbor: unsupported simple value %d", h.value)
+}
+
+func (d *decoder) decodeAnyMap(h header, depth int) (any, error) {
+	n := h.value
+	if h.indefinite {
+		n = 0
+	}
+	strings := make(map[string]any, min(n, 1024))
+	var others map[any]any
+
+	err := d.items(h, func() error {
+		key, err := d.decodeAny(depth + 1)
+		if err != nil {
+			return err
+		}
+		value, err := d.decodeAny(depth + 1)
+		if err != nil {
+			return err
+		}
+
+		if s, ok := key.(string); ok && others == nil {
+			strings[s] = value
+			return nil
+		}
+
+		// the dynamic value is checked, as a Tag holding a byte string is not comparable
+		if key != nil && !reflect.ValueOf(key).Comparable() {
+			return fmt.Errorf("beehive-cbor: unsupported map key type %T", key)
+		}
+
+		if others == nil {
+			others = make(map[any]any, len(strings)+1)
+			for k, v := range strings {
+				others[k] = v
+			}
+		}
+		others[key] = value
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if others != nil {
+		return others, nil
+	}
+	return strings, nil
+}
+
+// skip discards the next data item.
+func (d *decoder) skip(depth int) error {
+	if depth > maxDepth {
+		return ErrTooDeep
+	}
+
+	h, err := d.readHeader()
+	if err != nil {
+		return err
+	}
+
+	switch h.major {
+	case majorBytes, majorText:
+		_, err = d.readString(h)
+	case majorArray:
+		err = d.items(h, func() error { return d.skip(depth + 1) })
+	case majorMap:
+		err = d.items(h, func() error {
+			if err := d.skip(depth + 1); err != nil {
+				return err
+			}
+			return d.skip(depth + 1)
+		})
+	case majorTag:
+		err = d.skip(depth + 1)
+	}
+	return err
+}
+
+// rawItem returns the encoding of the next data item.
+func (d *decoder) rawItem(depth int) ([]byte, error) {
+	d.raw, d.recording = d.raw[:0], true
+	err := d.skip(depth)
+	d.recording = false
+	if err != nil {
+		return nil, err
+	}
+
+	return bytes.Clone(d.raw), nil
+}
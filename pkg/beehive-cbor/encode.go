@@ -0,0 +1,324 @@
+package beehive_cbor
+
+import (
+	"bytes"
+	"encoding"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"reflect"
+	"slices"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"go.sdls.io/beehive/internal/fields"
+)
+
+// Marshal returns the CBOR encoding of v, following the core deterministic encoding of RFC 8949 section 4.2: lengths
+// are definite, integers and floats use their shortest form, and map keys are sorted by their encoding. Values are
+// encoded like encoding/json does: structs are maps of their exported fields, named after the `cbor` tag (or the
+// `json` tag) with the omitempty and "-" options, and encoding.TextMarshaler values are text strings. Byte slices and
+// arrays are byte strings, time.Time values are tag 0 date/time strings.
+func Marshal(v any) ([]byte, error) {
+	var e encoder
+	if err := e.encode(reflect.ValueOf(v), 0); err != nil {
+		return nil, err
+	}
+	return e.buf, nil
+}
+
+// Encoder writes CBOR data items to a stream.
+type Encoder struct {
+	w io.Writer
+	e encoder
+}
+
+// NewEncoder returns an Encoder writing to w.
+func NewEncoder(w io.Writer) *Encoder {
+	return &Encoder{w: w}
+}
+
+// Encode writes the CBOR encoding of v, see Marshal.
+func (enc *Encoder) Encode(v any) error {
+	enc.e.buf = enc.e.buf[:0]
+	if err := enc.e.encode(reflect.ValueOf(v), 0); err != nil {
+		return err
+	}
+
+	_, err := enc.w.Write(enc.e.buf)
+	return err
+}
+
+type encoder struct {
+	buf []byte
+}
+
+func (e *encoder) encode(v reflect.Value, depth int) error {
+	if depth > maxDepth {
+		return ErrTooDeep
+	}
+
+	if !v.IsValid() {
+		e.buf = append(e.buf, 0xf6)
+		return nil
+	}
+
+	t := v.Type()
+	if (v.Kind() == reflect.Pointer || v.Kind() == reflect.Interface) && v.IsNil() {
+		e.buf = append(e.buf, 0xf6)
+		return nil
+	}
+
+	if !t.Implements(marshalerType) && v.CanAddr() && reflect.PointerTo(t).Implements(marshalerType) {
+		v, t = v.Addr(), reflect.PointerTo(t)
+	}
+
+	if t.Implements(marshalerType) {
+		data, err := v.Interface().(Marshaler).MarshalCBOR()
+		if err != nil {
+			return err
+		}
+		e.buf = append(e.buf, data...)
+		return nil
+	}
+
+	switch t {
+	case timeType:
+		e.encodeHead(majorTag, tagDateTime)
+		e.encodeText(v.Interface().(time.Time).Format(time.RFC3339Nano))
+		return nil
+	case tagType:
+		tag := v.Interface().(Tag)
+		e.encodeHead(majorTag, tag.Number)
+		return e.encode(reflect.ValueOf(tag.Content), depth+1)
+	}
+
+	if t.Implements(textMarshalerType) {
+		text, err := v.Interface().(encoding.TextMarshaler).MarshalText()
+		if err != nil {
+			return err
+		}
+		e.encodeText(string(text))
+		return nil
+	}
+
+	switch v.Kind() {
+	case reflect.Bool:
+		if v.Bool() {
+			e.buf = append(e.buf, 0xf5)
+		} else {
+			e.buf = append(e.buf, 0xf4)
+		}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		if n := v.Int(); n >= 0 {
+			e.encodeHead(majorUint, uint64(n))
+		} else {
+			e.encodeHead(majorNegInt, uint64(-1-n))
+		}
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		e.encodeHead(majorUint, v.Uint())
+	case reflect.Float32, reflect.Float64:
+		e.encodeFloat(v.Float())
+	case reflect.String:
+		e.encodeText(v.String())
+	case reflect.Slice:
+		if v.IsNil() {
+			e.buf = append(e.buf, 0xf6)
+			return nil
+		}
+		if t.Elem().Kind() == reflect.Uint8 {
+			e.encodeHead(majorBytes, uint64(v.Len()))
+			e.buf = append(e.buf, v.Bytes()...)
+			return nil
+		}
+		return e.encodeArray(v, depth)
+	case reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			data := make([]byte, v.Len())
+			reflect.Copy(reflect.ValueOf(data), v)
+			e.encodeHead(majorBytes, uint64(len(data)))
+			e.buf = append(e.buf, data...)
+			return nil
+		}
+		return e.encodeArray(v, depth)
+	case reflect.Map:
+		if v.IsNil() {
+			e.buf = append(e.buf, 0xf6)
+			return nil
+		}
+		return e.encodeMap(v, depth)
+	case reflect.Struct:
+		return e.encodeStruct(v, depth)
+	case reflect.Pointer, reflect.Interface:
+		return e.encode(v.Elem(), depth+1)
+	default:
+		return fmt.Errorf("beehive-cbor: unsupported type %s", t)
+	}
+
+	return nil
+}
+
+// encodeHead writes the initial byte of a data item with its argument, in its shortest form.
+func (e *encoder) encodeHead(major byte, n uint64) {
+	major <<= 5
+	switch {
+	case n < 24:
+		e.buf = append(e.buf, major|byte(n))
+	case n <= math.MaxUint8:
+		e.buf = append(e.buf, major|24, byte(n))
+	case n <= math.MaxUint16:
+		e.buf = binary.BigEndian.AppendUint16(append(e.buf, major|25), uint16(n))
+	case n <= math.MaxUint32:
+		e.buf = binary.BigEndian.AppendUint32(append(e.buf, major|26), uint32(n))
+	default:
+		e.buf = binary.BigEndian.AppendUint64(append(e.buf, major|27), n)
+	}
+}
+
+// encodeText writes a text string, replacing invalid UTF-8 like encoding/json does.
+func (e *encoder) encodeText(s string) {
+	if !utf8.ValidString(s) {
+		s = strings.ToValidUTF8(s, "\uFFFD")
+	}
+	e.encodeHead(majorText, uint64(len(s)))
+	e.buf = append(e.buf, s...)
+}
+
+// encodeFloat writes f as the shortest of half, single and double precision floats that preserves its value.
+func (e *encoder) encodeFloat(f float64) {
+	if math.IsNaN(f) {
+		e.buf = append(e.buf, 0xf9, 0x7e, 0x00)
+		return
+	}
+
+	f32 := float32(f)
+	if float64(f32) != f {
+		e.buf = binary.BigEndian.AppendUint64(append(e.buf, 0xfb), math.Float64bits(f))
+		return
+	}
+
+	if half, ok := float16(f32); ok {
+		e.buf = binary.BigEndian.AppendUint16(append(e.buf, 0xf9), half)
+		return
+	}
+
+	e.buf = binary.BigEndian.AppendUint32(append(e.buf, 0xfa), math.Float32bits(f32))
+}
+
+// float16 returns the half precision representation of f, if it is exact.
+func float16(f float32) (uint16, bool) {
+	bits := math.Float32bits(f)
+	sign := uint16(bits>>16) & 0x8000
+	exp := int(bits>>23) & 0xff
+	mant := bits & 0x7fffff
+
+	switch {
+	case exp == 0 && mant == 0:
+		return sign, true
+	case exp == 0xff:
+		// infinities, NaN is handled by the caller
+		return sign | 0x7c00 | uint16(mant>>13), mant&0x1fff == 0
+	case exp == 0:
+		// single precision subnormals are below the half precision range
+		return 0, false
+	}
+
+	switch e := exp - 127; {
+	case e >= -14 && e <= 15:
+		return sign | uint16(e+15)<<10 | uint16(mant>>13), mant&0x1fff == 0
+	case e >= -24 && e < -14:
+		// half precision subnormal, m * 2^-24
+		full := mant | 1<<23
+		shift := uint(-(e + 1))
+		return sign | uint16(full>>shift), full&(1<<shift-1) == 0
+	default:
+		return 0, false
+	}
+}
+
+func (e *encoder) encodeArray(v reflect.Value, depth int) error {
+	e.encodeHead(majorArray, uint64(v.Len()))
+	for i := range v.Len() {
+		if err := e.encode(v.Index(i), depth+1); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (e *encoder) encodeMap(v reflect.Value, depth int) error {
+	type entry struct {
+		key, value []byte
+	}
+
+	entries := make([]entry, 0, v.Len())
+	var sub encoder
+
+	iter := v.MapRange()
+	for iter.Next() {
+		sub.buf = nil
+		if err := sub.encode(iter.Key(), depth+1); err != nil {
+			return err
+		}
+		key := sub.buf
+
+		sub.buf = nil
+		if err := sub.encode(iter.Value(), depth+1); err != nil {
+			return err
+		}
+
+		entries = append(entries, entry{key: key, value: sub.buf})
+	}
+
+	slices.SortFunc(entries, func(a, b entry) int {
+		return bytes.Compare(a.key, b.key)
+	})
+
+	e.encodeHead(majorMap, uint64(len(entries)))
+	for _, entry := range entries {
+		e.buf = append(append(e.buf, entry.key...), entry.value...)
+	}
+
+	return nil
+}
+
+func (e *encoder) encodeStruct(v reflect.Value, depth int) error {
+	s := fields.Of(v.Type(), "cbor")
+
+	type entry struct {
+		key   []byte
+		value reflect.Value
+	}
+
+	entries := make([]entry, 0, len(s.Fields))
+	var sub encoder
+	for idx := range s.Fields {
+		f := &s.Fields[idx]
+
+		fv, ok := f.Value(v, false)
+		if !ok || (f.OmitEmpty && fields.IsEmpty(fv)) {
+			continue
+		}
+
+		sub.buf = nil
+		sub.encodeText(f.Name)
+		entries = append(entries, entry{key: sub.buf, value: fv})
+	}
+
+	// struct fields are map keys as well, sorted for a deterministic encoding
+	slices.SortStableFunc(entries, func(a, b entry) int {
+		return bytes.Compare(a.key, b.key)
+	})
+
+	e.encodeHead(majorMap, uint64(len(entries)))
+	for _, entry := range entries {
+		e.buf = append(e.buf, entry.key...)
+		if err := e.encode(entry.value, depth+1); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,303 @@
+package beehive_concurrency
+
+import (
+	"context"
+	"errors"
+	"math"
+	"strconv"
+	"sync"
+	"time"
+
+	"go.sdls.io/beehive/pkg/beehive"
+)
+
+// Sample is a single observed request, passed to Algorithm.Update.
+type Sample struct {
+	// Latency is the duration of the handler chain after the middleware, as measured around beehive.Context.Next.
+	Latency time.Duration
+
+	// InFlight is the number of requests in flight when the request finished, including itself.
+	InFlight int
+
+	// Dropped reports whether the request is considered an overload signal, see AdaptiveConfig.Dropped.
+	Dropped bool
+}
+
+// Algorithm computes the concurrency limit from observed requests. Update is never called concurrently by the
+// AdaptiveLimiter, so implementations can keep state without synchronization.
+type Algorithm interface {
+	// Update returns the new limit given the current limit and the observed Sample.
+	Update(limit int, sample Sample) int
+}
+
+// AIMD is an additive increase, multiplicative decrease Algorithm. The limit is increased by Increase for every
+// successful request while the limit is being used, and multiplied by Backoff for every dropped request or request
+// slower than Timeout.
+type AIMD struct {
+	// Min and Max bound the limit. If Max is zero, there is no upper bound. Min is at least 1.
+	Min, Max int
+
+	// Increase is added to the limit on success. If zero, 1 is used.
+	Increase int
+
+	// Backoff is the factor applied on drops, between 0 and 1. If zero, 0.9 is used.
+	Backoff float64
+
+	// Timeout marks requests slower than it as dropped. If zero, only Sample.Dropped is used.
+	Timeout time.Duration
+}
+
+// Update satisfies the Algorithm interface.
+func (a *AIMD) Update(limit int, sample Sample) int {
+	if sample.Dropped || (a.Timeout > 0 && sample.Latency > a.Timeout) {
+		backoff := a.Backoff
+		if backoff == 0 {
+			backoff = 0.9
+		}
+
+		return clampLimit(int(float64(limit)*backoff), a.Min, a.Max)
+	}
+
+	// only grow the limit when it is actually being used
+	if sample.InFlight*2 < limit {
+		return limit
+	}
+
+	increase := a.Increase
+	if increase == 0 {
+		increase = 1
+	}
+
+	return clampLimit(limit+increase, a.Min, a.Max)
+}
+
+// Gradient is a latency gradient Algorithm, in the spirit of TCP Vegas. It tracks the minimum observed latency (the
+// latency without queueing) and compares every sample against it, the limit shrinks when latency grows because
+// requests are queueing downstream and grows by a small queue allowance while latency stays close to the minimum.
+type Gradient struct {
+	// Min and Max bound the limit. If Max is zero, there is no upper bound. Min is at least 1.
+	Min, Max int
+
+	// Smoothing is how fast the limit moves towards the new estimate, between 0 and 1. If zero, 0.2 is used.
+	Smoothing float64
+
+	// Tolerance is how much the latency can grow above the minimum before the limit is reduced. If zero, 1.5 is
+	// used.
+	Tolerance float64
+
+	// ProbeInterval is the number of samples after which the minimum latency is forgotten and learned again, such
+	// that a permanently slower downstream is eventually accepted as the new normal. If zero, 1000 is used.
+	ProbeInterval int
+
+	estimate float64
+	minRTT   time.Duration
+	samples  int
+}
+
+// Update satisfies the Algorithm interface.
+func (g *Gradient) Update(limit int, sample Sample) int {
+	smoothing := g.Smoothing
+	if smoothing == 0 {
+		smoothing = 0.2
+	}
+	tolerance := g.Tolerance
+	if tolerance == 0 {
+		tolerance = 1.5
+	}
+	probeInterval := g.ProbeInterval
+	if probeInterval == 0 {
+		probeInterval = 1000
+	}
+
+	if g.estimate == 0 {
+		g.estimate = float64(limit)
+	}
+
+	rtt := max(sample.Latency, time.Nanosecond)
+
+	g.samples++
+	if g.minRTT == 0 || rtt < g.minRTT || g.samples >= probeInterval {
+		g.minRTT = rtt
+		g.samples = 0
+	}
+
+	// only grow the limit when it is actually being used
+	if !sample.Dropped && float64(sample.InFlight) < g.estimate/2 {
+		return limit
+	}
+
+	gradient := math.Max(0.5, math.Min(1, tolerance*float64(g.minRTT)/float64(rtt)))
+	if sample.Dropped {
+		gradient = 0.5
+	}
+
+	estimate := g.estimate*gradient + math.Sqrt(g.estimate)
+	estimate = g.estimate*(1-smoothing) + estimate*smoothing
+
+	estimate = math.Max(estimate, float64(max(g.Min, 1)))
+	if g.Max > 0 {
+		estimate = math.Min(estimate, float64(g.Max))
+	}
+	g.estimate = estimate
+
+	return int(estimate)
+}
+
+func clampLimit(limit, minLimit, maxLimit int) int {
+	limit = max(limit, minLimit, 1)
+	if maxLimit > 0 {
+		limit = min(limit, maxLimit)
+	}
+	return limit
+}
+
+// AdaptiveConfig describes an adaptive concurrency limiting middleware, use NewAdaptiveLimiter to create it.
+type AdaptiveConfig struct {
+	// Algorithm adjusts the limit after every request. If nil, AIMD with the default values is used.
+	Algorithm Algorithm
+
+	// InitialLimit is the limit used before any request was observed. If zero, 20 is used.
+	InitialLimit int
+
+	// MaxQueue, MaxWait, RetryAfter and Responder behave as in Config.
+	MaxQueue   int
+	MaxWait    time.Duration
+	RetryAfter time.Duration
+	Responder  beehive.Responder
+
+	// Dropped reports whether a finished request is an overload signal. If nil, requests whose context deadline was
+	// exceeded are considered dropped.
+	Dropped func(ctx *beehive.Context, res beehive.Responder) bool
+
+	// OnLimit is called with the new limit every time it changes.
+	OnLimit func(limit int)
+
+	// Now is the clock used to measure latency. If nil, time.Now is used.
+	Now func() time.Time
+}
+
+// AdaptiveLimiter is a beehive middleware limiting the number of requests handled at the same time, where the limit
+// is continuously adjusted by an Algorithm based on the observed latency of the handler chain. AdaptiveLimiter is
+// safe for concurrent use.
+type AdaptiveLimiter struct {
+	sem              *Semaphore
+	algorithm        Algorithm
+	maxWait          time.Duration
+	responder        beehive.Responder
+	dropped          func(ctx *beehive.Context, res beehive.Responder) bool
+	onLimit          func(limit int)
+	now              func() time.Time
+	headerRetryAfter []string
+
+	mu sync.Mutex
+}
+
+// NewAdaptiveLimiter returns an AdaptiveLimiter for the given AdaptiveConfig.
+func NewAdaptiveLimiter(config AdaptiveConfig) *AdaptiveLimiter {
+	a := &AdaptiveLimiter{
+		algorithm: config.Algorithm,
+		maxWait:   config.MaxWait,
+		responder: config.Responder,
+		dropped:   config.Dropped,
+		onLimit:   config.OnLimit,
+		now:       config.Now,
+	}
+
+	if a.algorithm == nil {
+		a.algorithm = &AIMD{}
+	}
+	if a.responder == nil {
+		a.responder = defaultResponder
+	}
+	if a.dropped == nil {
+		a.dropped = func(ctx *beehive.Context, _ beehive.Responder) bool {
+			return errors.Is(ctx.Err(), context.DeadlineExceeded)
+		}
+	}
+	if a.now == nil {
+		a.now = time.Now
+	}
+
+	initialLimit := config.InitialLimit
+	if initialLimit == 0 {
+		initialLimit = 20
+	}
+	a.sem = NewSemaphore(initialLimit, config.MaxQueue)
+
+	retryAfter := config.RetryAfter
+	if retryAfter == 0 {
+		retryAfter = time.Second
+	}
+	a.headerRetryAfter = []string{strconv.Itoa(max(int(retryAfter.Seconds()), 1))}
+
+	return a
+}
+
+// HandlerFunc returns the beehive.HandlerFunc enforcing the adaptive limit. Like Limiter, the slot is held until the
+// response is written (see beehive.Context.After), and the latency is measured over the same span, so the middleware
+// should be placed right before the handlers it protects.
+func (a *AdaptiveLimiter) HandlerFunc() beehive.HandlerFunc {
+	return func(ctx *beehive.Context) beehive.Responder {
+		if err := a.sem.Acquire(ctx, a.maxWait); err != nil {
+			ctx.ResponseWriter.Header()["Retry-After"] = a.headerRetryAfter
+			return a.responder
+		}
+
+		start := a.now()
+		finished := false
+
+		var res beehive.Responder
+		ctx.After(func() {
+			if !finished {
+				// the chain panicked, the request says nothing about the latency
+				a.sem.Release()
+				return
+			}
+
+			sample := Sample{
+				Latency:  a.now().Sub(start),
+				InFlight: a.sem.InFlight(),
+				Dropped:  a.dropped(ctx, res),
+			}
+
+			a.sem.Release()
+			a.Observe(sample)
+		})
+
+		res = ctx.Next()
+		finished = true
+
+		return res
+	}
+}
+
+// Observe feeds a Sample to the Algorithm and applies the new limit. It is called by the middleware after every
+// request, and can be called directly to drive the AdaptiveLimiter deterministically.
+func (a *AdaptiveLimiter) Observe(sample Sample) {
+	a.mu.Lock()
+	limit := a.sem.Limit()
+	newLimit := max(a.algorithm.Update(limit, sample), 1)
+	if newLimit != limit {
+		a.sem.SetLimit(newLimit)
+	}
+	a.mu.Unlock()
+
+	if newLimit != limit && a.onLimit != nil {
+		a.onLimit(newLimit)
+	}
+}
+
+// Limit returns the current concurrency limit.
+func (a *AdaptiveLimiter) Limit() int {
+	return a.sem.Limit()
+}
+
+// InFlight returns the number of requests currently being handled.
+func (a *AdaptiveLimiter) InFlight() int {
+	return a.sem.InFlight()
+}
+
+// Queued returns the number of requests waiting for a slot.
+func (a *AdaptiveLimiter) Queued() int {
+	return a.sem.Queued()
+}
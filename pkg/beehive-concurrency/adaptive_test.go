@@ -0,0 +1,214 @@
+package beehive_concurrency
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"go.sdls.io/beehive/pkg/beehive"
+)
+
+// testSimulation is a deterministic harness driving an AdaptiveLimiter against a simulated downstream that handles
+// capacity requests at baseLatency and queues anything above, such that latency grows linearly with the overload.
+type testSimulation struct {
+	capacity    int
+	baseLatency time.Duration
+}
+
+func (s testSimulation) latency(inFlight int) time.Duration {
+	if inFlight <= s.capacity {
+		return s.baseLatency
+	}
+	return s.baseLatency * time.Duration(inFlight) / time.Duration(s.capacity)
+}
+
+// run keeps the limiter saturated for the given number of samples and returns the observed limits.
+func (s testSimulation) run(limiter *AdaptiveLimiter, samples int) []int {
+	limits := make([]int, 0, samples)
+	for range samples {
+		inFlight := limiter.Limit()
+		limiter.Observe(Sample{
+			Latency:  s.latency(inFlight),
+			InFlight: inFlight,
+		})
+		limits = append(limits, limiter.Limit())
+	}
+	return limits
+}
+
+func TestAIMD(t *testing.T) {
+	t.Parallel()
+
+	aimd := &AIMD{Min: 2, Max: 10, Timeout: time.Second}
+
+	tests := []struct {
+		limit  int
+		sample Sample
+		want   int
+	}{
+		{5, Sample{Latency: time.Millisecond, InFlight: 5}, 6},
+		{5, Sample{Latency: time.Millisecond, InFlight: 1}, 5},
+		{10, Sample{Latency: time.Millisecond, InFlight: 10}, 10},
+		{10, Sample{Latency: time.Millisecond, InFlight: 10, Dropped: true}, 9},
+		{10, Sample{Latency: 2 * time.Second, InFlight: 10}, 9},
+		{2, Sample{Dropped: true}, 2},
+	}
+
+	for _, tt := range tests {
+		if got := aimd.Update(tt.limit, tt.sample); got != tt.want {
+			t.Errorf("Update(%d, %+v): expected %d, got %d", tt.limit, tt.sample, tt.want, got)
+		}
+	}
+}
+
+func TestAdaptiveLimiter_simulation(t *testing.T) {
+	t.Parallel()
+
+	sim := testSimulation{capacity: 50, baseLatency: 10 * time.Millisecond}
+
+	algorithms := map[string]Algorithm{
+		"aimd":     &AIMD{Timeout: 15 * time.Millisecond},
+		"gradient": &Gradient{ProbeInterval: 10000},
+	}
+
+	for name, algorithm := range algorithms {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			var changes int
+			limiter := NewAdaptiveLimiter(AdaptiveConfig{
+				Algorithm:    algorithm,
+				InitialLimit: 5,
+				OnLimit:      func(_ int) { changes++ },
+			})
+
+			limits := sim.run(limiter, 2000)
+			if changes == 0 {
+				t.Errorf("expected OnLimit to be called")
+			}
+
+			// after warming up the limit must stay around the capacity, without exceeding the tolerated latency
+			for _, limit := range limits[1000:] {
+				if limit < sim.capacity*3/4 || limit > sim.capacity*2 {
+					t.Fatalf("expected limit to converge around %d, got %d", sim.capacity, limit)
+				}
+			}
+
+			// the downstream gets twice as slow, the limit must go down
+			before := limiter.Limit()
+			slow := testSimulation{capacity: sim.capacity / 2, baseLatency: sim.baseLatency}
+			slow.run(limiter, 200)
+			if limiter.Limit() >= before {
+				t.Errorf("expected limit to decrease from %d, got %d", before, limiter.Limit())
+			}
+		})
+	}
+}
+
+func TestAdaptiveLimiter_HandlerFunc(t *testing.T) {
+	t.Parallel()
+
+	now := time.Unix(1700000000, 0)
+	var samples []Sample
+
+	limiter := NewAdaptiveLimiter(AdaptiveConfig{
+		Algorithm: testAlgorithmFunc(func(limit int, sample Sample) int {
+			samples = append(samples, sample)
+			return limit + 1
+		}),
+		InitialLimit: 1,
+		Now:          func() time.Time { return now },
+	})
+
+	router := beehive.NewRouter()
+	router.Handle("GET", "/foo", limiter.HandlerFunc(), func(_ *beehive.Context) beehive.Responder {
+		now = now.Add(20 * time.Millisecond)
+		return &testSlowResponder{advance: func() { now = now.Add(5 * time.Millisecond) }}
+	})
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", "/foo", nil))
+
+	if w.Code != http.StatusOK || w.Body.String() != "ok" {
+		t.Errorf("unexpected response %d %q", w.Code, w.Body.String())
+	}
+	if len(samples) != 1 || samples[0].Latency != 25*time.Millisecond || samples[0].InFlight != 1 || samples[0].Dropped {
+		t.Errorf("unexpected samples %+v", samples)
+	}
+	if limiter.Limit() != 2 || limiter.InFlight() != 0 || limiter.Queued() != 0 {
+		t.Errorf("unexpected state limit=%d in flight=%d queued=%d", limiter.Limit(), limiter.InFlight(), limiter.Queued())
+	}
+
+	ctx, cancel := context.WithDeadline(t.Context(), now.Add(-time.Second))
+	defer cancel()
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", "/foo", nil).WithContext(ctx))
+	if len(samples) != 2 || !samples[1].Dropped {
+		t.Errorf("expected exceeded deadline to be dropped, got %+v", samples)
+	}
+}
+
+func TestAdaptiveLimiter_shed(t *testing.T) {
+	t.Parallel()
+
+	limiter := NewAdaptiveLimiter(AdaptiveConfig{InitialLimit: 1})
+	tr := newTestBlockingRouter(limiter.HandlerFunc())
+
+	codes := make(chan int, 1)
+	tr.serve("/slow/a", nil, codes)
+	testWaitFor(t, func() bool { return limiter.InFlight() == 1 })
+
+	w := httptest.NewRecorder()
+	tr.router.ServeHTTP(w, httptest.NewRequest("GET", "/slow/a", nil))
+	if w.Code != http.StatusServiceUnavailable || w.Header().Get("Retry-After") != "1" {
+		t.Errorf("expected shed request, got %d with Retry-After %q", w.Code, w.Header().Get("Retry-After"))
+	}
+
+	close(tr.release)
+	tr.wg.Wait()
+
+	if code := <-codes; code != http.StatusOK {
+		t.Errorf("expected status code %d, got %d", http.StatusOK, code)
+	}
+}
+
+func TestAdaptiveLimiter_panic(t *testing.T) {
+	t.Parallel()
+
+	limiter := NewAdaptiveLimiter(AdaptiveConfig{InitialLimit: 1})
+
+	router := beehive.NewRouter()
+	router.Handle("GET", "/foo", limiter.HandlerFunc(), func(_ *beehive.Context) beehive.Responder {
+		panic("boom")
+	})
+
+	router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/foo", nil))
+
+	if limiter.InFlight() != 0 {
+		t.Errorf("expected slot to be released after panic, got %d in flight", limiter.InFlight())
+	}
+}
+
+// testSlowResponder calls advance while writing the response, to include it in the measured latency.
+type testSlowResponder struct {
+	advance func()
+}
+
+func (r *testSlowResponder) StatusCode(_ *beehive.Context) int {
+	return http.StatusOK
+}
+
+func (r *testSlowResponder) Respond(ctx *beehive.Context) {
+	r.advance()
+	ctx.ResponseWriter.WriteHeader(http.StatusOK)
+	_, _ = ctx.ResponseWriter.Write([]byte("ok"))
+}
+
+type testAlgorithmFunc func(limit int, sample Sample) int
+
+func (f testAlgorithmFunc) Update(limit int, sample Sample) int {
+	return f(limit, sample)
+}
@@ -0,0 +1,198 @@
+package beehive_concurrency
+
+import (
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"go.sdls.io/beehive/pkg/beehive"
+	beehiveRate "go.sdls.io/beehive/pkg/beehive-rate"
+	beehiveResponder "go.sdls.io/beehive/pkg/beehive-responder"
+)
+
+// Config describes a concurrency limiting middleware, use NewLimiter to create it.
+type Config struct {
+	// Limit is the maximum number of requests handled at the same time across all keys. If zero, there is no
+	// global limit.
+	Limit int
+
+	// KeyLimit is the maximum number of requests handled at the same time for the same key. If zero, there is no
+	// per key limit.
+	KeyLimit int
+
+	// Key extracts the key used by KeyLimit. If nil, beehive-rate.KeyRoute is used. Requests without a key are only
+	// subject to the global Limit.
+	Key beehiveRate.KeyFunc
+
+	// MaxQueue is the number of requests that can wait for a slot, for the global limit and for each key. If zero,
+	// requests are shed as soon as the limit is reached.
+	MaxQueue int
+
+	// MaxWait is how long a request can wait in the queue before it is shed. If zero, requests wait until a slot is
+	// free or the request context is done.
+	MaxWait time.Duration
+
+	// RetryAfter is sent in the Retry-After header of shed requests. If zero, 1 second is used.
+	RetryAfter time.Duration
+
+	// Responder is returned for shed requests. If nil, a 503 Service Unavailable with no body is used.
+	Responder beehive.Responder
+}
+
+// Limiter is a beehive middleware limiting the number of requests handled at the same time. A request holds its slot
+// until the response is sent. Limiter is safe for concurrent use.
+type Limiter struct {
+	config           Config
+	key              beehiveRate.KeyFunc
+	global           *Semaphore
+	headerRetryAfter []string
+
+	mu   sync.Mutex
+	keys map[string]*keySemaphore
+}
+
+type keySemaphore struct {
+	*Semaphore
+	refs int
+}
+
+var defaultResponder = beehiveResponder.Status{Code: http.StatusServiceUnavailable}
+
+// NewLimiter returns a Limiter for the given Config.
+func NewLimiter(config Config) *Limiter {
+	l := &Limiter{
+		config: config,
+		key:    config.Key,
+		keys:   make(map[string]*keySemaphore),
+	}
+
+	if l.key == nil {
+		l.key = beehiveRate.KeyRoute()
+	}
+
+	if config.Limit > 0 {
+		l.global = NewSemaphore(config.Limit, config.MaxQueue)
+	}
+
+	retryAfter := config.RetryAfter
+	if retryAfter == 0 {
+		retryAfter = time.Second
+	}
+	l.headerRetryAfter = []string{strconv.Itoa(max(int(retryAfter.Seconds()), 1))}
+
+	if l.config.Responder == nil {
+		l.config.Responder = defaultResponder
+	}
+
+	return l
+}
+
+// HandlerFunc returns the beehive.HandlerFunc enforcing the limits.
+func (l *Limiter) HandlerFunc() beehive.HandlerFunc {
+	return func(ctx *beehive.Context) beehive.Responder {
+		var keySem *keySemaphore
+		var key string
+		if l.config.KeyLimit > 0 {
+			var ok bool
+			if key, ok = l.key(ctx); ok {
+				keySem = l.acquireKey(key)
+				if err := keySem.Acquire(ctx, l.config.MaxWait); err != nil {
+					l.releaseKey(key, keySem, false)
+					return l.shed(ctx)
+				}
+			}
+		}
+
+		if l.global != nil {
+			if err := l.global.Acquire(ctx, l.config.MaxWait); err != nil {
+				if keySem != nil {
+					l.releaseKey(key, keySem, true)
+				}
+				return l.shed(ctx)
+			}
+		}
+
+		ctx.After(func() {
+			if l.global != nil {
+				l.global.Release()
+			}
+			if keySem != nil {
+				l.releaseKey(key, keySem, true)
+			}
+		})
+
+		return nil
+	}
+}
+
+func (l *Limiter) shed(ctx *beehive.Context) beehive.Responder {
+	ctx.ResponseWriter.Header()["Retry-After"] = l.headerRetryAfter
+	return l.config.Responder
+}
+
+func (l *Limiter) acquireKey(key string) *keySemaphore {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	sem := l.keys[key]
+	if sem == nil {
+		sem = &keySemaphore{Semaphore: NewSemaphore(l.config.KeyLimit, l.config.MaxQueue)}
+		l.keys[key] = sem
+	}
+	sem.refs++
+
+	return sem
+}
+
+func (l *Limiter) releaseKey(key string, sem *keySemaphore, acquired bool) {
+	if acquired {
+		sem.Release()
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	sem.refs--
+	if sem.refs == 0 {
+		delete(l.keys, key)
+	}
+}
+
+// InFlight returns the number of requests currently holding a global slot, or 0 without a global limit.
+func (l *Limiter) InFlight() int {
+	if l.global == nil {
+		return 0
+	}
+	return l.global.InFlight()
+}
+
+// Queued returns the number of requests waiting for a global slot, or 0 without a global limit.
+func (l *Limiter) Queued() int {
+	if l.global == nil {
+		return 0
+	}
+	return l.global.Queued()
+}
+
+// KeyInFlight returns the number of requests currently holding a slot for the given key.
+func (l *Limiter) KeyInFlight(key string) int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if sem := l.keys[key]; sem != nil {
+		return sem.InFlight()
+	}
+	return 0
+}
+
+// KeyQueued returns the number of requests waiting for a slot for the given key.
+func (l *Limiter) KeyQueued(key string) int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if sem := l.keys[key]; sem != nil {
+		return sem.Queued()
+	}
+	return 0
+}
@@ -0,0 +1,197 @@
+package beehive_concurrency
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"go.sdls.io/beehive/pkg/beehive"
+	beehiveRate "go.sdls.io/beehive/pkg/beehive-rate"
+)
+
+type testBlockingRouter struct {
+	router  *beehive.Router
+	release chan struct{}
+	wg      sync.WaitGroup
+}
+
+func newTestBlockingRouter(handlers ...beehive.HandlerFunc) *testBlockingRouter {
+	tr := &testBlockingRouter{
+		router:  beehive.NewRouter(),
+		release: make(chan struct{}),
+	}
+
+	handlers = append(handlers, func(_ *beehive.Context) beehive.Responder {
+		<-tr.release
+		return &beehive.DefaultResponder{Message: "ok", Status: http.StatusOK}
+	})
+
+	tr.router.Handle("GET", "/slow/*", handlers...)
+
+	return tr
+}
+
+func (tr *testBlockingRouter) serve(path string, header http.Header, codes chan<- int) {
+	tr.wg.Add(1)
+	go func() {
+		defer tr.wg.Done()
+
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest("GET", path, nil)
+		for k, v := range header {
+			r.Header[k] = v
+		}
+
+		tr.router.ServeHTTP(w, r)
+		codes <- w.Code
+	}()
+}
+
+func TestLimiter_global(t *testing.T) {
+	t.Parallel()
+
+	limiter := NewLimiter(Config{
+		Limit:      2,
+		MaxQueue:   1,
+		RetryAfter: 3 * time.Second,
+	})
+	tr := newTestBlockingRouter(limiter.HandlerFunc())
+
+	codes := make(chan int, 4)
+	for range 3 {
+		tr.serve("/slow/a", nil, codes)
+	}
+
+	testWaitFor(t, func() bool { return limiter.InFlight() == 2 && limiter.Queued() == 1 })
+
+	w := httptest.NewRecorder()
+	tr.router.ServeHTTP(w, httptest.NewRequest("GET", "/slow/a", nil))
+
+	if w.Code != http.StatusServiceUnavailable {
+		t.Errorf("expected status code %d, got %d", http.StatusServiceUnavailable, w.Code)
+	}
+	if w.Header().Get("Retry-After") != "3" {
+		t.Errorf("expected Retry-After %q, got %q", "3", w.Header().Get("Retry-After"))
+	}
+
+	close(tr.release)
+	tr.wg.Wait()
+	close(codes)
+
+	for code := range codes {
+		if code != http.StatusOK {
+			t.Errorf("expected status code %d, got %d", http.StatusOK, code)
+		}
+	}
+
+	if limiter.InFlight() != 0 || limiter.Queued() != 0 {
+		t.Errorf("expected no requests in flight, got %d and %d queued", limiter.InFlight(), limiter.Queued())
+	}
+}
+
+func TestLimiter_maxWait(t *testing.T) {
+	t.Parallel()
+
+	limiter := NewLimiter(Config{
+		Limit:    1,
+		MaxQueue: 1,
+		MaxWait:  10 * time.Millisecond,
+		Responder: &beehive.DefaultResponder{
+			Message: "busy",
+			Status:  http.StatusTooManyRequests,
+		},
+	})
+	tr := newTestBlockingRouter(limiter.HandlerFunc())
+
+	codes := make(chan int, 1)
+	tr.serve("/slow/a", nil, codes)
+	testWaitFor(t, func() bool { return limiter.InFlight() == 1 })
+
+	w := httptest.NewRecorder()
+	tr.router.ServeHTTP(w, httptest.NewRequest("GET", "/slow/a", nil))
+
+	if w.Code != http.StatusTooManyRequests || w.Body.String() != "busy" {
+		t.Errorf("expected custom responder, got %d %q", w.Code, w.Body.String())
+	}
+
+	close(tr.release)
+	tr.wg.Wait()
+}
+
+func TestLimiter_key(t *testing.T) {
+	t.Parallel()
+
+	limiter := NewLimiter(Config{
+		KeyLimit: 1,
+		Key:      beehiveRate.KeyHeader("X-User"),
+	})
+	tr := newTestBlockingRouter(limiter.HandlerFunc())
+
+	codes := make(chan int, 3)
+	tr.serve("/slow/a", http.Header{"X-User": {"alice"}}, codes)
+	tr.serve("/slow/a", http.Header{"X-User": {"bob"}}, codes)
+	tr.serve("/slow/a", nil, codes)
+
+	testWaitFor(t, func() bool { return limiter.KeyInFlight("alice") == 1 && limiter.KeyInFlight("bob") == 1 })
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest("GET", "/slow/b", nil)
+	r.Header.Set("X-User", "alice")
+	tr.router.ServeHTTP(w, r)
+
+	if w.Code != http.StatusServiceUnavailable {
+		t.Errorf("expected status code %d, got %d", http.StatusServiceUnavailable, w.Code)
+	}
+	if limiter.KeyQueued("alice") != 0 {
+		t.Errorf("expected no queue, got %d", limiter.KeyQueued("alice"))
+	}
+
+	close(tr.release)
+	tr.wg.Wait()
+	close(codes)
+
+	for code := range codes {
+		if code != http.StatusOK {
+			t.Errorf("expected status code %d, got %d", http.StatusOK, code)
+		}
+	}
+
+	limiter.mu.Lock()
+	defer limiter.mu.Unlock()
+	if len(limiter.keys) != 0 {
+		t.Errorf("expected key semaphores to be removed, got %d", len(limiter.keys))
+	}
+}
+
+func TestLimiter_keyAndGlobal(t *testing.T) {
+	t.Parallel()
+
+	limiter := NewLimiter(Config{
+		Limit:    1,
+		KeyLimit: 1,
+	})
+	tr := newTestBlockingRouter(limiter.HandlerFunc())
+
+	codes := make(chan int, 1)
+	tr.serve("/slow/a", nil, codes)
+	testWaitFor(t, func() bool { return limiter.InFlight() == 1 })
+
+	if limiter.KeyInFlight("GET /slow/*") != 1 {
+		t.Errorf("expected route key to be in flight, got %d", limiter.KeyInFlight("GET /slow/*"))
+	}
+
+	w := httptest.NewRecorder()
+	tr.router.ServeHTTP(w, httptest.NewRequest("GET", "/slow/b", nil))
+	if w.Code != http.StatusServiceUnavailable {
+		t.Errorf("expected status code %d, got %d", http.StatusServiceUnavailable, w.Code)
+	}
+
+	close(tr.release)
+	tr.wg.Wait()
+
+	if limiter.InFlight() != 0 || limiter.KeyInFlight("GET /slow/*") != 0 {
+		t.Errorf("expected all slots to be released")
+	}
+}
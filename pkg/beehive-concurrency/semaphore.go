@@ -0,0 +1,135 @@
+package beehive_concurrency
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+var (
+	// ErrQueueFull is returned by Semaphore.Acquire when no slot is available and the queue is full.
+	ErrQueueFull = errors.New("beehive-concurrency: queue full")
+
+	// ErrWaitTimeout is returned by Semaphore.Acquire when no slot became available within the maximum wait.
+	ErrWaitTimeout = errors.New("beehive-concurrency: wait timeout")
+)
+
+// Semaphore bounds the number of concurrent holders to Limit, with a bounded FIFO queue for callers waiting for a
+// slot. The limit can be changed at any time with SetLimit. Semaphore is safe for concurrent use.
+type Semaphore struct {
+	mu       sync.Mutex
+	limit    int
+	maxQueue int
+	inFlight int
+	waiters  []chan struct{}
+}
+
+// NewSemaphore returns a Semaphore allowing limit concurrent holders and at most maxQueue waiting callers.
+func NewSemaphore(limit, maxQueue int) *Semaphore {
+	return &Semaphore{
+		limit:    limit,
+		maxQueue: maxQueue,
+	}
+}
+
+// Acquire takes a slot, waiting in the queue if none is available. Acquire returns ErrQueueFull if the queue is full,
+// ErrWaitTimeout if maxWait (when not zero) passed or the ctx error if ctx is done before a slot was taken. Every
+// successful Acquire must be followed by a Release.
+func (s *Semaphore) Acquire(ctx context.Context, maxWait time.Duration) error {
+	s.mu.Lock()
+	if s.inFlight < s.limit && len(s.waiters) == 0 {
+		s.inFlight++
+		s.mu.Unlock()
+		return nil
+	}
+
+	if len(s.waiters) >= s.maxQueue {
+		s.mu.Unlock()
+		return ErrQueueFull
+	}
+
+	ready := make(chan struct{})
+	s.waiters = append(s.waiters, ready)
+	s.mu.Unlock()
+
+	var timeout <-chan time.Time
+	if maxWait > 0 {
+		timer := time.NewTimer(maxWait)
+		defer timer.Stop()
+		timeout = timer.C
+	}
+
+	var err error
+	select {
+	case <-ready:
+		return nil
+	case <-ctx.Done():
+		err = ctx.Err()
+	case <-timeout:
+		err = ErrWaitTimeout
+	}
+
+	s.mu.Lock()
+	for idx, waiter := range s.waiters {
+		if waiter == ready {
+			s.waiters = append(s.waiters[:idx], s.waiters[idx+1:]...)
+			s.mu.Unlock()
+			return err
+		}
+	}
+	s.mu.Unlock()
+
+	// the slot was granted while giving up, hand it over to the next waiter
+	s.Release()
+
+	return err
+}
+
+// Release frees a slot taken by Acquire and hands it to the next waiting caller, if any.
+func (s *Semaphore) Release() {
+	s.mu.Lock()
+	s.inFlight--
+	s.grant()
+	s.mu.Unlock()
+}
+
+func (s *Semaphore) grant() {
+	for s.inFlight < s.limit && len(s.waiters) != 0 {
+		ready := s.waiters[0]
+		s.waiters[0] = nil
+		s.waiters = s.waiters[1:]
+		s.inFlight++
+		close(ready)
+	}
+}
+
+// SetLimit changes the number of concurrent holders. Lowering the limit does not affect current holders, new holders
+// are only admitted once enough of them Release.
+func (s *Semaphore) SetLimit(limit int) {
+	s.mu.Lock()
+	s.limit = limit
+	s.grant()
+	s.mu.Unlock()
+}
+
+// Limit returns the current limit.
+func (s *Semaphore) Limit() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.limit
+}
+
+// InFlight returns the number of slots currently taken.
+func (s *Semaphore) InFlight() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.inFlight
+}
+
+// Queued returns the number of callers waiting for a slot.
+func (s *Semaphore) Queued() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.waiters)
+}
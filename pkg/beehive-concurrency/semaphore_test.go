@@ -0,0 +1,132 @@
+package beehive_concurrency
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func testWaitFor(t *testing.T, cond func() bool) {
+	t.Helper()
+
+	deadline := time.Now().Add(time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatal("condition not met in time")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestSemaphore(t *testing.T) {
+	t.Parallel()
+
+	sem := NewSemaphore(2, 1)
+	ctx := t.Context()
+
+	if err := sem.Acquire(ctx, 0); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if err := sem.Acquire(ctx, 0); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if sem.InFlight() != 2 {
+		t.Errorf("expected %d in flight, got %d", 2, sem.InFlight())
+	}
+
+	acquired := make(chan error)
+	go func() {
+		acquired <- sem.Acquire(ctx, 0)
+	}()
+
+	testWaitFor(t, func() bool { return sem.Queued() == 1 })
+
+	if err := sem.Acquire(ctx, 0); !errors.Is(err, ErrQueueFull) {
+		t.Errorf("expected %v, got %v", ErrQueueFull, err)
+	}
+
+	sem.Release()
+	if err := <-acquired; err != nil {
+		t.Errorf("unexpected error %v", err)
+	}
+	if sem.InFlight() != 2 || sem.Queued() != 0 {
+		t.Errorf("expected slot to be handed over, got %d in flight and %d queued", sem.InFlight(), sem.Queued())
+	}
+}
+
+func TestSemaphore_waitTimeout(t *testing.T) {
+	t.Parallel()
+
+	sem := NewSemaphore(1, 1)
+	_ = sem.Acquire(t.Context(), 0)
+
+	if err := sem.Acquire(t.Context(), 10*time.Millisecond); !errors.Is(err, ErrWaitTimeout) {
+		t.Errorf("expected %v, got %v", ErrWaitTimeout, err)
+	}
+	if sem.Queued() != 0 {
+		t.Errorf("expected empty queue, got %d", sem.Queued())
+	}
+}
+
+func TestSemaphore_contextDone(t *testing.T) {
+	t.Parallel()
+
+	sem := NewSemaphore(1, 1)
+	_ = sem.Acquire(t.Context(), 0)
+
+	ctx, cancel := context.WithCancel(t.Context())
+	acquired := make(chan error)
+	go func() {
+		acquired <- sem.Acquire(ctx, 0)
+	}()
+
+	testWaitFor(t, func() bool { return sem.Queued() == 1 })
+	cancel()
+
+	if err := <-acquired; !errors.Is(err, context.Canceled) {
+		t.Errorf("expected %v, got %v", context.Canceled, err)
+	}
+	if sem.Queued() != 0 || sem.InFlight() != 1 {
+		t.Errorf("expected %d in flight and no queue, got %d and %d", 1, sem.InFlight(), sem.Queued())
+	}
+}
+
+func TestSemaphore_SetLimit(t *testing.T) {
+	t.Parallel()
+
+	sem := NewSemaphore(1, 2)
+	_ = sem.Acquire(t.Context(), 0)
+
+	acquired := make(chan error, 2)
+	for range 2 {
+		go func() {
+			acquired <- sem.Acquire(t.Context(), 0)
+		}()
+	}
+
+	testWaitFor(t, func() bool { return sem.Queued() == 2 })
+
+	sem.SetLimit(3)
+	for range 2 {
+		if err := <-acquired; err != nil {
+			t.Errorf("unexpected error %v", err)
+		}
+	}
+
+	if sem.Limit() != 3 || sem.InFlight() != 3 {
+		t.Errorf("expected limit and in flight %d, got %d and %d", 3, sem.Limit(), sem.InFlight())
+	}
+
+	sem.SetLimit(1)
+	sem.Release()
+	sem.Release()
+	if err := sem.Acquire(t.Context(), time.Millisecond); !errors.Is(err, ErrWaitTimeout) {
+		t.Errorf("expected lowered limit to apply, got %v", err)
+	}
+
+	sem.Release()
+	if err := sem.Acquire(t.Context(), 0); err != nil {
+		t.Errorf("unexpected error %v", err)
+	}
+}
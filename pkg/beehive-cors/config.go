@@ -3,28 +3,97 @@ package beehive_cors
 import (
 	"net/http"
 	"net/url"
+	"regexp"
+	"slices"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"go.sdls.io/beehive/pkg/beehive"
 	beehiveResponder "go.sdls.io/beehive/pkg/beehive-responder"
 )
 
+// Config describes the CORS policy applied to a group of routes.
 type Config struct {
+	// AllowHosts are host names allowed regardless of the origin scheme and port.
 	AllowHosts []string
 
-	AllowMethods     []string
-	AllowHeaders     []string
+	// AllowOrigins are origin patterns matched against the origin scheme, host and port. A pattern has the form
+	// scheme://host[:port], the host can start with "*." to match any subdomain (but not the domain itself) and the
+	// port can be "*" to match any port. When the port is omitted, the default port of the scheme is expected. The
+	// pattern "*" matches any origin. Invalid patterns cause a panic when the Config is first used.
+	AllowOrigins []string
+
+	// AllowOriginRegex are matched against the whole origin, as sent by the browser.
+	AllowOriginRegex []*regexp.Regexp
+
+	// AllowOriginFunc is called for origins that are not allowed by any of the other rules. The request is allowed if
+	// it returns true.
+	AllowOriginFunc func(ctx *beehive.Context, origin string) bool
+
+	// AllowMethods are the methods allowed for cross-origin requests, "*" allows any method. Preflight requests are
+	// answered with the methods registered for the requested path that are allowed, the CORS-safelisted methods GET,
+	// HEAD and POST are always allowed.
+	AllowMethods []string
+
+	// AllowHeaders are sent in Access-Control-Allow-Headers and used to validate the preflight
+	// Access-Control-Request-Headers (case-insensitive). "*" allows any header.
+	AllowHeaders []string
+
+	// ExposeHeaders are sent in Access-Control-Expose-Headers on actual (non preflight) requests, such that scripts
+	// can read them. Browsers treat "*" literally on credentialed requests.
+	ExposeHeaders []string
+
+	// ReflectRequestHeaders sends back the preflight Access-Control-Request-Headers as Access-Control-Allow-Headers,
+	// allowing any header. This is also done for AllowHeaders "*" when AllowCredentials is set, as browsers do not
+	// accept the wildcard on credentialed requests.
+	ReflectRequestHeaders bool
+
+	// AllowCredentials sends Access-Control-Allow-Credentials. It cannot be combined with the AllowOrigins pattern "*",
+	// which would let any website make credentialed requests, HandlerFunc panics in that case.
 	AllowCredentials bool
-	MaxAge           time.Duration
+
+	// AllowPrivateNetwork answers preflight requests sent with Access-Control-Request-Private-Network with
+	// Access-Control-Allow-Private-Network, allowing public websites to reach this (private network) server.
+	AllowPrivateNetwork bool
+
+	// MaxAge is sent in Access-Control-Max-Age on preflight requests.
+	MaxAge time.Duration
+
+	// Lenient lets requests from disallowed origins, or preflight requests asking for disallowed methods or headers,
+	// proceed without any CORS header instead of failing them. The browser then enforces the policy, while
+	// same-origin navigations and server-to-server calls sending an Origin header keep working.
+	Lenient bool
+
+	// Forbidden is returned for disallowed requests when Lenient is not set. If nil, a 403 Forbidden with the
+	// message "cors forbidden" is used.
+	Forbidden beehive.Responder
+
+	once    sync.Once
+	origins []originPattern
 }
 
+var defaultForbiddenResponder = &beehive.DefaultResponder{
+	Message: "cors forbidden",
+	Status:  http.StatusForbidden,
+}
+
+// Apply returns a Grouper applying the CORS policy on every route registered through it. For every registered path,
+// an OPTIONS route answering the preflight requests is registered on the given group as well, such that different
+// Configs can be applied to different groups of the same Router. Paths handling OPTIONS themselves must do so before
+// any other method is registered for them.
 func (c *Config) Apply(group beehive.Grouper) beehive.Grouper {
-	group.Handle(http.MethodOptions, "*", c.HandlerFunc(true))
-	return group.Group("", c.HandlerFunc(false))
+	return &corsGroup{
+		parent:    group,
+		actual:    group.Group("", c.HandlerFunc(false)),
+		preflight: c.HandlerFunc(true),
+		paths:     make(map[string]bool),
+	}
 }
 
+// Allow reports whether the origin is allowed by AllowHosts, AllowOrigins or AllowOriginRegex. AllowOriginFunc is not
+// consulted as it requires the request context.
 func (c *Config) Allow(origin string) bool {
 	u, err := url.Parse(origin)
 	if err != nil {
@@ -39,23 +108,94 @@ func (c *Config) Allow(origin string) bool {
 		}
 	}
 
+	for _, pattern := range c.compile() {
+		if pattern.match(u) {
+			return true
+		}
+	}
+
+	for _, re := range c.AllowOriginRegex {
+		if re.MatchString(origin) {
+			return true
+		}
+	}
+
 	return false
 }
 
+func (c *Config) allow(ctx *beehive.Context, origin string) bool {
+	if c.Allow(origin) {
+		return true
+	}
+
+	return c.AllowOriginFunc != nil && c.AllowOriginFunc(ctx, origin)
+}
+
+func (c *Config) compile() []originPattern {
+	c.once.Do(func() {
+		c.origins = make([]originPattern, len(c.AllowOrigins))
+		for idx, origin := range c.AllowOrigins {
+			pattern, err := parseOriginPattern(origin)
+			if err != nil {
+				panic("beehive-cors: " + err.Error())
+			}
+
+			c.origins[idx] = pattern
+		}
+	})
+
+	return c.origins
+}
+
+// HandlerFunc returns the CORS beehive.HandlerFunc. With preFlight, the returned HandlerFunc answers preflight
+// requests and ends the chain with a 204 No Content, otherwise it only sets the CORS headers for the actual request
+// and continues the chain. Requests without an Origin header are not affected. Disallowed requests get the Forbidden
+// Responder, unless Lenient is set.
 func (c *Config) HandlerFunc(preFlight bool) beehive.HandlerFunc {
 	var responderAllow beehive.Responder
 	if preFlight {
 		responderAllow = &beehiveResponder.Status{Code: http.StatusNoContent}
 	}
 
-	responderForbidden := &beehive.DefaultResponder{
-		Message: "cors forbidden",
-		Status:  http.StatusForbidden,
+	// in lenient mode, disallowed requests continue without the CORS headers, preflight requests are still answered
+	// but the browser rejects the response
+	responderForbidden := c.Forbidden
+	if c.Lenient {
+		responderForbidden = responderAllow
+	} else if responderForbidden == nil {
+		responderForbidden = defaultForbiddenResponder
+	}
+
+	c.compile()
+
+	if c.AllowCredentials && slices.Contains(c.AllowOrigins, "*") {
+		panic(`beehive-cors: AllowOrigins "*" cannot be combined with AllowCredentials`)
+	}
+
+	allowMethods := make(map[string]bool, len(c.AllowMethods))
+	allowMethodsAny := false
+	for _, method := range c.AllowMethods {
+		allowMethods[method] = true
+		allowMethodsAny = allowMethodsAny || method == "*"
 	}
 
-	headerAllowMethods := []string{strings.Join(c.AllowMethods, ", ")}
+	allowHeaders := make(map[string]bool, len(c.AllowHeaders))
+	allowHeadersAny := false
+	for _, header := range c.AllowHeaders {
+		allowHeaders[strings.ToLower(header)] = true
+		allowHeadersAny = allowHeadersAny || header == "*"
+	}
+
+	// with credentials, browsers take "*" literally, so the requested headers are reflected instead
+	reflectHeaders := c.ReflectRequestHeaders || (allowHeadersAny && c.AllowCredentials)
+
 	headerAllowHeaders := []string{strings.Join(c.AllowHeaders, ", ")}
 
+	var headerExposeHeaders []string
+	if len(c.ExposeHeaders) > 0 {
+		headerExposeHeaders = []string{strings.Join(c.ExposeHeaders, ", ")}
+	}
+
 	var headerMaxAge []string
 	if c.MaxAge > 0 {
 		headerMaxAge = []string{strconv.Itoa(int(c.MaxAge.Seconds()))}
@@ -66,27 +206,84 @@ func (c *Config) HandlerFunc(preFlight bool) beehive.HandlerFunc {
 		headerAllowCredentials = []string{"true"}
 	}
 
+	headerVaryPreflight := "Origin, Access-Control-Request-Method, Access-Control-Request-Headers"
+	if c.AllowPrivateNetwork {
+		headerVaryPreflight += ", Access-Control-Request-Private-Network"
+	}
+
 	return func(ctx *beehive.Context) beehive.Responder {
-		origin := ctx.Request.Header.Get("Origin")
+		r := ctx.Request
+		h := ctx.ResponseWriter.Header()
+
+		requestMethod := r.Header.Get("Access-Control-Request-Method")
+		isPreflight := preFlight && r.Method == http.MethodOptions && requestMethod != ""
+
+		if isPreflight {
+			h.Add("Vary", headerVaryPreflight)
+		} else {
+			h.Add("Vary", "Origin")
+		}
+
+		origin := r.Header.Get("Origin")
 		if origin == "" {
 			return nil
 		}
-		if !c.Allow(origin) {
+		if !c.allow(ctx, origin) {
 			return responderForbidden
 		}
 
-		w := ctx.ResponseWriter
-		h := w.Header()
+		if isPreflight {
+			// only the methods registered for the path, and allowed by the Config, are advertised
+			var methods []string
+			requestMethodAllowed := false
+			for _, method := range ctx.Router().Methods(r.URL.Path) {
+				if method == http.MethodOptions {
+					continue
+				}
+				if allowMethodsAny || allowMethods[method] || isSafelistedMethod(method) {
+					methods = append(methods, method)
+					requestMethodAllowed = requestMethodAllowed || method == requestMethod
+				}
+			}
 
-		h.Add("Vary", "Origin")
+			if !requestMethodAllowed {
+				return responderForbidden
+			}
 
-		h["Access-Control-Allow-Origin"] = []string{origin}
-		h["Access-Control-Allow-Methods"] = headerAllowMethods
-		h["Access-Control-Allow-Headers"] = headerAllowHeaders
+			requestHeaders := r.Header.Values("Access-Control-Request-Headers")
+			if !reflectHeaders && !allowHeadersAny {
+				for _, header := range requestHeaders {
+					for _, name := range strings.Split(header, ",") {
+						name = strings.ToLower(strings.TrimSpace(name))
+						if name != "" && !allowHeaders[name] && !isSafelistedHeader(name) {
+							return responderForbidden
+						}
+					}
+				}
+			}
 
-		if len(headerMaxAge) > 0 {
-			h["Access-Control-Max-Age"] = headerMaxAge
+			h["Access-Control-Allow-Methods"] = []string{strings.Join(methods, ", ")}
+
+			if reflectHeaders {
+				if len(requestHeaders) > 0 {
+					h["Access-Control-Allow-Headers"] = []string{strings.Join(requestHeaders, ", ")}
+				}
+			} else if len(c.AllowHeaders) > 0 {
+				h["Access-Control-Allow-Headers"] = headerAllowHeaders
+			}
+
+			if len(headerMaxAge) > 0 {
+				h["Access-Control-Max-Age"] = headerMaxAge
+			}
+
+			if c.AllowPrivateNetwork && r.Header.Get("Access-Control-Request-Private-Network") == "true" {
+				h["Access-Control-Allow-Private-Network"] = []string{"true"}
+			}
+		} else if len(headerExposeHeaders) > 0 {
+			h["Access-Control-Expose-Headers"] = headerExposeHeaders
 		}
+
+		h["Access-Control-Allow-Origin"] = []string{origin}
 		if len(headerAllowCredentials) > 0 {
 			h["Access-Control-Allow-Credentials"] = headerAllowCredentials
 		}
@@ -94,3 +291,18 @@ func (c *Config) HandlerFunc(preFlight bool) beehive.HandlerFunc {
 		return responderAllow
 	}
 }
+
+// isSafelistedMethod reports whether the method is a CORS-safelisted method, which is always allowed.
+func isSafelistedMethod(method string) bool {
+	return method == http.MethodGet || method == http.MethodHead || method == http.MethodPost
+}
+
+// isSafelistedHeader reports whether the lower case header name is a CORS-safelisted request header.
+func isSafelistedHeader(name string) bool {
+	switch name {
+	case "accept", "accept-language", "content-language", "content-type":
+		return true
+	default:
+		return false
+	}
+}
@@ -1,8 +1,10 @@
 package beehive_cors
 
 import (
+	"context"
 	"net/http"
 	"net/http/httptest"
+	"regexp"
 	"testing"
 	"time"
 
@@ -17,6 +19,7 @@ func TestCORS(t *testing.T) {
 		AllowHosts:       []string{"example.com", "dashboard.example.com", "api.example.net"},
 		AllowMethods:     []string{"GET", "POST", "PUT", "DELETE"},
 		AllowHeaders:     []string{"*"},
+		ExposeHeaders:    []string{"X-Request-Id"},
 		AllowCredentials: true,
 		MaxAge:           time.Second * 3600,
 	}
@@ -31,6 +34,9 @@ func TestCORS(t *testing.T) {
 	corsGroup.Handle("GET", "/foo/bar", func(_ *beehive.Context) beehive.Responder {
 		return ok
 	})
+	corsGroup.Handle("PUT", "/foo/bar", func(_ *beehive.Context) beehive.Responder {
+		return ok
+	})
 
 	t.Run("pass", func(t *testing.T) {
 		t.Parallel()
@@ -48,17 +54,19 @@ func TestCORS(t *testing.T) {
 		if h.Get("Access-Control-Allow-Origin") != "https://example.com" {
 			t.Errorf("expected Access-Control-Allow-Origin %s, got %s", "https://example.com", h.Get("Access-Control-Allow-Origin"))
 		}
-		if h.Get("Access-Control-Allow-Methods") != "GET, POST, PUT, DELETE" {
-			t.Errorf("expected Access-Control-Allow-Methods %s, got %s", "GET, POST, PUT, DELETE", h.Get("Access-Control-Allow-Methods"))
+		for _, header := range []string{"Access-Control-Allow-Methods", "Access-Control-Allow-Headers", "Access-Control-Max-Age"} {
+			if h.Get(header) != "" {
+				t.Errorf("expected no %s on actual request, got %s", header, h.Get(header))
+			}
 		}
-		if h.Get("Access-Control-Allow-Headers") != "*" {
-			t.Errorf("expected Access-Control-Allow-Headers %s, got %s", "*", h.Get("Access-Control-Allow-Headers"))
+		if h.Get("Access-Control-Expose-Headers") != "X-Request-Id" {
+			t.Errorf("expected Access-Control-Expose-Headers %s, got %s", "X-Request-Id", h.Get("Access-Control-Expose-Headers"))
 		}
 		if h.Get("Access-Control-Allow-Credentials") != "true" {
 			t.Errorf("expected Access-Control-Allow-Credentials %s, got %s", "true", h.Get("Access-Control-Allow-Credentials"))
 		}
-		if h.Get("Access-Control-Max-Age") != "3600" {
-			t.Errorf("expected Access-Control-Max-Age %s, got %s", "3600", h.Get("Access-Control-Max-Age"))
+		if h.Get("Vary") != "Origin" {
+			t.Errorf("expected Vary %s, got %s", "Origin", h.Get("Vary"))
 		}
 	})
 	t.Run("deny", func(t *testing.T) {
@@ -102,6 +110,8 @@ func TestCORS(t *testing.T) {
 
 		r := httptest.NewRequest("OPTIONS", "/foo/bar", nil)
 		r.Header.Set("Origin", "https://example.com")
+		r.Header.Set("Access-Control-Request-Method", "PUT")
+		r.Header.Set("Access-Control-Request-Headers", "X-Custom")
 		w := httptest.NewRecorder()
 		router.ServeHTTP(w, r)
 
@@ -113,11 +123,11 @@ func TestCORS(t *testing.T) {
 		if h.Get("Access-Control-Allow-Origin") != "https://example.com" {
 			t.Errorf("expected Access-Control-Allow-Origin %s, got %s", "https://example.com", h.Get("Access-Control-Allow-Origin"))
 		}
-		if h.Get("Access-Control-Allow-Methods") != "GET, POST, PUT, DELETE" {
-			t.Errorf("expected Access-Control-Allow-Methods %s, got %s", "GET, POST, PUT, DELETE", h.Get("Access-Control-Allow-Methods"))
+		if h.Get("Access-Control-Allow-Methods") != "GET, PUT" {
+			t.Errorf("expected Access-Control-Allow-Methods %s, got %s", "GET, PUT", h.Get("Access-Control-Allow-Methods"))
 		}
-		if h.Get("Access-Control-Allow-Headers") != "*" {
-			t.Errorf("expected Access-Control-Allow-Headers %s, got %s", "*", h.Get("Access-Control-Allow-Headers"))
+		if h.Get("Access-Control-Allow-Headers") != "X-Custom" {
+			t.Errorf("expected Access-Control-Allow-Headers %s, got %s", "X-Custom", h.Get("Access-Control-Allow-Headers"))
 		}
 		if h.Get("Access-Control-Allow-Credentials") != "true" {
 			t.Errorf("expected Access-Control-Allow-Credentials %s, got %s", "true", h.Get("Access-Control-Allow-Credentials"))
@@ -125,6 +135,12 @@ func TestCORS(t *testing.T) {
 		if h.Get("Access-Control-Max-Age") != "3600" {
 			t.Errorf("expected Access-Control-Max-Age %s, got %s", "3600", h.Get("Access-Control-Max-Age"))
 		}
+		if h.Get("Access-Control-Expose-Headers") != "" {
+			t.Errorf("expected no Access-Control-Expose-Headers on preflight, got %s", h.Get("Access-Control-Expose-Headers"))
+		}
+		if h.Get("Vary") != "Origin, Access-Control-Request-Method, Access-Control-Request-Headers" {
+			t.Errorf("expected Vary %s, got %s", "Origin, Access-Control-Request-Method, Access-Control-Request-Headers", h.Get("Vary"))
+		}
 	})
 	t.Run("deny OPTIONS", func(t *testing.T) {
 		t.Parallel()
@@ -139,3 +155,359 @@ func TestCORS(t *testing.T) {
 		}
 	})
 }
+
+func TestCORS_preflight(t *testing.T) {
+	t.Parallel()
+
+	config := &Config{
+		AllowOrigins:        []string{"*"},
+		AllowMethods:        []string{"PUT", "PATCH"},
+		AllowHeaders:        []string{"Authorization", "X-Custom"},
+		AllowPrivateNetwork: true,
+	}
+
+	router := beehive.NewRouter()
+	config.Apply(router).HandleAny([]string{"PUT", "PATCH", "POST", "DELETE"}, "/foo", func(_ *beehive.Context) beehive.Responder {
+		return &beehive.DefaultResponder{Message: "ok", Status: http.StatusOK}
+	})
+
+	tests := []struct {
+		name    string
+		header  http.Header
+		code    int
+		headers map[string]string
+	}{
+		{
+			name:   "allowed method and headers",
+			header: http.Header{"Access-Control-Request-Method": {"PATCH"}, "Access-Control-Request-Headers": {"authorization, x-custom"}},
+			code:   http.StatusNoContent,
+			headers: map[string]string{
+				"Access-Control-Allow-Methods": "PUT, PATCH, POST",
+				"Access-Control-Allow-Headers": "Authorization, X-Custom",
+			},
+		},
+		{
+			name:   "safelisted method and headers",
+			header: http.Header{"Access-Control-Request-Method": {"POST"}, "Access-Control-Request-Headers": {"Content-Type"}},
+			code:   http.StatusNoContent,
+		},
+		{
+			name:   "denied method",
+			header: http.Header{"Access-Control-Request-Method": {"DELETE"}},
+			code:   http.StatusForbidden,
+		},
+		{
+			name:   "unregistered method",
+			header: http.Header{"Access-Control-Request-Method": {"GET"}},
+			code:   http.StatusForbidden,
+		},
+		{
+			name:   "denied header",
+			header: http.Header{"Access-Control-Request-Method": {"PUT"}, "Access-Control-Request-Headers": {"X-Custom, X-Other"}},
+			code:   http.StatusForbidden,
+		},
+		{
+			name:   "private network",
+			header: http.Header{"Access-Control-Request-Method": {"PUT"}, "Access-Control-Request-Private-Network": {"true"}},
+			code:   http.StatusNoContent,
+			headers: map[string]string{
+				"Access-Control-Allow-Private-Network": "true",
+				"Vary":                                 "Origin, Access-Control-Request-Method, Access-Control-Request-Headers, Access-Control-Request-Private-Network",
+			},
+		},
+		{
+			name:   "private network not requested",
+			header: http.Header{"Access-Control-Request-Method": {"PUT"}},
+			code:   http.StatusNoContent,
+			headers: map[string]string{
+				"Access-Control-Allow-Private-Network": "",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			r := httptest.NewRequest("OPTIONS", "/foo", nil)
+			r.Header = tt.header
+			r.Header.Set("Origin", "https://example.com")
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, r)
+
+			if w.Code != tt.code {
+				t.Errorf("expected status code %d, got %d", tt.code, w.Code)
+			}
+			for header, value := range tt.headers {
+				if w.Header().Get(header) != value {
+					t.Errorf("expected %s %q, got %q", header, value, w.Header().Get(header))
+				}
+			}
+		})
+	}
+}
+
+func TestCORS_reflect(t *testing.T) {
+	t.Parallel()
+
+	config := &Config{
+		AllowHosts:            []string{"example.com"},
+		AllowMethods:          []string{"*"},
+		ReflectRequestHeaders: true,
+		AllowCredentials:      true,
+	}
+
+	router := beehive.NewRouter()
+	config.Apply(router).Handle("DELETE", "/foo", func(_ *beehive.Context) beehive.Responder {
+		return &beehive.DefaultResponder{Message: "ok", Status: http.StatusOK}
+	})
+
+	r := httptest.NewRequest("OPTIONS", "/foo", nil)
+	r.Header.Set("Origin", "https://example.com")
+	r.Header.Set("Access-Control-Request-Method", "DELETE")
+	r.Header.Set("Access-Control-Request-Headers", "X-One, X-Two")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, r)
+
+	if w.Code != http.StatusNoContent {
+		t.Errorf("expected status code %d, got %d", http.StatusNoContent, w.Code)
+	}
+	if w.Header().Get("Access-Control-Allow-Methods") != "DELETE" {
+		t.Errorf("expected Access-Control-Allow-Methods %s, got %s", "DELETE", w.Header().Get("Access-Control-Allow-Methods"))
+	}
+	if w.Header().Get("Access-Control-Allow-Headers") != "X-One, X-Two" {
+		t.Errorf("expected Access-Control-Allow-Headers %s, got %s", "X-One, X-Two", w.Header().Get("Access-Control-Allow-Headers"))
+	}
+}
+
+func TestCORS_groups(t *testing.T) {
+	t.Parallel()
+
+	public := &Config{AllowOrigins: []string{"*"}, AllowMethods: []string{"*"}}
+	private := &Config{AllowHosts: []string{"admin.example.com"}, AllowMethods: []string{"GET", "DELETE"}}
+
+	handler := func(_ *beehive.Context) beehive.Responder {
+		return &beehive.DefaultResponder{Message: "ok", Status: http.StatusOK}
+	}
+
+	router := beehive.NewRouter()
+	api := public.Apply(router.Group("/api"))
+	api.Handle("GET", "/items", handler)
+	api.Group("/items").Handle("POST", "/new", handler).Handle("GET", "/new", handler)
+
+	admin := private.Apply(router.Group("/admin"))
+	admin.HandleAny([]string{"GET", "PUT", "DELETE"}, "/items/*", handler)
+
+	tests := []struct {
+		path, origin, method string
+		code                 int
+		methods              string
+	}{
+		{"/api/items", "https://example.org", "GET", http.StatusNoContent, "GET"},
+		{"/api/items", "https://example.org", "POST", http.StatusForbidden, ""},
+		{"/api/items/new", "https://example.org", "POST", http.StatusNoContent, "GET, POST"},
+		{"/admin/items/1", "https://admin.example.com", "DELETE", http.StatusNoContent, "GET, DELETE"},
+		{"/admin/items/1", "https://admin.example.com", "PUT", http.StatusForbidden, ""},
+		{"/admin/items/1", "https://example.org", "GET", http.StatusForbidden, ""},
+		{"/nope", "https://example.org", "GET", http.StatusNotFound, ""},
+	}
+
+	for _, tt := range tests {
+		r := httptest.NewRequest("OPTIONS", tt.path, nil)
+		r.Header.Set("Origin", tt.origin)
+		r.Header.Set("Access-Control-Request-Method", tt.method)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, r)
+
+		if w.Code != tt.code {
+			t.Errorf("%s %s: expected status code %d, got %d", tt.method, tt.path, tt.code, w.Code)
+		}
+		if w.Header().Get("Access-Control-Allow-Methods") != tt.methods {
+			t.Errorf("%s %s: expected Access-Control-Allow-Methods %q, got %q", tt.method, tt.path, tt.methods, w.Header().Get("Access-Control-Allow-Methods"))
+		}
+	}
+}
+
+func TestCORS_wildcardOverlap(t *testing.T) {
+	t.Parallel()
+
+	handler := func(_ *beehive.Context) beehive.Responder {
+		return &beehive.DefaultResponder{Message: "ok", Status: http.StatusOK}
+	}
+
+	router := beehive.NewRouter()
+	files := (&Config{AllowOrigins: []string{"*"}, AllowMethods: []string{"*"}}).Apply(router.Group("/files"))
+	files.Handle("GET", "/*", handler)
+	files.Handle("POST", "/upload", handler)
+	files.Group("/archive").Handle("DELETE", "/old", handler)
+
+	tests := []struct {
+		path, method string
+		code         int
+		methods      string
+	}{
+		{"/files/upload", "POST", http.StatusNoContent, "GET, POST"},
+		{"/files/a.txt", "GET", http.StatusNoContent, "GET"},
+		{"/files/archive/old", "DELETE", http.StatusNoContent, "GET, DELETE"},
+	}
+
+	for _, tt := range tests {
+		r := httptest.NewRequest("OPTIONS", tt.path, nil)
+		r.Header.Set("Origin", "https://example.org")
+		r.Header.Set("Access-Control-Request-Method", tt.method)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, r)
+
+		if w.Code != tt.code {
+			t.Errorf("%s %s: expected status code %d, got %d", tt.method, tt.path, tt.code, w.Code)
+		}
+		if w.Header().Get("Access-Control-Allow-Methods") != tt.methods {
+			t.Errorf("%s %s: expected Access-Control-Allow-Methods %q, got %q", tt.method, tt.path, tt.methods, w.Header().Get("Access-Control-Allow-Methods"))
+		}
+	}
+}
+
+func TestCORS_Lenient(t *testing.T) {
+	t.Parallel()
+
+	config := &Config{
+		AllowHosts:   []string{"example.com"},
+		AllowMethods: []string{"GET"},
+		Lenient:      true,
+	}
+
+	router := beehive.NewRouter()
+	config.Apply(router).HandleAny([]string{"GET", "DELETE"}, "/foo", func(_ *beehive.Context) beehive.Responder {
+		return &beehive.DefaultResponder{Message: "ok", Status: http.StatusOK}
+	})
+
+	tests := []struct {
+		method, origin, requestMethod string
+		code                          int
+		allowOrigin                   string
+	}{
+		{"GET", "https://example.com", "", http.StatusOK, "https://example.com"},
+		{"GET", "https://other.example.org", "", http.StatusOK, ""},
+		{"OPTIONS", "https://other.example.org", "GET", http.StatusNoContent, ""},
+		{"OPTIONS", "https://example.com", "DELETE", http.StatusNoContent, ""},
+	}
+
+	for _, tt := range tests {
+		r := httptest.NewRequest(tt.method, "/foo", nil)
+		r.Header.Set("Origin", tt.origin)
+		if tt.requestMethod != "" {
+			r.Header.Set("Access-Control-Request-Method", tt.requestMethod)
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, r)
+
+		if w.Code != tt.code {
+			t.Errorf("%s %s: expected status code %d, got %d", tt.method, tt.origin, tt.code, w.Code)
+		}
+		if w.Header().Get("Access-Control-Allow-Origin") != tt.allowOrigin {
+			t.Errorf("%s %s: expected Access-Control-Allow-Origin %q, got %q", tt.method, tt.origin, tt.allowOrigin, w.Header().Get("Access-Control-Allow-Origin"))
+		}
+		if tt.allowOrigin == "" && w.Header().Get("Access-Control-Allow-Methods") != "" {
+			t.Errorf("%s %s: expected no Access-Control-Allow-Methods, got %q", tt.method, tt.origin, w.Header().Get("Access-Control-Allow-Methods"))
+		}
+	}
+}
+
+func TestCORS_Forbidden(t *testing.T) {
+	t.Parallel()
+
+	config := &Config{
+		AllowHosts: []string{"example.com"},
+		Forbidden:  &beehive.DefaultResponder{Message: "origin not allowed", Status: http.StatusUnauthorized},
+	}
+
+	router := beehive.NewRouter()
+	config.Apply(router).Handle("GET", "/foo", func(_ *beehive.Context) beehive.Responder {
+		return &beehive.DefaultResponder{Message: "ok", Status: http.StatusOK}
+	})
+
+	r := httptest.NewRequest("GET", "/foo", nil)
+	r.Header.Set("Origin", "https://other.example.org")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, r)
+
+	if w.Code != http.StatusUnauthorized || w.Body.String() != "origin not allowed" {
+		t.Errorf("expected custom forbidden responder, got %d %q", w.Code, w.Body.String())
+	}
+}
+
+func TestCORS_origins(t *testing.T) {
+	t.Parallel()
+
+	type tenantKey struct{}
+
+	config := &Config{
+		AllowOrigins:     []string{"https://*.example.com", "http://localhost:*"},
+		AllowOriginRegex: []*regexp.Regexp{regexp.MustCompile(`^https://pr-\d+\.preview\.example\.net$`)},
+		AllowOriginFunc: func(ctx *beehive.Context, origin string) bool {
+			return ctx.Value(tenantKey{}) == origin
+		},
+		AllowMethods: []string{"GET"},
+	}
+
+	router := beehive.NewRouter()
+	config.Apply(router).Handle("GET", "/foo", func(ctx *beehive.Context) beehive.Responder {
+		return &beehive.DefaultResponder{Message: "ok", Status: http.StatusOK}
+	})
+
+	origins := map[string]int{
+		"https://app.example.com":           http.StatusOK,
+		"http://app.example.com":            http.StatusForbidden,
+		"https://example.com":               http.StatusForbidden,
+		"http://localhost:5173":             http.StatusOK,
+		"https://pr-42.preview.example.net": http.StatusOK,
+		"https://pr-x.preview.example.net":  http.StatusForbidden,
+		"https://tenant.example.org":        http.StatusOK,
+		"https://other.example.org":         http.StatusForbidden,
+	}
+
+	for origin, code := range origins {
+		r := httptest.NewRequest("GET", "/foo", nil)
+		r = r.WithContext(context.WithValue(r.Context(), tenantKey{}, "https://tenant.example.org"))
+		r.Header.Set("Origin", origin)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, r)
+
+		if w.Code != code {
+			t.Errorf("%s: expected status code %d, got %d", origin, code, w.Code)
+		}
+		if code == http.StatusOK && w.Header().Get("Access-Control-Allow-Origin") != origin {
+			t.Errorf("%s: expected Access-Control-Allow-Origin %s, got %s", origin, origin, w.Header().Get("Access-Control-Allow-Origin"))
+		}
+	}
+
+	if config.Allow("https://tenant.example.org") {
+		t.Errorf("expected Allow to ignore AllowOriginFunc")
+	}
+}
+
+func TestCORS_invalidOrigin(t *testing.T) {
+	t.Parallel()
+
+	defer func() {
+		if recover() == nil {
+			t.Errorf("expected panic")
+		}
+	}()
+
+	config := &Config{AllowOrigins: []string{"example.com"}}
+	config.Apply(beehive.NewRouter())
+}
+
+func TestCORS_anyOriginWithCredentials(t *testing.T) {
+	t.Parallel()
+
+	defer func() {
+		if recover() == nil {
+			t.Errorf("expected panic")
+		}
+	}()
+
+	config := &Config{AllowOrigins: []string{"https://example.com", "*"}, AllowCredentials: true}
+	config.Apply(beehive.NewRouter())
+}
@@ -0,0 +1,68 @@
+package beehive_cors
+
+import (
+	"net/http"
+	"strings"
+
+	"go.sdls.io/beehive/pkg/beehive"
+)
+
+// test that corsGroup implements beehive.Grouper.
+var _ beehive.Grouper = &corsGroup{}
+
+// corsGroup registers the routes on actual, which runs the CORS middleware, and the preflight OPTIONS route of every
+// new path on parent.
+type corsGroup struct {
+	parent    beehive.Grouper
+	actual    beehive.Grouper
+	prefix    string
+	preflight beehive.HandlerFunc
+	paths     map[string]bool
+}
+
+func (g *corsGroup) Group(pathPrefix string, middleware ...beehive.HandlerFunc) beehive.Grouper {
+	return &corsGroup{
+		parent:    g.parent.Group(pathPrefix),
+		actual:    g.actual.Group(pathPrefix, middleware...),
+		prefix:    g.prefix + pathPrefix,
+		preflight: g.preflight,
+		paths:     g.paths,
+	}
+}
+
+func (g *corsGroup) Handle(method, path string, handlers ...beehive.HandlerFunc) beehive.Grouper {
+	if full := g.prefix + path; !g.paths[full] {
+		covered := g.covered(full)
+		g.paths[full] = true
+		if method != http.MethodOptions && !covered {
+			g.parent.Handle(http.MethodOptions, path, g.preflight)
+		}
+	}
+
+	g.actual.Handle(method, path, handlers...)
+	return g
+}
+
+// covered reports whether an OPTIONS route registered for a wildcard path already matches path, registering another
+// one would then panic as the router considers it defined.
+func (g *corsGroup) covered(path string) bool {
+	for registered := range g.paths {
+		if strings.HasSuffix(registered, "*") && strings.HasPrefix(path, registered[:len(registered)-1]) {
+			return true
+		}
+	}
+	return false
+}
+
+func (g *corsGroup) HandleAny(methods []string, path string, handlers ...beehive.HandlerFunc) beehive.Grouper {
+	for _, method := range methods {
+		g.Handle(method, path, handlers...)
+	}
+
+	return g
+}
+
+func (g *corsGroup) With(middleware ...beehive.HandlerFunc) beehive.Grouper {
+	g.actual.With(middleware...)
+	return g
+}
@@ -0,0 +1,96 @@
+package beehive_cors
+
+import (
+	"errors"
+	"net/url"
+	"strings"
+)
+
+type originPattern struct {
+	any       bool
+	scheme    string
+	host      string
+	subdomain bool
+	port      string
+}
+
+func defaultPort(scheme string) string {
+	switch scheme {
+	case "http", "ws":
+		return "80"
+	case "https", "wss":
+		return "443"
+	default:
+		return ""
+	}
+}
+
+func parseOriginPattern(pattern string) (originPattern, error) {
+	if pattern == "*" {
+		return originPattern{any: true}, nil
+	}
+
+	scheme, rest, found := strings.Cut(pattern, "://")
+	if !found || scheme == "" || rest == "" {
+		return originPattern{}, errors.New("invalid origin pattern " + pattern + ", expected scheme://host[:port]")
+	}
+
+	host, port := rest, ""
+	if idx := strings.LastIndexByte(rest, ':'); idx != -1 && !strings.HasSuffix(rest, "]") {
+		host, port = rest[:idx], rest[idx+1:]
+		if port == "" {
+			return originPattern{}, errors.New("invalid origin pattern " + pattern + ", empty port")
+		}
+	}
+
+	p := originPattern{
+		scheme: strings.ToLower(scheme),
+		host:   strings.ToLower(host),
+		port:   port,
+	}
+
+	if strings.HasPrefix(p.host, "*.") {
+		p.subdomain = true
+		p.host = p.host[1:]
+	}
+
+	if p.host == "" || strings.ContainsAny(p.host, "*/") {
+		return originPattern{}, errors.New("invalid origin pattern " + pattern + ", bad host")
+	}
+
+	if p.port == "" {
+		p.port = defaultPort(p.scheme)
+	}
+
+	return p, nil
+}
+
+func (p originPattern) match(u *url.URL) bool {
+	if p.any {
+		return u.Scheme != "" && u.Host != ""
+	}
+
+	if !strings.EqualFold(u.Scheme, p.scheme) {
+		return false
+	}
+
+	host := strings.ToLower(u.Hostname())
+	if p.subdomain {
+		if len(host) <= len(p.host) || !strings.HasSuffix(host, p.host) {
+			return false
+		}
+	} else if host != strings.Trim(p.host, "[]") {
+		return false
+	}
+
+	if p.port == "*" {
+		return true
+	}
+
+	port := u.Port()
+	if port == "" {
+		port = defaultPort(strings.ToLower(u.Scheme))
+	}
+
+	return port == p.port
+}
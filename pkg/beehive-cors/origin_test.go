@@ -0,0 +1,83 @@
+package beehive_cors
+
+import (
+	"net/url"
+	"testing"
+)
+
+func TestOriginPattern(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]map[string]bool{
+		"*": {
+			"https://example.com": true,
+			"http://localhost:1":  true,
+			"null":                false,
+		},
+		"https://example.com": {
+			"https://example.com":     true,
+			"https://EXAMPLE.com":     true,
+			"https://example.com:443": true,
+			"https://example.com:444": false,
+			"http://example.com":      false,
+			"https://a.example.com":   false,
+		},
+		"https://*.example.com": {
+			"https://a.example.com":         true,
+			"https://a.b.example.com":       true,
+			"https://example.com":           false,
+			"https://badexample.com":        false,
+			"http://a.example.com":          false,
+			"https://a.example.com:8443":    false,
+			"https://a.example.com.evil":    false,
+			"https://evil.com/.example.com": false,
+		},
+		"http://localhost:*": {
+			"http://localhost":       true,
+			"http://localhost:3000":  true,
+			"https://localhost:3000": false,
+			"http://localhost.evil":  false,
+		},
+		"http://localhost:8080": {
+			"http://localhost:8080": true,
+			"http://localhost":      false,
+		},
+		"http://[::1]:*": {
+			"http://[::1]:3000": true,
+			"http://[::2]:3000": false,
+		},
+		"app://internal": {
+			"app://internal":      true,
+			"app://internal:1234": false,
+		},
+	}
+
+	for pattern, origins := range tests {
+		p, err := parseOriginPattern(pattern)
+		if err != nil {
+			t.Errorf("%s: unexpected error %v", pattern, err)
+			continue
+		}
+
+		for origin, want := range origins {
+			u, err := url.Parse(origin)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if got := p.match(u); got != want {
+				t.Errorf("%s: %s expected %v, got %v", pattern, origin, want, got)
+			}
+		}
+	}
+}
+
+func TestOriginPattern_invalid(t *testing.T) {
+	t.Parallel()
+
+	for _, pattern := range []string{"", "example.com", "://example.com", "https://", "https://example.com:", "https://a.*.com", "https://example.com/path"} {
+		if _, err := parseOriginPattern(pattern); err == nil {
+			t.Errorf("%q: expected error", pattern)
+		}
+	}
+}
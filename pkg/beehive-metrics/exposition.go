@@ -0,0 +1,192 @@
+package beehive_metrics
+
+import (
+	"cmp"
+	"io"
+	"math"
+	"net/http"
+	"slices"
+	"strconv"
+	"sync/atomic"
+
+	"go.sdls.io/beehive/pkg/beehive"
+)
+
+// ContentType is the content type of the Prometheus text exposition format.
+const ContentType = "text/plain; version=0.0.4; charset=utf-8"
+
+// Handler returns a beehive.HandlerFunc exposing the metrics in the Prometheus text exposition format, to be
+// registered with Handle("GET", "/metrics", m.Handler()).
+func (m *Metrics) Handler() beehive.HandlerFunc {
+	responder := &exposition{metrics: m}
+	return func(_ *beehive.Context) beehive.Responder {
+		return responder
+	}
+}
+
+type exposition struct {
+	metrics *Metrics
+}
+
+// test that exposition implements beehive.Responder.
+var _ beehive.Responder = &exposition{}
+
+func (e *exposition) StatusCode(_ *beehive.Context) int {
+	return http.StatusOK
+}
+
+func (e *exposition) Respond(ctx *beehive.Context) {
+	ctx.ResponseWriter.Header().Set("Content-Type", ContentType)
+	ctx.ResponseWriter.WriteHeader(http.StatusOK)
+	_, _ = e.metrics.WriteTo(ctx.ResponseWriter)
+}
+
+// WriteTo writes the metrics in the Prometheus text exposition format to w, sorted by labels.
+func (m *Metrics) WriteTo(w io.Writer) (int64, error) {
+	m.mu.RLock()
+	seriesKeys := make([]seriesKey, 0, len(m.series))
+	for key := range m.series {
+		seriesKeys = append(seriesKeys, key)
+	}
+	routeKeys := make([]routeKey, 0, len(m.inFlight))
+	for key := range m.inFlight {
+		routeKeys = append(routeKeys, key)
+	}
+	m.mu.RUnlock()
+
+	slices.SortFunc(routeKeys, compareRouteKeys)
+	slices.SortFunc(seriesKeys, func(a, b seriesKey) int {
+		return cmp.Or(compareRouteKeys(a.routeKey, b.routeKey), cmp.Compare(a.status, b.status))
+	})
+
+	// entries are never removed, the pointers are looked up again after sorting
+	series := make([]*series, len(seriesKeys))
+	inFlight := make([]*atomic.Int64, len(routeKeys))
+	m.mu.RLock()
+	for idx, key := range seriesKeys {
+		series[idx] = m.series[key]
+	}
+	for idx, key := range routeKeys {
+		inFlight[idx] = m.inFlight[key]
+	}
+	m.mu.RUnlock()
+
+	b := make([]byte, 0, 4096)
+
+	name := m.namespace + "_requests_total"
+	b = appendHeader(b, name, "Total number of HTTP requests.", "counter")
+	for idx, key := range seriesKeys {
+		b = appendSample(b, name, key, "", series[idx].requests.Load())
+	}
+
+	name = m.namespace + "_request_duration_seconds"
+	b = appendHeader(b, name, "Duration of HTTP requests in seconds, until the response is sent.", "histogram")
+	for idx, key := range seriesKeys {
+		b = appendHistogram(b, name, key, series[idx].duration)
+	}
+
+	name = m.namespace + "_response_size_bytes"
+	b = appendHeader(b, name, "Size of HTTP response bodies in bytes.", "histogram")
+	for idx, key := range seriesKeys {
+		b = appendHistogram(b, name, key, series[idx].size)
+	}
+
+	name = m.namespace + "_requests_in_flight"
+	b = appendHeader(b, name, "Number of HTTP requests currently being handled.", "gauge")
+	for idx, key := range routeKeys {
+		b = append(b, name...)
+		b = appendLabels(b, key, 0, "")
+		b = append(b, ' ')
+		b = strconv.AppendInt(b, inFlight[idx].Load(), 10)
+		b = append(b, '\n')
+	}
+
+	n, err := w.Write(b)
+	return int64(n), err
+}
+
+func compareRouteKeys(a, b routeKey) int {
+	return cmp.Or(cmp.Compare(a.route, b.route), cmp.Compare(a.method, b.method))
+}
+
+func appendHeader(b []byte, name, help, typ string) []byte {
+	b = append(b, "# HELP "...)
+	b = append(b, name...)
+	b = append(b, ' ')
+	b = append(b, help...)
+	b = append(b, "\n# TYPE "...)
+	b = append(b, name...)
+	b = append(b, ' ')
+	b = append(b, typ...)
+	return append(b, '\n')
+}
+
+func appendSample(b []byte, name string, key seriesKey, le string, value uint64) []byte {
+	b = append(b, name...)
+	b = appendLabels(b, key.routeKey, key.status, le)
+	b = append(b, ' ')
+	b = strconv.AppendUint(b, value, 10)
+	return append(b, '\n')
+}
+
+func appendHistogram(b []byte, name string, key seriesKey, h *histogram) []byte {
+	counts, sum, count := h.snapshot()
+
+	for idx, bound := range h.buckets {
+		b = appendSample(b, name+"_bucket", key, formatFloat(bound), counts[idx])
+	}
+	b = appendSample(b, name+"_bucket", key, "+Inf", count)
+
+	b = append(b, name...)
+	b = append(b, "_sum"...)
+	b = appendLabels(b, key.routeKey, key.status, "")
+	b = append(b, ' ')
+	b = append(b, formatFloat(sum)...)
+	b = append(b, '\n')
+
+	return appendSample(b, name+"_count", key, "", count)
+}
+
+// appendLabels appends the label set, status and le are omitted when zero or empty.
+func appendLabels(b []byte, key routeKey, status int, le string) []byte {
+	b = append(b, `{method="`...)
+	b = appendEscaped(b, key.method)
+	b = append(b, `",route="`...)
+	b = appendEscaped(b, key.route)
+	b = append(b, '"')
+	if status != 0 {
+		b = append(b, `,status="`...)
+		b = strconv.AppendInt(b, int64(status), 10)
+		b = append(b, '"')
+	}
+	if le != "" {
+		b = append(b, `,le="`...)
+		b = append(b, le...)
+		b = append(b, '"')
+	}
+	return append(b, '}')
+}
+
+// appendEscaped escapes a label value as required by the text exposition format.
+func appendEscaped(b []byte, s string) []byte {
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; c {
+		case '\\':
+			b = append(b, `\\`...)
+		case '"':
+			b = append(b, `\"`...)
+		case '\n':
+			b = append(b, `\n`...)
+		default:
+			b = append(b, c)
+		}
+	}
+	return b
+}
+
+func formatFloat(f float64) string {
+	if math.IsInf(f, 1) {
+		return "+Inf"
+	}
+	return strconv.FormatFloat(f, 'g', -1, 64)
+}
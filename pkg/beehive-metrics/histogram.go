@@ -0,0 +1,52 @@
+package beehive_metrics
+
+import (
+	"math"
+	"sort"
+	"sync/atomic"
+)
+
+// DefaultDurationBuckets are the default request duration buckets, in seconds.
+var DefaultDurationBuckets = []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+
+// DefaultSizeBuckets are the default response size buckets, in bytes.
+var DefaultSizeBuckets = []float64{100, 1000, 10_000, 100_000, 1_000_000, 10_000_000}
+
+// histogram is a lock free histogram, counts are kept per bucket and only made cumulative when exposed.
+type histogram struct {
+	buckets []float64
+	counts  []atomic.Uint64 // len(buckets)+1, the last one is +Inf
+	sum     atomic.Uint64   // float64 bits
+}
+
+func newHistogram(buckets []float64) *histogram {
+	return &histogram{
+		buckets: buckets,
+		counts:  make([]atomic.Uint64, len(buckets)+1),
+	}
+}
+
+func (h *histogram) observe(v float64) {
+	h.counts[sort.SearchFloat64s(h.buckets, v)].Add(1)
+
+	for {
+		old := h.sum.Load()
+		if h.sum.CompareAndSwap(old, math.Float64bits(math.Float64frombits(old)+v)) {
+			return
+		}
+	}
+}
+
+// snapshot returns the cumulative bucket counts, the sum and the count. The values are not read atomically as a
+// whole, the count is the +Inf bucket such that both always match.
+func (h *histogram) snapshot() ([]uint64, float64, uint64) {
+	cumulative := make([]uint64, len(h.counts))
+
+	var total uint64
+	for idx := range h.counts {
+		total += h.counts[idx].Load()
+		cumulative[idx] = total
+	}
+
+	return cumulative, math.Float64frombits(h.sum.Load()), total
+}
@@ -0,0 +1,168 @@
+package beehive_metrics
+
+import (
+	"net/http"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"go.sdls.io/beehive/internal/recorder"
+	"go.sdls.io/beehive/pkg/beehive"
+)
+
+// Config describes the metrics middleware, use NewMetrics to create it.
+type Config struct {
+	// Namespace prefixes the metric names. If empty, "http" is used.
+	Namespace string
+
+	// DurationBuckets are the upper bounds of the request duration histogram buckets, in seconds and in increasing
+	// order. If nil, DefaultDurationBuckets is used.
+	DurationBuckets []float64
+
+	// SizeBuckets are the upper bounds of the response size histogram buckets, in bytes and in increasing order. If
+	// nil, DefaultSizeBuckets is used.
+	SizeBuckets []float64
+
+	// Now is the clock used to measure the duration. If nil, time.Now is used.
+	Now func() time.Time
+}
+
+type routeKey struct {
+	method string
+	route  string
+}
+
+type seriesKey struct {
+	routeKey
+	status int
+}
+
+type series struct {
+	requests atomic.Uint64
+	duration *histogram
+	size     *histogram
+}
+
+// Metrics is a beehive middleware recording the number of requests, the request duration, the response size and the
+// number of requests in flight per method and matched route (and status code), and exposing them in the Prometheus
+// text exposition format. Metrics is safe for concurrent use.
+type Metrics struct {
+	namespace       string
+	durationBuckets []float64
+	sizeBuckets     []float64
+	now             func() time.Time
+
+	mu       sync.RWMutex
+	series   map[seriesKey]*series
+	inFlight map[routeKey]*atomic.Int64
+}
+
+// NewMetrics returns a Metrics for the given Config.
+func NewMetrics(config Config) *Metrics {
+	m := &Metrics{
+		namespace:       config.Namespace,
+		durationBuckets: config.DurationBuckets,
+		sizeBuckets:     config.SizeBuckets,
+		now:             config.Now,
+		series:          make(map[seriesKey]*series),
+		inFlight:        make(map[routeKey]*atomic.Int64),
+	}
+
+	if m.namespace == "" {
+		m.namespace = "http"
+	}
+	if m.durationBuckets == nil {
+		m.durationBuckets = DefaultDurationBuckets
+	}
+	if m.sizeBuckets == nil {
+		m.sizeBuckets = DefaultSizeBuckets
+	}
+	if m.now == nil {
+		m.now = time.Now
+	}
+
+	if !slices.IsSorted(m.durationBuckets) || !slices.IsSorted(m.sizeBuckets) {
+		panic("beehive-metrics: buckets must be in increasing order")
+	}
+
+	return m
+}
+
+// HandlerFunc returns the beehive.HandlerFunc recording the metrics. The request is measured until the response is
+// sent, and the status code and size are the ones actually written. As a middleware, requests that do not match any
+// route (Router.WhenNotFound) are not recorded.
+func (m *Metrics) HandlerFunc() beehive.HandlerFunc {
+	return func(ctx *beehive.Context) beehive.Responder {
+		start := m.now()
+		key := routeKey{method: ctx.Request.Method, route: ctx.Route()}
+
+		inFlight := m.inFlightGauge(key)
+		inFlight.Add(1)
+
+		w := &recorder.ResponseWriter{ResponseWriter: ctx.ResponseWriter}
+		ctx.ResponseWriter = w
+
+		var res beehive.Responder
+		ctx.After(func() {
+			inFlight.Add(-1)
+
+			status := w.Status
+			if status == 0 && res != nil {
+				status = res.StatusCode(ctx)
+			}
+			if status == 0 {
+				status = http.StatusOK
+			}
+
+			s := m.seriesFor(seriesKey{routeKey: key, status: status})
+			s.requests.Add(1)
+			s.duration.observe(m.now().Sub(start).Seconds())
+			s.size.observe(float64(w.Bytes))
+		})
+
+		res = ctx.Next()
+		return res
+	}
+}
+
+func (m *Metrics) inFlightGauge(key routeKey) *atomic.Int64 {
+	m.mu.RLock()
+	gauge, ok := m.inFlight[key]
+	m.mu.RUnlock()
+	if ok {
+		return gauge
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if gauge, ok = m.inFlight[key]; !ok {
+		gauge = &atomic.Int64{}
+		m.inFlight[key] = gauge
+	}
+
+	return gauge
+}
+
+func (m *Metrics) seriesFor(key seriesKey) *series {
+	m.mu.RLock()
+	s, ok := m.series[key]
+	m.mu.RUnlock()
+	if ok {
+		return s
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if s, ok = m.series[key]; !ok {
+		s = &series{
+			duration: newHistogram(m.durationBuckets),
+			size:     newHistogram(m.sizeBuckets),
+		}
+		m.series[key] = s
+	}
+
+	return s
+}
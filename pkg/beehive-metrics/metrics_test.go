@@ -0,0 +1,209 @@
+package beehive_metrics
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"go.sdls.io/beehive/pkg/beehive"
+)
+
+func TestMetrics(t *testing.T) {
+	t.Parallel()
+
+	now := time.Unix(1700000000, 0)
+	metrics := NewMetrics(Config{
+		Namespace:       "api",
+		DurationBuckets: []float64{0.01, 0.1},
+		SizeBuckets:     []float64{1, 10},
+		Now: func() time.Time {
+			now = now.Add(20 * time.Millisecond)
+			return now
+		},
+	})
+
+	router := beehive.NewRouter()
+	router.Handle("GET", "/metrics", metrics.Handler())
+
+	api := router.Group("", metrics.HandlerFunc())
+	api.Handle("GET", "/users/*", func(_ *beehive.Context) beehive.Responder {
+		return &beehive.DefaultResponder{Message: "hello", Status: http.StatusOK}
+	})
+	api.Handle("POST", "/users/*", func(_ *beehive.Context) beehive.Responder {
+		return &beehive.DefaultResponder{Message: "nope", Status: http.StatusBadRequest}
+	})
+	api.Handle("GET", "/panic", func(_ *beehive.Context) beehive.Responder {
+		panic("boom")
+	})
+
+	for _, req := range []struct{ method, path string }{
+		{"GET", "/users/1"},
+		{"GET", "/users/2"},
+		{"POST", "/users/1"},
+		{"GET", "/panic"},
+		{"GET", "/nope"},
+	} {
+		router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(req.method, req.path, nil))
+	}
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", "/metrics", nil))
+
+	if w.Header().Get("Content-Type") != ContentType {
+		t.Errorf("expected Content-Type %s, got %s", ContentType, w.Header().Get("Content-Type"))
+	}
+
+	want := `# HELP api_requests_total Total number of HTTP requests.
+# TYPE api_requests_total counter
+api_requests_total{method="GET",route="/panic",status="500"} 1
+api_requests_total{method="GET",route="/users/*",status="200"} 2
+api_requests_total{method="POST",route="/users/*",status="400"} 1
+# HELP api_request_duration_seconds Duration of HTTP requests in seconds, until the response is sent.
+# TYPE api_request_duration_seconds histogram
+api_request_duration_seconds_bucket{method="GET",route="/panic",status="500",le="0.01"} 0
+api_request_duration_seconds_bucket{method="GET",route="/panic",status="500",le="0.1"} 1
+api_request_duration_seconds_bucket{method="GET",route="/panic",status="500",le="+Inf"} 1
+api_request_duration_seconds_sum{method="GET",route="/panic",status="500"} 0.02
+api_request_duration_seconds_count{method="GET",route="/panic",status="500"} 1
+api_request_duration_seconds_bucket{method="GET",route="/users/*",status="200",le="0.01"} 0
+api_request_duration_seconds_bucket{method="GET",route="/users/*",status="200",le="0.1"} 2
+api_request_duration_seconds_bucket{method="GET",route="/users/*",status="200",le="+Inf"} 2
+api_request_duration_seconds_sum{method="GET",route="/users/*",status="200"} 0.04
+api_request_duration_seconds_count{method="GET",route="/users/*",status="200"} 2
+api_request_duration_seconds_bucket{method="POST",route="/users/*",status="400",le="0.01"} 0
+api_request_duration_seconds_bucket{method="POST",route="/users/*",status="400",le="0.1"} 1
+api_request_duration_seconds_bucket{method="POST",route="/users/*",status="400",le="+Inf"} 1
+api_request_duration_seconds_sum{method="POST",route="/users/*",status="400"} 0.02
+api_request_duration_seconds_count{method="POST",route="/users/*",status="400"} 1
+# HELP api_response_size_bytes Size of HTTP response bodies in bytes.
+# TYPE api_response_size_bytes histogram
+api_response_size_bytes_bucket{method="GET",route="/panic",status="500",le="1"} 0
+api_response_size_bytes_bucket{method="GET",route="/panic",status="500",le="10"} 0
+api_response_size_bytes_bucket{method="GET",route="/panic",status="500",le="+Inf"} 1
+api_response_size_bytes_sum{method="GET",route="/panic",status="500"} 20
+api_response_size_bytes_count{method="GET",route="/panic",status="500"} 1
+api_response_size_bytes_bucket{method="GET",route="/users/*",status="200",le="1"} 0
+api_response_size_bytes_bucket{method="GET",route="/users/*",status="200",le="10"} 2
+api_response_size_bytes_bucket{method="GET",route="/users/*",status="200",le="+Inf"} 2
+api_response_size_bytes_sum{method="GET",route="/users/*",status="200"} 10
+api_response_size_bytes_count{method="GET",route="/users/*",status="200"} 2
+api_response_size_bytes_bucket{method="POST",route="/users/*",status="400",le="1"} 0
+api_response_size_bytes_bucket{method="POST",route="/users/*",status="400",le="10"} 1
+api_response_size_bytes_bucket{method="POST",route="/users/*",status="400",le="+Inf"} 1
+api_response_size_bytes_sum{method="POST",route="/users/*",status="400"} 4
+api_response_size_bytes_count{method="POST",route="/users/*",status="400"} 1
+# HELP api_requests_in_flight Number of HTTP requests currently being handled.
+# TYPE api_requests_in_flight gauge
+api_requests_in_flight{method="GET",route="/panic"} 0
+api_requests_in_flight{method="GET",route="/users/*"} 0
+api_requests_in_flight{method="POST",route="/users/*"} 0
+`
+
+	if w.Body.String() != want {
+		t.Errorf("expected\n%s\ngot\n%s", want, w.Body.String())
+	}
+}
+
+func TestMetrics_inFlight(t *testing.T) {
+	t.Parallel()
+
+	metrics := NewMetrics(Config{})
+	release := make(chan struct{})
+	started := make(chan struct{})
+
+	router := beehive.NewRouter()
+	router.Handle("GET", "/slow", metrics.HandlerFunc(), func(_ *beehive.Context) beehive.Responder {
+		close(started)
+		<-release
+		return &beehive.DefaultResponder{Message: "ok", Status: http.StatusOK}
+	})
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/slow", nil))
+	}()
+
+	<-started
+
+	var sb strings.Builder
+	_, _ = metrics.WriteTo(&sb)
+	if !strings.Contains(sb.String(), `http_requests_in_flight{method="GET",route="/slow"} 1`) {
+		t.Errorf("expected request in flight, got\n%s", sb.String())
+	}
+
+	close(release)
+	<-done
+}
+
+func TestMetrics_escape(t *testing.T) {
+	t.Parallel()
+
+	metrics := NewMetrics(Config{})
+	metrics.seriesFor(seriesKey{routeKey: routeKey{method: "GET", route: "/a\"b\\c\n"}, status: 200}).requests.Add(1)
+
+	var sb strings.Builder
+	_, _ = metrics.WriteTo(&sb)
+	if !strings.Contains(sb.String(), `http_requests_total{method="GET",route="/a\"b\\c\n",status="200"} 1`) {
+		t.Errorf("expected escaped label, got\n%s", sb.String())
+	}
+}
+
+func TestNewMetrics_buckets(t *testing.T) {
+	t.Parallel()
+
+	defer func() {
+		if recover() == nil {
+			t.Errorf("expected panic")
+		}
+	}()
+
+	NewMetrics(Config{DurationBuckets: []float64{1, 0.5}})
+}
+
+type noopResponder struct{}
+
+func (noopResponder) Respond(ctx *beehive.Context) {
+	ctx.ResponseWriter.WriteHeader(http.StatusOK)
+}
+
+func (noopResponder) StatusCode(_ *beehive.Context) int {
+	return http.StatusOK
+}
+
+type noopResponseWriter struct{}
+
+func (noopResponseWriter) Header() http.Header {
+	return http.Header{}
+}
+
+func (noopResponseWriter) Write(b []byte) (int, error) {
+	return len(b), nil
+}
+
+func (noopResponseWriter) WriteHeader(_ int) {}
+
+func BenchmarkMetrics_ServeHTTP(b *testing.B) {
+	metrics := NewMetrics(Config{})
+
+	router := beehive.NewRouter()
+	router.Context = func(_ *http.Request) context.Context {
+		return context.Background()
+	}
+	router.Handle("GET", "/foo/bar", metrics.HandlerFunc(), func(_ *beehive.Context) beehive.Responder {
+		return noopResponder{}
+	})
+
+	r := httptest.NewRequestWithContext(b.Context(), http.MethodGet, "/foo/bar", nil)
+	w := noopResponseWriter{}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for b.Loop() {
+		router.ServeHTTP(w, r)
+	}
+}
@@ -0,0 +1,743 @@
+package beehive_msgpack
+
+import (
+	"bufio"
+	"bytes"
+	"encoding"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"reflect"
+	"time"
+
+	"go.sdls.io/beehive/internal/fields"
+)
+
+// Unmarshal decodes the MessagePack value of data into v, a non-nil pointer. Values are decoded like encoding/json
+// does: map keys are matched to struct fields exactly, then case-insensitively, unknown keys are skipped, and nil
+// sets pointers, slices, maps and interfaces to nil. Interface values receive nil, bool, int64 (uint64 beyond
+// math.MaxInt64), float64, string, []byte, []any, map[string]any (map[any]any for non string keys), time.Time or Ext.
+func Unmarshal(data []byte, v any) error {
+	r := bytes.NewReader(data)
+	if err := NewDecoder(r).Decode(v); err != nil {
+		return err
+	}
+
+	if r.Len() > 0 {
+		return ErrTrailingData
+	}
+	return nil
+}
+
+// Decoder reads MessagePack values from a stream.
+type Decoder struct {
+	r byteReader
+}
+
+type byteReader interface {
+	io.Reader
+	io.ByteScanner
+}
+
+// NewDecoder returns a Decoder reading from r, buffered unless r implements io.ByteScanner. A buffered Decoder may
+// read past the decoded values.
+func NewDecoder(r io.Reader) *Decoder {
+	if br, ok := r.(byteReader); ok {
+		return &Decoder{r: br}
+	}
+	return &Decoder{r: bufio.NewReader(r)}
+}
+
+// Decode reads the next value into v, a non-nil pointer, see Unmarshal.
+func (dec *Decoder) Decode(v any) error {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Pointer || rv.IsNil() {
+		return errors.New("beehive-msgpack: decode requires a non-nil pointer")
+	}
+
+	d := decoder{r: dec.r}
+	err := d.decode(rv.Elem(), 0)
+	if errors.Is(err, io.EOF) && d.read {
+		err = io.ErrUnexpectedEOF
+	}
+	return err
+}
+
+type decoder struct {
+	r    byteReader
+	read bool
+	tmp  [8]byte
+}
+
+func (d *decoder) readByte() (byte, error) {
+	b, err := d.r.ReadByte()
+	if err == nil {
+		d.read = true
+	}
+	return b, err
+}
+
+func (d *decoder) peek() (byte, error) {
+	b, err := d.r.ReadByte()
+	if err != nil {
+		return 0, err
+	}
+	return b, d.r.UnreadByte()
+}
+
+func (d *decoder) readFull(b []byte) error {
+	_, err := io.ReadFull(d.r, b)
+	if errors.Is(err, io.EOF) {
+		return io.ErrUnexpectedEOF
+	}
+	return err
+}
+
+func (d *decoder) readUint(size int) (uint64, error) {
+	if err := d.readFull(d.tmp[:size]); err != nil {
+		return 0, err
+	}
+
+	switch size {
+	case 1:
+		return uint64(d.tmp[0]), nil
+	case 2:
+		return uint64(binary.BigEndian.Uint16(d.tmp[:2])), nil
+	case 4:
+		return uint64(binary.BigEndian.Uint32(d.tmp[:4])), nil
+	default:
+		return binary.BigEndian.Uint64(d.tmp[:8]), nil
+	}
+}
+
+// readN reads n bytes, growing the buffer as the data arrives such that a forged length cannot allocate more than
+// what is actually sent.
+func (d *decoder) readN(n uint64) ([]byte, error) {
+	const chunk = 64 << 10
+
+	buf := make([]byte, 0, min(n, chunk))
+	for uint64(len(buf)) < n {
+		size := min(n-uint64(len(buf)), chunk)
+		start := len(buf)
+		buf = append(buf, make([]byte, size)...)
+		if err := d.readFull(buf[start:]); err != nil {
+			return nil, err
+		}
+	}
+	return buf, nil
+}
+
+// header is a decoded type byte with its length or value.
+type header struct {
+	kind   byte // one of the kind constants
+	length uint64
+	value  uint64 // integers (two's complement for kindInt), float bits, bool
+	ext    int8
+}
+
+const (
+	kindNil = iota
+	kindBool
+	kindUint
+	kindInt
+	kindFloat32
+	kindFloat64
+	kindString
+	kindBinary
+	kindArray
+	kindMap
+	kindExt
+)
+
+var kindNames = []string{
+	"nil", "bool", "integer", "integer", "float", "float", "string", "binary", "array", "map", "extension",
+}
+
+func (d *decoder) readHeader() (header, error) {
+	b, err := d.readByte()
+	if err != nil {
+		return header{}, err
+	}
+
+	switch {
+	case b <= 0x7f:
+		return header{kind: kindUint, value: uint64(b)}, nil
+	case b >= 0xe0:
+		return header{kind: kindInt, value: uint64(int64(int8(b)))}, nil
+	case b&0xf0 == 0x80:
+		return header{kind: kindMap, length: uint64(b & 0x0f)}, nil
+	case b&0xf0 == 0x90:
+		return header{kind: kindArray, length: uint64(b & 0x0f)}, nil
+	case b&0xe0 == 0xa0:
+		return header{kind: kindString, length: uint64(b & 0x1f)}, nil
+	}
+
+	var h header
+	var size int
+	switch b {
+	case 0xc0:
+		return header{kind: kindNil}, nil
+	case 0xc2, 0xc3:
+		return header{kind: kindBool, value: uint64(b & 1)}, nil
+	case 0xc4, 0xc5, 0xc6:
+		h.kind, size = kindBinary, 1<<(b-0xc4)
+	case 0xc7, 0xc8, 0xc9:
+		h.kind, size = kindExt, 1<<(b-0xc7)
+	case 0xca:
+		h.kind, size = kindFloat32, 4
+	case 0xcb:
+		h.kind, size = kindFloat64, 8
+	case 0xcc, 0xcd, 0xce, 0xcf:
+		h.kind, size = kindUint, 1<<(b-0xcc)
+	case 0xd0, 0xd1, 0xd2, 0xd3:
+		h.kind, size = kindInt, 1<<(b-0xd0)
+	case 0xd4, 0xd5, 0xd6, 0xd7, 0xd8:
+		h.kind, h.length = kindExt, 1<<(b-0xd4)
+	case 0xd9, 0xda, 0xdb:
+		h.kind, size = kindString, 1<<(b-0xd9)
+	case 0xdc, 0xdd:
+		h.kind, size = kindArray, 2<<(b-0xdc)
+	case 0xde, 0xdf:
+		h.kind, size = kindMap, 2<<(b-0xde)
+	default:
+		return header{}, fmt.Errorf("beehive-msgpack: invalid type byte 0x%02x", b)
+	}
+
+	var n uint64
+	if size > 0 {
+		if n, err = d.readUint(size); err != nil {
+			return header{}, err
+		}
+	}
+
+	switch h.kind {
+	case kindUint, kindFloat32, kindFloat64:
+		h.value = n
+	case kindInt:
+		// sign extend
+		shift := 64 - 8*size
+		h.value = uint64(int64(n<<shift) >> shift)
+	case kindExt:
+		if size > 0 {
+			h.length = n
+		}
+		typ, err := d.readByte()
+		if err != nil {
+			return header{}, err
+		}
+		h.ext = int8(typ)
+	default:
+		h.length = n
+	}
+
+	return h, nil
+}
+
+func (d *decoder) decode(v reflect.Value, depth int) error {
+	if depth > maxDepth {
+		return ErrTooDeep
+	}
+
+	b, err := d.peek()
+	if err != nil {
+		return err
+	}
+
+	if b == 0xc0 {
+		_, _ = d.readByte()
+		switch v.Kind() {
+		case reflect.Pointer, reflect.Interface, reflect.Map, reflect.Slice:
+			v.SetZero()
+		}
+		return nil
+	}
+
+	if v.Kind() == reflect.Pointer {
+		if v.IsNil() {
+			v.Set(reflect.New(v.Type().Elem()))
+		}
+		return d.decode(v.Elem(), depth+1)
+	}
+
+	if v.CanAddr() {
+		pv := v.Addr()
+		if pv.Type().Implements(unmarshalerType) {
+			raw, err := d.raw(depth)
+			if err != nil {
+				return err
+			}
+			return pv.Interface().(Unmarshaler).UnmarshalMsgpack(raw)
+		}
+
+		// text is accepted by time.Time as well, while timestamps are decoded below
+		isString := b&0xe0 == 0xa0 || (b >= 0xd9 && b <= 0xdb)
+		if isString && pv.Type().Implements(textUnmarshalerType) {
+			h, err := d.readHeader()
+			if err != nil {
+				return err
+			}
+			text, err := d.readN(h.length)
+			if err != nil {
+				return err
+			}
+			return pv.Interface().(encoding.TextUnmarshaler).UnmarshalText(text)
+		}
+	}
+
+	if v.Kind() == reflect.Interface {
+		if v.NumMethod() == 0 && (v.IsNil() || v.Elem().Kind() != reflect.Pointer) {
+			x, err := d.decodeAny(depth)
+			if err != nil {
+				return err
+			}
+			if x == nil {
+				v.SetZero()
+			} else {
+				v.Set(reflect.ValueOf(x))
+			}
+			return nil
+		}
+		if !v.IsNil() {
+			return d.decode(v.Elem(), depth+1)
+		}
+	}
+
+	h, err := d.readHeader()
+	if err != nil {
+		return err
+	}
+
+	mismatch := func() error {
+		return fmt.Errorf("beehive-msgpack: cannot decode %s into %s", kindNames[h.kind], v.Type())
+	}
+
+	switch v.Type() {
+	case timeType:
+		if h.kind != kindExt || h.ext != extTimestamp {
+			return mismatch()
+		}
+		t, err := d.readTime(h)
+		if err != nil {
+			return err
+		}
+		v.Set(reflect.ValueOf(t))
+		return nil
+	case extType:
+		if h.kind != kindExt {
+			return mismatch()
+		}
+		data, err := d.readN(h.length)
+		if err != nil {
+			return err
+		}
+		v.Set(reflect.ValueOf(Ext{Type: h.ext, Data: data}))
+		return nil
+	}
+
+	switch v.Kind() {
+	case reflect.Bool:
+		if h.kind != kindBool {
+			return mismatch()
+		}
+		v.SetBool(h.value == 1)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, ok := h.int()
+		if !ok {
+			return mismatch()
+		}
+		if v.OverflowInt(n) || (h.kind == kindUint && h.value > math.MaxInt64) {
+			return fmt.Errorf("beehive-msgpack: %d overflows %s", h.value, v.Type())
+		}
+		v.SetInt(n)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		if h.kind != kindUint && (h.kind != kindInt || int64(h.value) < 0) {
+			return mismatch()
+		}
+		if v.OverflowUint(h.value) {
+			return fmt.Errorf("beehive-msgpack: %d overflows %s", h.value, v.Type())
+		}
+		v.SetUint(h.value)
+	case reflect.Float32, reflect.Float64:
+		f, ok := h.float()
+		if !ok {
+			return mismatch()
+		}
+		v.SetFloat(f)
+	case reflect.String:
+		if h.kind != kindString && h.kind != kindBinary {
+			return mismatch()
+		}
+		data, err := d.readN(h.length)
+		if err != nil {
+			return err
+		}
+		v.SetString(string(data))
+	case reflect.Slice:
+		if v.Type().Elem().Kind() == reflect.Uint8 && (h.kind == kindBinary || h.kind == kindString) {
+			data, err := d.readN(h.length)
+			if err != nil {
+				return err
+			}
+			v.SetBytes(data)
+			return nil
+		}
+		if h.kind != kindArray {
+			return mismatch()
+		}
+		return d.decodeSlice(v, h.length, depth)
+	case reflect.Array:
+		if v.Type().Elem().Kind() == reflect.Uint8 && (h.kind == kindBinary || h.kind == kindString) {
+			data, err := d.readN(h.length)
+			if err != nil {
+				return err
+			}
+			v.SetZero()
+			reflect.Copy(v, reflect.ValueOf(data))
+			return nil
+		}
+		if h.kind != kindArray {
+			return mismatch()
+		}
+		return d.decodeArray(v, h.length, depth)
+	case reflect.Map:
+		if h.kind != kindMap {
+			return mismatch()
+		}
+		return d.decodeMap(v, h.length, depth)
+	case reflect.Struct:
+		if h.kind != kindMap {
+			return mismatch()
+		}
+		return d.decodeStruct(v, h.length, depth)
+	default:
+		return mismatch()
+	}
+
+	return nil
+}
+
+func (h *header) int() (int64, bool) {
+	switch h.kind {
+	case kindInt:
+		return int64(h.value), true
+	case kindUint:
+		return int64(h.value), true
+	default:
+		return 0, false
+	}
+}
+
+func (h *header) float() (float64, bool) {
+	switch h.kind {
+	case kindFloat32:
+		return float64(math.Float32frombits(uint32(h.value))), true
+	case kindFloat64:
+		return math.Float64frombits(h.value), true
+	case kindInt:
+		return float64(int64(h.value)), true
+	case kindUint:
+		return float64(h.value), true
+	default:
+		return 0, false
+	}
+}
+
+func (d *decoder) decodeSlice(v reflect.Value, n uint64, depth int) error {
+	// the capacity is bounded, as the length is not trusted
+	slice := reflect.MakeSlice(v.Type(), 0, int(min(n, 1024)))
+	for range n {
+		elem := reflect.New(v.Type().Elem()).Elem()
+		if err := d.decode(elem, depth+1); err != nil {
+			return err
+		}
+		slice = reflect.Append(slice, elem)
+	}
+	v.Set(slice)
+	return nil
+}
+
+func (d *decoder) decodeArray(v reflect.Value, n uint64, depth int) error {
+	for i := range n {
+		if i >= uint64(v.Len()) {
+			if err := d.skip(depth); err != nil {
+				return err
+			}
+			continue
+		}
+		if err := d.decode(v.Index(int(i)), depth+1); err != nil {
+			return err
+		}
+	}
+	for i := int(min(n, uint64(v.Len()))); i < v.Len(); i++ {
+		v.Index(i).SetZero()
+	}
+	return nil
+}
+
+func (d *decoder) decodeMap(v reflect.Value, n uint64, depth int) error {
+	if v.IsNil() {
+		v.Set(reflect.MakeMap(v.Type()))
+	}
+
+	keyType, elemType := v.Type().Key(), v.Type().Elem()
+	for range n {
+		key := reflect.New(keyType).Elem()
+		if err := d.decode(key, depth+1); err != nil {
+			return err
+		}
+
+		elem := reflect.New(elemType).Elem()
+		if err := d.decode(elem, depth+1); err != nil {
+			return err
+		}
+
+		v.SetMapIndex(key, elem)
+	}
+	return nil
+}
+
+func (d *decoder) decodeStruct(v reflect.Value, n uint64, depth int) error {
+	s := fields.Of(v.Type(), "msgpack")
+
+	for range n {
+		h, err := d.readHeader()
+		if err != nil {
+			return err
+		}
+		if h.kind != kindString && h.kind != kindBinary {
+			return fmt.Errorf("beehive-msgpack: cannot decode %s key into %s", kindNames[h.kind], v.Type())
+		}
+		name, err := d.readN(h.length)
+		if err != nil {
+			return err
+		}
+
+		f, ok := s.Lookup(string(name))
+		if !ok {
+			if err := d.skip(depth); err != nil {
+				return err
+			}
+			continue
+		}
+
+		fv, _ := f.Value(v, true)
+		if !fv.IsValid() || !fv.CanSet() {
+			if err := d.skip(depth); err != nil {
+				return err
+			}
+			continue
+		}
+
+		if err := d.decode(fv, depth+1); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (d *decoder) decodeAny(depth int) (any, error) {
+	if depth > maxDepth {
+		return nil, ErrTooDeep
+	}
+
+	h, err := d.readHeader()
+	if err != nil {
+		return nil, err
+	}
+
+	switch h.kind {
+	case kindNil:
+		return nil, nil
+	case kindBool:
+		return h.value == 1, nil
+	case kindInt:
+		return int64(h.value), nil
+	case kindUint:
+		if h.value > math.MaxInt64 {
+			return h.value, nil
+		}
+		return int64(h.value), nil
+	case kindFloat32, kindFloat64:
+		f, _ := h.float()
+		return f, nil
+	case kindString:
+		data, err := d.readN(h.length)
+		return string(data), err
+	case kindBinary:
+		return d.readN(h.length)
+	case kindExt:
+		if h.ext == extTimestamp {
+			return d.readTime(h)
+		}
+		data, err := d.readN(h.length)
+		return Ext{Type: h.ext, Data: data}, err
+	case kindArray:
+		arr := make([]any, 0, min(h.length, 1024))
+		for range h.length {
+			x, err := d.decodeAny(depth + 1)
+			if err != nil {
+				return nil, err
+			}
+			arr = append(arr, x)
+		}
+		return arr, nil
+	default:
+		return d.decodeAnyMap(h.length, depth)
+	}
+}
+
+func (d *decoder) decodeAnyMap(n uint64, depth int) (any, error) {
+	strings := make(map[string]any, min(n, 1024))
+	var others map[any]any
+
+	for range n {
+		key, err := d.decodeAny(depth + 1)
+		if err != nil {
+			return nil, err
+		}
+		value, err := d.decodeAny(depth + 1)
+		if err != nil {
+			return nil, err
+		}
+
+		if s, ok := key.(string); ok && others == nil {
+			strings[s] = value
+			continue
+		}
+
+		if key != nil && !reflect.ValueOf(key).Comparable() {
+			return nil, fmt.Errorf("beehive-msgpack: unsupported map key type %T", key)
+		}
+
+		if others == nil {
+			others = make(map[any]any, len(strings)+1)
+			for k, v := range strings {
+				others[k] = v
+			}
+		}
+		others[key] = value
+	}
+
+	if others != nil {
+		return others, nil
+	}
+	return strings, nil
+}
+
+func (d *decoder) readTime(h header) (time.Time, error) {
+	data, err := d.readN(h.length)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	switch len(data) {
+	case 4:
+		return time.Unix(int64(binary.BigEndian.Uint32(data)), 0).UTC(), nil
+	case 8:
+		n := binary.BigEndian.Uint64(data)
+		return time.Unix(int64(n&(1<<34-1)), int64(n>>34)).UTC(), nil
+	case 12:
+		nsec := binary.BigEndian.Uint32(data[:4])
+		return time.Unix(int64(binary.BigEndian.Uint64(data[4:])), int64(nsec)).UTC(), nil
+	default:
+		return time.Time{}, errors.New("beehive-msgpack: invalid timestamp")
+	}
+}
+
+// skip discards the next value.
+func (d *decoder) skip(depth int) error {
+	_, err := d.rawValue(nil, depth, false)
+	return err
+}
+
+// raw returns the encoding of the next value.
+func (d *decoder) raw(depth int) ([]byte, error) {
+	return d.rawValue(nil, depth, true)
+}
+
+func (d *decoder) rawValue(buf []byte, depth int, keep bool) ([]byte, error) {
+	if depth > maxDepth {
+		return nil, ErrTooDeep
+	}
+
+	start, err := d.peek()
+	if err != nil {
+		return nil, err
+	}
+
+	h, err := d.readHeader()
+	if err != nil {
+		return nil, err
+	}
+	if keep {
+		buf = appendHeader(buf, start, h)
+	}
+
+	switch h.kind {
+	case kindString, kindBinary, kindExt:
+		data, err := d.readN(h.length)
+		if err != nil {
+			return nil, err
+		}
+		if keep {
+			buf = append(buf, data...)
+		}
+	case kindArray, kindMap:
+		n := h.length
+		if h.kind == kindMap {
+			n *= 2
+		}
+		for range n {
+			if buf, err = d.rawValue(buf, depth+1, keep); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	return buf, nil
+}
+
+// appendHeader re-encodes the header read from the type byte b.
+func appendHeader(buf []byte, b byte, h header) []byte {
+	buf = append(buf, b)
+
+	size := 0
+	switch {
+	case b >= 0xc4 && b <= 0xc6:
+		size = 1 << (b - 0xc4)
+	case b >= 0xc7 && b <= 0xc9:
+		size = 1 << (b - 0xc7)
+	case b == 0xca:
+		size = 4
+	case b == 0xcb:
+		size = 8
+	case b >= 0xcc && b <= 0xcf:
+		size = 1 << (b - 0xcc)
+	case b >= 0xd0 && b <= 0xd3:
+		size = 1 << (b - 0xd0)
+	case b >= 0xd9 && b <= 0xdb:
+		size = 1 << (b - 0xd9)
+	case b >= 0xdc && b <= 0xdd:
+		size = 2 << (b - 0xdc)
+	case b >= 0xde && b <= 0xdf:
+		size = 2 << (b - 0xde)
+	}
+
+	n := h.length
+	switch h.kind {
+	case kindUint, kindInt, kindFloat32, kindFloat64:
+		n = h.value
+	}
+
+	for i := size - 1; i >= 0; i-- {
+		buf = append(buf, byte(n>>(8*i)))
+	}
+
+	if h.kind == kindExt {
+		buf = append(buf, byte(h.ext))
+	}
+
+	return buf
+}
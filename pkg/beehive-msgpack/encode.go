@@ -0,0 +1,346 @@
+package beehive_msgpack
+
+import (
+	"bytes"
+	"encoding"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"reflect"
+	"slices"
+	"time"
+
+	"go.sdls.io/beehive/internal/fields"
+)
+
+// Marshal returns the MessagePack encoding of v. Values are encoded like encoding/json does: structs are maps of
+// their exported fields, named after the `msgpack` tag (or the `json` tag) with the omitempty and "-" options, and
+// encoding.TextMarshaler values are strings. Byte slices and arrays are binary, time.Time values are timestamps, map
+// keys are sorted by their encoding such that the output is deterministic.
+func Marshal(v any) ([]byte, error) {
+	var e encoder
+	if err := e.encode(reflect.ValueOf(v), 0); err != nil {
+		return nil, err
+	}
+	return e.buf, nil
+}
+
+// Encoder writes MessagePack values to a stream.
+type Encoder struct {
+	w io.Writer
+	e encoder
+}
+
+// NewEncoder returns an Encoder writing to w.
+func NewEncoder(w io.Writer) *Encoder {
+	return &Encoder{w: w}
+}
+
+// Encode writes the MessagePack encoding of v, see Marshal.
+func (enc *Encoder) Encode(v any) error {
+	enc.e.buf = enc.e.buf[:0]
+	if err := enc.e.encode(reflect.ValueOf(v), 0); err != nil {
+		return err
+	}
+
+	_, err := enc.w.Write(enc.e.buf)
+	return err
+}
+
+type encoder struct {
+	buf []byte
+}
+
+func (e *encoder) encode(v reflect.Value, depth int) error {
+	if depth > maxDepth {
+		return ErrTooDeep
+	}
+
+	if !v.IsValid() {
+		e.buf = append(e.buf, 0xc0)
+		return nil
+	}
+
+	t := v.Type()
+	if (v.Kind() == reflect.Pointer || v.Kind() == reflect.Interface) && v.IsNil() {
+		e.buf = append(e.buf, 0xc0)
+		return nil
+	}
+
+	if !t.Implements(marshalerType) && v.CanAddr() && reflect.PointerTo(t).Implements(marshalerType) {
+		v, t = v.Addr(), reflect.PointerTo(t)
+	}
+
+	if t.Implements(marshalerType) {
+		data, err := v.Interface().(Marshaler).MarshalMsgpack()
+		if err != nil {
+			return err
+		}
+		e.buf = append(e.buf, data...)
+		return nil
+	}
+
+	switch t {
+	case timeType:
+		e.encodeTime(v.Interface().(time.Time))
+		return nil
+	case extType:
+		ext := v.Interface().(Ext)
+		e.encodeExt(ext.Type, ext.Data)
+		return nil
+	}
+
+	if t.Implements(textMarshalerType) {
+		text, err := v.Interface().(encoding.TextMarshaler).MarshalText()
+		if err != nil {
+			return err
+		}
+		e.encodeString(string(text))
+		return nil
+	}
+
+	switch v.Kind() {
+	case reflect.Bool:
+		if v.Bool() {
+			e.buf = append(e.buf, 0xc3)
+		} else {
+			e.buf = append(e.buf, 0xc2)
+		}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		e.encodeInt(v.Int())
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		e.encodeUint(v.Uint())
+	case reflect.Float32:
+		e.buf = binary.BigEndian.AppendUint32(append(e.buf, 0xca), math.Float32bits(float32(v.Float())))
+	case reflect.Float64:
+		e.buf = binary.BigEndian.AppendUint64(append(e.buf, 0xcb), math.Float64bits(v.Float()))
+	case reflect.String:
+		e.encodeString(v.String())
+	case reflect.Slice:
+		if v.IsNil() {
+			e.buf = append(e.buf, 0xc0)
+			return nil
+		}
+		if t.Elem().Kind() == reflect.Uint8 {
+			e.encodeBinary(v.Bytes())
+			return nil
+		}
+		return e.encodeArray(v, depth)
+	case reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			data := make([]byte, v.Len())
+			reflect.Copy(reflect.ValueOf(data), v)
+			e.encodeBinary(data)
+			return nil
+		}
+		return e.encodeArray(v, depth)
+	case reflect.Map:
+		if v.IsNil() {
+			e.buf = append(e.buf, 0xc0)
+			return nil
+		}
+		return e.encodeMap(v, depth)
+	case reflect.Struct:
+		return e.encodeStruct(v, depth)
+	case reflect.Pointer, reflect.Interface:
+		return e.encode(v.Elem(), depth+1)
+	default:
+		return fmt.Errorf("beehive-msgpack: unsupported type %s", t)
+	}
+
+	return nil
+}
+
+func (e *encoder) encodeInt(n int64) {
+	switch {
+	case n >= 0:
+		e.encodeUint(uint64(n))
+	case n >= -32:
+		e.buf = append(e.buf, byte(n))
+	case n >= math.MinInt8:
+		e.buf = append(e.buf, 0xd0, byte(n))
+	case n >= math.MinInt16:
+		e.buf = binary.BigEndian.AppendUint16(append(e.buf, 0xd1), uint16(n))
+	case n >= math.MinInt32:
+		e.buf = binary.BigEndian.AppendUint32(append(e.buf, 0xd2), uint32(n))
+	default:
+		e.buf = binary.BigEndian.AppendUint64(append(e.buf, 0xd3), uint64(n))
+	}
+}
+
+func (e *encoder) encodeUint(n uint64) {
+	switch {
+	case n <= 0x7f:
+		e.buf = append(e.buf, byte(n))
+	case n <= math.MaxUint8:
+		e.buf = append(e.buf, 0xcc, byte(n))
+	case n <= math.MaxUint16:
+		e.buf = binary.BigEndian.AppendUint16(append(e.buf, 0xcd), uint16(n))
+	case n <= math.MaxUint32:
+		e.buf = binary.BigEndian.AppendUint32(append(e.buf, 0xce), uint32(n))
+	default:
+		e.buf = binary.BigEndian.AppendUint64(append(e.buf, 0xcf), n)
+	}
+}
+
+func (e *encoder) encodeString(s string) {
+	n := len(s)
+	switch {
+	case n < 32:
+		e.buf = append(e.buf, 0xa0|byte(n))
+	case n <= math.MaxUint8:
+		e.buf = append(e.buf, 0xd9, byte(n))
+	case n <= math.MaxUint16:
+		e.buf = binary.BigEndian.AppendUint16(append(e.buf, 0xda), uint16(n))
+	default:
+		e.buf = binary.BigEndian.AppendUint32(append(e.buf, 0xdb), uint32(n))
+	}
+	e.buf = append(e.buf, s...)
+}
+
+func (e *encoder) encodeBinary(data []byte) {
+	n := len(data)
+	switch {
+	case n <= math.MaxUint8:
+		e.buf = append(e.buf, 0xc4, byte(n))
+	case n <= math.MaxUint16:
+		e.buf = binary.BigEndian.AppendUint16(append(e.buf, 0xc5), uint16(n))
+	default:
+		e.buf = binary.BigEndian.AppendUint32(append(e.buf, 0xc6), uint32(n))
+	}
+	e.buf = append(e.buf, data...)
+}
+
+func (e *encoder) encodeArrayLen(n int) {
+	switch {
+	case n < 16:
+		e.buf = append(e.buf, 0x90|byte(n))
+	case n <= math.MaxUint16:
+		e.buf = binary.BigEndian.AppendUint16(append(e.buf, 0xdc), uint16(n))
+	default:
+		e.buf = binary.BigEndian.AppendUint32(append(e.buf, 0xdd), uint32(n))
+	}
+}
+
+func (e *encoder) encodeMapLen(n int) {
+	switch {
+	case n < 16:
+		e.buf = append(e.buf, 0x80|byte(n))
+	case n <= math.MaxUint16:
+		e.buf = binary.BigEndian.AppendUint16(append(e.buf, 0xde), uint16(n))
+	default:
+		e.buf = binary.BigEndian.AppendUint32(append(e.buf, 0xdf), uint32(n))
+	}
+}
+
+func (e *encoder) encodeArray(v reflect.Value, depth int) error {
+	e.encodeArrayLen(v.Len())
+	for i := range v.Len() {
+		if err := e.encode(v.Index(i), depth+1); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (e *encoder) encodeMap(v reflect.Value, depth int) error {
+	type entry struct {
+		key, value []byte
+	}
+
+	entries := make([]entry, 0, v.Len())
+	var sub encoder
+
+	iter := v.MapRange()
+	for iter.Next() {
+		sub.buf = nil
+		if err := sub.encode(iter.Key(), depth+1); err != nil {
+			return err
+		}
+		key := sub.buf
+
+		sub.buf = nil
+		if err := sub.encode(iter.Value(), depth+1); err != nil {
+			return err
+		}
+
+		entries = append(entries, entry{key: key, value: sub.buf})
+	}
+
+	slices.SortFunc(entries, func(a, b entry) int {
+		return bytes.Compare(a.key, b.key)
+	})
+
+	e.encodeMapLen(len(entries))
+	for _, entry := range entries {
+		e.buf = append(append(e.buf, entry.key...), entry.value...)
+	}
+
+	return nil
+}
+
+func (e *encoder) encodeStruct(v reflect.Value, depth int) error {
+	s := fields.Of(v.Type(), "msgpack")
+
+	values := make([]reflect.Value, 0, len(s.Fields))
+	names := make([]string, 0, len(s.Fields))
+	for idx := range s.Fields {
+		f := &s.Fields[idx]
+
+		fv, ok := f.Value(v, false)
+		if !ok || (f.OmitEmpty && fields.IsEmpty(fv)) {
+			continue
+		}
+
+		values = append(values, fv)
+		names = append(names, f.Name)
+	}
+
+	e.encodeMapLen(len(values))
+	for idx, fv := range values {
+		e.encodeString(names[idx])
+		if err := e.encode(fv, depth+1); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// encodeTime writes the timestamp extension, in its smallest format.
+func (e *encoder) encodeTime(t time.Time) {
+	sec, nsec := t.Unix(), int64(t.Nanosecond())
+
+	switch {
+	case sec>>34 == 0 && nsec == 0 && sec <= math.MaxUint32:
+		e.buf = binary.BigEndian.AppendUint32(append(e.buf, 0xd6, 0xff), uint32(sec))
+	case sec>>34 == 0:
+		e.buf = binary.BigEndian.AppendUint64(append(e.buf, 0xd7, 0xff), uint64(nsec)<<34|uint64(sec))
+	default:
+		e.buf = binary.BigEndian.AppendUint32(append(e.buf, 0xc7, 12, 0xff), uint32(nsec))
+		e.buf = binary.BigEndian.AppendUint64(e.buf, uint64(sec))
+	}
+}
+
+func (e *encoder) encodeExt(typ int8, data []byte) {
+	switch n := len(data); {
+	case n == 1:
+		e.buf = append(e.buf, 0xd4)
+	case n == 2:
+		e.buf = append(e.buf, 0xd5)
+	case n == 4:
+		e.buf = append(e.buf, 0xd6)
+	case n == 8:
+		e.buf = append(e.buf, 0xd7)
+	case n == 16:
+		e.buf = append(e.buf, 0xd8)
+	case n <= math.MaxUint8:
+		e.buf = append(e.buf, 0xc7, byte(n))
+	case n <= math.MaxUint16:
+		e.buf = binary.BigEndian.AppendUint16(append(e.buf, 0xc8), uint16(n))
+	default:
+		e.buf = binary.BigEndian.AppendUint32(append(e.buf, 0xc9), uint32(n))
+	}
+	e.buf = append(append(e.buf, byte(typ)), data...)
+}
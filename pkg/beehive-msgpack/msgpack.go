@@ -0,0 +1,73 @@
+package beehive_msgpack
+
+import (
+	"errors"
+	"io"
+	"reflect"
+	"time"
+
+	beehiveBind "go.sdls.io/beehive/pkg/beehive-bind"
+	beehiveResponder "go.sdls.io/beehive/pkg/beehive-responder"
+)
+
+// MediaType is the media type of MessagePack.
+const MediaType = "application/msgpack"
+
+// Codec encodes responses and decodes request bodies as application/msgpack, it plugs into a
+// beehive_responder.Negotiator and a beehive_bind.Binder.
+type Codec struct{}
+
+// test that Codec implements the responder Encoder and the bind Decoder.
+var (
+	_ beehiveResponder.Encoder = Codec{}
+	_ beehiveBind.Decoder      = Codec{}
+)
+
+func (Codec) MediaType() string { return MediaType }
+
+func (Codec) Encode(w io.Writer, v any) error {
+	return NewEncoder(w).Encode(v)
+}
+
+func (Codec) Decode(r io.Reader, v any) error {
+	return NewDecoder(r).Decode(v)
+}
+
+// Marshaler is implemented by types encoding themselves, MarshalMsgpack returns a single MessagePack value.
+type Marshaler interface {
+	MarshalMsgpack() ([]byte, error)
+}
+
+// Unmarshaler is implemented by types decoding themselves, UnmarshalMsgpack receives a single MessagePack value.
+type Unmarshaler interface {
+	UnmarshalMsgpack(data []byte) error
+}
+
+// Ext is an extension value of an application specific type, decoded into interface values.
+type Ext struct {
+	Type int8
+	Data []byte
+}
+
+// extTimestamp is the extension type of timestamps.
+const extTimestamp = -1
+
+// maxDepth is the maximum nesting of encoded and decoded values.
+const maxDepth = 10000
+
+var (
+	// ErrTooDeep is returned for values nested deeper than 10000 levels, such as cyclic structures.
+	ErrTooDeep = errors.New("beehive-msgpack: maximum nesting depth exceeded")
+
+	// ErrTrailingData is returned by Unmarshal when the data holds more than one value.
+	ErrTrailingData = errors.New("beehive-msgpack: trailing data")
+)
+
+var (
+	marshalerType       = reflect.TypeFor[Marshaler]()
+	unmarshalerType     = reflect.TypeFor[Unmarshaler]()
+	textMarshalerType   = reflect.TypeFor[interface{ MarshalText() ([]byte, error) }]()
+	textUnmarshalerType = reflect.TypeFor[interface{ UnmarshalText([]byte) error }]()
+	timeType            = reflect.TypeFor[time.Time]()
+	extType             = reflect.TypeFor[Ext]()
+)
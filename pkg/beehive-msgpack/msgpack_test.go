@@ -0,0 +1,352 @@
+package beehive_msgpack
+
+import (
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"go.sdls.io/beehive/pkg/beehive"
+	beehiveBind "go.sdls.io/beehive/pkg/beehive-bind"
+	beehiveResponder "go.sdls.io/beehive/pkg/beehive-responder"
+)
+
+func TestMarshal(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		value any
+		hex   string
+	}{
+		{nil, "c0"},
+		{false, "c2"},
+		{true, "c3"},
+		{0, "00"},
+		{127, "7f"},
+		{128, "cc80"},
+		{256, "cd0100"},
+		{65536, "ce00010000"},
+		{uint64(math.MaxUint64), "cfffffffffffffffff"},
+		{-1, "ff"},
+		{-32, "e0"},
+		{-33, "d0df"},
+		{-129, "d1ff7f"},
+		{-32769, "d2ffff7fff"},
+		{int64(math.MinInt64), "d38000000000000000"},
+		{float32(1.5), "ca3fc00000"},
+		{1.5, "cb3ff8000000000000"},
+		{"", "a0"},
+		{"a", "a161"},
+		{strings.Repeat("a", 32), "d920" + strings.Repeat("61", 32)},
+		{[]byte{1, 2}, "c4020102"},
+		{[2]byte{1, 2}, "c4020102"},
+		{[]int{1, 2}, "920102"},
+		{[]int(nil), "c0"},
+		{map[string]int{"b": 2, "a": 1}, "82a16101a16202"},
+		{time.Unix(1, 0), "d6ff00000001"},
+		{time.Unix(1, 1), "d7ff0000000400000001"},
+		{time.Unix(-1, 0), "c70cff00000000ffffffffffffffff"},
+		{Ext{Type: 5, Data: []byte{1}}, "d40501"},
+	}
+
+	for _, test := range tests {
+		data, err := Marshal(test.value)
+		if err != nil {
+			t.Errorf("%v: unexpected error %v", test.value, err)
+			continue
+		}
+		if got := hex.EncodeToString(data); got != test.hex {
+			t.Errorf("%v: expected %s, got %s", test.value, test.hex, got)
+		}
+	}
+}
+
+type testAddress struct {
+	City string `msgpack:"city"`
+}
+
+type testPerson struct {
+	testAddress
+	Name     string            `msgpack:"name"`
+	Age      int               `json:"age"`
+	Email    string            `msgpack:"email,omitempty"`
+	Tags     []string          `msgpack:"tags"`
+	Labels   map[string]string `msgpack:"labels,omitempty"`
+	Born     time.Time         `msgpack:"born"`
+	Manager  *testPerson       `msgpack:"manager,omitempty"`
+	Ignored  string            `msgpack:"-"`
+	internal int
+}
+
+func TestRoundTrip(t *testing.T) {
+	t.Parallel()
+
+	in := testPerson{
+		testAddress: testAddress{City: "Paris"},
+		Name:        "Jane",
+		Age:         30,
+		Tags:        []string{"a", "b"},
+		Born:        time.Date(1990, 1, 2, 3, 4, 5, 6, time.UTC),
+		Manager:     &testPerson{Name: "John", Born: time.Unix(0, 0).UTC()},
+		Ignored:     "ignored",
+	}
+
+	data, err := Marshal(in)
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+
+	var out testPerson
+	if err := Unmarshal(data, &out); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+
+	in.Ignored = ""
+	if !reflect.DeepEqual(in, out) {
+		t.Errorf("expected %+v, got %+v", in, out)
+	}
+
+	var generic map[string]any
+	if err := Unmarshal(data, &generic); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if _, ok := generic["email"]; ok {
+		t.Errorf("expected email to be omitted")
+	}
+	if generic["city"] != "Paris" || generic["age"] != int64(30) || !generic["born"].(time.Time).Equal(in.Born) {
+		t.Errorf("unexpected generic value %v", generic)
+	}
+}
+
+func TestUnmarshal_any(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		hex  string
+		want any
+	}{
+		{"c0", nil},
+		{"c3", true},
+		{"7f", int64(127)},
+		{"cfffffffffffffffff", uint64(math.MaxUint64)},
+		{"d0df", int64(-33)},
+		{"ca3fc00000", 1.5},
+		{"a161", "a"},
+		{"c4020102", []byte{1, 2}},
+		{"92c0a161", []any{nil, "a"}},
+		{"81a16101", map[string]any{"a": int64(1)}},
+		{"820102a161c2", map[any]any{int64(1): int64(2), "a": false}},
+		{"d40501", Ext{Type: 5, Data: []byte{1}}},
+		{"d6ff00000001", time.Unix(1, 0).UTC()},
+	}
+
+	for _, test := range tests {
+		data, _ := hex.DecodeString(test.hex)
+
+		var got any
+		if err := Unmarshal(data, &got); err != nil {
+			t.Errorf("%s: unexpected error %v", test.hex, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%s: expected %#v, got %#v", test.hex, test.want, got)
+		}
+	}
+}
+
+func TestUnmarshal_errors(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name   string
+		hex    string
+		target any
+		err    string
+	}{
+		{"overflow", "cd0100", new(int8), "beehive-msgpack: 256 overflows int8"},
+		{"negative unsigned", "ff", new(uint), "beehive-msgpack: cannot decode integer into uint"},
+		{"type mismatch", "a161", new(int), "beehive-msgpack: cannot decode string into int"},
+		{"truncated", "92a1", new([]string), "unexpected EOF"},
+		{"forged length", "dbffffffff", new(string), "unexpected EOF"},
+		{"invalid", "c1", new(any), "beehive-msgpack: invalid type byte 0xc1"},
+		{"trailing", "0000", new(int), ErrTrailingData.Error()},
+		{"unhashable key", "8190c0", new(any), "beehive-msgpack: unsupported map key type []interface {}"},
+		{"too deep", strings.Repeat("91", maxDepth+2) + "c0", new(any), ErrTooDeep.Error()},
+	}
+
+	for _, test := range tests {
+		data, _ := hex.DecodeString(test.hex)
+		err := Unmarshal(data, test.target)
+		if err == nil || err.Error() != test.err {
+			t.Errorf("%s: expected error %q, got %v", test.name, test.err, err)
+		}
+	}
+
+	if err := Unmarshal([]byte{0}, 0); err == nil {
+		t.Errorf("expected an error for a non pointer")
+	}
+}
+
+func TestUnmarshal_skip(t *testing.T) {
+	t.Parallel()
+
+	var in struct {
+		Name  string         `msgpack:"name"`
+		Extra map[string]any `msgpack:"extra"`
+		After int            `msgpack:"after"`
+	}
+	in.Name, in.Extra, in.After = "Jane", map[string]any{"list": []any{1, "two", Ext{Type: 1, Data: []byte{3}}}}, 3
+
+	data, err := Marshal(in)
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+
+	var out struct {
+		Name  string
+		After int
+	}
+	if err := Unmarshal(data, &out); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if out.Name != "Jane" || out.After != 3 {
+		t.Errorf("unexpected value %+v", out)
+	}
+}
+
+type testPoint struct {
+	X, Y int
+}
+
+func (p *testPoint) MarshalMsgpack() ([]byte, error) {
+	return Marshal([]int{p.X, p.Y})
+}
+
+func (p *testPoint) UnmarshalMsgpack(data []byte) error {
+	var xy []int
+	if err := Unmarshal(data, &xy); err != nil {
+		return err
+	}
+	if len(xy) != 2 {
+		return errors.New("expected two coordinates")
+	}
+	p.X, p.Y = xy[0], xy[1]
+	return nil
+}
+
+func TestMarshaler(t *testing.T) {
+	t.Parallel()
+
+	in := struct {
+		Points []testPoint `msgpack:"points"`
+	}{Points: []testPoint{{1, 2}, {3, 4}}}
+
+	data, err := Marshal(&in)
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if got, want := hex.EncodeToString(data), "81a6706f696e747392920102920304"; got != want {
+		t.Errorf("expected %s, got %s", want, got)
+	}
+
+	var out struct {
+		Points []testPoint `msgpack:"points"`
+	}
+	if err := Unmarshal(data, &out); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if !reflect.DeepEqual(in, out) {
+		t.Errorf("expected %+v, got %+v", in, out)
+	}
+}
+
+func TestDecoder_stream(t *testing.T) {
+	t.Parallel()
+
+	var buf bytes.Buffer
+	enc := NewEncoder(&buf)
+	for i := range 3 {
+		if err := enc.Encode(i); err != nil {
+			t.Fatalf("unexpected error %v", err)
+		}
+	}
+
+	dec := NewDecoder(&buf)
+	for i := range 3 {
+		var n int
+		if err := dec.Decode(&n); err != nil || n != i {
+			t.Errorf("expected %d, got %d and %v", i, n, err)
+		}
+	}
+
+	var n int
+	if err := dec.Decode(&n); err == nil {
+		t.Errorf("expected an error at the end of the stream")
+	}
+}
+
+func TestCodec(t *testing.T) {
+	t.Parallel()
+
+	negotiator := &beehiveResponder.Negotiator{
+		Encoders: slices.Concat(beehiveResponder.DefaultNegotiator.Encoders, []beehiveResponder.Encoder{Codec{}}),
+	}
+	binder := &beehiveBind.Binder{
+		Decoders: slices.Concat(beehiveBind.DefaultBinder.Decoders, []beehiveBind.Decoder{Codec{}}),
+	}
+	mapper := &beehiveResponder.ErrorMapper{}
+
+	router := beehive.NewRouter()
+	router.Handle("POST", "/people", mapper.Handle(func(ctx *beehive.Context) (beehive.Responder, error) {
+		var person testPerson
+		if err := binder.Bind(ctx, &person); err != nil {
+			return nil, err
+		}
+		person.Age++
+		return negotiator.Respond(person, http.StatusCreated), nil
+	}))
+
+	body, err := Marshal(map[string]any{"name": "Jane", "age": 30})
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+
+	r := httptest.NewRequest("POST", "/people", bytes.NewReader(body))
+	r.Header.Set("Content-Type", MediaType)
+	r.Header.Set("Accept", MediaType)
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, r)
+
+	if w.Code != http.StatusCreated {
+		t.Fatalf("expected %d, got %d: %s", http.StatusCreated, w.Code, w.Body.String())
+	}
+	if got := w.Header().Get("Content-Type"); got != MediaType {
+		t.Errorf("expected %s, got %s", MediaType, got)
+	}
+
+	var person testPerson
+	if err := Unmarshal(w.Body.Bytes(), &person); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if person.Name != "Jane" || person.Age != 31 {
+		t.Errorf("unexpected response %+v", person)
+	}
+
+	r = httptest.NewRequest("POST", "/people", strings.NewReader("\x81\xa4name"))
+	r.Header.Set("Content-Type", MediaType)
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, r)
+
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("expected %d, got %d", http.StatusBadRequest, w.Code)
+	}
+}
@@ -0,0 +1,122 @@
+package beehive_rate
+
+import (
+	"fmt"
+	"net/netip"
+	"strings"
+
+	"go.sdls.io/beehive/internal/clientip"
+	"go.sdls.io/beehive/pkg/beehive"
+)
+
+// KeyFunc extracts the rate limiting key from a request. The returned bool reports whether a key was found, if it
+// is false the Config.MissingKey policy decides what happens with the request.
+type KeyFunc func(ctx *beehive.Context) (string, bool)
+
+// MissingKeyPolicy decides how requests without a key (as reported by KeyFunc) are handled.
+type MissingKeyPolicy int
+
+const (
+	// MissingKeyShared limits all requests without a key in a single shared bucket with an empty key. This is the
+	// legacy behaviour of Limit.
+	MissingKeyShared MissingKeyPolicy = iota
+
+	// MissingKeySkip lets requests without a key pass without being limited or counted.
+	MissingKeySkip
+
+	// MissingKeyDeny rejects requests without a key using the configured responder.
+	MissingKeyDeny
+)
+
+// KeyHeader returns a KeyFunc that uses the value of the given request header as key.
+func KeyHeader(header string) KeyFunc {
+	return func(ctx *beehive.Context) (string, bool) {
+		key := ctx.Request.Header.Get(header)
+		return key, key != ""
+	}
+}
+
+// KeyRemoteIP returns a KeyFunc that uses the client IP address as key. When the direct peer (http.Request
+// RemoteAddr) is one of the trusted proxies, the X-Forwarded-For header is walked from right to left and the first
+// address that is not a trusted proxy is used instead. Without trusted proxies X-Forwarded-For is never consulted,
+// as it can be freely set by any client.
+func KeyRemoteIP(trustedProxies ...netip.Prefix) KeyFunc {
+	return func(ctx *beehive.Context) (string, bool) {
+		addr, ok := RemoteIP(ctx, trustedProxies)
+		if !ok {
+			return "", false
+		}
+
+		return addr.String(), true
+	}
+}
+
+// RemoteIP returns the client IP address of the request, see KeyRemoteIP for how trustedProxies are handled.
+func RemoteIP(ctx *beehive.Context, trustedProxies []netip.Prefix) (netip.Addr, bool) {
+	return clientip.FromRequest(ctx.Request, trustedProxies)
+}
+
+// KeyRoute returns a KeyFunc that uses the request method and the matched route pattern (see beehive.Context.Route)
+// as key, such that all requests on the same route share a bucket.
+func KeyRoute() KeyFunc {
+	return func(ctx *beehive.Context) (string, bool) {
+		route := ctx.Route()
+		if route == "" {
+			return "", false
+		}
+
+		return ctx.Request.Method + " " + route, true
+	}
+}
+
+// KeyContext returns a KeyFunc that uses the value stored in the context under the given key, usually the
+// authenticated principal set by an auth middleware. The value must be a non-empty string or a fmt.Stringer.
+func KeyContext(key any) KeyFunc {
+	return func(ctx *beehive.Context) (string, bool) {
+		switch value := ctx.Value(key).(type) {
+		case string:
+			return value, value != ""
+		case fmt.Stringer:
+			str := value.String()
+			return str, str != ""
+		default:
+			return "", false
+		}
+	}
+}
+
+// KeyComposite returns a KeyFunc that joins the keys of all given KeyFunc with '|'. The key is missing if any of the
+// keys are missing.
+func KeyComposite(keys ...KeyFunc) KeyFunc {
+	return func(ctx *beehive.Context) (string, bool) {
+		var sb strings.Builder
+
+		for idx, keyFunc := range keys {
+			key, ok := keyFunc(ctx)
+			if !ok {
+				return "", false
+			}
+
+			if idx != 0 {
+				sb.WriteByte('|')
+			}
+			sb.WriteString(key)
+		}
+
+		return sb.String(), true
+	}
+}
+
+// KeyFirst returns a KeyFunc that returns the first found key of the given KeyFunc, for example the authenticated
+// user when present and the remote IP otherwise.
+func KeyFirst(keys ...KeyFunc) KeyFunc {
+	return func(ctx *beehive.Context) (string, bool) {
+		for _, keyFunc := range keys {
+			if key, ok := keyFunc(ctx); ok {
+				return key, true
+			}
+		}
+
+		return "", false
+	}
+}
@@ -0,0 +1,168 @@
+package beehive_rate
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"testing"
+
+	"go.sdls.io/beehive/pkg/beehive"
+)
+
+type testKeyStringer struct{ id string }
+
+func (s testKeyStringer) String() string { return s.id }
+
+func testKeyContext(r *http.Request) *beehive.Context {
+	return &beehive.Context{
+		Request: r,
+		Context: r.Context(),
+	}
+}
+
+func TestKeyHeader(t *testing.T) {
+	t.Parallel()
+
+	r := httptest.NewRequest("GET", "/", nil)
+	keyFunc := KeyHeader("X-Api-Key")
+
+	if key, ok := keyFunc(testKeyContext(r)); ok || key != "" {
+		t.Errorf("expected missing key, got %q, %v", key, ok)
+	}
+
+	r.Header.Set("X-Api-Key", "foo")
+	if key, ok := keyFunc(testKeyContext(r)); !ok || key != "foo" {
+		t.Errorf("expected %q, got %q, %v", "foo", key, ok)
+	}
+}
+
+func TestKeyRemoteIP(t *testing.T) {
+	t.Parallel()
+
+	trusted := []netip.Prefix{
+		netip.MustParsePrefix("10.0.0.0/8"),
+		netip.MustParsePrefix("::1/128"),
+	}
+
+	tests := []struct {
+		name       string
+		remoteAddr string
+		forwarded  []string
+		trusted    []netip.Prefix
+		want       string
+		wantOk     bool
+	}{
+		{"no proxy", "192.0.2.1:1234", nil, nil, "192.0.2.1", true},
+		{"no port", "192.0.2.1", nil, nil, "192.0.2.1", true},
+		{"bad remote", "not an ip", nil, nil, "", false},
+		{"ignore forwarded untrusted", "192.0.2.1:1234", []string{"198.51.100.1"}, nil, "192.0.2.1", true},
+		{"ignore forwarded untrusted peer", "192.0.2.1:1234", []string{"198.51.100.1"}, trusted, "192.0.2.1", true},
+		{"trusted peer", "10.0.0.1:1234", []string{"198.51.100.1"}, trusted, "198.51.100.1", true},
+		{"trusted chain", "10.0.0.1:1234", []string{"198.51.100.1, 203.0.113.7, 10.1.1.1"}, trusted, "203.0.113.7", true},
+		{"trusted chain headers", "[::1]:1234", []string{"198.51.100.1", "203.0.113.7, 10.1.1.1"}, trusted, "203.0.113.7", true},
+		{"all trusted", "10.0.0.1:1234", []string{"10.0.0.2"}, trusted, "10.0.0.2", true},
+		{"bad hop", "10.0.0.1:1234", []string{"198.51.100.1, garbage"}, trusted, "10.0.0.1", true},
+		{"mapped", "[::ffff:192.0.2.1]:1234", nil, nil, "192.0.2.1", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			r := httptest.NewRequest("GET", "/", nil)
+			r.RemoteAddr = tt.remoteAddr
+			for _, value := range tt.forwarded {
+				r.Header.Add("X-Forwarded-For", value)
+			}
+
+			key, ok := KeyRemoteIP(tt.trusted...)(testKeyContext(r))
+			if ok != tt.wantOk || key != tt.want {
+				t.Errorf("expected %q, %v, got %q, %v", tt.want, tt.wantOk, key, ok)
+			}
+		})
+	}
+}
+
+func TestKeyRoute(t *testing.T) {
+	t.Parallel()
+
+	var keys []string
+	keyFunc := KeyRoute()
+
+	router := beehive.NewRouter()
+	router.WhenNotFound = func(ctx *beehive.Context) beehive.Responder {
+		if _, ok := keyFunc(ctx); ok {
+			t.Errorf("expected missing key")
+		}
+		return nil
+	}
+	router.Handle("GET", "/users/*", func(ctx *beehive.Context) beehive.Responder {
+		key, _ := keyFunc(ctx)
+		keys = append(keys, key)
+		return nil
+	})
+
+	for _, path := range []string{"/users/1", "/users/2", "/missing"} {
+		router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", path, nil))
+	}
+
+	if len(keys) != 2 || keys[0] != "GET /users/*" || keys[1] != keys[0] {
+		t.Errorf("unexpected keys %v", keys)
+	}
+}
+
+func TestKeyContext(t *testing.T) {
+	t.Parallel()
+
+	type userKey struct{}
+	keyFunc := KeyContext(userKey{})
+
+	r := httptest.NewRequest("GET", "/", nil)
+	if _, ok := keyFunc(testKeyContext(r)); ok {
+		t.Errorf("expected missing key")
+	}
+
+	ctx := testKeyContext(r).WithValue(userKey{}, "alice")
+	if key, ok := keyFunc(ctx); !ok || key != "alice" {
+		t.Errorf("expected %q, got %q", "alice", key)
+	}
+
+	ctx = testKeyContext(r).WithValue(userKey{}, testKeyStringer{id: "bob"})
+	if key, ok := keyFunc(ctx); !ok || key != "bob" {
+		t.Errorf("expected %q, got %q", "bob", key)
+	}
+
+	ctx = testKeyContext(r).WithValue(userKey{}, 42)
+	if _, ok := keyFunc(ctx); ok {
+		t.Errorf("expected missing key")
+	}
+}
+
+func TestKeyComposite(t *testing.T) {
+	t.Parallel()
+
+	type userKey struct{}
+
+	r := httptest.NewRequest("GET", "/", nil)
+	r.RemoteAddr = "192.0.2.1:1234"
+	r = r.WithContext(context.WithValue(r.Context(), userKey{}, "alice"))
+
+	key, ok := KeyComposite(KeyContext(userKey{}), KeyRemoteIP())(testKeyContext(r))
+	if !ok || key != "alice|192.0.2.1" {
+		t.Errorf("expected %q, got %q", "alice|192.0.2.1", key)
+	}
+
+	if _, ok = KeyComposite(KeyRemoteIP(), KeyHeader("X-Missing"))(testKeyContext(r)); ok {
+		t.Errorf("expected missing key")
+	}
+
+	key, ok = KeyFirst(KeyHeader("X-Missing"), KeyRemoteIP())(testKeyContext(r))
+	if !ok || key != "192.0.2.1" {
+		t.Errorf("expected %q, got %q", "192.0.2.1", key)
+	}
+
+	if _, ok = KeyFirst(KeyHeader("X-Missing"))(testKeyContext(r)); ok {
+		t.Errorf("expected missing key")
+	}
+}
@@ -0,0 +1,78 @@
+package beehive_rate
+
+import (
+	"sync"
+	"time"
+)
+
+// MemoryLimiter is an in-process fixed window WeightedLimiter. Every key gets a counter that resets Window after the
+// first unit was consumed. MemoryLimiter is safe for concurrent use. Expired keys are removed lazily, at most once
+// per window.
+type MemoryLimiter struct {
+	window time.Duration
+	now    func() time.Time
+
+	mu      sync.Mutex
+	buckets map[string]*memoryBucket
+	sweepAt time.Time
+}
+
+type memoryBucket struct {
+	count     int
+	expiresAt time.Time
+}
+
+// test that MemoryLimiter implements WeightedLimiter.
+var _ WeightedLimiter = &MemoryLimiter{}
+
+// NewMemoryLimiter returns a MemoryLimiter with the given window duration.
+func NewMemoryLimiter(window time.Duration) *MemoryLimiter {
+	return &MemoryLimiter{
+		window:  window,
+		now:     time.Now,
+		buckets: make(map[string]*memoryBucket),
+	}
+}
+
+// Window returns the window duration of the MemoryLimiter.
+func (m *MemoryLimiter) Window() time.Duration {
+	return m.window
+}
+
+// Limit consumes a single unit, see LimitN.
+func (m *MemoryLimiter) Limit(key string) (int, time.Time) {
+	return m.LimitN(key, 1)
+}
+
+// LimitN consumes n units for the given key and returns the units consumed in the current window and when the window
+// resets.
+func (m *MemoryLimiter) LimitN(key string, n int) (int, time.Time) {
+	now := m.now()
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if now.After(m.sweepAt) {
+		for k, bucket := range m.buckets {
+			if !now.Before(bucket.expiresAt) {
+				delete(m.buckets, k)
+			}
+		}
+		m.sweepAt = now.Add(m.window)
+	}
+
+	bucket := m.buckets[key]
+	if bucket == nil {
+		bucket = &memoryBucket{}
+		m.buckets[key] = bucket
+	}
+
+	if !now.Before(bucket.expiresAt) {
+		bucket.count = 0
+		bucket.expiresAt = now.Add(m.window)
+	}
+
+	bucket.count += n
+
+	return bucket.count, bucket.expiresAt
+}
@@ -0,0 +1,45 @@
+package beehive_rate
+
+import (
+	"testing"
+	"time"
+)
+
+func TestMemoryLimiter(t *testing.T) {
+	t.Parallel()
+
+	now := time.Unix(1700000000, 0)
+	limiter := NewMemoryLimiter(time.Minute)
+	limiter.now = func() time.Time { return now }
+
+	if limiter.Window() != time.Minute {
+		t.Errorf("expected window %v, got %v", time.Minute, limiter.Window())
+	}
+
+	current, expiresAt := limiter.Limit("foo")
+	if current != 1 || !expiresAt.Equal(now.Add(time.Minute)) {
+		t.Errorf("unexpected %d, %v", current, expiresAt)
+	}
+
+	current, _ = limiter.LimitN("foo", 5)
+	if current != 6 {
+		t.Errorf("expected %d, got %d", 6, current)
+	}
+
+	current, _ = limiter.Limit("bar")
+	if current != 1 {
+		t.Errorf("expected %d, got %d", 1, current)
+	}
+
+	now = now.Add(time.Minute)
+	current, expiresAt = limiter.LimitN("foo", 2)
+	if current != 2 || !expiresAt.Equal(now.Add(time.Minute)) {
+		t.Errorf("unexpected %d, %v", current, expiresAt)
+	}
+
+	now = now.Add(time.Second)
+	limiter.Limit("foo")
+	if _, found := limiter.buckets["bar"]; found {
+		t.Errorf("expected expired bucket to be removed")
+	}
+}
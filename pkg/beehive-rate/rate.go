@@ -1,7 +1,9 @@
 package beehive_rate
 
 import (
+	"math"
 	"strconv"
+	"strings"
 	"time"
 
 	"go.sdls.io/beehive/pkg/beehive"
@@ -11,30 +13,229 @@ type Limiter interface {
 	Limit(key string) (int, time.Time)
 }
 
+// WeightedLimiter is a Limiter that can consume more than one unit at once. Limiter implementations that do not
+// implement WeightedLimiter are called n times when a request costs n units.
+type WeightedLimiter interface {
+	Limiter
+
+	// LimitN consumes n units for the given key and returns the current usage and when it resets.
+	LimitN(key string, n int) (int, time.Time)
+}
+
+// Rule is a single limit enforced by Config, for example 100 units per minute.
+type Rule struct {
+	// Name identifies the rule in the RateLimit-Policy and RateLimit headers. If empty, "default" is used for a single
+	// rule and the rule index otherwise.
+	Name string
+
+	Limiter Limiter
+	Limit   int
+
+	// Window is the duration of the Limiter window, used only for the RateLimit-Policy header. If zero, the Limiter
+	// Window method is used when available, otherwise the w parameter is omitted.
+	Window time.Duration
+}
+
+// HeaderMode selects which rate limit headers are sent on responses.
+type HeaderMode int
+
+const (
+	// HeaderModeAll sends both the legacy X-RateLimit-* headers and the IETF draft RateLimit and RateLimit-Policy
+	// headers.
+	HeaderModeAll HeaderMode = iota
+
+	// HeaderModeLegacy sends X-RateLimit-Limit, X-RateLimit-Remaining and X-RateLimit-Reset. The reset is expressed
+	// as a Unix epoch in seconds.
+	HeaderModeLegacy
+
+	// HeaderModeDraft sends the IETF draft RateLimit-Policy and RateLimit headers. The reset is expressed as delta
+	// seconds in the t parameter.
+	HeaderModeDraft
+
+	// HeaderModeNone sends no rate limit headers, except for Retry-After on limited requests.
+	HeaderModeNone
+)
+
+// Config describes a rate limiting middleware, use HandlerFunc to obtain the beehive.HandlerFunc.
+type Config struct {
+	// Key extracts the rate limiting key from the request. If nil, KeyRemoteIP without trusted proxies is used.
+	Key KeyFunc
+
+	// MissingKey decides what happens with requests for which Key did not find a key. By default, they all share
+	// the same bucket.
+	MissingKey MissingKeyPolicy
+
+	// Rules are all evaluated for each request, the request is limited if any of them is exceeded. The headers are
+	// set based on the most restrictive rule (the one with the least remaining units).
+	Rules []Rule
+
+	// Cost returns how many units the request consumes on every rule. If nil, each request costs 1. Costs lower
+	// than 1 are treated as 1.
+	Cost func(ctx *beehive.Context) int
+
+	// Headers selects which rate limit headers are sent. Retry-After (as delta seconds) is always sent on limited
+	// requests when the reset time is known.
+	Headers HeaderMode
+
+	// Responder is called when the request is limited. If nil, a 429 Too Many Requests is sent with no body.
+	Responder ResponderFunc
+}
+
+// Limit returns a rate limiting beehive.HandlerFunc using the given request header value as key. Requests without the
+// header share the same bucket, use Config with a different KeyFunc and MissingKeyPolicy for more control.
 func Limit(header string, limiter Limiter, limit int, responderFunc ResponderFunc) beehive.HandlerFunc {
-	headerLimit := []string{strconv.Itoa(limit)}
+	config := &Config{
+		Key: KeyHeader(header),
+		Rules: []Rule{{
+			Limiter: limiter,
+			Limit:   limit,
+		}},
+		Responder: responderFunc,
+	}
+
+	return config.HandlerFunc()
+}
+
+type ruleState struct {
+	name        string
+	limiter     Limiter
+	limit       int
+	headerLimit []string
+}
+
+// HandlerFunc returns the rate limiting beehive.HandlerFunc for the Config. Changes to the Config after calling
+// HandlerFunc are not reflected in the returned beehive.HandlerFunc. HandlerFunc panics if Config has no Rules.
+func (c *Config) HandlerFunc() beehive.HandlerFunc {
+	if len(c.Rules) == 0 {
+		panic("beehive-rate: config has no rules")
+	}
+
+	keyFunc := c.Key
+	if keyFunc == nil {
+		keyFunc = KeyRemoteIP()
+	}
+	missingKey := c.MissingKey
+	costFunc := c.Cost
+	responderFunc := c.Responder
+	headerMode := c.Headers
+
+	legacyHeaders := headerMode == HeaderModeAll || headerMode == HeaderModeLegacy
+	draftHeaders := headerMode == HeaderModeAll || headerMode == HeaderModeDraft
+
+	rules := make([]ruleState, len(c.Rules))
+	policies := make([]string, len(c.Rules))
+	for idx, rule := range c.Rules {
+		name := rule.Name
+		if name == "" {
+			if len(c.Rules) == 1 {
+				name = "default"
+			} else {
+				name = strconv.Itoa(idx)
+			}
+		}
+
+		rules[idx] = ruleState{
+			name:        strconv.Quote(name),
+			limiter:     rule.Limiter,
+			limit:       rule.Limit,
+			headerLimit: []string{strconv.Itoa(rule.Limit)},
+		}
+
+		window := rule.Window
+		if w, ok := rule.Limiter.(interface{ Window() time.Duration }); ok && window == 0 {
+			window = w.Window()
+		}
+
+		policies[idx] = rules[idx].name + ";q=" + strconv.Itoa(rule.Limit)
+		if window > 0 {
+			policies[idx] += ";w=" + strconv.Itoa(int(window.Seconds()))
+		}
+	}
+
+	headerPolicy := []string{strings.Join(policies, ", ")}
 
 	return func(ctx *beehive.Context) beehive.Responder {
-		key := ctx.Request.Header.Get(header)
+		key, ok := keyFunc(ctx)
+		if !ok {
+			switch missingKey {
+			case MissingKeySkip:
+				return nil
+			case MissingKeyDeny:
+				if responderFunc != nil {
+					return responderFunc(key, rules[0].limit, 0, time.Time{})
+				}
+
+				return defaultResponder
+			case MissingKeyShared:
+				key = ""
+			}
+		}
+
+		cost := 1
+		if costFunc != nil {
+			cost = max(costFunc(ctx), 1)
+		}
+
+		var (
+			limited   bool
+			worst     *ruleState
+			remaining int
+			current   int
+			expiresAt time.Time
+		)
+
+		for idx := range rules {
+			rule := &rules[idx]
+
+			ruleCurrent, ruleExpiresAt := consume(rule.limiter, key, cost)
+			ruleRemaining := max(rule.limit-ruleCurrent, 0)
+
+			if ruleCurrent >= rule.limit {
+				limited = true
+			}
 
-		w := ctx.ResponseWriter
-		h := w.Header()
+			if worst == nil || ruleRemaining < remaining ||
+				(ruleRemaining == remaining && ruleExpiresAt.After(expiresAt)) {
+				worst = rule
+				remaining = ruleRemaining
+				current = ruleCurrent
+				expiresAt = ruleExpiresAt
+			}
+		}
 
-		current, expiresAt := limiter.Limit(key)
+		h := ctx.ResponseWriter.Header()
 
-		h["X-RateLimit-Limit"] = headerLimit
-		h["X-RateLimit-Remaining"] = []string{strconv.Itoa(limit - current)}
+		var resetDelta, resetEpoch int64
+		if !expiresAt.IsZero() {
+			now := time.Now()
+			resetDelta = max(int64(math.Ceil(expiresAt.Sub(now).Seconds())), 0)
+			resetEpoch = now.Unix() + resetDelta
+		}
 
-		if current >= limit {
+		if legacyHeaders {
+			h["X-RateLimit-Limit"] = worst.headerLimit
+			h["X-RateLimit-Remaining"] = []string{strconv.Itoa(remaining)}
 			if !expiresAt.IsZero() {
-				expiresAtSeconds := expiresAt.UTC().Second()
+				h["X-RateLimit-Reset"] = []string{strconv.FormatInt(resetEpoch, 10)}
+			}
+		}
 
-				h["X-RateLimit-Reset"] = []string{strconv.Itoa(expiresAtSeconds)}
-				h["Retry-After"] = []string{strconv.Itoa(expiresAtSeconds - int(time.Now().UTC().Unix()))}
+		if draftHeaders {
+			h["RateLimit-Policy"] = headerPolicy
+			headerRateLimit := worst.name + ";r=" + strconv.Itoa(remaining)
+			if !expiresAt.IsZero() {
+				headerRateLimit += ";t=" + strconv.FormatInt(resetDelta, 10)
+			}
+			h["RateLimit"] = []string{headerRateLimit}
+		}
+
+		if limited {
+			if !expiresAt.IsZero() {
+				h["Retry-After"] = []string{strconv.FormatInt(resetDelta, 10)}
 			}
 
 			if responderFunc != nil {
-				return responderFunc(key, limit, current, expiresAt)
+				return responderFunc(key, worst.limit, current, expiresAt)
 			} else {
 				return defaultResponder
 			}
@@ -43,3 +244,19 @@ func Limit(header string, limiter Limiter, limit int, responderFunc ResponderFun
 		return nil
 	}
 }
+
+func consume(limiter Limiter, key string, cost int) (int, time.Time) {
+	if weighted, ok := limiter.(WeightedLimiter); ok {
+		return weighted.LimitN(key, cost)
+	}
+
+	var (
+		current   int
+		expiresAt time.Time
+	)
+	for range cost {
+		current, expiresAt = limiter.Limit(key)
+	}
+
+	return current, expiresAt
+}
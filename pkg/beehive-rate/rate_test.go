@@ -3,6 +3,7 @@ package beehive_rate
 import (
 	"net/http"
 	"net/http/httptest"
+	"strconv"
 	"testing"
 	"time"
 
@@ -151,3 +152,286 @@ func TestRateLimit_noKey(t *testing.T) {
 		t.Errorf("expected %d, got %d", 1, testLimiter.rates[""])
 	}
 }
+
+func TestConfig_MissingKey(t *testing.T) {
+	t.Parallel()
+
+	handler := func(ctx *beehive.Context) beehive.Responder {
+		return &beehive.DefaultResponder{
+			Message: "ok",
+			Status:  http.StatusOK,
+		}
+	}
+
+	policies := map[MissingKeyPolicy]int{
+		MissingKeyShared: http.StatusTooManyRequests,
+		MissingKeySkip:   http.StatusOK,
+		MissingKeyDeny:   http.StatusTooManyRequests,
+	}
+
+	for policy, wantCode := range policies {
+		testLimiter := &testRateLimiter{
+			rates:       make(map[string]int),
+			expires:     make(map[string]time.Time),
+			expireAfter: time.Hour,
+		}
+
+		config := &Config{
+			Key:        KeyHeader("X-User"),
+			MissingKey: policy,
+			Rules: []Rule{{
+				Limiter: testLimiter,
+				Limit:   2,
+			}},
+		}
+
+		router := beehive.NewRouter()
+		router.Handle("GET", "/foo/bar", config.HandlerFunc(), handler)
+
+		var code int
+		for range 3 {
+			w := httptest.NewRecorder()
+			r := httptest.NewRequest("GET", "/foo/bar", nil)
+			router.ServeHTTP(w, r)
+			code = w.Code
+		}
+
+		if code != wantCode {
+			t.Errorf("policy %d: expected status code %d, got %d", policy, wantCode, code)
+		}
+
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest("GET", "/foo/bar", nil)
+		r.Header.Set("X-User", "alice")
+		router.ServeHTTP(w, r)
+
+		if w.Code != http.StatusOK {
+			t.Errorf("policy %d: expected status code %d for keyed request, got %d", policy, http.StatusOK, w.Code)
+		}
+	}
+}
+
+// testHeader returns the first value of a header set with a non-canonical key.
+func testHeader(h http.Header, key string) string {
+	if values := h[key]; len(values) > 0 {
+		return values[0]
+	}
+	return ""
+}
+
+func TestConfig_Rules(t *testing.T) {
+	t.Parallel()
+
+	handler := func(ctx *beehive.Context) beehive.Responder {
+		return &beehive.DefaultResponder{
+			Message: "ok",
+			Status:  http.StatusOK,
+		}
+	}
+
+	minute := NewMemoryLimiter(time.Minute)
+	day := NewMemoryLimiter(24 * time.Hour)
+	counting := &testRateLimiter{
+		rates:       make(map[string]int),
+		expires:     make(map[string]time.Time),
+		expireAfter: time.Hour,
+	}
+
+	config := &Config{
+		Key: KeyHeader("X-User"),
+		Rules: []Rule{
+			{Name: "minute", Limiter: minute, Limit: 100},
+			{Name: "day", Limiter: day, Limit: 50},
+			{Name: "hour", Limiter: counting, Limit: 1000, Window: time.Hour},
+		},
+		Cost: func(ctx *beehive.Context) int {
+			if ctx.Request.URL.Path == "/export" {
+				return 10
+			}
+			return 0
+		},
+	}
+
+	router := beehive.NewRouter()
+	router.Handle("GET", "/search", config.HandlerFunc(), handler)
+	router.Handle("GET", "/export", config.HandlerFunc(), handler)
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest("GET", "/search", nil)
+	r.Header.Set("X-User", "alice")
+	router.ServeHTTP(w, r)
+
+	h := w.Header()
+	if w.Code != http.StatusOK {
+		t.Errorf("expected status code %d, got %d", http.StatusOK, w.Code)
+	}
+	if got := testHeader(h, "RateLimit-Policy"); got != `"minute";q=100;w=60, "day";q=50;w=86400, "hour";q=1000;w=3600` {
+		t.Errorf("unexpected RateLimit-Policy %q", got)
+	}
+	if got := testHeader(h, "RateLimit"); got != `"day";r=49;t=86399` && got != `"day";r=49;t=86400` {
+		t.Errorf("unexpected RateLimit %q", got)
+	}
+	if got := testHeader(h, "X-RateLimit-Limit"); got != "50" {
+		t.Errorf("expected X-RateLimit-Limit %q, got %q", "50", got)
+	}
+	if got := testHeader(h, "X-RateLimit-Remaining"); got != "49" {
+		t.Errorf("expected X-RateLimit-Remaining %q, got %q", "49", got)
+	}
+
+	codes := make([]int, 0, 5)
+	for range 5 {
+		w = httptest.NewRecorder()
+		r = httptest.NewRequest("GET", "/export", nil)
+		r.Header.Set("X-User", "alice")
+		router.ServeHTTP(w, r)
+		codes = append(codes, w.Code)
+	}
+
+	want := []int{http.StatusOK, http.StatusOK, http.StatusOK, http.StatusOK, http.StatusTooManyRequests}
+	for idx := range want {
+		if codes[idx] != want[idx] {
+			t.Errorf("expected status codes %v, got %v", want, codes)
+			break
+		}
+	}
+
+	if got := testHeader(w.Header(), "X-RateLimit-Remaining"); got != "0" {
+		t.Errorf("expected X-RateLimit-Remaining %q, got %q", "0", got)
+	}
+	if counting.rates["alice"] != 51 {
+		t.Errorf("expected non-weighted limiter to be called %d times, got %d", 51, counting.rates["alice"])
+	}
+}
+
+func TestConfig_HandlerFunc_noRules(t *testing.T) {
+	t.Parallel()
+
+	defer func() {
+		if recover() == nil {
+			t.Errorf("expected panic")
+		}
+	}()
+
+	(&Config{Key: KeyHeader("X-User")}).HandlerFunc()
+}
+
+func TestConfig_Headers(t *testing.T) {
+	t.Parallel()
+
+	handler := func(ctx *beehive.Context) beehive.Responder {
+		return &beehive.DefaultResponder{
+			Message: "ok",
+			Status:  http.StatusOK,
+		}
+	}
+
+	headers := []string{"X-RateLimit-Limit", "X-RateLimit-Remaining", "X-RateLimit-Reset", "RateLimit-Policy", "RateLimit"}
+	modes := map[HeaderMode][]bool{
+		HeaderModeAll:    {true, true, true, true, true},
+		HeaderModeLegacy: {true, true, true, false, false},
+		HeaderModeDraft:  {false, false, false, true, true},
+		HeaderModeNone:   {false, false, false, false, false},
+	}
+
+	for mode, want := range modes {
+		config := &Config{
+			Key:     KeyHeader("X-User"),
+			Rules:   []Rule{{Limiter: NewMemoryLimiter(time.Minute), Limit: 2}},
+			Headers: mode,
+		}
+
+		router := beehive.NewRouter()
+		router.Handle("GET", "/foo", config.HandlerFunc(), handler)
+
+		var w *httptest.ResponseRecorder
+		for range 2 {
+			w = httptest.NewRecorder()
+			r := httptest.NewRequest("GET", "/foo", nil)
+			r.Header.Set("X-User", "alice")
+			router.ServeHTTP(w, r)
+		}
+
+		if w.Code != http.StatusTooManyRequests {
+			t.Errorf("mode %d: expected status code %d, got %d", mode, http.StatusTooManyRequests, w.Code)
+		}
+
+		for idx, header := range headers {
+			if got := testHeader(w.Header(), header) != ""; got != want[idx] {
+				t.Errorf("mode %d: expected %s present %v, got %v", mode, header, want[idx], got)
+			}
+		}
+
+		if got := w.Header().Get("Retry-After"); got != "60" {
+			t.Errorf("mode %d: expected Retry-After %q, got %q", mode, "60", got)
+		}
+	}
+}
+
+func TestConfig_Headers_reset(t *testing.T) {
+	t.Parallel()
+
+	config := &Config{
+		Key:   KeyHeader("X-User"),
+		Rules: []Rule{{Limiter: NewMemoryLimiter(90 * time.Second), Limit: 1}},
+	}
+
+	router := beehive.NewRouter()
+	router.Handle("GET", "/foo", config.HandlerFunc())
+
+	before := time.Now()
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest("GET", "/foo", nil)
+	r.Header.Set("X-User", "alice")
+	router.ServeHTTP(w, r)
+
+	reset, err := strconv.ParseInt(testHeader(w.Header(), "X-RateLimit-Reset"), 10, 64)
+	if err != nil {
+		t.Fatalf("expected X-RateLimit-Reset epoch, got error %v", err)
+	}
+
+	wantReset := before.Add(90 * time.Second).Unix()
+	if reset < wantReset || reset > wantReset+1 {
+		t.Errorf("expected X-RateLimit-Reset around %d, got %d", wantReset, reset)
+	}
+
+	retryAfter, err := strconv.ParseInt(w.Header().Get("Retry-After"), 10, 64)
+	if err != nil {
+		t.Fatalf("expected Retry-After delta seconds, got error %v", err)
+	}
+	if retryAfter != 90 {
+		t.Errorf("expected Retry-After %d, got %d", 90, retryAfter)
+	}
+
+	if got := testHeader(w.Header(), "RateLimit"); got != `"default";r=0;t=90` {
+		t.Errorf("unexpected RateLimit %q", got)
+	}
+}
+
+func TestConfig_HandlerFunc_defaultKey(t *testing.T) {
+	t.Parallel()
+
+	counting := &testRateLimiter{
+		rates:       make(map[string]int),
+		expires:     make(map[string]time.Time),
+		expireAfter: time.Hour,
+	}
+
+	router := beehive.NewRouter()
+	router.Handle("GET", "/", (&Config{Rules: []Rule{{Limiter: counting, Limit: 10}}}).HandlerFunc(),
+		func(_ *beehive.Context) beehive.Responder {
+			return &beehive.DefaultResponder{Message: "ok", Status: http.StatusOK}
+		})
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest("GET", "/", nil)
+	r.RemoteAddr = "192.0.2.1:1234"
+	router.ServeHTTP(w, r)
+
+	if w.Code != http.StatusOK {
+		t.Errorf("expected %d, got %d", http.StatusOK, w.Code)
+	}
+	if counting.rates["192.0.2.1"] != 1 {
+		t.Errorf("expected the remote IP to be used as key, got %v", counting.rates)
+	}
+}
@@ -0,0 +1,346 @@
+package beehive_rate
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// RedisError is an error reply sent by the Redis server. The connection remains usable after a RedisError.
+type RedisError struct {
+	Message string
+}
+
+func (e *RedisError) Error() string {
+	return "beehive-rate: redis: " + e.Message
+}
+
+// ErrRedisProtocol is returned when the server reply is not valid RESP or is not of the expected type.
+var ErrRedisProtocol = errors.New("beehive-rate: redis: protocol error")
+
+const (
+	redisScriptIncrement = `local v = redis.call('INCRBY', KEYS[1], ARGV[1])
+local ttl = redis.call('PTTL', KEYS[1])
+if ttl < 0 and tonumber(ARGV[2]) > 0 then
+	redis.call('PEXPIRE', KEYS[1], ARGV[2])
+	ttl = tonumber(ARGV[2])
+end
+return {v, ttl}`
+
+	redisScriptCompareAndSet = `local v = redis.call('GET', KEYS[1])
+if (v == false and ARGV[1] == '') or v == ARGV[1] then
+	if tonumber(ARGV[3]) > 0 then
+		redis.call('SET', KEYS[1], ARGV[2], 'PX', ARGV[3])
+	else
+		redis.call('SET', KEYS[1], ARGV[2])
+	end
+	return 1
+end
+return 0`
+)
+
+// RedisStore is a Store implemented over the Redis serialization protocol (RESP2) using only the standard library.
+// Both operations are executed as Lua scripts (EVAL) so they are atomic on the server. Connections are dialed lazily
+// and kept in a small idle pool, RedisStore is safe for concurrent use.
+type RedisStore struct {
+	// Addr is the host:port of the Redis server.
+	Addr string
+
+	// Username and Password are sent with AUTH on every new connection when Password is not empty.
+	Username string
+	Password string
+
+	// DB is selected with SELECT on every new connection when not zero.
+	DB int
+
+	// Dial is used to open new connections. If nil, a net.Dialer is used.
+	Dial func(ctx context.Context, network, addr string) (net.Conn, error)
+
+	// MaxIdle is the maximum number of idle connections kept open. If zero, 8 is used.
+	MaxIdle int
+
+	once   sync.Once
+	idle   chan *redisConn
+	mu     sync.Mutex
+	closed bool
+}
+
+// test that RedisStore implements Store.
+var _ Store = &RedisStore{}
+
+type redisConn struct {
+	conn net.Conn
+	r    *bufio.Reader
+	w    *bufio.Writer
+}
+
+// Increment satisfies the Store interface.
+func (s *RedisStore) Increment(ctx context.Context, key string, n int64, ttl time.Duration) (int64, time.Time, error) {
+	reply, err := s.Do(ctx, "EVAL", redisScriptIncrement, "1", key,
+		strconv.FormatInt(n, 10), strconv.FormatInt(ttl.Milliseconds(), 10))
+	if err != nil {
+		return 0, time.Time{}, err
+	}
+
+	values, ok := reply.([]any)
+	if !ok || len(values) != 2 {
+		return 0, time.Time{}, ErrRedisProtocol
+	}
+
+	value, ok := values[0].(int64)
+	if !ok {
+		return 0, time.Time{}, ErrRedisProtocol
+	}
+
+	ttlMs, ok := values[1].(int64)
+	if !ok {
+		return 0, time.Time{}, ErrRedisProtocol
+	}
+
+	var expiresAt time.Time
+	if ttlMs >= 0 {
+		expiresAt = time.Now().Add(time.Duration(ttlMs) * time.Millisecond)
+	}
+
+	return value, expiresAt, nil
+}
+
+// CompareAndSet satisfies the Store interface.
+func (s *RedisStore) CompareAndSet(ctx context.Context, key, old, value string, ttl time.Duration) (bool, error) {
+	reply, err := s.Do(ctx, "EVAL", redisScriptCompareAndSet, "1", key,
+		old, value, strconv.FormatInt(ttl.Milliseconds(), 10))
+	if err != nil {
+		return false, err
+	}
+
+	set, ok := reply.(int64)
+	if !ok {
+		return false, ErrRedisProtocol
+	}
+
+	return set == 1, nil
+}
+
+// Do sends a single command and returns the reply. Replies are decoded as string (simple and bulk strings), int64,
+// []any (arrays) or nil (null bulk strings and arrays). Error replies are returned as *RedisError, or as a *RedisError
+// element when nested in an array.
+func (s *RedisStore) Do(ctx context.Context, args ...string) (any, error) {
+	conn, err := s.get(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	reply, err := conn.do(ctx, args...)
+	var redisErr *RedisError
+	if err != nil && !errors.As(err, &redisErr) {
+		_ = conn.conn.Close()
+		return nil, err
+	}
+
+	s.put(conn)
+
+	return reply, err
+}
+
+func (s *RedisStore) get(ctx context.Context) (*redisConn, error) {
+	s.once.Do(func() {
+		maxIdle := s.MaxIdle
+		if maxIdle == 0 {
+			maxIdle = 8
+		}
+		s.idle = make(chan *redisConn, maxIdle)
+	})
+
+	select {
+	case conn := <-s.idle:
+		return conn, nil
+	default:
+	}
+
+	dial := s.Dial
+	if dial == nil {
+		dialer := &net.Dialer{}
+		dial = dialer.DialContext
+	}
+
+	netConn, err := dial(ctx, "tcp", s.Addr)
+	if err != nil {
+		return nil, err
+	}
+
+	conn := &redisConn{
+		conn: netConn,
+		r:    bufio.NewReader(netConn),
+		w:    bufio.NewWriter(netConn),
+	}
+
+	if s.Password != "" {
+		args := []string{"AUTH", s.Password}
+		if s.Username != "" {
+			args = []string{"AUTH", s.Username, s.Password}
+		}
+
+		if _, err = conn.do(ctx, args...); err != nil {
+			_ = netConn.Close()
+			return nil, err
+		}
+	}
+
+	if s.DB != 0 {
+		if _, err = conn.do(ctx, "SELECT", strconv.Itoa(s.DB)); err != nil {
+			_ = netConn.Close()
+			return nil, err
+		}
+	}
+
+	return conn, nil
+}
+
+func (s *RedisStore) put(conn *redisConn) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.closed {
+		_ = conn.conn.Close()
+		return
+	}
+
+	select {
+	case s.idle <- conn:
+	default:
+		_ = conn.conn.Close()
+	}
+}
+
+// Close closes all idle connections. Connections in use are closed when returned.
+func (s *RedisStore) Close() error {
+	s.once.Do(func() {
+		s.idle = make(chan *redisConn)
+	})
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.closed = true
+	for {
+		select {
+		case conn := <-s.idle:
+			_ = conn.conn.Close()
+		default:
+			return nil
+		}
+	}
+}
+
+func (c *redisConn) do(ctx context.Context, args ...string) (any, error) {
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		deadline = time.Time{}
+	}
+	if err := c.conn.SetDeadline(deadline); err != nil {
+		return nil, err
+	}
+
+	if err := writeRESP(c.w, args); err != nil {
+		return nil, err
+	}
+	if err := c.w.Flush(); err != nil {
+		return nil, err
+	}
+
+	return readRESP(c.r)
+}
+
+func writeRESP(w *bufio.Writer, args []string) error {
+	_, _ = fmt.Fprintf(w, "*%d\r\n", len(args))
+	for _, arg := range args {
+		_, _ = fmt.Fprintf(w, "$%d\r\n", len(arg))
+		_, _ = w.WriteString(arg)
+		if _, err := w.WriteString("\r\n"); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func readRESPLine(r *bufio.Reader) (string, error) {
+	line, err := r.ReadString('\n')
+	if err != nil {
+		return "", err
+	}
+
+	if len(line) < 3 || line[len(line)-2] != '\r' {
+		return "", ErrRedisProtocol
+	}
+
+	return line[:len(line)-2], nil
+}
+
+func readRESP(r *bufio.Reader) (any, error) {
+	line, err := readRESPLine(r)
+	if err != nil {
+		return nil, err
+	}
+
+	switch line[0] {
+	case '+':
+		return line[1:], nil
+	case '-':
+		return nil, &RedisError{Message: line[1:]}
+	case ':':
+		value, parseErr := strconv.ParseInt(line[1:], 10, 64)
+		if parseErr != nil {
+			return nil, ErrRedisProtocol
+		}
+		return value, nil
+	case '$':
+		size, parseErr := strconv.Atoi(line[1:])
+		if parseErr != nil || size < -1 {
+			return nil, ErrRedisProtocol
+		}
+		if size == -1 {
+			return nil, nil //nolint:nilnil // null bulk string
+		}
+
+		data := make([]byte, size+2)
+		if _, err = io.ReadFull(r, data); err != nil {
+			return nil, err
+		}
+		if data[size] != '\r' || data[size+1] != '\n' {
+			return nil, ErrRedisProtocol
+		}
+
+		return string(data[:size]), nil
+	case '*':
+		size, parseErr := strconv.Atoi(line[1:])
+		if parseErr != nil || size < -1 {
+			return nil, ErrRedisProtocol
+		}
+		if size == -1 {
+			return nil, nil //nolint:nilnil // null array
+		}
+
+		values := make([]any, size)
+		for idx := range values {
+			values[idx], err = readRESP(r)
+
+			var redisErr *RedisError
+			if errors.As(err, &redisErr) {
+				values[idx] = redisErr
+			} else if err != nil {
+				return nil, err
+			}
+		}
+
+		return values, nil
+	default:
+		return nil, ErrRedisProtocol
+	}
+}
@@ -0,0 +1,335 @@
+package beehive_rate
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"net"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// testRedisServer is an in-process RESP stand-in for a Redis server. It understands the commands and the exact scripts
+// used by RedisStore and is backed by a MemoryStore.
+type testRedisServer struct {
+	listener net.Listener
+	store    *MemoryStore
+	password string
+
+	mu       sync.Mutex
+	commands [][]string
+	wg       sync.WaitGroup
+}
+
+func newTestRedisServer(t *testing.T, password string) *testRedisServer {
+	t.Helper()
+
+	listener, err := net.Listen("tcp4", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	server := &testRedisServer{
+		listener: listener,
+		store:    NewMemoryStore(),
+		password: password,
+	}
+
+	server.wg.Add(1)
+	go server.serve()
+
+	t.Cleanup(server.close)
+
+	return server
+}
+
+func (s *testRedisServer) addr() string {
+	return s.listener.Addr().String()
+}
+
+func (s *testRedisServer) close() {
+	_ = s.listener.Close()
+	s.wg.Wait()
+}
+
+func (s *testRedisServer) serve() {
+	defer s.wg.Done()
+
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			return
+		}
+
+		s.wg.Add(1)
+		go func() {
+			defer s.wg.Done()
+			s.handle(conn)
+		}()
+	}
+}
+
+func (s *testRedisServer) handle(conn net.Conn) {
+	defer func() { _ = conn.Close() }()
+
+	r := bufio.NewReader(conn)
+	w := bufio.NewWriter(conn)
+	authenticated := s.password == ""
+
+	for {
+		request, err := readRESP(r)
+		if err != nil {
+			return
+		}
+
+		values, _ := request.([]any)
+		args := make([]string, len(values))
+		for idx, value := range values {
+			args[idx], _ = value.(string)
+		}
+
+		s.mu.Lock()
+		s.commands = append(s.commands, args)
+		s.mu.Unlock()
+
+		if len(args) == 0 {
+			_, _ = w.WriteString("-ERR empty command\r\n")
+		} else if args[0] != "AUTH" && !authenticated {
+			_, _ = w.WriteString("-NOAUTH Authentication required.\r\n")
+		} else {
+			authenticated = s.reply(w, args) || authenticated
+		}
+
+		if err = w.Flush(); err != nil {
+			return
+		}
+	}
+}
+
+func (s *testRedisServer) reply(w *bufio.Writer, args []string) bool {
+	ctx := context.Background()
+
+	switch {
+	case args[0] == "AUTH" && args[len(args)-1] == s.password:
+		_, _ = w.WriteString("+OK\r\n")
+		return true
+	case args[0] == "AUTH":
+		_, _ = w.WriteString("-WRONGPASS invalid password\r\n")
+	case args[0] == "PING":
+		_, _ = w.WriteString("+PONG\r\n")
+	case args[0] == "SELECT":
+		_, _ = w.WriteString("+OK\r\n")
+	case args[0] == "EVAL" && len(args) == 6 && args[1] == redisScriptIncrement:
+		n, _ := strconv.ParseInt(args[4], 10, 64)
+		ttl, _ := strconv.ParseInt(args[5], 10, 64)
+
+		value, expiresAt, err := s.store.Increment(ctx, args[3], n, time.Duration(ttl)*time.Millisecond)
+		if err != nil {
+			_, _ = w.WriteString("-ERR value is not an integer or out of range\r\n")
+			break
+		}
+
+		pttl := int64(-1)
+		if !expiresAt.IsZero() {
+			pttl = time.Until(expiresAt).Milliseconds()
+		}
+
+		_, _ = w.WriteString("*2\r\n:" + strconv.FormatInt(value, 10) + "\r\n:" + strconv.FormatInt(pttl, 10) + "\r\n")
+	case args[0] == "EVAL" && len(args) == 7 && args[1] == redisScriptCompareAndSet:
+		ttl, _ := strconv.ParseInt(args[6], 10, 64)
+
+		set, _ := s.store.CompareAndSet(ctx, args[3], args[4], args[5], time.Duration(ttl)*time.Millisecond)
+		if set {
+			_, _ = w.WriteString(":1\r\n")
+		} else {
+			_, _ = w.WriteString(":0\r\n")
+		}
+	default:
+		_, _ = w.WriteString("-ERR unknown command '" + args[0] + "'\r\n")
+	}
+
+	return false
+}
+
+func TestRedisStore(t *testing.T) {
+	t.Parallel()
+
+	server := newTestRedisServer(t, "secret")
+
+	store := &RedisStore{
+		Addr:     server.addr(),
+		Password: "secret",
+		DB:       2,
+	}
+	t.Cleanup(func() { _ = store.Close() })
+
+	ctx := t.Context()
+
+	value, expiresAt, err := store.Increment(ctx, "foo", 3, time.Minute)
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if value != 3 {
+		t.Errorf("expected %d, got %d", 3, value)
+	}
+	if until := time.Until(expiresAt); until <= 59*time.Second || until > time.Minute {
+		t.Errorf("unexpected expiry in %v", until)
+	}
+
+	value, _, err = store.Increment(ctx, "foo", 2, time.Minute)
+	if err != nil || value != 5 {
+		t.Errorf("expected %d, got %d, %v", 5, value, err)
+	}
+
+	set, err := store.CompareAndSet(ctx, "bar", "", "1", 0)
+	if err != nil || !set {
+		t.Errorf("expected set, got %v, %v", set, err)
+	}
+
+	set, err = store.CompareAndSet(ctx, "bar", "", "2", 0)
+	if err != nil || set {
+		t.Errorf("expected not set, got %v, %v", set, err)
+	}
+
+	set, err = store.CompareAndSet(ctx, "bar", "1", "2", time.Minute)
+	if err != nil || !set {
+		t.Errorf("expected set, got %v, %v", set, err)
+	}
+
+	_, _, err = store.Increment(ctx, "bar", 1, time.Minute)
+	if err != nil {
+		t.Errorf("unexpected error %v", err)
+	}
+
+	set, err = store.CompareAndSet(ctx, "bar", "3", "", 0)
+	if err != nil || !set {
+		t.Errorf("expected set, got %v, %v", set, err)
+	}
+
+	_, _, err = store.Increment(ctx, "bar", 1, time.Minute)
+	var redisErr *RedisError
+	if !errors.As(err, &redisErr) {
+		t.Errorf("expected RedisError, got %v", err)
+	}
+
+	reply, err := store.Do(ctx, "PING")
+	if err != nil || reply != "PONG" {
+		t.Errorf("expected PONG, got %v, %v", reply, err)
+	}
+
+	server.mu.Lock()
+	defer server.mu.Unlock()
+
+	var dials int
+	for _, command := range server.commands {
+		if command[0] == "AUTH" {
+			dials++
+		}
+	}
+	if dials != 1 {
+		t.Errorf("expected connection to be reused, got %d connections", dials)
+	}
+	if server.commands[1][0] != "SELECT" || server.commands[1][1] != "2" {
+		t.Errorf("expected SELECT 2, got %v", server.commands[1])
+	}
+}
+
+func TestRedisStore_Close(t *testing.T) {
+	t.Parallel()
+
+	server := newTestRedisServer(t, "")
+	store := &RedisStore{Addr: server.addr()}
+
+	conn, err := store.get(t.Context())
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+
+	if err := store.Close(); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	store.put(conn)
+
+	if len(store.idle) != 0 {
+		t.Errorf("expected no idle connection after Close, got %d", len(store.idle))
+	}
+	if _, err := conn.conn.Write([]byte("PING\r\n")); !errors.Is(err, net.ErrClosed) {
+		t.Errorf("expected the returned connection to be closed, got %v", err)
+	}
+}
+
+func TestRedisStore_auth(t *testing.T) {
+	t.Parallel()
+
+	server := newTestRedisServer(t, "secret")
+
+	store := &RedisStore{Addr: server.addr(), Password: "wrong"}
+	t.Cleanup(func() { _ = store.Close() })
+
+	_, err := store.Do(t.Context(), "PING")
+	var redisErr *RedisError
+	if !errors.As(err, &redisErr) {
+		t.Errorf("expected RedisError, got %v", err)
+	}
+}
+
+func TestRedisStore_unreachable(t *testing.T) {
+	t.Parallel()
+
+	server := newTestRedisServer(t, "")
+	addr := server.addr()
+	server.close()
+
+	store := &RedisStore{Addr: addr}
+	if _, _, err := store.Increment(t.Context(), "foo", 1, time.Minute); err == nil {
+		t.Errorf("expected error")
+	}
+}
+
+func TestReadRESP(t *testing.T) {
+	t.Parallel()
+
+	valid := map[string]any{
+		"+OK\r\n":                  "OK",
+		":42\r\n":                  int64(42),
+		"$5\r\nhello\r\n":          "hello",
+		"$0\r\n\r\n":               "",
+		"$-1\r\n":                  nil,
+		"*-1\r\n":                  nil,
+		"*2\r\n:1\r\n$1\r\na\r\n":  []any{int64(1), "a"},
+		"*2\r\n-ERR x\r\n:1\r\n":   []any{&RedisError{Message: "ERR x"}, int64(1)},
+		"*1\r\n*1\r\n+nested\r\n":  []any{[]any{"nested"}},
+		"$6\r\nfoo\r\nb\r\n":       "foo\r\nb",
+		"*0\r\n":                   []any{},
+		"$3\r\n\x00\x01\x02\r\n":   "\x00\x01\x02",
+		"*1\r\n$-1\r\n":            []any{nil},
+		"+\r\n":                    "",
+		"*2\r\n+a\r\n*1\r\n:9\r\n": []any{"a", []any{int64(9)}},
+	}
+
+	for input, want := range valid {
+		got, err := readRESP(bufio.NewReader(strings.NewReader(input)))
+		if err != nil {
+			t.Errorf("%q: unexpected error %v", input, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("%q: expected %#v, got %#v", input, want, got)
+		}
+	}
+
+	invalid := []string{"", "OK\r\n", "+OK\n", ":abc\r\n", "$abc\r\n", "$5\r\nhi\r\n", "$2\r\nhiXX", "*x\r\n", "*2\r\n:1\r\n", "?\r\n"}
+	for _, input := range invalid {
+		if _, err := readRESP(bufio.NewReader(strings.NewReader(input))); err == nil {
+			t.Errorf("%q: expected error", input)
+		}
+	}
+
+	if _, err := readRESP(bufio.NewReader(strings.NewReader("-ERR boom\r\n"))); err == nil || err.Error() != "beehive-rate: redis: ERR boom" {
+		t.Errorf("unexpected error %v", err)
+	}
+}
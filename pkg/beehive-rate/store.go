@@ -0,0 +1,182 @@
+package beehive_rate
+
+import (
+	"context"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Store is a key value store shared between multiple processes, used by StoreLimiter to enforce the same limits across
+// all replicas of a service. All methods must be atomic with regard to the given key.
+type Store interface {
+	// Increment adds n to the integer counter stored at key and returns the new value and when the key expires. If the
+	// key does not exist (or has expired) it is created with the given ttl before being incremented.
+	Increment(ctx context.Context, key string, n int64, ttl time.Duration) (int64, time.Time, error)
+
+	// CompareAndSet sets key to value with the given ttl if and only if the current value of key equals old. An
+	// empty old value matches a missing key. A ttl of 0 means the key does not expire. The returned bool reports
+	// whether the value was set.
+	CompareAndSet(ctx context.Context, key, old, value string, ttl time.Duration) (bool, error)
+}
+
+// MemoryStore is an in-process Store, useful for tests and as reference implementation.
+type MemoryStore struct {
+	mu      sync.Mutex
+	entries map[string]memoryEntry
+	now     func() time.Time
+}
+
+type memoryEntry struct {
+	value     string
+	expiresAt time.Time
+}
+
+// test that MemoryStore implements Store.
+var _ Store = &MemoryStore{}
+
+// NewMemoryStore returns an empty MemoryStore.
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		entries: make(map[string]memoryEntry),
+		now:     time.Now,
+	}
+}
+
+func (m *MemoryStore) get(key string, now time.Time) (memoryEntry, bool) {
+	entry, found := m.entries[key]
+	if found && !entry.expiresAt.IsZero() && !now.Before(entry.expiresAt) {
+		delete(m.entries, key)
+		return memoryEntry{}, false
+	}
+
+	return entry, found
+}
+
+// Increment satisfies the Store interface.
+func (m *MemoryStore) Increment(_ context.Context, key string, n int64, ttl time.Duration) (int64, time.Time, error) {
+	now := m.now()
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	entry, found := m.get(key, now)
+	if !found {
+		entry = memoryEntry{value: "0"}
+		if ttl > 0 {
+			entry.expiresAt = now.Add(ttl)
+		}
+	}
+
+	value, err := strconv.ParseInt(entry.value, 10, 64)
+	if err != nil {
+		return 0, time.Time{}, err
+	}
+
+	value += n
+	entry.value = strconv.FormatInt(value, 10)
+	m.entries[key] = entry
+
+	return value, entry.expiresAt, nil
+}
+
+// CompareAndSet satisfies the Store interface.
+func (m *MemoryStore) CompareAndSet(_ context.Context, key, old, value string, ttl time.Duration) (bool, error) {
+	now := m.now()
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	entry, found := m.get(key, now)
+	if (!found && old != "") || (found && entry.value != old) {
+		return false, nil
+	}
+
+	entry = memoryEntry{value: value}
+	if ttl > 0 {
+		entry.expiresAt = now.Add(ttl)
+	}
+	m.entries[key] = entry
+
+	return true, nil
+}
+
+// StoreLimiter is a fixed window WeightedLimiter backed by a Store, such that all processes using the same Store share
+// the same counters. When the Store returns an error, the StoreLimiter falls back to a local MemoryLimiter for the
+// Cooldown duration before trying the Store again.
+type StoreLimiter struct {
+	// Prefix is prepended to all keys sent to the Store.
+	Prefix string
+
+	// Timeout bounds every call to the Store. If zero, 100ms is used.
+	Timeout time.Duration
+
+	// Cooldown is how long the local fallback is used after a Store error. If zero, 1s is used.
+	Cooldown time.Duration
+
+	// OnError is called with every error returned by the Store.
+	OnError func(err error)
+
+	store    Store
+	window   time.Duration
+	fallback *MemoryLimiter
+	retryAt  atomic.Int64
+}
+
+// test that StoreLimiter implements WeightedLimiter.
+var _ WeightedLimiter = &StoreLimiter{}
+
+// NewStoreLimiter returns a StoreLimiter using the given Store and window duration.
+func NewStoreLimiter(store Store, window time.Duration) *StoreLimiter {
+	return &StoreLimiter{
+		store:    store,
+		window:   window,
+		fallback: NewMemoryLimiter(window),
+	}
+}
+
+// Window returns the window duration of the StoreLimiter.
+func (s *StoreLimiter) Window() time.Duration {
+	return s.window
+}
+
+// Limit consumes a single unit, see LimitN.
+func (s *StoreLimiter) Limit(key string) (int, time.Time) {
+	return s.LimitN(key, 1)
+}
+
+// LimitN consumes n units for the given key in the Store, or in the local fallback when the Store is unavailable.
+func (s *StoreLimiter) LimitN(key string, n int) (int, time.Time) {
+	now := time.Now()
+	if retryAt := s.retryAt.Load(); retryAt != 0 && now.UnixNano() < retryAt {
+		return s.fallback.LimitN(key, n)
+	}
+
+	timeout := s.Timeout
+	if timeout == 0 {
+		timeout = 100 * time.Millisecond
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	current, expiresAt, err := s.store.Increment(ctx, s.Prefix+key, int64(n), s.window)
+	if err != nil {
+		cooldown := s.Cooldown
+		if cooldown == 0 {
+			cooldown = time.Second
+		}
+		s.retryAt.Store(now.Add(cooldown).UnixNano())
+
+		if s.OnError != nil {
+			s.OnError(err)
+		}
+
+		return s.fallback.LimitN(key, n)
+	}
+
+	s.retryAt.Store(0)
+
+	return int(current), expiresAt
+}
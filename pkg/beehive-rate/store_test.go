@@ -0,0 +1,165 @@
+package beehive_rate
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"go.sdls.io/beehive/pkg/beehive"
+)
+
+func TestMemoryStore(t *testing.T) {
+	t.Parallel()
+
+	now := time.Unix(1700000000, 0)
+	store := NewMemoryStore()
+	store.now = func() time.Time { return now }
+
+	ctx := t.Context()
+
+	value, expiresAt, err := store.Increment(ctx, "foo", 2, time.Minute)
+	if err != nil || value != 2 || !expiresAt.Equal(now.Add(time.Minute)) {
+		t.Errorf("unexpected %d, %v, %v", value, expiresAt, err)
+	}
+
+	value, expiresAt, err = store.Increment(ctx, "foo", 3, time.Hour)
+	if err != nil || value != 5 || !expiresAt.Equal(now.Add(time.Minute)) {
+		t.Errorf("unexpected %d, %v, %v", value, expiresAt, err)
+	}
+
+	now = now.Add(time.Minute)
+	value, _, err = store.Increment(ctx, "foo", 1, time.Minute)
+	if err != nil || value != 1 {
+		t.Errorf("expected expired key to reset, got %d, %v", value, err)
+	}
+
+	if set, _ := store.CompareAndSet(ctx, "bar", "x", "y", 0); set {
+		t.Errorf("expected missing key to not match %q", "x")
+	}
+	if set, _ := store.CompareAndSet(ctx, "bar", "", "y", time.Second); !set {
+		t.Errorf("expected missing key to match empty old value")
+	}
+	if set, _ := store.CompareAndSet(ctx, "bar", "x", "z", 0); set {
+		t.Errorf("expected %q to not match", "x")
+	}
+	if set, _ := store.CompareAndSet(ctx, "bar", "y", "z", 0); !set {
+		t.Errorf("expected %q to match", "y")
+	}
+
+	now = now.Add(time.Hour)
+	if set, _ := store.CompareAndSet(ctx, "bar", "", "y", 0); set {
+		t.Errorf("expected key without ttl to not expire")
+	}
+
+	if _, _, err = store.Increment(ctx, "bar", 1, 0); err == nil {
+		t.Errorf("expected error incrementing non integer value")
+	}
+}
+
+type testFailingStore struct {
+	Store
+	fail  atomic.Bool
+	calls atomic.Int32
+}
+
+func (s *testFailingStore) Increment(ctx context.Context, key string, n int64, ttl time.Duration) (int64, time.Time, error) {
+	s.calls.Add(1)
+	if s.fail.Load() {
+		return 0, time.Time{}, errors.New("unreachable")
+	}
+	return s.Store.Increment(ctx, key, n, ttl)
+}
+
+func TestStoreLimiter(t *testing.T) {
+	t.Parallel()
+
+	store := &testFailingStore{Store: NewMemoryStore()}
+
+	var errs atomic.Int32
+	limiter := NewStoreLimiter(store, time.Minute)
+	limiter.Prefix = "rate:"
+	limiter.Cooldown = time.Hour
+	limiter.OnError = func(_ error) { errs.Add(1) }
+
+	if limiter.Window() != time.Minute {
+		t.Errorf("expected window %v, got %v", time.Minute, limiter.Window())
+	}
+
+	limiter.LimitN("alice", 5)
+	if current, _ := limiter.Limit("alice"); current != 6 {
+		t.Errorf("expected %d, got %d", 6, current)
+	}
+
+	shared := NewStoreLimiter(store, time.Minute)
+	shared.Prefix = "rate:"
+	if current, _ := shared.Limit("alice"); current != 7 {
+		t.Errorf("expected limiters to share the store, got %d", current)
+	}
+
+	store.fail.Store(true)
+	if current, expiresAt := limiter.Limit("alice"); current != 1 || expiresAt.IsZero() {
+		t.Errorf("expected local fallback, got %d, %v", current, expiresAt)
+	}
+	if current, _ := limiter.Limit("alice"); current != 2 {
+		t.Errorf("expected local fallback, got %d", current)
+	}
+
+	if errs.Load() != 1 {
+		t.Errorf("expected %d errors, got %d", 1, errs.Load())
+	}
+	if store.calls.Load() != 4 {
+		t.Errorf("expected store to not be called during cooldown, got %d calls", store.calls.Load())
+	}
+
+	store.fail.Store(false)
+	limiter.retryAt.Store(time.Now().Add(-time.Second).UnixNano())
+	if current, _ := limiter.Limit("alice"); current != 8 {
+		t.Errorf("expected store to be used after cooldown, got %d", current)
+	}
+}
+
+func TestStoreLimiter_redis(t *testing.T) {
+	t.Parallel()
+
+	server := newTestRedisServer(t, "")
+	store := &RedisStore{Addr: server.addr()}
+	t.Cleanup(func() { _ = store.Close() })
+
+	// two replicas sharing the same store
+	replicas := make([]*beehive.Router, 2)
+	for idx := range replicas {
+		config := &Config{
+			Key:   KeyHeader("X-User"),
+			Rules: []Rule{{Limiter: NewStoreLimiter(store, time.Minute), Limit: 4}},
+		}
+
+		replicas[idx] = beehive.NewRouter()
+		replicas[idx].Handle("GET", "/foo", config.HandlerFunc(), func(_ *beehive.Context) beehive.Responder {
+			return &beehive.DefaultResponder{Status: http.StatusOK}
+		})
+	}
+
+	codes := make([]int, 0, 4)
+	for idx := range 4 {
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest("GET", "/foo", nil)
+		r.Header.Set("X-User", "alice")
+		replicas[idx%2].ServeHTTP(w, r)
+		codes = append(codes, w.Code)
+	}
+
+	want := []int{http.StatusOK, http.StatusOK, http.StatusOK, http.StatusTooManyRequests}
+	if !reflect.DeepEqual(codes, want) {
+		t.Errorf("expected %v, got %v", want, codes)
+	}
+
+	value, _, err := server.store.Increment(t.Context(), "alice", 0, time.Minute)
+	if err != nil || value != 4 {
+		t.Errorf("expected %d, got %d, %v", 4, value, err)
+	}
+}
@@ -0,0 +1,54 @@
+package beehive_requestid
+
+import (
+	"encoding/binary"
+	"math/rand/v2"
+	"time"
+)
+
+// NewUUIDv7 returns a new UUID version 7 (RFC 9562) in its canonical lower case form, made of the current Unix time
+// in milliseconds and 74 random bits. UUIDv7 sort by creation time (to the millisecond).
+func NewUUIDv7() string {
+	return uuidV7(time.Now().UnixMilli(), rand.Uint64(), rand.Uint64()) //nolint:gosec
+}
+
+// NewULID returns a new ULID, made of the current Unix time in milliseconds and 80 random bits encoded in 26
+// characters of Crockford's base32. ULIDs sort by creation time (to the millisecond).
+func NewULID() string {
+	return ulid(time.Now().UnixMilli(), rand.Uint64(), rand.Uint64()) //nolint:gosec
+}
+
+func uuidV7(millis int64, randA, randB uint64) string {
+	var b [16]byte
+	binary.BigEndian.PutUint64(b[0:8], uint64(millis)<<16|randA&0x0fff|0x7000) //nolint:gosec
+	binary.BigEndian.PutUint64(b[8:16], randB&0x3fffffffffffffff|0x8000000000000000)
+
+	const hexDigits = "0123456789abcdef"
+
+	dst := make([]byte, 0, 36)
+	for i, v := range b {
+		if i == 4 || i == 6 || i == 8 || i == 10 {
+			dst = append(dst, '-')
+		}
+		dst = append(dst, hexDigits[v>>4], hexDigits[v&0xf])
+	}
+
+	return string(dst)
+}
+
+func ulid(millis int64, randHi, randLo uint64) string {
+	const crockford = "0123456789ABCDEFGHJKMNPQRSTVWXYZ"
+
+	// 128 bits: 48 bits of time, 80 bits of randomness, encoded 5 bits at a time from the least significant end
+	hi := uint64(millis)<<16 | randHi&0xffff //nolint:gosec
+	lo := randLo
+
+	var dst [26]byte
+	for i := len(dst) - 1; i >= 0; i-- {
+		dst[i] = crockford[lo&0x1f]
+		lo = lo>>5 | hi<<59
+		hi >>= 5
+	}
+
+	return string(dst[:])
+}
@@ -0,0 +1,45 @@
+package beehive_requestid
+
+import (
+	"testing"
+)
+
+func TestUUIDv7(t *testing.T) {
+	t.Parallel()
+
+	// RFC 9562 Appendix A.6
+	got := uuidV7(0x017F22E279B0, 0xCC3, 0x18C4DC0C0C07398F)
+	if want := "017f22e2-79b0-7cc3-98c4-dc0c0c07398f"; got != want {
+		t.Errorf("expected %s, got %s", want, got)
+	}
+
+	a, b := NewUUIDv7(), NewUUIDv7()
+	if a == b || len(a) != 36 || a[14] != '7' {
+		t.Errorf("unexpected UUIDv7 %s and %s", a, b)
+	}
+}
+
+func TestULID(t *testing.T) {
+	t.Parallel()
+
+	got := ulid(1469918176385, 0, 0)
+	if want := "01ARYZ6S410000000000000000"; got != want {
+		t.Errorf("expected %s, got %s", want, got)
+	}
+
+	got = ulid(0, 0xffff, 0xffffffffffffffff)
+	if want := "0000000000ZZZZZZZZZZZZZZZZ"; got != want {
+		t.Errorf("expected %s, got %s", want, got)
+	}
+
+	a, b := NewULID(), NewULID()
+	if a == b || len(a) != 26 {
+		t.Errorf("unexpected ULID %s and %s", a, b)
+	}
+}
+
+func BenchmarkNewUUIDv7(b *testing.B) {
+	for b.Loop() {
+		_ = NewUUIDv7()
+	}
+}
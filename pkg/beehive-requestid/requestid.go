@@ -0,0 +1,127 @@
+package beehive_requestid
+
+import (
+	"context"
+	"net/http"
+
+	"go.sdls.io/beehive/pkg/beehive"
+)
+
+// DefaultHeader is the header used when Config.Header is empty.
+const DefaultHeader = "X-Request-Id"
+
+type contextKey struct{}
+
+// Config describes the request ID middleware.
+type Config struct {
+	// Header is the request header the ID is read from and the response header it is echoed on. If empty,
+	// DefaultHeader is used.
+	Header string
+
+	// MaxLength is the maximum length of an inbound ID, longer IDs are replaced. If zero, 128 is used.
+	MaxLength int
+
+	// Validate reports whether an inbound ID is accepted, invalid IDs are replaced by a generated one. If nil, only
+	// IDs made of ASCII letters, digits and "-_.:+/=@" are accepted.
+	Validate func(id string) bool
+
+	// Generate returns a new unique ID. If nil, NewUUIDv7 is used.
+	Generate func() string
+}
+
+// HandlerFunc returns the request ID beehive.HandlerFunc. The inbound ID, or a generated one, is stored on the
+// beehive.Context (see FromContext) and set on the response header.
+func (c *Config) HandlerFunc() beehive.HandlerFunc {
+	header := c.Header
+	if header == "" {
+		header = DefaultHeader
+	}
+	header = http.CanonicalHeaderKey(header)
+
+	maxLength := c.MaxLength
+	if maxLength == 0 {
+		maxLength = 128
+	}
+
+	validate := c.Validate
+	if validate == nil {
+		validate = valid
+	}
+
+	generate := c.Generate
+	if generate == nil {
+		generate = NewUUIDv7
+	}
+
+	return func(ctx *beehive.Context) beehive.Responder {
+		id := ctx.Request.Header.Get(header)
+		if id == "" || len(id) > maxLength || !validate(id) {
+			id = generate()
+		}
+
+		ctx.WithValue(contextKey{}, id)
+		ctx.ResponseWriter.Header()[header] = []string{id}
+
+		return nil
+	}
+}
+
+// FromContext returns the request ID stored by the middleware. The beehive.Context, and any context.Context derived
+// from it, can be used.
+func FromContext(ctx context.Context) (string, bool) {
+	id, ok := ctx.Value(contextKey{}).(string)
+	return id, ok
+}
+
+// WithID returns a copy of ctx carrying the given request ID, for example to propagate it to background work.
+func WithID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, contextKey{}, id)
+}
+
+func valid(id string) bool {
+	for i := 0; i < len(id); i++ {
+		c := id[i]
+		switch {
+		case 'a' <= c && c <= 'z', 'A' <= c && c <= 'Z', '0' <= c && c <= '9':
+		case c == '-', c == '_', c == '.', c == ':', c == '+', c == '/', c == '=', c == '@':
+		default:
+			return false
+		}
+	}
+
+	return true
+}
+
+// Transport is an http.RoundTripper setting the request ID found in the outbound request context on the request
+// header, such that it is propagated to downstream services. Requests already carrying the header are not modified.
+type Transport struct {
+	// Base is the underlying http.RoundTripper. If nil, http.DefaultTransport is used.
+	Base http.RoundTripper
+
+	// Header is the request header to set. If empty, DefaultHeader is used.
+	Header string
+}
+
+// test that Transport implements http.RoundTripper.
+var _ http.RoundTripper = &Transport{}
+
+// RoundTrip satisfies the http.RoundTripper interface.
+func (t *Transport) RoundTrip(r *http.Request) (*http.Response, error) {
+	base := t.Base
+	if base == nil {
+		base = http.DefaultTransport
+	}
+
+	header := t.Header
+	if header == "" {
+		header = DefaultHeader
+	}
+
+	if id, ok := FromContext(r.Context()); ok && r.Header.Get(header) == "" {
+		// a RoundTripper must not modify the given request
+		r = r.Clone(r.Context())
+		r.Header.Set(header, id)
+	}
+
+	return base.RoundTrip(r)
+}
@@ -0,0 +1,88 @@
+package beehive_requestid
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"go.sdls.io/beehive/pkg/beehive"
+)
+
+func TestConfig_HandlerFunc(t *testing.T) {
+	t.Parallel()
+
+	config := &Config{
+		Header:    "X-Correlation-Id",
+		MaxLength: 16,
+		Generate: func() string {
+			return "generated"
+		},
+	}
+
+	router := beehive.NewRouter()
+	router.Handle("GET", "/", config.HandlerFunc(), func(ctx *beehive.Context) beehive.Responder {
+		id, _ := FromContext(ctx)
+		return &beehive.DefaultResponder{Message: id, Status: http.StatusOK}
+	})
+
+	tests := map[string]string{
+		"":                      "generated",
+		"abc-123":               "abc-123",
+		"with space":            "generated",
+		"line\nbreak":           "generated",
+		strings.Repeat("a", 16): strings.Repeat("a", 16),
+		strings.Repeat("a", 17): "generated",
+		"t:4/r=1+a@b_c.":        "t:4/r=1+a@b_c.",
+	}
+
+	for inbound, want := range tests {
+		r := httptest.NewRequest("GET", "/", nil)
+		if inbound != "" {
+			r.Header["X-Correlation-Id"] = []string{inbound}
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, r)
+
+		if w.Body.String() != want {
+			t.Errorf("%q: expected context ID %q, got %q", inbound, want, w.Body.String())
+		}
+		if w.Header().Get("X-Correlation-Id") != want {
+			t.Errorf("%q: expected response header %q, got %q", inbound, want, w.Header().Get("X-Correlation-Id"))
+		}
+	}
+}
+
+func TestTransport(t *testing.T) {
+	t.Parallel()
+
+	var got []string
+	server := httptest.NewServer(http.HandlerFunc(func(_ http.ResponseWriter, r *http.Request) {
+		got = append(got, r.Header.Get(DefaultHeader))
+	}))
+	defer server.Close()
+
+	client := &http.Client{Transport: &Transport{}}
+
+	for _, ctx := range []bool{true, false} {
+		c := t.Context()
+		if ctx {
+			c = WithID(c, "req-1")
+		}
+
+		r, _ := http.NewRequestWithContext(c, "GET", server.URL, nil)
+		res, err := client.Do(r)
+		if err != nil {
+			t.Fatalf("unexpected error %v", err)
+		}
+		_ = res.Body.Close()
+
+		if r.Header.Get(DefaultHeader) != "" {
+			t.Errorf("expected the original request to be left untouched")
+		}
+	}
+
+	if len(got) != 2 || got[0] != "req-1" || got[1] != "" {
+		t.Errorf("unexpected propagated IDs %q", got)
+	}
+}
@@ -0,0 +1,177 @@
+package beehive_responder
+
+import (
+	"encoding"
+	"encoding/csv"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+)
+
+// Encoder encodes values to a media type, see Negotiator.
+type Encoder interface {
+	// MediaType returns the Content-Type of the encoded values, optionally with parameters.
+	MediaType() string
+
+	// Encode writes the encoding of v to w.
+	Encode(w io.Writer, v any) error
+}
+
+// SelectiveEncoder is an Encoder only supporting some values, the Negotiator skips it for the values it cannot
+// encode.
+type SelectiveEncoder interface {
+	Encoder
+
+	// CanEncode reports whether v is supported by Encode.
+	CanEncode(v any) bool
+}
+
+// test that the codecs implement Encoder.
+var (
+	_ Encoder          = JSONCodec{}
+	_ Encoder          = XMLCodec{}
+	_ SelectiveEncoder = CSVCodec{}
+	_ Encoder          = TextCodec{}
+)
+
+// JSONCodec encodes values with encoding/json as application/json.
+type JSONCodec struct{}
+
+func (JSONCodec) MediaType() string { return "application/json" }
+
+func (JSONCodec) Encode(w io.Writer, v any) error {
+	return json.NewEncoder(w).Encode(v)
+}
+
+// XMLCodec encodes values with encoding/xml as application/xml.
+type XMLCodec struct{}
+
+func (XMLCodec) MediaType() string { return "application/xml" }
+
+func (XMLCodec) Encode(w io.Writer, v any) error {
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	return xml.NewEncoder(w).Encode(v)
+}
+
+// ErrCSVUnsupported is returned by CSVCodec for values that are not slices of structs.
+var ErrCSVUnsupported = errors.New("beehive-responder: csv requires a slice of structs")
+
+// CSVCodec encodes slices (or arrays) of structs, or of pointers to structs, as text/csv with a header row. The
+// column names are the field names, or the name of the `csv` tag ("-" skips the field). Values implementing
+// encoding.TextMarshaler are marshaled, everything else is formatted with fmt.
+type CSVCodec struct{}
+
+func (CSVCodec) MediaType() string { return "text/csv; charset=utf-8" }
+
+func (CSVCodec) CanEncode(v any) bool {
+	_, _, ok := csvRows(v)
+	return ok
+}
+
+func (CSVCodec) Encode(w io.Writer, v any) error {
+	rv, elemType, ok := csvRows(v)
+	if !ok {
+		return ErrCSVUnsupported
+	}
+
+	var (
+		fields []int
+		header []string
+	)
+	for idx := range elemType.NumField() {
+		field := elemType.Field(idx)
+		if !field.IsExported() {
+			continue
+		}
+
+		name := field.Name
+		if tag, ok := field.Tag.Lookup("csv"); ok {
+			if tag == "-" {
+				continue
+			}
+			if tag != "" {
+				name = tag
+			}
+		}
+
+		fields = append(fields, idx)
+		header = append(header, name)
+	}
+
+	cw := csv.NewWriter(w)
+	if err := cw.Write(header); err != nil {
+		return err
+	}
+
+	record := make([]string, len(fields))
+	for i := range rv.Len() {
+		elem := rv.Index(i)
+		if elem.Kind() == reflect.Pointer {
+			if elem.IsNil() {
+				continue
+			}
+			elem = elem.Elem()
+		}
+
+		for col, idx := range fields {
+			value, err := csvValue(elem.Field(idx))
+			if err != nil {
+				return err
+			}
+			record[col] = value
+		}
+
+		if err := cw.Write(record); err != nil {
+			return err
+		}
+	}
+
+	cw.Flush()
+	return cw.Error()
+}
+
+// csvRows returns the slice (or array) of v and the struct type of its elements, ok is false when v is not supported.
+func csvRows(v any) (rv reflect.Value, elemType reflect.Type, ok bool) {
+	rv = reflect.ValueOf(v)
+	for rv.Kind() == reflect.Pointer && !rv.IsNil() {
+		rv = rv.Elem()
+	}
+	if rv.Kind() != reflect.Slice && rv.Kind() != reflect.Array {
+		return rv, nil, false
+	}
+
+	elemType = rv.Type().Elem()
+	if elemType.Kind() == reflect.Pointer {
+		elemType = elemType.Elem()
+	}
+
+	return rv, elemType, elemType.Kind() == reflect.Struct
+}
+
+func csvValue(v reflect.Value) (string, error) {
+	if v.Kind() == reflect.Pointer && v.IsNil() {
+		return "", nil
+	}
+
+	if m, ok := v.Interface().(encoding.TextMarshaler); ok {
+		text, err := m.MarshalText()
+		return string(text), err
+	}
+
+	return fmt.Sprint(reflect.Indirect(v).Interface()), nil
+}
+
+// TextCodec encodes values as text/plain with fmt, such that strings and fmt.Stringer are written as is.
+type TextCodec struct{}
+
+func (TextCodec) MediaType() string { return "text/plain; charset=utf-8" }
+
+func (TextCodec) Encode(w io.Writer, v any) error {
+	_, err := fmt.Fprint(w, v)
+	return err
+}
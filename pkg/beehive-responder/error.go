@@ -0,0 +1,110 @@
+package beehive_responder
+
+import (
+	"errors"
+	"net/http"
+
+	"go.sdls.io/beehive/pkg/beehive"
+)
+
+// ErrorHandlerFunc is a handler returning an error instead of a Responder for failures, see ErrorMapper.Handle.
+type ErrorHandlerFunc func(ctx *beehive.Context) (beehive.Responder, error)
+
+// ErrorMapper maps errors to Problem responders, such that domain errors get a consistent HTTP representation. The
+// mappings are registered with RegisterError and RegisterErrorType before the ErrorMapper is used, and are tried
+// in registration order.
+type ErrorMapper struct {
+	// Fallback maps the errors not matched by any registered mapping. If nil, a 500 Internal Server Error without
+	// detail is returned, such that internal errors are not leaked.
+	Fallback func(err error) *Problem
+
+	mappings []func(err error) (*Problem, bool)
+}
+
+// RegisterError maps the errors matching target with errors.Is to a copy of problem.
+func (m *ErrorMapper) RegisterError(target error, problem Problem) {
+	m.mappings = append(m.mappings, func(err error) (*Problem, bool) {
+		if !errors.Is(err, target) {
+			return nil, false
+		}
+
+		p := problem
+		return &p, true
+	})
+}
+
+// RegisterErrorType maps the errors matching the error type E with errors.As to the Problem returned by f. When f
+// returns nil, the error is not matched and the next mappings are tried.
+func RegisterErrorType[E error](m *ErrorMapper, f func(err E) *Problem) {
+	m.mappings = append(m.mappings, func(err error) (*Problem, bool) {
+		var target E
+		if !errors.As(err, &target) {
+			return nil, false
+		}
+
+		p := f(target)
+		return p, p != nil
+	})
+}
+
+// Problem returns the Problem of the given error, errors already wrapping a *Problem (see ProblemError) are
+// returned as is.
+func (m *ErrorMapper) Problem(err error) *Problem {
+	var problemErr *ProblemError
+	if errors.As(err, &problemErr) {
+		return problemErr.Problem
+	}
+
+	for _, mapping := range m.mappings {
+		if p, ok := mapping(err); ok {
+			return p
+		}
+	}
+
+	if m.Fallback != nil {
+		return m.Fallback(err)
+	}
+
+	return &Problem{Status: http.StatusInternalServerError}
+}
+
+// Handle adapts an ErrorHandlerFunc to a beehive.HandlerFunc, responding with the Problem of the returned error.
+// Returning a nil Responder and a nil error continues the chain.
+func (m *ErrorMapper) Handle(f ErrorHandlerFunc) beehive.HandlerFunc {
+	return func(ctx *beehive.Context) beehive.Responder {
+		res, err := f(ctx)
+		if err != nil {
+			return m.Problem(err)
+		}
+		return res
+	}
+}
+
+// HandleError adapts a handler (or middleware) only returning an error to a beehive.HandlerFunc, responding with the
+// Problem of the returned error. A nil error continues the chain.
+func (m *ErrorMapper) HandleError(f func(ctx *beehive.Context) error) beehive.HandlerFunc {
+	return func(ctx *beehive.Context) beehive.Responder {
+		if err := f(ctx); err != nil {
+			return m.Problem(err)
+		}
+		return nil
+	}
+}
+
+// ProblemError is an error carrying the Problem to respond with, letting handlers return a specific Problem through
+// an ErrorMapper.
+type ProblemError struct {
+	Problem *Problem
+}
+
+func (e *ProblemError) Error() string {
+	title := e.Problem.Title
+	if title == "" {
+		title = http.StatusText(e.Problem.StatusCode(nil))
+	}
+
+	if e.Problem.Detail != "" {
+		return title + ": " + e.Problem.Detail
+	}
+	return title
+}
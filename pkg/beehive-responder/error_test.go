@@ -0,0 +1,117 @@
+package beehive_responder
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"go.sdls.io/beehive/pkg/beehive"
+)
+
+var errTestNotFound = errors.New("not found")
+
+type testValidationError struct {
+	Field string
+}
+
+func (e *testValidationError) Error() string {
+	return "invalid " + e.Field
+}
+
+func TestErrorMapper(t *testing.T) {
+	t.Parallel()
+
+	mapper := &ErrorMapper{}
+	mapper.RegisterError(errTestNotFound, Problem{Status: http.StatusNotFound, Title: "Resource not found"})
+	RegisterErrorType(mapper, func(err *testValidationError) *Problem {
+		if err.Field == "" {
+			return nil
+		}
+		return &Problem{
+			Status:     http.StatusUnprocessableEntity,
+			Detail:     err.Error(),
+			Extensions: map[string]any{"field": err.Field},
+		}
+	})
+
+	router := beehive.NewRouter()
+	router.Handle("GET", "/errors/*", mapper.Handle(func(ctx *beehive.Context) (beehive.Responder, error) {
+		switch ctx.Request.URL.Path {
+		case "/errors/not-found":
+			return nil, fmt.Errorf("loading user: %w", errTestNotFound)
+		case "/errors/validation":
+			return nil, fmt.Errorf("decoding: %w", &testValidationError{Field: "email"})
+		case "/errors/no-field":
+			return nil, &testValidationError{}
+		case "/errors/problem":
+			return nil, &ProblemError{Problem: &Problem{Status: http.StatusTeapot}}
+		case "/errors/internal":
+			return nil, errors.New("connection refused to 10.0.0.1")
+		}
+		return &beehive.DefaultResponder{Message: "ok", Status: http.StatusOK}, nil
+	}))
+
+	tests := map[string]struct {
+		code int
+		body string
+	}{
+		"/errors/ok":         {http.StatusOK, "ok"},
+		"/errors/not-found":  {http.StatusNotFound, `{"status":404,"title":"Resource not found"}`},
+		"/errors/validation": {http.StatusUnprocessableEntity, `{"detail":"invalid email","field":"email","status":422,"title":"Unprocessable Entity"}`},
+		"/errors/no-field":   {http.StatusInternalServerError, `{"status":500,"title":"Internal Server Error"}`},
+		"/errors/problem":    {http.StatusTeapot, `{"status":418,"title":"I'm a teapot"}`},
+		"/errors/internal":   {http.StatusInternalServerError, `{"status":500,"title":"Internal Server Error"}`},
+	}
+
+	for path, tt := range tests {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest("GET", path, nil))
+
+		if w.Code != tt.code || w.Body.String() != tt.body {
+			t.Errorf("%s: expected %d %s, got %d %s", path, tt.code, tt.body, w.Code, w.Body.String())
+		}
+	}
+}
+
+func TestErrorMapper_HandleError(t *testing.T) {
+	t.Parallel()
+
+	mapper := &ErrorMapper{
+		Fallback: func(err error) *Problem {
+			return &Problem{Status: http.StatusBadGateway, Detail: err.Error()}
+		},
+	}
+
+	router := beehive.NewRouter()
+	router.Handle("GET", "/", mapper.HandleError(func(ctx *beehive.Context) error {
+		if ctx.Request.URL.Query().Get("fail") != "" {
+			return errors.New("upstream down")
+		}
+		return nil
+	}), func(_ *beehive.Context) beehive.Responder {
+		return &beehive.DefaultResponder{Message: "ok", Status: http.StatusOK}
+	})
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", "/", nil))
+	if w.Code != http.StatusOK {
+		t.Errorf("expected chain to continue, got %d", w.Code)
+	}
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", "/?fail=1", nil))
+	if want := `{"detail":"upstream down","status":502,"title":"Bad Gateway"}`; w.Code != http.StatusBadGateway || w.Body.String() != want {
+		t.Errorf("expected %s, got %d %s", want, w.Code, w.Body.String())
+	}
+}
+
+func TestProblemError(t *testing.T) {
+	t.Parallel()
+
+	err := &ProblemError{Problem: &Problem{Status: http.StatusNotFound, Detail: "user 42"}}
+	if err.Error() != "Not Found: user 42" {
+		t.Errorf("expected %q, got %q", "Not Found: user 42", err.Error())
+	}
+}
@@ -0,0 +1,205 @@
+package beehive_responder
+
+import (
+	"bytes"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"go.sdls.io/beehive/pkg/beehive"
+)
+
+// MediaRange is a single media range of an Accept header.
+type MediaRange struct {
+	Type    string
+	Subtype string
+	Q       float64
+}
+
+// ParseAccept parses an Accept header value, media ranges with an invalid q-value are ignored. The media ranges are
+// returned in header order.
+func ParseAccept(accept string) []MediaRange {
+	var ranges []MediaRange
+
+	for part := range strings.SplitSeq(accept, ",") {
+		mediaType, params, _ := strings.Cut(part, ";")
+
+		typ, subtype, found := strings.Cut(strings.TrimSpace(mediaType), "/")
+		if !found || typ == "" || subtype == "" {
+			continue
+		}
+
+		mr := MediaRange{
+			Type:    strings.ToLower(typ),
+			Subtype: strings.ToLower(subtype),
+			Q:       1,
+		}
+
+		valid := true
+		for param := range strings.SplitSeq(params, ";") {
+			key, value, _ := strings.Cut(strings.TrimSpace(param), "=")
+			if !strings.EqualFold(key, "q") {
+				continue
+			}
+
+			q, err := strconv.ParseFloat(value, 64)
+			if err != nil || q < 0 || q > 1 {
+				valid = false
+			}
+			mr.Q = q
+			break
+		}
+
+		if valid {
+			ranges = append(ranges, mr)
+		}
+	}
+
+	return ranges
+}
+
+// quality returns the q-value given to the media type by the most specific matching media range, zero when no range
+// matches.
+func quality(ranges []MediaRange, mediaType string) float64 {
+	mediaType, _, _ = strings.Cut(mediaType, ";")
+	typ, subtype, _ := strings.Cut(strings.ToLower(strings.TrimSpace(mediaType)), "/")
+
+	q, specificity := 0.0, -1
+	for _, mr := range ranges {
+		s := -1
+		switch {
+		case mr.Type == typ && mr.Subtype == subtype:
+			s = 2
+		case mr.Type == typ && mr.Subtype == "*":
+			s = 1
+		case mr.Type == "*" && mr.Subtype == "*":
+			s = 0
+		}
+
+		if s > specificity {
+			q, specificity = mr.Q, s
+		}
+	}
+
+	return q
+}
+
+// Negotiator selects the Encoder of a response from the request Accept header.
+type Negotiator struct {
+	// Encoders are the available encoders, in order of preference. The first one able to encode the value is used
+	// when the request has no Accept header, and on equal q-values the earlier one wins. A SelectiveEncoder is only
+	// selected for the values it can encode.
+	Encoders []Encoder
+
+	// NotAcceptable is returned when no Encoder is acceptable. If nil, a 406 Not Acceptable is returned.
+	NotAcceptable beehive.Responder
+}
+
+// DefaultNegotiator negotiates between JSON, XML, CSV and plain text, preferring JSON.
+var DefaultNegotiator = &Negotiator{
+	Encoders: []Encoder{JSONCodec{}, XMLCodec{}, CSVCodec{}, TextCodec{}},
+}
+
+var defaultNotAcceptableResponder = &beehive.DefaultResponder{
+	Message: "not acceptable",
+	Status:  http.StatusNotAcceptable,
+}
+
+// Select returns the Encoder preferred by the given Accept header value able to encode v, or nil when none is
+// acceptable.
+func (n *Negotiator) Select(accept string, v any) Encoder {
+	var ranges []MediaRange
+	if strings.TrimSpace(accept) != "" {
+		ranges = ParseAccept(accept)
+	}
+
+	var (
+		best  Encoder
+		bestQ float64
+	)
+	for _, encoder := range n.Encoders {
+		if s, ok := encoder.(SelectiveEncoder); ok && !s.CanEncode(v) {
+			continue
+		}
+		if ranges == nil {
+			return encoder
+		}
+
+		if q := quality(ranges, encoder.MediaType()); q > bestQ {
+			best, bestQ = encoder, q
+		}
+	}
+
+	return best
+}
+
+// Respond returns a Negotiated responder for the given object and status code.
+func (n *Negotiator) Respond(object any, code int) *Negotiated {
+	return &Negotiated{
+		Negotiator: n,
+		Object:     object,
+		Code:       code,
+	}
+}
+
+// Negotiated implements the beehive.Responder interface by encoding Object with the Encoder selected by the
+// Negotiator from the request Accept header. Vary: Accept is always set. Object is encoded before any header is
+// written, encoding errors are reported with beehive.Context.ReportError and answered with a 500.
+type Negotiated struct {
+	Negotiator *Negotiator
+	Object     any
+	Code       int
+
+	// written is the status code sent by Respond, when it differs from the negotiated one.
+	written int
+}
+
+// test that Negotiated implements the beehive.Responder interface.
+var _ beehive.Responder = &Negotiated{}
+
+func (n *Negotiated) negotiator() *Negotiator {
+	if n.Negotiator == nil {
+		return DefaultNegotiator
+	}
+	return n.Negotiator
+}
+
+func (n *Negotiated) notAcceptable() beehive.Responder {
+	if res := n.negotiator().NotAcceptable; res != nil {
+		return res
+	}
+	return defaultNotAcceptableResponder
+}
+
+func (n *Negotiated) StatusCode(ctx *beehive.Context) int {
+	if n.written != 0 {
+		return n.written
+	}
+	if n.negotiator().Select(ctx.Request.Header.Get("Accept"), n.Object) == nil {
+		return n.notAcceptable().StatusCode(ctx)
+	}
+	return n.Code
+}
+
+func (n *Negotiated) Respond(ctx *beehive.Context) {
+	h := ctx.ResponseWriter.Header()
+	h.Add("Vary", "Accept")
+
+	encoder := n.negotiator().Select(ctx.Request.Header.Get("Accept"), n.Object)
+	if encoder == nil {
+		n.notAcceptable().Respond(ctx)
+		return
+	}
+
+	var buf bytes.Buffer
+	if err := encoder.Encode(&buf, n.Object); err != nil {
+		ctx.ReportError(err)
+		n.written = http.StatusInternalServerError
+		ctx.ResponseWriter.WriteHeader(n.written)
+		return
+	}
+
+	h.Set("Content-Type", encoder.MediaType())
+	ctx.ResponseWriter.WriteHeader(n.Code)
+	_, _ = ctx.ResponseWriter.Write(buf.Bytes())
+}
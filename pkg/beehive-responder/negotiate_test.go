@@ -0,0 +1,196 @@
+package beehive_responder
+
+import (
+	"encoding/xml"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+
+	"go.sdls.io/beehive/pkg/beehive"
+)
+
+type testUser struct {
+	XMLName  struct{}  `json:"-" xml:"user" csv:"-"`
+	Name     string    `json:"name" xml:"name" csv:"name"`
+	Age      int       `json:"age" xml:"age" csv:"-"`
+	Joined   time.Time `json:"-" xml:"-" csv:"joined"`
+	Manager  *string   `json:"-" xml:"-"`
+	internal string
+}
+
+func (u testUser) String() string {
+	return "user " + u.Name
+}
+
+func TestParseAccept(t *testing.T) {
+	t.Parallel()
+
+	got := ParseAccept("text/html, application/XML;q=0.9;level=1, */*;q=0.8, bad, image/png;q=2, text/*;q=x")
+	want := []MediaRange{
+		{Type: "text", Subtype: "html", Q: 1},
+		{Type: "application", Subtype: "xml", Q: 0.9},
+		{Type: "*", Subtype: "*", Q: 0.8},
+	}
+
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("expected %v, got %v", want, got)
+	}
+}
+
+func TestNegotiator_Select(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]Encoder{
+		"":                                      JSONCodec{},
+		"*/*":                                   JSONCodec{},
+		"application/xml":                       XMLCodec{},
+		"text/*":                                CSVCodec{},
+		"text/plain, text/csv;q=0.5":            TextCodec{},
+		"application/json;q=0, */*;q=0.1":       XMLCodec{},
+		"application/xml;q=0.5, text/csv;q=0.9": CSVCodec{},
+		"image/png":                             nil,
+		"*/*;q=0":                               nil,
+	}
+
+	for accept, want := range tests {
+		if got := DefaultNegotiator.Select(accept, []testUser{}); got != want {
+			t.Errorf("%q: expected %T, got %T", accept, want, got)
+		}
+	}
+
+	// CSVCodec cannot encode a single user.
+	tests = map[string]Encoder{
+		"text/*":              TextCodec{},
+		"text/csv":            nil,
+		"text/csv, */*;q=0.1": JSONCodec{},
+	}
+
+	for accept, want := range tests {
+		if got := DefaultNegotiator.Select(accept, testUser{}); got != want {
+			t.Errorf("%q: expected %T, got %T", accept, want, got)
+		}
+	}
+
+	negotiator := &Negotiator{Encoders: []Encoder{CSVCodec{}, TextCodec{}}}
+	if got := negotiator.Select("", testUser{}); got != (TextCodec{}) {
+		t.Errorf("expected %T, got %T", TextCodec{}, got)
+	}
+}
+
+func TestNegotiated(t *testing.T) {
+	t.Parallel()
+
+	manager := "alice"
+	users := []*testUser{
+		{Name: "bob", Age: 30, Joined: time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC), Manager: &manager, internal: "hidden"},
+		nil,
+		{Name: "carol, jr", Age: 25},
+	}
+
+	router := beehive.NewRouter()
+	router.Handle("GET", "/users", func(_ *beehive.Context) beehive.Responder {
+		return DefaultNegotiator.Respond(users, http.StatusOK)
+	})
+	router.Handle("GET", "/user", func(_ *beehive.Context) beehive.Responder {
+		return DefaultNegotiator.Respond(users[0], http.StatusCreated)
+	})
+
+	tests := []struct {
+		path, accept, contentType, body string
+		code                            int
+	}{
+		{"/user", "", "application/json", "{\"name\":\"bob\",\"age\":30}\n", http.StatusCreated},
+		{"/user", "application/xml", "application/xml", xml.Header + "<user><name>bob</name><age>30</age></user>", http.StatusCreated},
+		{"/user", "text/plain", "text/plain; charset=utf-8", "user bob", http.StatusCreated},
+		{"/users", "text/csv", "text/csv; charset=utf-8", "name,joined,Manager\nbob,2024-01-02T00:00:00Z,alice\n\"carol, jr\",0001-01-01T00:00:00Z,\n", http.StatusOK},
+		{"/users", "image/png", "", "not acceptable", http.StatusNotAcceptable},
+		{"/user", "text/csv", "", "not acceptable", http.StatusNotAcceptable},
+	}
+
+	for _, tt := range tests {
+		r := httptest.NewRequest("GET", tt.path, nil)
+		r.Header.Set("Accept", tt.accept)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, r)
+
+		if w.Code != tt.code {
+			t.Errorf("%s %q: expected status code %d, got %d", tt.path, tt.accept, tt.code, w.Code)
+		}
+		if w.Header().Get("Content-Type") != tt.contentType {
+			t.Errorf("%s %q: expected Content-Type %s, got %s", tt.path, tt.accept, tt.contentType, w.Header().Get("Content-Type"))
+		}
+		if w.Header().Get("Vary") != "Accept" {
+			t.Errorf("%s %q: expected Vary Accept, got %s", tt.path, tt.accept, w.Header().Get("Vary"))
+		}
+		if w.Body.String() != tt.body {
+			t.Errorf("%s %q: expected body %q, got %q", tt.path, tt.accept, tt.body, w.Body.String())
+		}
+	}
+}
+
+func TestNegotiated_errors(t *testing.T) {
+	t.Parallel()
+
+	negotiator := &Negotiator{
+		Encoders:      []Encoder{JSONCodec{}, CSVCodec{}},
+		NotAcceptable: &Status{Code: http.StatusUnsupportedMediaType},
+	}
+
+	var (
+		reported []error
+		statuses []int
+	)
+
+	router := beehive.NewRouter()
+	router.WhenError = func(_ *beehive.Context, err error) {
+		reported = append(reported, err)
+	}
+	router.After = func(ctx *beehive.Context, res beehive.Responder) {
+		statuses = append(statuses, res.StatusCode(ctx))
+	}
+	router.Handle("GET", "/", func(_ *beehive.Context) beehive.Responder {
+		return negotiator.Respond(map[string]any{"a": func() {}}, http.StatusOK)
+	})
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", "/", nil))
+	if w.Code != http.StatusInternalServerError || len(reported) != 1 {
+		t.Errorf("expected the encoding error to be reported with a 500, got %d and %v", w.Code, reported)
+	}
+
+	r := httptest.NewRequest("GET", "/", nil)
+	r.Header.Set("Accept", "text/csv")
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, r)
+	if w.Code != http.StatusUnsupportedMediaType {
+		t.Errorf("expected custom not acceptable responder, got %d", w.Code)
+	}
+
+	want := []int{http.StatusInternalServerError, http.StatusUnsupportedMediaType}
+	if !reflect.DeepEqual(statuses, want) {
+		t.Errorf("expected status codes %v, got %v", want, statuses)
+	}
+}
+
+func TestCSVCodec_CanEncode(t *testing.T) {
+	t.Parallel()
+
+	tests := map[any]bool{
+		&[]testUser{}:  true,
+		[1]*testUser{}: true,
+		testUser{}:     false,
+		"users":        false,
+	}
+
+	for v, want := range tests {
+		if got := (CSVCodec{}).CanEncode(v); got != want {
+			t.Errorf("%T: expected %t, got %t", v, want, got)
+		}
+		if err := (CSVCodec{}).Encode(io.Discard, v); (err == nil) != want {
+			t.Errorf("%T: expected %t, got %v", v, want, err)
+		}
+	}
+}
@@ -0,0 +1,88 @@
+package beehive_responder
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"go.sdls.io/beehive/pkg/beehive"
+)
+
+// ProblemContentType is the media type of RFC 9457 problem details.
+const ProblemContentType = "application/problem+json"
+
+// Problem implements the beehive.Responder interface with an RFC 9457 problem details object, sent as
+// application/problem+json.
+type Problem struct {
+	// Type is a URI reference identifying the problem type. If empty, the member is omitted, meaning "about:blank".
+	Type string
+
+	// Title is a short summary of the problem type. If empty and Type is empty, the status text is used.
+	Title string
+
+	// Status is the HTTP status code. If zero, 500 is used.
+	Status int
+
+	// Detail is an explanation specific to this occurrence of the problem.
+	Detail string
+
+	// Instance is a URI reference identifying this occurrence of the problem.
+	Instance string
+
+	// Extensions are additional members. They cannot override the members above.
+	Extensions map[string]any
+}
+
+// test that Problem implements beehive.Responder.
+var _ beehive.Responder = &Problem{}
+
+func (p *Problem) StatusCode(_ *beehive.Context) int {
+	if p.Status == 0 {
+		return http.StatusInternalServerError
+	}
+	return p.Status
+}
+
+// Respond sends the problem details. When they cannot be encoded (for example unsupported Extensions), a plain
+// 500 Internal Server Error problem is sent instead.
+func (p *Problem) Respond(ctx *beehive.Context) {
+	status := p.StatusCode(ctx)
+	data, err := json.Marshal(p)
+	if err != nil {
+		status = http.StatusInternalServerError
+		data, _ = json.Marshal(&Problem{Status: status})
+	}
+
+	w := ctx.ResponseWriter
+	w.Header().Set("Content-Type", ProblemContentType)
+	w.WriteHeader(status)
+	_, _ = w.Write(data)
+}
+
+// MarshalJSON encodes the problem details with the Extensions as top level members.
+func (p *Problem) MarshalJSON() ([]byte, error) {
+	m := make(map[string]any, len(p.Extensions)+5)
+	for k, v := range p.Extensions {
+		m[k] = v
+	}
+
+	status := p.Status
+	if status == 0 {
+		status = http.StatusInternalServerError
+	}
+
+	title := p.Title
+	if title == "" && p.Type == "" {
+		title = http.StatusText(status)
+	}
+
+	for k, v := range map[string]string{"type": p.Type, "title": title, "detail": p.Detail, "instance": p.Instance} {
+		if v != "" {
+			m[k] = v
+		} else {
+			delete(m, k)
+		}
+	}
+	m["status"] = status
+
+	return json.Marshal(m)
+}
@@ -0,0 +1,77 @@
+package beehive_responder
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"go.sdls.io/beehive/pkg/beehive"
+)
+
+func TestProblem(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		problem *Problem
+		code    int
+		want    string
+	}{
+		{
+			problem: &Problem{},
+			code:    http.StatusInternalServerError,
+			want:    `{"status":500,"title":"Internal Server Error"}`,
+		},
+		{
+			problem: &Problem{Status: http.StatusNotFound, Detail: "user 42 not found"},
+			code:    http.StatusNotFound,
+			want:    `{"detail":"user 42 not found","status":404,"title":"Not Found"}`,
+		},
+		{
+			problem: &Problem{
+				Type:     "https://example.com/probs/out-of-credit",
+				Title:    "You do not have enough credit.",
+				Status:   http.StatusForbidden,
+				Detail:   "Your current balance is 30, but that costs 50.",
+				Instance: "/account/12345/msgs/abc",
+				Extensions: map[string]any{
+					"balance": 30,
+					"status":  200,
+					"title":   "overridden",
+				},
+			},
+			code: http.StatusForbidden,
+			want: `{"balance":30,"detail":"Your current balance is 30, but that costs 50.","instance":"/account/12345/msgs/abc",` +
+				`"status":403,"title":"You do not have enough credit.","type":"https://example.com/probs/out-of-credit"}`,
+		},
+		{
+			problem: &Problem{Type: "https://example.com/probs/custom", Status: http.StatusConflict},
+			code:    http.StatusConflict,
+			want:    `{"status":409,"type":"https://example.com/probs/custom"}`,
+		},
+		{
+			problem: &Problem{Status: http.StatusBadRequest, Extensions: map[string]any{"invalid": make(chan int)}},
+			code:    http.StatusInternalServerError,
+			want:    `{"status":500,"title":"Internal Server Error"}`,
+		},
+	}
+
+	for _, tt := range tests {
+		router := beehive.NewRouter()
+		router.Handle("GET", "/", func(_ *beehive.Context) beehive.Responder {
+			return tt.problem
+		})
+
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest("GET", "/", nil))
+
+		if w.Code != tt.code {
+			t.Errorf("expected status code %d, got %d", tt.code, w.Code)
+		}
+		if w.Header().Get("Content-Type") != ProblemContentType {
+			t.Errorf("expected Content-Type %s, got %s", ProblemContentType, w.Header().Get("Content-Type"))
+		}
+		if w.Body.String() != tt.want {
+			t.Errorf("expected body %s, got %s", tt.want, w.Body.String())
+		}
+	}
+}
@@ -0,0 +1,190 @@
+package beehive_responder
+
+import (
+	"html"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"go.sdls.io/beehive/pkg/beehive"
+)
+
+// Redirect implements the beehive.Responder interface by redirecting to Location. Relative locations (such as "edit",
+// "../list" or "?page=2") are resolved against the request URL into an absolute path, like a browser would do.
+// Locations coming from user input must be validated, see RedirectPolicy.
+type Redirect struct {
+	// Location is the target URL, absolute or relative to the request URL.
+	Location string
+
+	// Code is one of 301, 302, 303, 307 or 308. If zero, 302 is used.
+	Code int
+}
+
+// test that Redirect implements beehive.Responder.
+var _ beehive.Responder = &Redirect{}
+
+// MovedPermanently returns a 301 Moved Permanently Redirect. Clients may change POST to GET on the redirected
+// request, use PermanentRedirect to preserve the method.
+func MovedPermanently(location string) *Redirect {
+	return &Redirect{Location: location, Code: http.StatusMovedPermanently}
+}
+
+// Found returns a 302 Found Redirect. Clients may change POST to GET on the redirected request, use
+// TemporaryRedirect to preserve the method.
+func Found(location string) *Redirect {
+	return &Redirect{Location: location, Code: http.StatusFound}
+}
+
+// SeeOther returns a 303 See Other Redirect, the redirected request is a GET. It is typically used after a POST.
+func SeeOther(location string) *Redirect {
+	return &Redirect{Location: location, Code: http.StatusSeeOther}
+}
+
+// TemporaryRedirect returns a 307 Temporary Redirect, preserving the method and body of the request.
+func TemporaryRedirect(location string) *Redirect {
+	return &Redirect{Location: location, Code: http.StatusTemporaryRedirect}
+}
+
+// PermanentRedirect returns a 308 Permanent Redirect, preserving the method and body of the request.
+func PermanentRedirect(location string) *Redirect {
+	return &Redirect{Location: location, Code: http.StatusPermanentRedirect}
+}
+
+func (rd *Redirect) StatusCode(_ *beehive.Context) int {
+	if rd.Code == 0 {
+		return http.StatusFound
+	}
+	return rd.Code
+}
+
+func (rd *Redirect) Respond(ctx *beehive.Context) {
+	location := resolveLocation(ctx.Request.URL, rd.Location)
+	code := rd.StatusCode(ctx)
+
+	w := ctx.ResponseWriter
+	w.Header().Set("Location", location)
+
+	// like net/http, a short body is sent to GET requests of clients not following redirects
+	if ctx.Request.Method == http.MethodGet {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.WriteHeader(code)
+		_, _ = w.Write([]byte("<a href=\"" + html.EscapeString(location) + "\">" + http.StatusText(code) + "</a>.\n"))
+		return
+	}
+
+	w.WriteHeader(code)
+}
+
+// resolveLocation resolves the relative location against the request URL, absolute URLs and invalid locations are
+// returned as is. A relative location never resolves to a scheme-relative one ("/.//host" would give "//host").
+func resolveLocation(requestURL *url.URL, location string) string {
+	ref, err := url.Parse(location)
+	if err != nil || ref.Scheme != "" || ref.Host != "" || strings.HasPrefix(location, "//") {
+		return location
+	}
+
+	base := &url.URL{Path: requestURL.Path, RawPath: requestURL.RawPath, RawQuery: requestURL.RawQuery}
+	if base.Path == "" {
+		base.Path = "/"
+	}
+
+	resolved := base.ResolveReference(ref).String()
+	if strings.HasPrefix(resolved, "//") {
+		resolved = "/" + strings.TrimLeft(resolved, "/")
+	}
+
+	return resolved
+}
+
+// RedirectPolicy validates redirect targets coming from user input (such as ?next=), preventing open redirects to
+// other sites. Relative targets are allowed unless they resolve to a scheme-relative one (such as "/.//host"),
+// absolute ones (including scheme-relative "//host/path" targets) only when their host is allowed.
+type RedirectPolicy struct {
+	// AllowHosts are the hosts absolute targets can redirect to, compared case-insensitively without the port. A host
+	// starting with "*." matches any subdomain (but not the domain itself).
+	AllowHosts []string
+
+	// AllowSchemes are the schemes of absolute targets. If empty, "http" and "https" are allowed.
+	AllowSchemes []string
+
+	// Fallback is the location used by Redirect for rejected targets. If empty, "/" is used.
+	Fallback string
+}
+
+// Allow reports whether the target is a relative URL, or an absolute URL to an allowed scheme and host. Targets with
+// backslashes or control characters, which browsers interpret leniently, are rejected.
+func (p *RedirectPolicy) Allow(target string) bool {
+	if target == "" || strings.ContainsFunc(target, func(r rune) bool {
+		return r == '\\' || r < 0x20 || r == 0x7f
+	}) {
+		return false
+	}
+
+	u, err := url.Parse(target)
+	if err != nil {
+		return false
+	}
+
+	if u.Scheme == "" && u.Host == "" && !strings.HasPrefix(target, "//") {
+		if u.Opaque != "" {
+			return false
+		}
+
+		// dot segments are removed when resolving, such that "/.//evil.com" would become "//evil.com"
+		resolved := (&url.URL{Path: "/"}).ResolveReference(u).String()
+		if strings.HasPrefix(resolved, "//") || strings.HasPrefix(resolved, "/\\") {
+			return false
+		}
+
+		r, err := url.Parse(resolved)
+		return err == nil && r.Host == ""
+	}
+
+	schemes := p.AllowSchemes
+	if len(schemes) == 0 {
+		schemes = []string{"http", "https"}
+	}
+
+	if u.Scheme != "" && !containsFold(schemes, u.Scheme) {
+		return false
+	}
+
+	host := strings.ToLower(u.Hostname())
+	if host == "" || u.User != nil {
+		return false
+	}
+
+	for _, allowed := range p.AllowHosts {
+		allowed = strings.ToLower(allowed)
+		if suffix, ok := strings.CutPrefix(allowed, "*."); ok {
+			if strings.HasSuffix(host, "."+suffix) {
+				return true
+			}
+		} else if host == allowed {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Redirect returns a Redirect to the target if allowed, to the Fallback otherwise.
+func (p *RedirectPolicy) Redirect(target string, code int) *Redirect {
+	if !p.Allow(target) {
+		target = p.Fallback
+		if target == "" {
+			target = "/"
+		}
+	}
+
+	return &Redirect{Location: target, Code: code}
+}
+
+func containsFold(values []string, value string) bool {
+	for _, v := range values {
+		if strings.EqualFold(v, value) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,137 @@
+package beehive_responder
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"go.sdls.io/beehive/pkg/beehive"
+)
+
+func TestRedirect(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		method   string
+		path     string
+		res      *Redirect
+		code     int
+		location string
+	}{
+		{"GET", "/a/b/c", MovedPermanently("https://example.com/x"), http.StatusMovedPermanently, "https://example.com/x"},
+		{"GET", "/a/b/c", Found("/login"), http.StatusFound, "/login"},
+		{"POST", "/a/b/c", SeeOther("d"), http.StatusSeeOther, "/a/b/d"},
+		{"POST", "/a/b/c", TemporaryRedirect("../d?x=1"), http.StatusTemporaryRedirect, "/a/d?x=1"},
+		{"PUT", "/a/b/", PermanentRedirect("d"), http.StatusPermanentRedirect, "/a/b/d"},
+		{"GET", "/a/b/c?page=1", &Redirect{Location: "?page=2"}, http.StatusFound, "/a/b/c?page=2"},
+		{"GET", "/a/b/c", &Redirect{Location: "//cdn.example.com/x"}, http.StatusFound, "//cdn.example.com/x"},
+		{"GET", "/a/b/c", &Redirect{Location: "#top"}, http.StatusFound, "/a/b/c#top"},
+		{"GET", "/a/b/c", &Redirect{Location: "/.//evil.com"}, http.StatusFound, "/evil.com"},
+		{"GET", "/", &Redirect{Location: ".//evil.com"}, http.StatusFound, "/evil.com"},
+	}
+
+	for _, test := range tests {
+		router := beehive.NewRouter()
+		router.Handle(test.method, "/*", func(_ *beehive.Context) beehive.Responder {
+			return test.res
+		})
+
+		var status int
+		router.After = func(ctx *beehive.Context, res beehive.Responder) {
+			status = res.StatusCode(ctx)
+		}
+
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(test.method, test.path, nil))
+
+		if w.Code != test.code {
+			t.Errorf("%s: expected %d, got %d", test.res.Location, test.code, w.Code)
+		}
+		if status != test.code {
+			t.Errorf("%s: expected %d in After, got %d", test.res.Location, test.code, status)
+		}
+		if location := w.Header().Get("Location"); location != test.location {
+			t.Errorf("%s: expected %q, got %q", test.res.Location, test.location, location)
+		}
+		if test.method != "GET" && w.Body.Len() != 0 {
+			t.Errorf("%s: expected empty body, got %q", test.res.Location, w.Body.String())
+		}
+	}
+}
+
+func TestRedirect_body(t *testing.T) {
+	t.Parallel()
+
+	router := beehive.NewRouter()
+	router.Handle("GET", "/", func(_ *beehive.Context) beehive.Responder {
+		return Found(`/search?q="<x>"`)
+	})
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", "/", nil))
+
+	want := "<a href=\"/search?q=&#34;&lt;x&gt;&#34;\">Found</a>.\n"
+	if w.Body.String() != want {
+		t.Errorf("expected %q, got %q", want, w.Body.String())
+	}
+}
+
+func TestRedirectPolicy_Allow(t *testing.T) {
+	t.Parallel()
+
+	policy := &RedirectPolicy{AllowHosts: []string{"example.com", "*.example.org"}}
+
+	tests := []struct {
+		target string
+		want   bool
+	}{
+		{"/dashboard", true},
+		{"dashboard?tab=1", true},
+		{"../up", true},
+		{"/a/./b//c", true},
+		{"https://example.com/x", true},
+		{"http://EXAMPLE.com:8080/x", true},
+		{"https://app.example.org/x", true},
+		{"//example.com/x", true},
+		{"https://example.org/x", false},
+		{"https://evil.com", false},
+		{"//evil.com", false},
+		{"/\\evil.com", false},
+		{"\\\\evil.com", false},
+		{"/\t/evil.com", false},
+		{"/.//evil.com", false},
+		{".//evil.com", false},
+		{"a/..//evil.com", true},
+		{"https:evil.com", false},
+		{"javascript:alert(1)", false},
+		{"mailto:a@example.com", false},
+		{"ftp://example.com/x", false},
+		{"https://user@example.com/x", false},
+		{"https://example.com.evil.com/x", false},
+		{"", false},
+	}
+
+	for _, test := range tests {
+		if got := policy.Allow(test.target); got != test.want {
+			t.Errorf("%q: expected %t, got %t", test.target, test.want, got)
+		}
+	}
+}
+
+func TestRedirectPolicy_Redirect(t *testing.T) {
+	t.Parallel()
+
+	policy := &RedirectPolicy{}
+
+	if res := policy.Redirect("/next", http.StatusSeeOther); res.Location != "/next" || res.Code != http.StatusSeeOther {
+		t.Errorf("expected %q, got %q", "/next", res.Location)
+	}
+	if res := policy.Redirect("https://evil.com", http.StatusSeeOther); res.Location != "/" {
+		t.Errorf("expected %q, got %q", "/", res.Location)
+	}
+
+	policy.Fallback = "/home"
+	if res := policy.Redirect("//evil.com", 0); res.Location != "/home" {
+		t.Errorf("expected %q, got %q", "/home", res.Location)
+	}
+}
@@ -0,0 +1,232 @@
+package beehive_responder
+
+import (
+	"iter"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"go.sdls.io/beehive/pkg/beehive"
+)
+
+// Event is a server-sent event.
+type Event struct {
+	// ID is sent back by the client in Last-Event-ID when it reconnects.
+	ID string
+
+	// Event is the event type, "message" if empty.
+	Event string
+
+	// Data is the event payload, multiline data is sent as multiple data fields.
+	Data string
+
+	// Retry is the reconnection time of the client, sent if positive.
+	Retry time.Duration
+}
+
+// encode writes the event in the text/event-stream format. Line breaks, which would inject fields, are removed from
+// the ID and Event.
+func (e *Event) encode(b *strings.Builder) {
+	if e.ID != "" {
+		b.WriteString("id: " + stripLineBreaks(e.ID) + "\n")
+	}
+	if e.Event != "" {
+		b.WriteString("event: " + stripLineBreaks(e.Event) + "\n")
+	}
+	if e.Retry > 0 {
+		b.WriteString("retry: " + strconv.FormatInt(e.Retry.Milliseconds(), 10) + "\n")
+	}
+
+	data := strings.ReplaceAll(e.Data, "\r\n", "\n")
+	for line := range strings.SplitSeq(strings.ReplaceAll(data, "\r", "\n"), "\n") {
+		b.WriteString("data: " + line + "\n")
+	}
+
+	b.WriteByte('\n')
+}
+
+func stripLineBreaks(s string) string {
+	return strings.NewReplacer("\r", "", "\n", "", "\x00", "").Replace(s)
+}
+
+// DefaultHeartbeat is the interval of the SSE keepalive comments.
+const DefaultHeartbeat = 15 * time.Second
+
+// SSE implements the beehive.Responder interface with a text/event-stream of server-sent events, received from Events
+// or Seq (only one of them is used, Events first), until they are exhausted or the request context is done. Every
+// event is flushed as soon as it is written.
+type SSE struct {
+	// Events are sent until the channel is closed.
+	Events <-chan Event
+
+	// Seq is iterated on a separate goroutine, such that heartbeats are sent while it blocks. It must stop when the
+	// request context is done, otherwise the goroutine is only released on its next element.
+	Seq iter.Seq[Event]
+
+	// Retry is the reconnection time of the client, sent before the first event if positive.
+	Retry time.Duration
+
+	// Heartbeat is the interval of the keepalive comments, preventing proxies from closing idle connections. If zero,
+	// DefaultHeartbeat is used, negative values disable heartbeats.
+	Heartbeat time.Duration
+}
+
+// test that SSE implements the beehive.Responder interface.
+var _ beehive.Responder = &SSE{}
+
+func (s *SSE) StatusCode(_ *beehive.Context) int {
+	return http.StatusOK
+}
+
+func (s *SSE) Respond(ctx *beehive.Context) {
+	w := ctx.ResponseWriter
+	h := w.Header()
+	h.Set("Content-Type", "text/event-stream")
+	h.Set("Cache-Control", "no-cache")
+	h.Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+
+	rc := http.NewResponseController(w)
+
+	write := func(data string) bool {
+		if _, err := w.Write([]byte(data)); err != nil {
+			ctx.ReportError(err)
+			return false
+		}
+		_ = rc.Flush()
+		return true
+	}
+
+	var b strings.Builder
+	if s.Retry > 0 {
+		b.WriteString("retry: " + strconv.FormatInt(s.Retry.Milliseconds(), 10) + "\n\n")
+	}
+	// the headers are flushed right away, such that the client knows the stream is open
+	if !write(b.String()) {
+		return
+	}
+
+	events := s.Events
+	if events == nil && s.Seq != nil {
+		stop := make(chan struct{})
+		defer close(stop)
+		events = pullEvents(s.Seq, stop)
+	}
+
+	var done <-chan struct{}
+	if ctx.Context != nil {
+		done = ctx.Done()
+	}
+
+	var heartbeat <-chan time.Time
+	interval := s.Heartbeat
+	if interval == 0 {
+		interval = DefaultHeartbeat
+	}
+	if interval > 0 {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		heartbeat = ticker.C
+	}
+
+	for {
+		select {
+		case <-done:
+			return
+		case <-heartbeat:
+			if !write(":\n\n") {
+				return
+			}
+		case e, ok := <-events:
+			if !ok {
+				return
+			}
+
+			b.Reset()
+			e.encode(&b)
+			if !write(b.String()) {
+				return
+			}
+		}
+	}
+}
+
+// pullEvents iterates seq on a new goroutine, until it is exhausted or stop is closed.
+func pullEvents(seq iter.Seq[Event], stop <-chan struct{}) <-chan Event {
+	events := make(chan Event)
+
+	go func() {
+		defer close(events)
+
+		for e := range seq {
+			select {
+			case events <- e:
+			case <-stop:
+				return
+			}
+		}
+	}()
+
+	return events
+}
+
+// LastEventID returns the Last-Event-ID sent by a reconnecting client, the ID of the last event it received.
+func LastEventID(ctx *beehive.Context) string {
+	return ctx.Request.Header.Get("Last-Event-ID")
+}
+
+// EventBuffer keeps the last Size events, such that reconnecting clients can be sent the events they missed since
+// their LastEventID. Events added without ID are given a sequential one. It is safe for concurrent use.
+type EventBuffer struct {
+	// Size is the number of events kept. If zero, 100 is used.
+	Size int
+
+	mu     sync.Mutex
+	events []Event
+	next   uint64
+}
+
+// Add appends the event to the buffer, evicting the oldest one when full, and returns it with its ID.
+func (eb *EventBuffer) Add(e Event) Event {
+	eb.mu.Lock()
+	defer eb.mu.Unlock()
+
+	eb.next++
+	if e.ID == "" {
+		e.ID = strconv.FormatUint(eb.next, 10)
+	}
+
+	size := eb.Size
+	if size <= 0 {
+		size = 100
+	}
+
+	if len(eb.events) >= size {
+		eb.events = append(eb.events[:0], eb.events[len(eb.events)-size+1:]...)
+	}
+	eb.events = append(eb.events, e)
+
+	return e
+}
+
+// Since returns the events added after the one with the given ID. It returns false when the ID is not (or no longer)
+// in the buffer, in which case the client may have missed events and all the buffered events are returned. An empty
+// ID returns no event, as the client is not resuming.
+func (eb *EventBuffer) Since(id string) ([]Event, bool) {
+	if id == "" {
+		return nil, true
+	}
+
+	eb.mu.Lock()
+	defer eb.mu.Unlock()
+
+	for idx := len(eb.events) - 1; idx >= 0; idx-- {
+		if eb.events[idx].ID == id {
+			return append([]Event(nil), eb.events[idx+1:]...), true
+		}
+	}
+
+	return append([]Event(nil), eb.events...), false
+}
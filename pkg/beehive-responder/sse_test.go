@@ -0,0 +1,195 @@
+package beehive_responder
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"go.sdls.io/beehive/pkg/beehive"
+)
+
+func TestSSE(t *testing.T) {
+	t.Parallel()
+
+	events := make(chan Event, 3)
+	events <- Event{ID: "1", Event: "update", Data: "hello"}
+	events <- Event{Data: "multi\nline\r\ndata", Retry: 2 * time.Second}
+	events <- Event{ID: "2\nevent: injected", Event: "x\r\ny"}
+	close(events)
+
+	router := beehive.NewRouter()
+	router.Handle("GET", "/events", func(_ *beehive.Context) beehive.Responder {
+		return &SSE{Events: events, Retry: time.Second}
+	})
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", "/events", nil))
+
+	want := "retry: 1000\n\n" +
+		"id: 1\nevent: update\ndata: hello\n\n" +
+		"retry: 2000\ndata: multi\ndata: line\ndata: data\n\n" +
+		"id: 2event: injected\nevent: xy\ndata: \n\n"
+	if w.Body.String() != want {
+		t.Errorf("expected %q, got %q", want, w.Body.String())
+	}
+
+	for key, value := range map[string]string{"Content-Type": "text/event-stream", "Cache-Control": "no-cache"} {
+		if got := w.Header().Get(key); got != value {
+			t.Errorf("expected %s %q, got %q", key, value, got)
+		}
+	}
+	if !w.Flushed {
+		t.Errorf("expected the response to be flushed")
+	}
+}
+
+func TestSSE_Seq(t *testing.T) {
+	t.Parallel()
+
+	router := beehive.NewRouter()
+	router.Handle("GET", "/events", func(_ *beehive.Context) beehive.Responder {
+		return &SSE{Seq: func(yield func(Event) bool) {
+			for _, data := range []string{"a", "b"} {
+				if !yield(Event{Data: data}) {
+					return
+				}
+			}
+		}}
+	})
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", "/events", nil))
+
+	want := "data: a\n\ndata: b\n\n"
+	if w.Body.String() != want {
+		t.Errorf("expected %q, got %q", want, w.Body.String())
+	}
+}
+
+func TestSSE_heartbeat(t *testing.T) {
+	t.Parallel()
+
+	events := make(chan Event)
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		close(events)
+	}()
+
+	router := beehive.NewRouter()
+	router.Handle("GET", "/events", func(_ *beehive.Context) beehive.Responder {
+		return &SSE{Events: events, Heartbeat: 5 * time.Millisecond}
+	})
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", "/events", nil))
+
+	if !strings.HasPrefix(w.Body.String(), ":\n\n") {
+		t.Errorf("expected heartbeat comments, got %q", w.Body.String())
+	}
+}
+
+func TestSSE_done(t *testing.T) {
+	t.Parallel()
+
+	requestCtx, cancel := context.WithCancel(context.Background())
+
+	blocked := make(chan struct{})
+	router := beehive.NewRouter()
+	router.Handle("GET", "/events", func(_ *beehive.Context) beehive.Responder {
+		return &SSE{Heartbeat: -1, Seq: func(yield func(Event) bool) {
+			yield(Event{Data: "first"})
+			cancel()
+			<-blocked
+		}}
+	})
+
+	finished := make(chan struct{})
+	w := httptest.NewRecorder()
+	go func() {
+		defer close(finished)
+		router.ServeHTTP(w, httptest.NewRequest("GET", "/events", nil).WithContext(requestCtx))
+	}()
+
+	select {
+	case <-finished:
+	case <-time.After(time.Second):
+		t.Fatalf("expected the stream to stop when the request context is done")
+	}
+	close(blocked)
+
+	if w.Body.String() != "data: first\n\n" {
+		t.Errorf("expected %q, got %q", "data: first\n\n", w.Body.String())
+	}
+}
+
+func TestLastEventID(t *testing.T) {
+	t.Parallel()
+
+	buffer := &EventBuffer{Size: 3}
+	for _, data := range []string{"a", "b", "c", "d"} {
+		buffer.Add(Event{Data: data})
+	}
+
+	router := beehive.NewRouter()
+	router.Handle("GET", "/events", func(ctx *beehive.Context) beehive.Responder {
+		missed, _ := buffer.Since(LastEventID(ctx))
+
+		events := make(chan Event, len(missed))
+		for _, e := range missed {
+			events <- e
+		}
+		close(events)
+
+		return &SSE{Events: events}
+	})
+
+	r := httptest.NewRequest("GET", "/events", nil)
+	r.Header.Set("Last-Event-ID", "3")
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, r)
+
+	if w.Code != http.StatusOK || w.Body.String() != "id: 4\ndata: d\n\n" {
+		t.Errorf("unexpected response %d %q", w.Code, w.Body.String())
+	}
+}
+
+func TestEventBuffer(t *testing.T) {
+	t.Parallel()
+
+	buffer := &EventBuffer{Size: 2}
+	if e := buffer.Add(Event{Data: "a"}); e.ID != "1" {
+		t.Errorf("expected %q, got %q", "1", e.ID)
+	}
+	buffer.Add(Event{ID: "custom", Data: "b"})
+	buffer.Add(Event{Data: "c"})
+
+	tests := []struct {
+		id   string
+		data []string
+		ok   bool
+	}{
+		{"", nil, true},
+		{"custom", []string{"c"}, true},
+		{"3", nil, true},
+		{"1", []string{"b", "c"}, false},
+	}
+
+	for _, test := range tests {
+		events, ok := buffer.Since(test.id)
+		if ok != test.ok {
+			t.Errorf("%q: expected %t, got %t", test.id, test.ok, ok)
+		}
+
+		var data []string
+		for _, e := range events {
+			data = append(data, e.Data)
+		}
+		if strings.Join(data, ",") != strings.Join(test.data, ",") {
+			t.Errorf("%q: expected %v, got %v", test.id, test.data, data)
+		}
+	}
+}
@@ -0,0 +1,121 @@
+package beehive_responder
+
+import (
+	"bytes"
+	"encoding/json"
+	"iter"
+	"net/http"
+
+	"go.sdls.io/beehive/pkg/beehive"
+)
+
+// JSONEncoder implements the beehive.Responder interface by encoding Object with a json.Encoder when responding,
+// before the response is started. Encoding errors are reported with beehive.Context.ReportError and answered with a
+// 500 Internal Server Error, write errors are only reported.
+type JSONEncoder struct {
+	Object any
+	Code   int
+}
+
+// test that JSONEncoder implements the beehive.Responder interface.
+var _ beehive.Responder = &JSONEncoder{}
+
+func (j *JSONEncoder) StatusCode(_ *beehive.Context) int {
+	return j.Code
+}
+
+func (j *JSONEncoder) Respond(ctx *beehive.Context) {
+	w := ctx.ResponseWriter
+
+	var buf bytes.Buffer
+	if err := json.NewEncoder(&buf).Encode(j.Object); err != nil {
+		ctx.ReportError(err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(j.Code)
+
+	if _, err := w.Write(buf.Bytes()); err != nil {
+		ctx.ReportError(err)
+	}
+}
+
+// JSONStream implements the beehive.Responder interface by encoding the elements of Seq one by one as they are
+// produced, either as a JSON array or, with Lines, as JSON Lines (application/x-ndjson). The response is started
+// before the first element, so encoding errors can only be reported with beehive.Context.ReportError, and the
+// iteration stops early when the request context is done.
+type JSONStream[T any] struct {
+	Seq  iter.Seq[T]
+	Code int
+
+	// Lines writes one JSON value per line instead of a JSON array.
+	Lines bool
+
+	// Flush flushes the response after every element, such that the client receives them as soon as they are
+	// produced.
+	Flush bool
+}
+
+// test that JSONStream implements the beehive.Responder interface.
+var _ beehive.Responder = &JSONStream[any]{}
+
+func (j *JSONStream[T]) StatusCode(_ *beehive.Context) int {
+	return j.Code
+}
+
+func (j *JSONStream[T]) Respond(ctx *beehive.Context) {
+	w := ctx.ResponseWriter
+	if j.Lines {
+		w.Header().Set("Content-Type", "application/x-ndjson")
+	} else {
+		w.Header().Set("Content-Type", "application/json")
+	}
+	w.WriteHeader(j.Code)
+
+	var rc *http.ResponseController
+	if j.Flush {
+		rc = http.NewResponseController(w)
+	}
+
+	enc := json.NewEncoder(w)
+	first := true
+
+	if !j.Lines {
+		if _, err := w.Write([]byte{'['}); err != nil {
+			ctx.ReportError(err)
+			return
+		}
+	}
+
+	for v := range j.Seq {
+		if ctx.Context != nil && ctx.Err() != nil {
+			return
+		}
+
+		if !j.Lines && !first {
+			if _, err := w.Write([]byte{','}); err != nil {
+				ctx.ReportError(err)
+				return
+			}
+		}
+		first = false
+
+		// the encoder only writes complete values, the newline it appends is valid whitespace in an array
+		if err := enc.Encode(v); err != nil {
+			ctx.ReportError(err)
+			return
+		}
+
+		if rc != nil {
+			_ = rc.Flush()
+		}
+	}
+
+	if !j.Lines {
+		if _, err := w.Write([]byte("]\n")); err != nil {
+			ctx.ReportError(err)
+		}
+	}
+}
@@ -0,0 +1,158 @@
+package beehive_responder
+
+import (
+	"context"
+	"errors"
+	"iter"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"go.sdls.io/beehive/pkg/beehive"
+)
+
+func testSeq(values ...any) iter.Seq[any] {
+	return func(yield func(any) bool) {
+		for _, v := range values {
+			if !yield(v) {
+				return
+			}
+		}
+	}
+}
+
+func TestJSONEncoder(t *testing.T) {
+	t.Parallel()
+
+	var reported []error
+
+	router := beehive.NewRouter()
+	router.WhenError = func(_ *beehive.Context, err error) {
+		reported = append(reported, err)
+	}
+	router.Handle("GET", "/ok", func(_ *beehive.Context) beehive.Responder {
+		return &JSONEncoder{Object: map[string]int{"a": 1}, Code: http.StatusCreated}
+	})
+	router.Handle("GET", "/fail", func(_ *beehive.Context) beehive.Responder {
+		return &JSONEncoder{Object: func() {}, Code: http.StatusOK}
+	})
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", "/ok", nil))
+	if w.Code != http.StatusCreated || w.Body.String() != "{\"a\":1}\n" || w.Header().Get("Content-Type") != "application/json" {
+		t.Errorf("unexpected response %d %q %s", w.Code, w.Body.String(), w.Header().Get("Content-Type"))
+	}
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", "/fail", nil))
+	if len(reported) != 1 || w.Body.Len() != 0 {
+		t.Errorf("expected encoding error to be reported, got %v and body %q", reported, w.Body.String())
+	}
+	if w.Code != http.StatusInternalServerError {
+		t.Errorf("expected %d, got %d", http.StatusInternalServerError, w.Code)
+	}
+}
+
+func TestJSONStream(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name        string
+		responder   beehive.Responder
+		contentType string
+		body        string
+		errors      int
+	}{
+		{
+			name:        "array",
+			responder:   &JSONStream[any]{Seq: testSeq(1, "two", map[string]bool{"three": true}), Code: http.StatusOK},
+			contentType: "application/json",
+			body:        "[1\n,\"two\"\n,{\"three\":true}\n]\n",
+		},
+		{
+			name:        "empty array",
+			responder:   &JSONStream[any]{Seq: testSeq(), Code: http.StatusOK},
+			contentType: "application/json",
+			body:        "[]\n",
+		},
+		{
+			name:        "lines",
+			responder:   &JSONStream[any]{Seq: testSeq(1, "two"), Code: http.StatusOK, Lines: true, Flush: true},
+			contentType: "application/x-ndjson",
+			body:        "1\n\"two\"\n",
+		},
+		{
+			name:        "encoding error",
+			responder:   &JSONStream[any]{Seq: testSeq(1, make(chan int), 3), Code: http.StatusOK},
+			contentType: "application/json",
+			body:        "[1\n,",
+			errors:      1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			var reported []error
+
+			router := beehive.NewRouter()
+			router.WhenError = func(_ *beehive.Context, err error) {
+				reported = append(reported, err)
+			}
+			router.Handle("GET", "/", func(_ *beehive.Context) beehive.Responder {
+				return tt.responder
+			})
+
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, httptest.NewRequest("GET", "/", nil))
+
+			if w.Header().Get("Content-Type") != tt.contentType {
+				t.Errorf("expected Content-Type %s, got %s", tt.contentType, w.Header().Get("Content-Type"))
+			}
+			if w.Body.String() != tt.body {
+				t.Errorf("expected body %q, got %q", tt.body, w.Body.String())
+			}
+			if len(reported) != tt.errors {
+				t.Errorf("expected %d reported errors, got %v", tt.errors, reported)
+			}
+		})
+	}
+}
+
+func TestJSONStream_canceled(t *testing.T) {
+	t.Parallel()
+
+	ctx, cancel := context.WithCancel(t.Context())
+
+	produced := 0
+	seq := func(yield func(int) bool) {
+		for i := range 100 {
+			produced++
+			if i == 1 {
+				cancel()
+			}
+			if !yield(i) {
+				return
+			}
+		}
+	}
+
+	router := beehive.NewRouter()
+	router.WhenError = func(_ *beehive.Context, err error) {
+		t.Errorf("unexpected error %v", err)
+	}
+	router.Handle("GET", "/", func(_ *beehive.Context) beehive.Responder {
+		return &JSONStream[int]{Seq: seq, Code: http.StatusOK}
+	})
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", "/", nil).WithContext(ctx))
+
+	if produced != 2 || w.Body.String() != "[0\n" {
+		t.Errorf("expected the stream to stop, got %d produced and body %q", produced, w.Body.String())
+	}
+	if !errors.Is(ctx.Err(), context.Canceled) {
+		t.Errorf("expected context to be canceled")
+	}
+}
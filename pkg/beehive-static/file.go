@@ -0,0 +1,295 @@
+package beehive_static
+
+import (
+	"io"
+	"io/fs"
+	"mime/multipart"
+	"net/http"
+	"net/textproto"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.sdls.io/beehive/pkg/beehive"
+)
+
+// fileResponder writes a file, or ranges of it, as decided by evaluate from the request preconditions.
+type fileResponder struct {
+	file        fs.File
+	header      http.Header
+	contentType string
+	size        int64
+	head        bool
+
+	status int
+	ranges []httpRange
+}
+
+// test that fileResponder implements beehive.Responder.
+var _ beehive.Responder = &fileResponder{}
+
+func (f *fileResponder) StatusCode(_ *beehive.Context) int {
+	return f.status
+}
+
+func (f *fileResponder) Respond(ctx *beehive.Context) {
+	h := ctx.ResponseWriter.Header()
+	for key, values := range f.header {
+		h[key] = values
+	}
+
+	switch f.status {
+	case http.StatusNotModified:
+		// a 304 only carries the validators and the caching headers
+		delete(h, "Content-Encoding")
+		ctx.ResponseWriter.WriteHeader(f.status)
+		return
+	case http.StatusPreconditionFailed:
+		ctx.ResponseWriter.WriteHeader(f.status)
+		return
+	case http.StatusRequestedRangeNotSatisfiable:
+		h.Set("Content-Range", "bytes */"+strconv.FormatInt(f.size, 10))
+		ctx.ResponseWriter.WriteHeader(f.status)
+		return
+	}
+
+	h.Set("Accept-Ranges", "bytes")
+
+	if f.status == http.StatusPartialContent && len(f.ranges) > 1 {
+		f.respondMultipart(ctx)
+		return
+	}
+
+	start, length := int64(0), f.size
+	if f.status == http.StatusPartialContent {
+		start, length = f.ranges[0].start, f.ranges[0].length
+		h.Set("Content-Range", f.ranges[0].contentRange(f.size))
+	}
+
+	h.Set("Content-Type", f.contentType)
+	h.Set("Content-Length", strconv.FormatInt(length, 10))
+	ctx.ResponseWriter.WriteHeader(f.status)
+
+	if f.head {
+		return
+	}
+
+	if start > 0 {
+		if _, err := f.file.(io.Seeker).Seek(start, io.SeekStart); err != nil {
+			ctx.ReportError(err)
+			return
+		}
+	}
+
+	if _, err := io.CopyN(ctx.ResponseWriter, f.file, length); err != nil {
+		ctx.ReportError(err)
+	}
+}
+
+func (f *fileResponder) respondMultipart(ctx *beehive.Context) {
+	w := multipart.NewWriter(ctx.ResponseWriter)
+
+	h := ctx.ResponseWriter.Header()
+	h.Set("Content-Type", "multipart/byteranges; boundary="+w.Boundary())
+	ctx.ResponseWriter.WriteHeader(f.status)
+
+	if f.head {
+		return
+	}
+
+	seeker := f.file.(io.Seeker)
+	for _, ra := range f.ranges {
+		part, err := w.CreatePart(textproto.MIMEHeader{
+			"Content-Type":  {f.contentType},
+			"Content-Range": {ra.contentRange(f.size)},
+		})
+		if err != nil {
+			ctx.ReportError(err)
+			return
+		}
+
+		if _, err := seeker.Seek(ra.start, io.SeekStart); err != nil {
+			ctx.ReportError(err)
+			return
+		}
+
+		if _, err := io.CopyN(part, f.file, ra.length); err != nil {
+			ctx.ReportError(err)
+			return
+		}
+	}
+
+	if err := w.Close(); err != nil {
+		ctx.ReportError(err)
+	}
+}
+
+// evaluate sets the response status from the conditional and range request headers (RFC 9110 section 13.2.2).
+func (f *fileResponder) evaluate(r *http.Request, etag string, modTime time.Time) {
+	f.status = http.StatusOK
+
+	modTime = modTime.Truncate(time.Second)
+
+	if im := r.Header.Get("If-Match"); im != "" {
+		if !matchETag(im, etag, false) {
+			f.status = http.StatusPreconditionFailed
+			return
+		}
+	} else if ius := r.Header.Get("If-Unmodified-Since"); ius != "" && !modTime.IsZero() {
+		if t, err := http.ParseTime(ius); err == nil && modTime.After(t) {
+			f.status = http.StatusPreconditionFailed
+			return
+		}
+	}
+
+	if inm := r.Header.Get("If-None-Match"); inm != "" {
+		if matchETag(inm, etag, true) {
+			f.status = http.StatusNotModified
+			return
+		}
+	} else if ims := r.Header.Get("If-Modified-Since"); ims != "" && !modTime.IsZero() {
+		if t, err := http.ParseTime(ims); err == nil && !modTime.After(t) {
+			f.status = http.StatusNotModified
+			return
+		}
+	}
+
+	rangeHeader := r.Header.Get("Range")
+	if rangeHeader == "" {
+		return
+	}
+
+	// ranges need a seekable file, otherwise the whole file is sent
+	if _, ok := f.file.(io.Seeker); !ok {
+		return
+	}
+
+	if ir := r.Header.Get("If-Range"); ir != "" && !matchIfRange(ir, etag, modTime) {
+		return
+	}
+
+	ranges, ok := parseRange(rangeHeader, f.size)
+	if !ok {
+		return
+	}
+	if len(ranges) == 0 {
+		f.status = http.StatusRequestedRangeNotSatisfiable
+		return
+	}
+
+	f.status = http.StatusPartialContent
+	f.ranges = ranges
+}
+
+// matchETag reports whether the If-Match or If-None-Match list matches the ETag, using the weak comparison for
+// If-None-Match and the strong comparison for If-Match.
+func matchETag(list, etag string, weak bool) bool {
+	for candidate := range strings.SplitSeq(list, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" {
+			return true
+		}
+
+		if weak {
+			candidate = strings.TrimPrefix(candidate, "W/")
+		} else if strings.HasPrefix(candidate, "W/") {
+			continue
+		}
+
+		if candidate == etag {
+			return true
+		}
+	}
+
+	return false
+}
+
+// matchIfRange reports whether the If-Range validator, an ETag or an HTTP date, matches the representation.
+func matchIfRange(ir, etag string, modTime time.Time) bool {
+	if strings.HasPrefix(ir, `"`) {
+		return ir == etag
+	}
+
+	if modTime.IsZero() {
+		return false
+	}
+
+	t, err := http.ParseTime(ir)
+	return err == nil && t.Equal(modTime)
+}
+
+type httpRange struct {
+	start, length int64
+}
+
+func (r httpRange) contentRange(size int64) string {
+	return "bytes " + strconv.FormatInt(r.start, 10) + "-" + strconv.FormatInt(r.start+r.length-1, 10) + "/" +
+		strconv.FormatInt(size, 10)
+}
+
+// parseRange parses a Range header for a file of the given size. It returns false when the header is invalid or
+// should be ignored, in which case the whole file is sent, and no ranges when none of them is satisfiable.
+func parseRange(header string, size int64) ([]httpRange, bool) {
+	spec, ok := strings.CutPrefix(header, "bytes=")
+	if !ok {
+		return nil, false
+	}
+
+	var ranges []httpRange
+	var total int64
+	for part := range strings.SplitSeq(spec, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+
+		first, last, ok := strings.Cut(part, "-")
+		if !ok {
+			return nil, false
+		}
+		first, last = strings.TrimSpace(first), strings.TrimSpace(last)
+
+		var ra httpRange
+		if first == "" {
+			// suffix range, the last n bytes
+			n, err := strconv.ParseInt(last, 10, 64)
+			if err != nil || n < 0 {
+				return nil, false
+			}
+			if n == 0 || size == 0 {
+				continue
+			}
+			n = min(n, size)
+			ra = httpRange{start: size - n, length: n}
+		} else {
+			start, err := strconv.ParseInt(first, 10, 64)
+			if err != nil || start < 0 {
+				return nil, false
+			}
+
+			end := size - 1
+			if last != "" {
+				end, err = strconv.ParseInt(last, 10, 64)
+				if err != nil || end < start {
+					return nil, false
+				}
+				end = min(end, size-1)
+			}
+
+			if start >= size {
+				continue
+			}
+			ra = httpRange{start: start, length: end - start + 1}
+		}
+
+		ranges = append(ranges, ra)
+		total += ra.length
+	}
+
+	// like net/http, ranges adding up to more than the file are ignored, as they are likely an attack
+	if total > size {
+		return nil, false
+	}
+
+	return ranges, true
+}
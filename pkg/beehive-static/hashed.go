@@ -0,0 +1,49 @@
+package beehive_static
+
+import (
+	"path"
+	"strings"
+)
+
+// IsHashed reports whether the base name of the file contains a content hash, as produced by bundlers:
+// "main.3f2a9c1b.js" (webpack) or "index-B7x2kq9A.js" (vite). The hash is a dot or dash separated part of the name,
+// other than the first one, of at least 8 letters, digits or underscores, with at least one digit. Hashes without any
+// digit are missed, for the safety of not caching non-hashed files forever.
+func IsHashed(name string) bool {
+	base := path.Base(name)
+
+	// the first part is the name stem, the last one the extension
+	parts := strings.FieldsFunc(base, func(r rune) bool {
+		return r == '.' || r == '-'
+	})
+	if len(parts) < 3 {
+		return false
+	}
+
+	for _, part := range parts[1 : len(parts)-1] {
+		if isHash(part) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func isHash(s string) bool {
+	if len(s) < 8 {
+		return false
+	}
+
+	digit := false
+	for _, r := range s {
+		switch {
+		case r >= '0' && r <= '9':
+			digit = true
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r == '_':
+		default:
+			return false
+		}
+	}
+
+	return digit
+}
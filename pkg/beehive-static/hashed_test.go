@@ -0,0 +1,32 @@
+package beehive_static
+
+import (
+	"testing"
+)
+
+func TestIsHashed(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name string
+		want bool
+	}{
+		{"main.3f2a9c1b.js", true},
+		{"assets/index-B7x2kq9A.js", true},
+		{"assets/vendor-a1b2c3d4_e.css", true},
+		{"main.3f2a9c1b.js.map", true},
+		{"main.js", false},
+		{"index.html", false},
+		{"assets/index-DiwrgTda.js", false},
+		{"jquery-3.7.1.min.js", false},
+		{"3f2a9c1b.js", false},
+		{"my-component.js", false},
+		{"logo.abc123.svg", false},
+	}
+
+	for _, test := range tests {
+		if got := IsHashed(test.name); got != test.want {
+			t.Errorf("%s: expected %t, got %t", test.name, test.want, got)
+		}
+	}
+}
@@ -0,0 +1,64 @@
+package beehive_static
+
+import (
+	"html"
+	"io/fs"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"go.sdls.io/beehive/pkg/beehive"
+)
+
+// listingResponder writes an HTML listing of a directory.
+type listingResponder struct {
+	entries []fs.DirEntry
+}
+
+// test that listingResponder implements beehive.Responder.
+var _ beehive.Responder = &listingResponder{}
+
+func (c *Config) listing(name string, notFound beehive.Responder) beehive.Responder {
+	entries, err := fs.ReadDir(c.FS, name)
+	if err != nil {
+		return notFound
+	}
+
+	if !c.Hidden {
+		visible := entries[:0]
+		for _, entry := range entries {
+			if !strings.HasPrefix(entry.Name(), ".") {
+				visible = append(visible, entry)
+			}
+		}
+		entries = visible
+	}
+
+	return &listingResponder{entries: entries}
+}
+
+func (l *listingResponder) StatusCode(_ *beehive.Context) int {
+	return http.StatusOK
+}
+
+func (l *listingResponder) Respond(ctx *beehive.Context) {
+	var b strings.Builder
+	b.WriteString("<!doctype html>\n<meta name=\"viewport\" content=\"width=device-width\">\n<pre>\n")
+	for _, entry := range l.entries {
+		name := entry.Name()
+		if entry.IsDir() {
+			name += "/"
+		}
+
+		// entries are linked relatively, the directory path always ends with a slash
+		link := url.URL{Path: name}
+		b.WriteString("<a href=\"" + html.EscapeString(link.String()) + "\">" + html.EscapeString(name) + "</a>\n")
+	}
+	b.WriteString("</pre>\n")
+
+	ctx.ResponseWriter.Header().Set("Content-Type", "text/html; charset=utf-8")
+	ctx.ResponseWriter.WriteHeader(http.StatusOK)
+	if ctx.Request.Method != http.MethodHead {
+		_, _ = ctx.ResponseWriter.Write([]byte(b.String()))
+	}
+}
@@ -0,0 +1,111 @@
+package beehive_static
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"go.sdls.io/beehive/pkg/beehive"
+)
+
+func newTestSPARouter() *beehive.Router {
+	fsys := fstest.MapFS{
+		"index.html":               {Data: []byte("app"), ModTime: testModTime},
+		"favicon.ico":              {Data: []byte("icon"), ModTime: testModTime},
+		"assets/index-B7x2kq9A.js": {Data: []byte("js"), ModTime: testModTime},
+		"docs/index.html":          {Data: []byte("docs"), ModTime: testModTime},
+	}
+
+	router := beehive.NewRouter()
+	router.Handle("GET", "/api/users", func(_ *beehive.Context) beehive.Responder {
+		return &beehive.DefaultResponder{Message: "users", Status: http.StatusOK}
+	})
+
+	SPA(fsys, "/api/").Mount(router, "/")
+
+	return router
+}
+
+func TestSPA(t *testing.T) {
+	t.Parallel()
+
+	router := newTestSPARouter()
+
+	tests := []struct {
+		method       string
+		path         string
+		code         int
+		body         string
+		cacheControl string
+	}{
+		{"GET", "/", http.StatusOK, "app", "no-cache"},
+		{"GET", "/index.html", http.StatusOK, "app", "no-cache"},
+		{"GET", "/users/42", http.StatusOK, "app", "no-cache"},
+		{"HEAD", "/settings/profile", http.StatusOK, "", "no-cache"},
+		{"GET", "/docs/", http.StatusOK, "docs", "no-cache"},
+		{"GET", "/assets/index-B7x2kq9A.js", http.StatusOK, "js", "public, max-age=31536000, immutable"},
+		{"GET", "/favicon.ico", http.StatusOK, "icon", ""},
+		{"GET", "/assets/missing-B7x2kq9A.js", http.StatusNotFound, "not found", ""},
+		{"GET", "/api/users", http.StatusOK, "users", ""},
+		{"GET", "/api/unknown", http.StatusNotFound, "not found", ""},
+		{"GET", "/.env", http.StatusNotFound, "not found", ""},
+	}
+
+	for _, test := range tests {
+		w := serve(router, test.method, test.path, nil)
+
+		if w.Code != test.code {
+			t.Errorf("%s: expected %d, got %d", test.path, test.code, w.Code)
+		}
+		if body := w.Body.String(); body != test.body {
+			t.Errorf("%s: expected %q, got %q", test.path, test.body, body)
+		}
+		if cacheControl := w.Header().Get("Cache-Control"); cacheControl != test.cacheControl {
+			t.Errorf("%s: expected %q, got %q", test.path, test.cacheControl, cacheControl)
+		}
+	}
+}
+
+func TestSPA_conditional(t *testing.T) {
+	t.Parallel()
+
+	router := newTestSPARouter()
+
+	etag := serve(router, "GET", "/users/42", nil).Header().Get("ETag")
+
+	w := serve(router, "GET", "/settings", map[string]string{"If-None-Match": etag})
+	if w.Code != http.StatusNotModified {
+		t.Errorf("expected %d, got %d", http.StatusNotModified, w.Code)
+	}
+}
+
+func TestSPA_cacheControl(t *testing.T) {
+	t.Parallel()
+
+	config := SPA(fstest.MapFS{
+		"index.html":        {Data: []byte("app"), ModTime: testModTime},
+		"main.3f2a9c1b.css": {Data: []byte("css"), ModTime: testModTime},
+		"app.v2.js":         {Data: []byte("js"), ModTime: testModTime},
+	})
+	config.CacheControl = []CacheRule{{Pattern: "*.css", Value: "public, max-age=60"}}
+	config.Hashed = func(name string) bool {
+		return strings.Contains(name, ".v")
+	}
+
+	router := beehive.NewRouter()
+	config.Mount(router, "/")
+
+	tests := []struct {
+		path, cacheControl string
+	}{
+		{"/main.3f2a9c1b.css", "public, max-age=60"},
+		{"/app.v2.js", "public, max-age=31536000, immutable"},
+	}
+
+	for _, test := range tests {
+		if cacheControl := serve(router, "GET", test.path, nil).Header().Get("Cache-Control"); cacheControl != test.cacheControl {
+			t.Errorf("%s: expected %q, got %q", test.path, test.cacheControl, cacheControl)
+		}
+	}
+}
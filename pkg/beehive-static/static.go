@@ -0,0 +1,436 @@
+package beehive_static
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"io/fs"
+	"mime"
+	"net/http"
+	"path"
+	"strconv"
+	"strings"
+	"sync"
+
+	"go.sdls.io/beehive/pkg/beehive"
+)
+
+// CacheRule sets the Cache-Control header of the files matching Pattern, see Config.CacheControl.
+type CacheRule struct {
+	// Pattern is a path.Match pattern, matched against the file name relative to the FS root and against its base
+	// name, such that "*.css" matches CSS files in any directory.
+	Pattern string
+
+	// Value is the Cache-Control header value.
+	Value string
+}
+
+// Config describes a static file handler, serving files from FS under a wildcard route. The part of the request path
+// matched by the wildcard is the file name, for example with Handle("GET", "/assets/*", c.HandlerFunc()) a request
+// to /assets/css/main.css serves css/main.css. Routes without wildcard serve the request path itself.
+type Config struct {
+	// FS is the file system to serve, for example an embed.FS or os.DirFS. It is required.
+	FS fs.FS
+
+	// Index is the file served for directories. If empty, "index.html" is used.
+	Index string
+
+	// Listing serves an HTML listing of directories without Index, instead of NotFound.
+	Listing bool
+
+	// Hidden serves files and directories whose name starts with a dot, such as .env or .git, which are not found
+	// otherwise.
+	Hidden bool
+
+	// Precompressed serves the precompressed .br or .gz sibling of a file, when it exists and the client accepts the
+	// encoding.
+	Precompressed bool
+
+	// CacheControl are the rules setting the Cache-Control header, the first matching rule is used.
+	CacheControl []CacheRule
+
+	// NotFound is returned for files that do not exist. If nil, a 404 Not Found is returned.
+	NotFound beehive.Responder
+
+	// Fallback is the file served, with a 200 OK, for request paths that do not exist, such that a single-page app
+	// handles its own routes. Paths with an extension (missing assets) and paths starting with one of FallbackExclude
+	// are not found instead.
+	Fallback string
+
+	// FallbackExclude are the request path prefixes that never fall back, such as "/api/".
+	FallbackExclude []string
+
+	// Immutable sets Cache-Control "public, max-age=31536000, immutable" for hashed file names, which never change,
+	// and "no-cache" for the Index and Fallback files referencing them, such that they are revalidated. CacheControl
+	// rules take precedence.
+	Immutable bool
+
+	// Hashed reports whether a file name contains a content hash, for Immutable. If nil, IsHashed is used.
+	Hashed func(name string) bool
+
+	// hashes caches the content based ETags of files without modification time (such as embed.FS files), which are
+	// expected to never change.
+	hashes sync.Map
+}
+
+// SPA returns a Config serving a single-page app from fsys, falling back to its index.html for unknown paths except
+// the excluded prefixes, with immutable caching of hashed assets.
+func SPA(fsys fs.FS, exclude ...string) *Config {
+	return &Config{
+		FS:              fsys,
+		Fallback:        "index.html",
+		FallbackExclude: exclude,
+		Immutable:       true,
+	}
+}
+
+var defaultNotFoundResponder = &beehive.DefaultResponder{
+	Message: "not found",
+	Status:  http.StatusNotFound,
+}
+
+// encodings are the supported precompressed encodings, in order of preference.
+var encodings = []struct {
+	name, ext string
+}{
+	{"br", ".br"},
+	{"gzip", ".gz"},
+}
+
+// HandlerFunc returns the static file beehive.HandlerFunc, to be registered for GET and HEAD. The returned Responder
+// reports the actual status code (200, 206, 304, 412 or 416) before the response is written.
+func (c *Config) HandlerFunc() beehive.HandlerFunc {
+	if c.FS == nil {
+		panic("beehive-static: config has no file system")
+	}
+
+	index := c.index()
+
+	notFound := c.NotFound
+	if notFound == nil {
+		notFound = defaultNotFoundResponder
+	}
+
+	return func(ctx *beehive.Context) beehive.Responder {
+		urlPath := ctx.Request.URL.Path
+
+		name, ok := c.name(ctx.Route(), urlPath)
+		if !ok {
+			return notFound
+		}
+
+		info, err := fs.Stat(c.FS, name)
+		if err != nil {
+			return c.fallback(ctx, urlPath, notFound)
+		}
+
+		if info.IsDir() {
+			if !strings.HasSuffix(urlPath, "/") && name != "." {
+				return &redirect{location: path.Base(urlPath) + "/"}
+			}
+
+			indexName := path.Join(name, index)
+			if indexInfo, err := fs.Stat(c.FS, indexName); err == nil && !indexInfo.IsDir() {
+				name, info = indexName, indexInfo
+			} else if c.Listing {
+				return c.listing(name, notFound)
+			} else {
+				return c.fallback(ctx, urlPath, notFound)
+			}
+		}
+
+		res, err := c.serveFile(ctx, name, info)
+		if err != nil {
+			return notFound
+		}
+
+		return res
+	}
+}
+
+// Mount registers the HandlerFunc for GET and HEAD on the wildcard route prefix + "*" of the group, the prefix
+// ending with a slash.
+func (c *Config) Mount(group beehive.Grouper, prefix string) beehive.Grouper {
+	return group.HandleAny([]string{http.MethodGet, http.MethodHead}, prefix+"*", c.HandlerFunc())
+}
+
+func (c *Config) index() string {
+	if c.Index == "" {
+		return "index.html"
+	}
+	return c.Index
+}
+
+// fallback serves the Fallback file for an unknown path, or returns notFound.
+func (c *Config) fallback(ctx *beehive.Context, urlPath string, notFound beehive.Responder) beehive.Responder {
+	if c.Fallback == "" || path.Ext(urlPath) != "" {
+		return notFound
+	}
+
+	for _, prefix := range c.FallbackExclude {
+		if strings.HasPrefix(urlPath, prefix) {
+			return notFound
+		}
+	}
+
+	info, err := fs.Stat(c.FS, c.Fallback)
+	if err != nil || info.IsDir() {
+		return notFound
+	}
+
+	res, err := c.serveFile(ctx, c.Fallback, info)
+	if err != nil {
+		return notFound
+	}
+
+	return res
+}
+
+// name returns the cleaned file name of the request, or false when the path is not a valid (or allowed) name.
+func (c *Config) name(route, urlPath string) (string, bool) {
+	if strings.HasSuffix(route, "*") {
+		prefix := route[:len(route)-1]
+		if !strings.HasPrefix(urlPath, prefix) {
+			return "", false
+		}
+		urlPath = urlPath[len(prefix):]
+	}
+
+	if strings.ContainsAny(urlPath, "\\\x00") {
+		return "", false
+	}
+
+	name := strings.TrimPrefix(path.Clean("/"+urlPath), "/")
+	if name == "" {
+		name = "."
+	}
+
+	if !fs.ValidPath(name) {
+		return "", false
+	}
+
+	if !c.Hidden {
+		for segment := range strings.SplitSeq(name, "/") {
+			if strings.HasPrefix(segment, ".") && segment != "." {
+				return "", false
+			}
+		}
+	}
+
+	return name, true
+}
+
+func (c *Config) serveFile(ctx *beehive.Context, name string, info fs.FileInfo) (beehive.Responder, error) {
+	header := http.Header{}
+
+	contentType := mime.TypeByExtension(path.Ext(name))
+
+	servedName, servedInfo, servedEncoding := name, info, ""
+	if c.Precompressed {
+		header["Vary"] = []string{"Accept-Encoding"}
+
+		accept := ctx.Request.Header.Get("Accept-Encoding")
+		for _, encoding := range encodings {
+			if !acceptsEncoding(accept, encoding.name) {
+				continue
+			}
+
+			if encInfo, err := fs.Stat(c.FS, name+encoding.ext); err == nil && !encInfo.IsDir() {
+				servedName, servedInfo, servedEncoding = name+encoding.ext, encInfo, encoding.name
+				header["Content-Encoding"] = []string{encoding.name}
+				break
+			}
+		}
+	}
+
+	f, err := c.FS.Open(servedName)
+	if err != nil {
+		return nil, err
+	}
+	ctx.After(func() {
+		_ = f.Close()
+	})
+
+	if contentType == "" {
+		if servedEncoding == "" {
+			contentType = sniff(f)
+		} else {
+			// the compressed content tells nothing about the type of the original
+			contentType = c.sniffFile(name)
+		}
+	}
+
+	etag, err := c.etag(servedName, servedInfo)
+	if err != nil {
+		return nil, err
+	}
+	if servedEncoding != "" {
+		// each encoding is a distinct representation, which must not share the ETag of the original
+		etag = etag[:len(etag)-1] + "-" + servedEncoding + `"`
+	}
+	header["Etag"] = []string{etag}
+
+	modTime := servedInfo.ModTime()
+	if !modTime.IsZero() {
+		header["Last-Modified"] = []string{modTime.UTC().Format(http.TimeFormat)}
+	}
+
+	if cacheControl := c.cacheControl(name); cacheControl != "" {
+		header["Cache-Control"] = []string{cacheControl}
+	}
+
+	res := &fileResponder{
+		file:        f,
+		header:      header,
+		contentType: contentType,
+		size:        servedInfo.Size(),
+		head:        ctx.Request.Method == http.MethodHead,
+	}
+	res.evaluate(ctx.Request, etag, modTime)
+
+	return res, nil
+}
+
+func (c *Config) cacheControl(name string) string {
+	for _, rule := range c.CacheControl {
+		if match(rule.Pattern, name) {
+			return rule.Value
+		}
+	}
+
+	if !c.Immutable {
+		return ""
+	}
+
+	if name == c.Fallback || path.Base(name) == c.index() {
+		return "no-cache"
+	}
+
+	hashed := c.Hashed
+	if hashed == nil {
+		hashed = IsHashed
+	}
+	if hashed(name) {
+		return "public, max-age=31536000, immutable"
+	}
+
+	return ""
+}
+
+// etag returns a strong ETag of the file, from its size and modification time, or from its content when the
+// modification time is unknown.
+func (c *Config) etag(name string, info fs.FileInfo) (string, error) {
+	if modTime := info.ModTime(); !modTime.IsZero() {
+		return `"` + strconv.FormatInt(info.Size(), 16) + "-" + strconv.FormatInt(modTime.UnixNano(), 16) + `"`, nil
+	}
+
+	if etag, ok := c.hashes.Load(name); ok {
+		return etag.(string), nil
+	}
+
+	f, err := c.FS.Open(name)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+
+	etag := `"` + hex.EncodeToString(h.Sum(nil)[:16]) + `"`
+	c.hashes.Store(name, etag)
+
+	return etag, nil
+}
+
+// sniff detects the content type from the first 512 bytes of a seekable file, and rewinds it.
+func sniff(f fs.File) string {
+	seeker, ok := f.(io.Seeker)
+	if !ok {
+		return "application/octet-stream"
+	}
+
+	var buf [512]byte
+	n, err := io.ReadFull(f, buf[:])
+	if err != nil && !errors.Is(err, io.ErrUnexpectedEOF) && !errors.Is(err, io.EOF) {
+		return "application/octet-stream"
+	}
+
+	if _, err := seeker.Seek(0, io.SeekStart); err != nil {
+		return "application/octet-stream"
+	}
+
+	return http.DetectContentType(buf[:n])
+}
+
+// sniffFile detects the content type of the named file, application/octet-stream is returned when it cannot be read
+// (for example when only its precompressed variants exist).
+func (c *Config) sniffFile(name string) string {
+	f, err := c.FS.Open(name)
+	if err != nil {
+		return "application/octet-stream"
+	}
+	defer f.Close()
+
+	return sniff(f)
+}
+
+func match(pattern, name string) bool {
+	if ok, _ := path.Match(pattern, name); ok {
+		return true
+	}
+	ok, _ := path.Match(pattern, path.Base(name))
+	return ok
+}
+
+// acceptsEncoding reports whether the Accept-Encoding header accepts the encoding with a non zero q-value. An explicit
+// coding takes precedence over the "*" wildcard, wherever it appears in the header.
+func acceptsEncoding(accept, encoding string) bool {
+	wildcard := false
+	for part := range strings.SplitSeq(accept, ",") {
+		coding, params, _ := strings.Cut(part, ";")
+		coding = strings.TrimSpace(coding)
+
+		switch {
+		case strings.EqualFold(coding, encoding):
+			return qualityNonZero(params)
+		case coding == "*":
+			wildcard = qualityNonZero(params)
+		}
+	}
+
+	return wildcard
+}
+
+// qualityNonZero reports whether the parameters of an Accept-Encoding element have no q-value or a non zero one.
+func qualityNonZero(params string) bool {
+	key, value, _ := strings.Cut(strings.TrimSpace(params), "=")
+	if !strings.EqualFold(strings.TrimSpace(key), "q") {
+		return true
+	}
+
+	q, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+	return err == nil && q != 0
+}
+
+// redirect redirects to the directory, with a trailing slash, relative to the request path.
+type redirect struct {
+	location string
+}
+
+func (r *redirect) StatusCode(_ *beehive.Context) int {
+	return http.StatusMovedPermanently
+}
+
+func (r *redirect) Respond(ctx *beehive.Context) {
+	location := r.location
+	if q := ctx.Request.URL.RawQuery; q != "" {
+		location += "?" + q
+	}
+
+	ctx.ResponseWriter.Header().Set("Location", location)
+	ctx.ResponseWriter.WriteHeader(http.StatusMovedPermanently)
+}
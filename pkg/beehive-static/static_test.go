@@ -0,0 +1,473 @@
+package beehive_static
+
+import (
+	"io"
+	"io/fs"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"go.sdls.io/beehive/pkg/beehive"
+)
+
+var testModTime = time.Date(2024, 3, 1, 12, 30, 0, 0, time.UTC)
+
+func newTestFS() fstest.MapFS {
+	return fstest.MapFS{
+		"index.html":          {Data: []byte("<h1>home</h1>"), ModTime: testModTime},
+		"css/main.css":        {Data: []byte("body{}"), ModTime: testModTime},
+		"css/main.css.br":     {Data: []byte("br-css"), ModTime: testModTime},
+		"css/main.css.gz":     {Data: []byte("gzip-css"), ModTime: testModTime},
+		"docs/a.txt":          {Data: []byte("0123456789"), ModTime: testModTime},
+		"docs/<b>.txt":        {Data: []byte("b"), ModTime: testModTime},
+		"docs/.secret":        {Data: []byte("secret"), ModTime: testModTime},
+		"embedded/data.bin":   {Data: []byte{0x00, 0x01}},
+		".env":                {Data: []byte("TOKEN=1"), ModTime: testModTime},
+		"empty/.gitkeep":      {Data: nil, ModTime: testModTime},
+		"nested/dir/x.json":   {Data: []byte(`{}`), ModTime: testModTime},
+		"nested/index.html":   {Data: []byte("nested"), ModTime: testModTime},
+		"nested/dir/y.js":     {Data: []byte("js"), ModTime: testModTime},
+		"nested/dir/z.js.map": {Data: []byte("map"), ModTime: testModTime},
+	}
+}
+
+func newTestRouter(config *Config) (*beehive.Router, *int) {
+	var status int
+
+	router := beehive.NewRouter()
+	router.After = func(ctx *beehive.Context, res beehive.Responder) {
+		status = http.StatusOK
+		if res != nil {
+			status = res.StatusCode(ctx)
+		}
+	}
+
+	handler := config.HandlerFunc()
+	router.Handle("GET", "/assets/*", handler)
+	router.Handle("HEAD", "/assets/*", handler)
+	router.Handle("GET", "/favicon.ico", handler)
+
+	return router, &status
+}
+
+func serve(router *beehive.Router, method, path string, header map[string]string) *httptest.ResponseRecorder {
+	r := httptest.NewRequest(method, path, nil)
+	for key, value := range header {
+		r.Header.Set(key, value)
+	}
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, r)
+	return w
+}
+
+func TestConfig_HandlerFunc(t *testing.T) {
+	t.Parallel()
+
+	router, status := newTestRouter(&Config{FS: newTestFS()})
+
+	tests := []struct {
+		path        string
+		code        int
+		body        string
+		contentType string
+	}{
+		{"/assets/css/main.css", http.StatusOK, "body{}", "text/css; charset=utf-8"},
+		{"/assets/", http.StatusOK, "<h1>home</h1>", "text/html; charset=utf-8"},
+		{"/assets/index.html", http.StatusOK, "<h1>home</h1>", "text/html; charset=utf-8"},
+		{"/assets/nested/", http.StatusOK, "nested", "text/html; charset=utf-8"},
+		{"/assets/embedded/data.bin", http.StatusOK, "\x00\x01", "application/octet-stream"},
+		{"/assets/missing.txt", http.StatusNotFound, "not found", ""},
+		{"/assets/docs/", http.StatusNotFound, "not found", ""},
+		{"/assets/.env", http.StatusNotFound, "not found", ""},
+		{"/assets/docs/.secret", http.StatusNotFound, "not found", ""},
+		{"/assets/../static.go", http.StatusNotFound, "not found", ""},
+		{"/assets/..%2fstatic.go", http.StatusNotFound, "not found", ""},
+		{"/assets/css/..\\..\\.env", http.StatusNotFound, "not found", ""},
+		{"/favicon.ico", http.StatusNotFound, "not found", ""},
+	}
+
+	for _, test := range tests {
+		w := serve(router, "GET", test.path, nil)
+
+		if w.Code != test.code {
+			t.Errorf("%s: expected %d, got %d", test.path, test.code, w.Code)
+		}
+		if *status != test.code {
+			t.Errorf("%s: expected %d in After, got %d", test.path, test.code, *status)
+		}
+		if body := w.Body.String(); body != test.body {
+			t.Errorf("%s: expected %q, got %q", test.path, test.body, body)
+		}
+		if test.contentType != "" && w.Header().Get("Content-Type") != test.contentType {
+			t.Errorf("%s: expected %q, got %q", test.path, test.contentType, w.Header().Get("Content-Type"))
+		}
+	}
+}
+
+func TestConfig_HandlerFunc_redirect(t *testing.T) {
+	t.Parallel()
+
+	router, _ := newTestRouter(&Config{FS: newTestFS()})
+
+	w := serve(router, "GET", "/assets/nested?v=1", nil)
+	if w.Code != http.StatusMovedPermanently {
+		t.Errorf("expected %d, got %d", http.StatusMovedPermanently, w.Code)
+	}
+	if location := w.Header().Get("Location"); location != "nested/?v=1" {
+		t.Errorf("expected %q, got %q", "nested/?v=1", location)
+	}
+}
+
+func TestConfig_HandlerFunc_head(t *testing.T) {
+	t.Parallel()
+
+	router, _ := newTestRouter(&Config{FS: newTestFS()})
+
+	w := serve(router, "HEAD", "/assets/docs/a.txt", nil)
+	if w.Code != http.StatusOK {
+		t.Errorf("expected %d, got %d", http.StatusOK, w.Code)
+	}
+	if length := w.Header().Get("Content-Length"); length != "10" {
+		t.Errorf("expected %q, got %q", "10", length)
+	}
+	if w.Body.Len() != 0 {
+		t.Errorf("expected empty body, got %q", w.Body.String())
+	}
+}
+
+func TestConfig_HandlerFunc_conditional(t *testing.T) {
+	t.Parallel()
+
+	router, status := newTestRouter(&Config{FS: newTestFS()})
+
+	w := serve(router, "GET", "/assets/docs/a.txt", nil)
+	etag := w.Header().Get("ETag")
+	if etag == "" {
+		t.Fatalf("expected an ETag")
+	}
+	lastModified := w.Header().Get("Last-Modified")
+	if lastModified != testModTime.Format(http.TimeFormat) {
+		t.Errorf("expected %q, got %q", testModTime.Format(http.TimeFormat), lastModified)
+	}
+
+	tests := []struct {
+		header map[string]string
+		code   int
+	}{
+		{map[string]string{"If-None-Match": etag}, http.StatusNotModified},
+		{map[string]string{"If-None-Match": `"other", W/` + etag}, http.StatusNotModified},
+		{map[string]string{"If-None-Match": `"other"`}, http.StatusOK},
+		{map[string]string{"If-None-Match": `"other"`, "If-Modified-Since": lastModified}, http.StatusOK},
+		{map[string]string{"If-Modified-Since": lastModified}, http.StatusNotModified},
+		{map[string]string{"If-Modified-Since": testModTime.Add(-time.Hour).Format(http.TimeFormat)}, http.StatusOK},
+		{map[string]string{"If-Match": etag}, http.StatusOK},
+		{map[string]string{"If-Match": "W/" + etag}, http.StatusPreconditionFailed},
+		{map[string]string{"If-Match": "*"}, http.StatusOK},
+		{map[string]string{"If-Unmodified-Since": testModTime.Add(-time.Hour).Format(http.TimeFormat)}, http.StatusPreconditionFailed},
+		{map[string]string{"If-Unmodified-Since": lastModified}, http.StatusOK},
+	}
+
+	for _, test := range tests {
+		w := serve(router, "GET", "/assets/docs/a.txt", test.header)
+
+		if w.Code != test.code {
+			t.Errorf("%v: expected %d, got %d", test.header, test.code, w.Code)
+		}
+		if *status != test.code {
+			t.Errorf("%v: expected %d in After, got %d", test.header, test.code, *status)
+		}
+		if test.code != http.StatusOK && w.Body.Len() != 0 {
+			t.Errorf("%v: expected empty body, got %q", test.header, w.Body.String())
+		}
+	}
+}
+
+func TestConfig_HandlerFunc_contentETag(t *testing.T) {
+	t.Parallel()
+
+	router, _ := newTestRouter(&Config{FS: newTestFS()})
+
+	first := serve(router, "GET", "/assets/embedded/data.bin", nil)
+	etag := first.Header().Get("ETag")
+	if etag == "" {
+		t.Fatalf("expected an ETag")
+	}
+	if lastModified := first.Header().Get("Last-Modified"); lastModified != "" {
+		t.Errorf("expected no Last-Modified, got %q", lastModified)
+	}
+
+	w := serve(router, "GET", "/assets/embedded/data.bin", map[string]string{"If-None-Match": etag})
+	if w.Code != http.StatusNotModified {
+		t.Errorf("expected %d, got %d", http.StatusNotModified, w.Code)
+	}
+}
+
+func TestConfig_HandlerFunc_range(t *testing.T) {
+	t.Parallel()
+
+	router, status := newTestRouter(&Config{FS: newTestFS()})
+
+	etag := serve(router, "GET", "/assets/docs/a.txt", nil).Header().Get("ETag")
+
+	tests := []struct {
+		header       map[string]string
+		code         int
+		body         string
+		contentRange string
+	}{
+		{map[string]string{"Range": "bytes=0-3"}, http.StatusPartialContent, "0123", "bytes 0-3/10"},
+		{map[string]string{"Range": "bytes=7-"}, http.StatusPartialContent, "789", "bytes 7-9/10"},
+		{map[string]string{"Range": "bytes=-2"}, http.StatusPartialContent, "89", "bytes 8-9/10"},
+		{map[string]string{"Range": "bytes=5-100"}, http.StatusPartialContent, "56789", "bytes 5-9/10"},
+		{map[string]string{"Range": "bytes=10-"}, http.StatusRequestedRangeNotSatisfiable, "", "bytes */10"},
+		{map[string]string{"Range": "bytes=3-1"}, http.StatusOK, "0123456789", ""},
+		{map[string]string{"Range": "items=0-1"}, http.StatusOK, "0123456789", ""},
+		{map[string]string{"Range": "bytes=0-9,0-9"}, http.StatusOK, "0123456789", ""},
+		{map[string]string{"Range": "bytes=0-1", "If-Range": etag}, http.StatusPartialContent, "01", "bytes 0-1/10"},
+		{map[string]string{"Range": "bytes=0-1", "If-Range": `"stale"`}, http.StatusOK, "0123456789", ""},
+		{map[string]string{"Range": "bytes=0-1", "If-Range": testModTime.Format(http.TimeFormat)}, http.StatusPartialContent, "01", "bytes 0-1/10"},
+		{map[string]string{"Range": "bytes=0-1", "If-Range": testModTime.Add(-time.Hour).Format(http.TimeFormat)}, http.StatusOK, "0123456789", ""},
+	}
+
+	for _, test := range tests {
+		w := serve(router, "GET", "/assets/docs/a.txt", test.header)
+
+		if w.Code != test.code {
+			t.Errorf("%v: expected %d, got %d", test.header, test.code, w.Code)
+		}
+		if *status != test.code {
+			t.Errorf("%v: expected %d in After, got %d", test.header, test.code, *status)
+		}
+		if body := w.Body.String(); body != test.body {
+			t.Errorf("%v: expected %q, got %q", test.header, test.body, body)
+		}
+		if contentRange := w.Header().Get("Content-Range"); contentRange != test.contentRange {
+			t.Errorf("%v: expected %q, got %q", test.header, test.contentRange, contentRange)
+		}
+	}
+}
+
+func TestConfig_HandlerFunc_multipartRange(t *testing.T) {
+	t.Parallel()
+
+	router, _ := newTestRouter(&Config{FS: newTestFS()})
+
+	w := serve(router, "GET", "/assets/docs/a.txt", map[string]string{"Range": "bytes=0-1, 8-"})
+	if w.Code != http.StatusPartialContent {
+		t.Fatalf("expected %d, got %d", http.StatusPartialContent, w.Code)
+	}
+
+	mediaType, params, err := mime.ParseMediaType(w.Header().Get("Content-Type"))
+	if err != nil || mediaType != "multipart/byteranges" {
+		t.Fatalf("expected multipart/byteranges, got %q", w.Header().Get("Content-Type"))
+	}
+
+	want := []struct {
+		contentRange, body string
+	}{
+		{"bytes 0-1/10", "01"},
+		{"bytes 8-9/10", "89"},
+	}
+
+	reader := multipart.NewReader(w.Body, params["boundary"])
+	for _, part := range want {
+		p, err := reader.NextPart()
+		if err != nil {
+			t.Fatalf("unexpected error %v", err)
+		}
+
+		if contentRange := p.Header.Get("Content-Range"); contentRange != part.contentRange {
+			t.Errorf("expected %q, got %q", part.contentRange, contentRange)
+		}
+		if contentType := p.Header.Get("Content-Type"); contentType != "text/plain; charset=utf-8" {
+			t.Errorf("expected %q, got %q", "text/plain; charset=utf-8", contentType)
+		}
+
+		body, _ := io.ReadAll(p)
+		if string(body) != part.body {
+			t.Errorf("expected %q, got %q", part.body, body)
+		}
+	}
+
+	if _, err := reader.NextPart(); err != io.EOF {
+		t.Errorf("expected %v, got %v", io.EOF, err)
+	}
+}
+
+func TestConfig_HandlerFunc_precompressed(t *testing.T) {
+	t.Parallel()
+
+	router, _ := newTestRouter(&Config{FS: newTestFS(), Precompressed: true})
+
+	tests := []struct {
+		acceptEncoding  string
+		body            string
+		contentEncoding string
+	}{
+		{"gzip, deflate, br", "br-css", "br"},
+		{"gzip", "gzip-css", "gzip"},
+		{"br;q=0, gzip;q=0.5", "gzip-css", "gzip"},
+		{"*;q=0, gzip", "gzip-css", "gzip"},
+		{"*;q=0, br;q=0.5", "br-css", "br"},
+		{"identity", "body{}", ""},
+		{"", "body{}", ""},
+	}
+
+	etags := make(map[string]bool)
+	for _, test := range tests {
+		w := serve(router, "GET", "/assets/css/main.css", map[string]string{"Accept-Encoding": test.acceptEncoding})
+
+		if body := w.Body.String(); body != test.body {
+			t.Errorf("%q: expected %q, got %q", test.acceptEncoding, test.body, body)
+		}
+		if contentEncoding := w.Header().Get("Content-Encoding"); contentEncoding != test.contentEncoding {
+			t.Errorf("%q: expected %q, got %q", test.acceptEncoding, test.contentEncoding, contentEncoding)
+		}
+		if contentType := w.Header().Get("Content-Type"); contentType != "text/css; charset=utf-8" {
+			t.Errorf("%q: expected %q, got %q", test.acceptEncoding, "text/css; charset=utf-8", contentType)
+		}
+		if vary := w.Header().Get("Vary"); vary != "Accept-Encoding" {
+			t.Errorf("%q: expected %q, got %q", test.acceptEncoding, "Accept-Encoding", vary)
+		}
+
+		etags[w.Header().Get("ETag")] = true
+	}
+
+	if len(etags) != 3 {
+		t.Errorf("expected %d distinct ETags, got %d", 3, len(etags))
+	}
+}
+
+func TestConfig_HandlerFunc_precompressedSniff(t *testing.T) {
+	t.Parallel()
+
+	router, _ := newTestRouter(&Config{
+		FS: fstest.MapFS{
+			"page":    {Data: []byte("<html><body>page</body></html>"), ModTime: testModTime},
+			"page.gz": {Data: []byte("\x1f\x8b\x08\x00compressed"), ModTime: testModTime},
+		},
+		Precompressed: true,
+	})
+
+	w := serve(router, "GET", "/assets/page", map[string]string{"Accept-Encoding": "gzip"})
+
+	if contentEncoding := w.Header().Get("Content-Encoding"); contentEncoding != "gzip" {
+		t.Errorf("expected %q, got %q", "gzip", contentEncoding)
+	}
+	if contentType := w.Header().Get("Content-Type"); contentType != "text/html; charset=utf-8" {
+		t.Errorf("expected %q, got %q", "text/html; charset=utf-8", contentType)
+	}
+}
+
+func TestConfig_HandlerFunc_listing(t *testing.T) {
+	t.Parallel()
+
+	router, _ := newTestRouter(&Config{FS: newTestFS(), Listing: true})
+
+	w := serve(router, "GET", "/assets/docs/", nil)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected %d, got %d", http.StatusOK, w.Code)
+	}
+
+	body := w.Body.String()
+	for _, want := range []string{`<a href="%3Cb%3E.txt">&lt;b&gt;.txt</a>`, `<a href="a.txt">a.txt</a>`} {
+		if !strings.Contains(body, want) {
+			t.Errorf("expected %q in %q", want, body)
+		}
+	}
+	if strings.Contains(body, ".secret") {
+		t.Errorf("expected hidden files to be omitted, got %q", body)
+	}
+
+	// the index is still preferred over the listing
+	w = serve(router, "GET", "/assets/nested/", nil)
+	if body := w.Body.String(); body != "nested" {
+		t.Errorf("expected %q, got %q", "nested", body)
+	}
+}
+
+// failingDirFS fails to read directories, as a file system being modified concurrently may.
+type failingDirFS struct {
+	fstest.MapFS
+}
+
+func (f failingDirFS) ReadDir(name string) ([]fs.DirEntry, error) {
+	return nil, &fs.PathError{Op: "readdir", Path: name, Err: fs.ErrPermission}
+}
+
+func TestConfig_HandlerFunc_listingError(t *testing.T) {
+	t.Parallel()
+
+	router, _ := newTestRouter(&Config{
+		FS:       failingDirFS{newTestFS()},
+		Listing:  true,
+		NotFound: &beehive.DefaultResponder{Message: "gone", Status: http.StatusGone},
+	})
+
+	w := serve(router, "GET", "/assets/docs/", nil)
+	if w.Code != http.StatusGone {
+		t.Errorf("expected %d, got %d", http.StatusGone, w.Code)
+	}
+}
+
+func TestConfig_HandlerFunc_cacheControl(t *testing.T) {
+	t.Parallel()
+
+	router, _ := newTestRouter(&Config{
+		FS: newTestFS(),
+		CacheControl: []CacheRule{
+			{Pattern: "index.html", Value: "no-cache"},
+			{Pattern: "nested/dir/*.js", Value: "public, max-age=60"},
+			{Pattern: "*.css", Value: "public, max-age=3600"},
+		},
+	})
+
+	tests := []struct {
+		path, cacheControl string
+	}{
+		{"/assets/", "no-cache"},
+		{"/assets/nested/", "no-cache"},
+		{"/assets/css/main.css", "public, max-age=3600"},
+		{"/assets/nested/dir/y.js", "public, max-age=60"},
+		{"/assets/nested/dir/x.json", ""},
+	}
+
+	for _, test := range tests {
+		w := serve(router, "GET", test.path, nil)
+		if cacheControl := w.Header().Get("Cache-Control"); cacheControl != test.cacheControl {
+			t.Errorf("%s: expected %q, got %q", test.path, test.cacheControl, cacheControl)
+		}
+	}
+}
+
+func TestConfig_HandlerFunc_notFound(t *testing.T) {
+	t.Parallel()
+
+	router, _ := newTestRouter(&Config{
+		FS:       newTestFS(),
+		NotFound: &beehive.DefaultResponder{Message: "gone", Status: http.StatusGone},
+	})
+
+	w := serve(router, "GET", "/assets/missing", nil)
+	if w.Code != http.StatusGone {
+		t.Errorf("expected %d, got %d", http.StatusGone, w.Code)
+	}
+}
+
+func TestConfig_HandlerFunc_panic(t *testing.T) {
+	t.Parallel()
+
+	defer func() {
+		if recover() == nil {
+			t.Errorf("expected a panic")
+		}
+	}()
+
+	(&Config{}).HandlerFunc()
+}
@@ -0,0 +1,334 @@
+package beehive_template
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"html/template"
+	"io/fs"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+	"sync"
+	"time"
+
+	"go.sdls.io/beehive/pkg/beehive"
+)
+
+// Config describes a set of HTML templates loaded from FS. Pages are the templates rendered by name, every page is
+// parsed with all the layouts and partials, such that it can use them and override their blocks. Templates are named
+// after their path relative to their directory, without extension: "pages/users/list.html" is the page
+// "users/list" and "partials/nav.html" the partial "nav". The body of a page is the "content" template.
+type Config struct {
+	// FS is the file system holding the templates. It is required.
+	FS fs.FS
+
+	// Pages is the directory of the pages. If empty, "pages" is used.
+	Pages string
+
+	// Layouts is the directory of the layouts, it may not exist. If empty, "layouts" is used.
+	Layouts string
+
+	// Partials is the directory of the partials, it may not exist. If empty, "partials" is used.
+	Partials string
+
+	// Extension is the extension of the template files, other files are ignored. If empty, ".html" is used.
+	Extension string
+
+	// Layout is the layout executed to render pages, which includes the page with {{template "content" .}}. If
+	// empty, the "content" of pages is executed directly.
+	Layout string
+
+	// Funcs are added to the templates, in addition to the "url" function building a path from Routes.
+	Funcs template.FuncMap
+
+	// Helpers are functions bound to the request, such as a CSRF token. Every Helper returns the template function
+	// for the request being rendered, for example:
+	//
+	//	"csrf": func(ctx *beehive.Context) any { return func() string { return token(ctx) } }
+	Helpers map[string]func(ctx *beehive.Context) any
+
+	// Routes are the path patterns used by the "url" function, by name. The '*' of wildcard patterns is replaced by
+	// the path escaped arguments, joined by slashes: with "user": "/users/*", {{url "user" 42}} is "/users/42".
+	Routes map[string]string
+
+	// Reload reparses the templates when any of their files changed, was added or removed, checked on every render.
+	// It is meant for development, templates are parsed once otherwise.
+	Reload bool
+}
+
+// Templates renders the pages of a Config.
+type Templates struct {
+	config Config
+
+	mu      sync.RWMutex
+	pages   map[string]*template.Template
+	modTime map[string]time.Time
+}
+
+var defaultErrorResponder = &beehive.DefaultResponder{
+	Message: "internal server error",
+	Status:  http.StatusInternalServerError,
+}
+
+// New parses the templates of the Config, returning the first parse error.
+func New(config Config) (*Templates, error) {
+	if config.FS == nil {
+		panic("beehive-template: config has no file system")
+	}
+
+	if config.Pages == "" {
+		config.Pages = "pages"
+	}
+	if config.Layouts == "" {
+		config.Layouts = "layouts"
+	}
+	if config.Partials == "" {
+		config.Partials = "partials"
+	}
+	if config.Extension == "" {
+		config.Extension = ".html"
+	}
+
+	t := &Templates{config: config}
+	if err := t.parse(); err != nil {
+		return nil, err
+	}
+
+	return t, nil
+}
+
+// Must is a helper wrapping a call to New, panicking if the error is non-nil.
+func Must(t *Templates, err error) *Templates {
+	if err != nil {
+		panic(err)
+	}
+	return t
+}
+
+// Render executes the page with the data, and returns a Responder writing it with the status. The page is rendered
+// before Render returns, such that errors are answered with a 500 Internal Server Error, reported with
+// beehive.Context.ReportError, instead of a partial page.
+func (t *Templates) Render(ctx *beehive.Context, status int, name string, data any) beehive.Responder {
+	if t.config.Reload {
+		if err := t.reload(); err != nil {
+			ctx.ReportError(err)
+			return defaultErrorResponder
+		}
+	}
+
+	t.mu.RLock()
+	page, ok := t.pages[name]
+	t.mu.RUnlock()
+
+	if !ok {
+		ctx.ReportError(errors.New("beehive-template: page " + name + " not found"))
+		return defaultErrorResponder
+	}
+
+	// pages are cloned before execution to bind the helpers, an executed template cannot be cloned anymore
+	if len(t.config.Helpers) > 0 {
+		clone, err := page.Clone()
+		if err != nil {
+			ctx.ReportError(err)
+			return defaultErrorResponder
+		}
+
+		funcs := make(template.FuncMap, len(t.config.Helpers))
+		for helper, f := range t.config.Helpers {
+			funcs[helper] = f(ctx)
+		}
+		page = clone.Funcs(funcs)
+	}
+
+	root := "content"
+	if t.config.Layout != "" {
+		root = t.config.Layout
+	}
+
+	var buf bytes.Buffer
+	if err := page.ExecuteTemplate(&buf, root, data); err != nil {
+		ctx.ReportError(err)
+		return defaultErrorResponder
+	}
+
+	return &rendered{status: status, body: buf.Bytes()}
+}
+
+// Pages returns the names of the pages.
+func (t *Templates) Pages() []string {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	names := make([]string, 0, len(t.pages))
+	for name := range t.pages {
+		names = append(names, name)
+	}
+	return names
+}
+
+// rendered writes a rendered page.
+type rendered struct {
+	status int
+	body   []byte
+}
+
+func (r *rendered) StatusCode(_ *beehive.Context) int {
+	return r.status
+}
+
+func (r *rendered) Respond(ctx *beehive.Context) {
+	w := ctx.ResponseWriter
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(r.status)
+	_, _ = w.Write(r.body)
+}
+
+// test that rendered implements beehive.Responder.
+var _ beehive.Responder = &rendered{}
+
+func (t *Templates) parse() error {
+	c := &t.config
+
+	funcs := template.FuncMap{"url": t.url}
+	for name, f := range c.Funcs {
+		funcs[name] = f
+	}
+	// the helpers are bound on every render, placeholders let the templates parse
+	for name := range c.Helpers {
+		funcs[name] = func(...any) (any, error) {
+			return nil, errors.New("beehive-template: helper " + name + " is not bound")
+		}
+	}
+
+	modTime := make(map[string]time.Time)
+	base := template.New("").Funcs(funcs)
+
+	for _, dir := range []string{c.Layouts, c.Partials} {
+		err := t.walk(dir, modTime, func(name string, src []byte) error {
+			if base.Lookup(name) != nil {
+				return fmt.Errorf("beehive-template: template %s defined twice", name)
+			}
+			_, err := base.New(name).Parse(string(src))
+			return err
+		})
+		if err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return err
+		}
+	}
+
+	pages := make(map[string]*template.Template)
+	err := t.walk(c.Pages, modTime, func(name string, src []byte) error {
+		page, err := base.Clone()
+		if err != nil {
+			return err
+		}
+
+		if _, err := page.New("content").Parse(string(src)); err != nil {
+			return err
+		}
+
+		pages[name] = page
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	t.mu.Lock()
+	t.pages, t.modTime = pages, modTime
+	t.mu.Unlock()
+
+	return nil
+}
+
+// walk calls f with the name and content of the template files of the directory, and records their modification
+// time.
+func (t *Templates) walk(dir string, modTime map[string]time.Time, f func(name string, src []byte) error) error {
+	return fs.WalkDir(t.config.FS, dir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() || path.Ext(p) != t.config.Extension {
+			return err
+		}
+
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		modTime[p] = info.ModTime()
+
+		src, err := fs.ReadFile(t.config.FS, p)
+		if err != nil {
+			return err
+		}
+
+		name := strings.TrimSuffix(strings.TrimPrefix(p, dir+"/"), t.config.Extension)
+		if err := f(name, src); err != nil {
+			return fmt.Errorf("%s: %w", p, err)
+		}
+		return nil
+	})
+}
+
+// reload parses the templates again if any file changed.
+func (t *Templates) reload() error {
+	c := &t.config
+	current := make(map[string]time.Time)
+
+	for _, dir := range []string{c.Layouts, c.Partials, c.Pages} {
+		err := fs.WalkDir(c.FS, dir, func(p string, d fs.DirEntry, err error) error {
+			if err != nil || d.IsDir() || path.Ext(p) != c.Extension {
+				return err
+			}
+
+			info, err := d.Info()
+			if err != nil {
+				return err
+			}
+			current[p] = info.ModTime()
+			return nil
+		})
+		if err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return err
+		}
+	}
+
+	t.mu.RLock()
+	changed := len(current) != len(t.modTime)
+	for p, modTime := range current {
+		if previous, ok := t.modTime[p]; !ok || !previous.Equal(modTime) {
+			changed = true
+			break
+		}
+	}
+	t.mu.RUnlock()
+
+	if !changed {
+		return nil
+	}
+
+	return t.parse()
+}
+
+// url builds the path of the named route, see Config.Routes.
+func (t *Templates) url(name string, args ...any) (string, error) {
+	pattern, ok := t.config.Routes[name]
+	if !ok {
+		return "", errors.New("beehive-template: route " + name + " not found")
+	}
+
+	prefix, wildcard := strings.CutSuffix(pattern, "*")
+	if !wildcard {
+		if len(args) > 0 {
+			return "", errors.New("beehive-template: route " + name + " takes no argument")
+		}
+		return pattern, nil
+	}
+
+	segments := make([]string, len(args))
+	for idx, arg := range args {
+		segments[idx] = url.PathEscape(fmt.Sprint(arg))
+	}
+
+	return prefix + strings.Join(segments, "/"), nil
+}
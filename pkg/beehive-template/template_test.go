@@ -0,0 +1,237 @@
+package beehive_template
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"go.sdls.io/beehive/pkg/beehive"
+)
+
+type contextKey struct{}
+
+func newTestFS() fstest.MapFS {
+	return fstest.MapFS{
+		"layouts/base.html":     {Data: []byte(`<title>{{block "title" .}}admin{{end}}</title>{{template "nav" .}}<main>{{template "content" .}}</main>`)},
+		"partials/nav.html":     {Data: []byte(`<nav><a href="{{url "users"}}">users</a></nav>`)},
+		"partials/README.md":    {Data: []byte(`ignored`)},
+		"pages/home.html":       {Data: []byte(`hello {{.}}`)},
+		"pages/users/show.html": {Data: []byte(`{{define "title"}}user {{.ID}}{{end}}<a href="{{url "user" .ID "edit"}}">{{.Name}}</a><input value="{{csrf}}">`)},
+		"pages/broken.html":     {Data: []byte(`{{template "undefined" .}}`)},
+	}
+}
+
+func newTestConfig(fsys fstest.MapFS) Config {
+	return Config{
+		FS:     fsys,
+		Layout: "base",
+		Routes: map[string]string{
+			"users": "/users",
+			"user":  "/users/*",
+		},
+		Helpers: map[string]func(ctx *beehive.Context) any{
+			"csrf": func(ctx *beehive.Context) any {
+				return func() string {
+					token, _ := ctx.Value(contextKey{}).(string)
+					return token
+				}
+			},
+		},
+	}
+}
+
+func render(router *beehive.Router, path string) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", path, nil))
+	return w
+}
+
+func TestTemplates_Render(t *testing.T) {
+	t.Parallel()
+
+	templates := Must(New(newTestConfig(newTestFS())))
+
+	var reported []error
+	router := beehive.NewRouter()
+	router.WhenError = func(_ *beehive.Context, err error) {
+		reported = append(reported, err)
+	}
+	router.Handle("GET", "/", func(ctx *beehive.Context) beehive.Responder {
+		return templates.Render(ctx, http.StatusOK, "home", "<world>")
+	})
+	router.Handle("GET", "/users/*", func(ctx *beehive.Context) beehive.Responder {
+		ctx.WithValue(contextKey{}, "token-"+ctx.Request.URL.Query().Get("session"))
+		return templates.Render(ctx, http.StatusAccepted, "users/show", map[string]any{"ID": "a b", "Name": "Jane"})
+	})
+	router.Handle("GET", "/broken", func(ctx *beehive.Context) beehive.Responder {
+		return templates.Render(ctx, http.StatusOK, "broken", nil)
+	})
+	router.Handle("GET", "/missing", func(ctx *beehive.Context) beehive.Responder {
+		return templates.Render(ctx, http.StatusOK, "missing", nil)
+	})
+
+	tests := []struct {
+		path string
+		code int
+		body string
+	}{
+		{"/", http.StatusOK, `<title>admin</title><nav><a href="/users">users</a></nav><main>hello &lt;world&gt;</main>`},
+		{"/users/1?session=42", http.StatusAccepted, `<title>user a b</title><nav><a href="/users">users</a></nav><main><a href="/users/a%20b/edit">Jane</a><input value="token-42"></main>`},
+		{"/broken", http.StatusInternalServerError, "internal server error"},
+		{"/missing", http.StatusInternalServerError, "internal server error"},
+	}
+
+	for _, test := range tests {
+		w := render(router, test.path)
+
+		if w.Code != test.code {
+			t.Errorf("%s: expected %d, got %d", test.path, test.code, w.Code)
+		}
+		if body := w.Body.String(); body != test.body {
+			t.Errorf("%s: expected %q, got %q", test.path, test.body, body)
+		}
+		if test.code == http.StatusOK && w.Header().Get("Content-Type") != "text/html; charset=utf-8" {
+			t.Errorf("%s: expected %q, got %q", test.path, "text/html; charset=utf-8", w.Header().Get("Content-Type"))
+		}
+	}
+
+	if len(reported) != 2 {
+		t.Errorf("expected %d reported errors, got %d", 2, len(reported))
+	}
+}
+
+func TestTemplates_Render_concurrent(t *testing.T) {
+	t.Parallel()
+
+	templates := Must(New(newTestConfig(newTestFS())))
+
+	router := beehive.NewRouter()
+	router.Handle("GET", "/users/*", func(ctx *beehive.Context) beehive.Responder {
+		ctx.WithValue(contextKey{}, ctx.Request.URL.Query().Get("session"))
+		return templates.Render(ctx, http.StatusOK, "users/show", map[string]any{"ID": 1, "Name": "Jane"})
+	})
+
+	var wg sync.WaitGroup
+	for idx := range 20 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			session := strings.Repeat("x", idx)
+			w := render(router, "/users/1?session="+session)
+			if !strings.Contains(w.Body.String(), `<input value="`+session+`">`) {
+				t.Errorf("expected the helper bound to the request, got %q", w.Body.String())
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+func TestTemplates_noLayout(t *testing.T) {
+	t.Parallel()
+
+	templates := Must(New(Config{FS: fstest.MapFS{
+		"views/index.tmpl": {Data: []byte(`<p>{{.}}</p>`)},
+		"views/other.html": {Data: []byte(`ignored`)},
+	}, Pages: "views", Extension: ".tmpl"}))
+
+	if pages := templates.Pages(); !slices.Equal(pages, []string{"index"}) {
+		t.Errorf("expected %v, got %v", []string{"index"}, pages)
+	}
+
+	router := beehive.NewRouter()
+	router.Handle("GET", "/", func(ctx *beehive.Context) beehive.Responder {
+		return templates.Render(ctx, http.StatusOK, "index", "hi")
+	})
+
+	if body := render(router, "/").Body.String(); body != "<p>hi</p>" {
+		t.Errorf("expected %q, got %q", "<p>hi</p>", body)
+	}
+}
+
+func TestNew_errors(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name string
+		fsys fstest.MapFS
+	}{
+		{"parse error", fstest.MapFS{"pages/a.html": {Data: []byte(`{{if}}`)}}},
+		{"unknown function", fstest.MapFS{"pages/a.html": {Data: []byte(`{{unknown}}`)}}},
+		{"duplicate name", fstest.MapFS{
+			"pages/a.html":    {Data: []byte(`a`)},
+			"layouts/x.html":  {Data: []byte(`x`)},
+			"partials/x.html": {Data: []byte(`x`)},
+		}},
+		{"no pages", fstest.MapFS{"layouts/x.html": {Data: []byte(`x`)}}},
+	}
+
+	for _, test := range tests {
+		if _, err := New(Config{FS: test.fsys}); err == nil {
+			t.Errorf("%s: expected an error", test.name)
+		}
+	}
+}
+
+func TestTemplates_Reload(t *testing.T) {
+	t.Parallel()
+
+	modTime := time.Date(2024, 3, 1, 12, 30, 0, 0, time.UTC)
+	fsys := fstest.MapFS{
+		"pages/home.html": {Data: []byte(`v1`), ModTime: modTime},
+	}
+
+	templates := Must(New(Config{FS: fsys, Reload: true}))
+
+	router := beehive.NewRouter()
+	router.Handle("GET", "/*", func(ctx *beehive.Context) beehive.Responder {
+		return templates.Render(ctx, http.StatusOK, strings.TrimPrefix(ctx.Request.URL.Path, "/"), nil)
+	})
+
+	if body := render(router, "/home").Body.String(); body != "v1" {
+		t.Errorf("expected %q, got %q", "v1", body)
+	}
+
+	fsys["pages/home.html"] = &fstest.MapFile{Data: []byte(`v2`), ModTime: modTime.Add(time.Second)}
+	if body := render(router, "/home").Body.String(); body != "v2" {
+		t.Errorf("expected %q, got %q", "v2", body)
+	}
+
+	fsys["pages/new.html"] = &fstest.MapFile{Data: []byte(`new`), ModTime: modTime}
+	if body := render(router, "/new").Body.String(); body != "new" {
+		t.Errorf("expected %q, got %q", "new", body)
+	}
+
+	// a broken template fails the render until it is fixed
+	fsys["pages/home.html"] = &fstest.MapFile{Data: []byte(`{{if}}`), ModTime: modTime.Add(2 * time.Second)}
+	if w := render(router, "/home"); w.Code != http.StatusInternalServerError {
+		t.Errorf("expected %d, got %d", http.StatusInternalServerError, w.Code)
+	}
+
+	fsys["pages/home.html"] = &fstest.MapFile{Data: []byte(`v3`), ModTime: modTime.Add(3 * time.Second)}
+	if body := render(router, "/home").Body.String(); body != "v3" {
+		t.Errorf("expected %q, got %q", "v3", body)
+	}
+}
+
+func TestTemplates_noReload(t *testing.T) {
+	t.Parallel()
+
+	fsys := fstest.MapFS{"pages/home.html": {Data: []byte(`v1`)}}
+	templates := Must(New(Config{FS: fsys}))
+
+	router := beehive.NewRouter()
+	router.Handle("GET", "/", func(ctx *beehive.Context) beehive.Responder {
+		return templates.Render(ctx, http.StatusOK, "home", nil)
+	})
+
+	fsys["pages/home.html"] = &fstest.MapFile{Data: []byte(`v2`), ModTime: time.Now()}
+	if body := render(router, "/").Body.String(); body != "v1" {
+		t.Errorf("expected %q, got %q", "v1", body)
+	}
+}
@@ -0,0 +1,332 @@
+package beehive_trace
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// test that OTLPExporter implements Exporter.
+var _ Exporter = &OTLPExporter{}
+
+// OTLPExporter is an Exporter sending the spans to an OpenTelemetry collector using OTLP with the JSON encoding over
+// HTTP. Spans are sent in the background in batches, when BatchSize spans are buffered or Interval after the first
+// buffered span. Flush must be called before exiting to send the remaining spans.
+type OTLPExporter struct {
+	// Endpoint is the traces URL of the collector, for example http://localhost:4318/v1/traces.
+	Endpoint string
+
+	// Client is the http.Client used to send the batches. If nil, http.DefaultClient is used.
+	Client *http.Client
+
+	// Header is added to every request, for example for authentication.
+	Header http.Header
+
+	// ServiceName is sent as the service.name resource attribute.
+	ServiceName string
+
+	// BatchSize is the number of spans sent in a single request. If zero, 128 is used.
+	BatchSize int
+
+	// Interval is the maximum time a span is buffered. If zero, 5 seconds is used.
+	Interval time.Duration
+
+	// Timeout bounds the requests sent in the background. If zero, 10 seconds is used.
+	Timeout time.Duration
+
+	// OnError is called with the errors of the batches sent in the background. If nil, errors are ignored.
+	OnError func(err error)
+
+	mu       sync.Mutex
+	spans    []*Span
+	timer    *time.Timer
+	inFlight int
+	idle     chan struct{} // closed when the last background send completes
+}
+
+// ExportSpans satisfies the Exporter interface. The spans are buffered, and sent in the background when the batch is
+// full, such that the request is never delayed by the collector.
+func (e *OTLPExporter) ExportSpans(_ context.Context, spans []*Span) error {
+	batchSize := e.BatchSize
+	if batchSize == 0 {
+		batchSize = 128
+	}
+
+	e.mu.Lock()
+	e.spans = append(e.spans, spans...)
+	if len(e.spans) < batchSize {
+		if e.timer == nil {
+			interval := e.Interval
+			if interval == 0 {
+				interval = 5 * time.Second
+			}
+			e.timer = time.AfterFunc(interval, e.flushInterval)
+		}
+		e.mu.Unlock()
+		return nil
+	}
+
+	batch := e.take()
+	e.begin()
+	e.mu.Unlock()
+
+	go e.sendBackground(batch)
+
+	return nil
+}
+
+// Flush sends all the buffered spans, and waits for the batches being sent in the background until ctx is done.
+func (e *OTLPExporter) Flush(ctx context.Context) error {
+	e.mu.Lock()
+	batch := e.take()
+	e.mu.Unlock()
+
+	var err error
+	if len(batch) > 0 {
+		err = e.send(ctx, batch)
+	}
+
+	e.mu.Lock()
+	idle, inFlight := e.idle, e.inFlight
+	e.mu.Unlock()
+
+	if inFlight > 0 {
+		select {
+		case <-idle:
+		case <-ctx.Done():
+			if err == nil {
+				err = ctx.Err()
+			}
+		}
+	}
+
+	return err
+}
+
+func (e *OTLPExporter) flushInterval() {
+	e.mu.Lock()
+	batch := e.take()
+	if len(batch) == 0 {
+		e.mu.Unlock()
+		return
+	}
+	e.begin()
+	e.mu.Unlock()
+
+	e.sendBackground(batch)
+}
+
+// begin counts a background send, e.mu must be held.
+func (e *OTLPExporter) begin() {
+	if e.inFlight == 0 {
+		e.idle = make(chan struct{})
+	}
+	e.inFlight++
+}
+
+// sendBackground sends a batch counted by begin with Timeout, reporting the error to OnError.
+func (e *OTLPExporter) sendBackground(batch []*Span) {
+	defer func() {
+		e.mu.Lock()
+		e.inFlight--
+		if e.inFlight == 0 {
+			close(e.idle)
+		}
+		e.mu.Unlock()
+	}()
+
+	timeout := e.Timeout
+	if timeout == 0 {
+		timeout = 10 * time.Second
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	if err := e.send(ctx, batch); err != nil && e.OnError != nil {
+		e.OnError(err)
+	}
+}
+
+// take returns the buffered spans and stops the timer, e.mu must be held.
+func (e *OTLPExporter) take() []*Span {
+	if e.timer != nil {
+		e.timer.Stop()
+		e.timer = nil
+	}
+
+	batch := e.spans
+	e.spans = nil
+
+	return batch
+}
+
+func (e *OTLPExporter) send(ctx context.Context, spans []*Span) error {
+	body, err := json.Marshal(e.request(spans))
+	if err != nil {
+		return fmt.Errorf("beehive-trace: encoding spans: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, e.Endpoint, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("beehive-trace: creating request: %w", err)
+	}
+	for k, v := range e.Header {
+		req.Header[k] = v
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	client := e.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+
+	res, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("beehive-trace: sending spans: %w", err)
+	}
+	defer res.Body.Close()
+	_, _ = io.Copy(io.Discard, res.Body)
+
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		return fmt.Errorf("beehive-trace: sending spans: unexpected status code %d", res.StatusCode)
+	}
+
+	return nil
+}
+
+// The OTLP/JSON types, only the fields set by the exporter are defined. IDs are hex encoded and 64 bit integers are
+// strings, as required by the OTLP JSON encoding.
+type (
+	otlpRequest struct {
+		ResourceSpans []otlpResourceSpans `json:"resourceSpans"`
+	}
+	otlpResourceSpans struct {
+		Resource   otlpResource     `json:"resource"`
+		ScopeSpans []otlpScopeSpans `json:"scopeSpans"`
+	}
+	otlpResource struct {
+		Attributes []otlpKeyValue `json:"attributes,omitempty"`
+	}
+	otlpScopeSpans struct {
+		Scope otlpScope  `json:"scope"`
+		Spans []otlpSpan `json:"spans"`
+	}
+	otlpScope struct {
+		Name string `json:"name"`
+	}
+	otlpSpan struct {
+		TraceID           string         `json:"traceId"`
+		SpanID            string         `json:"spanId"`
+		ParentSpanID      string         `json:"parentSpanId,omitempty"`
+		TraceState        string         `json:"traceState,omitempty"`
+		Flags             uint32         `json:"flags"`
+		Name              string         `json:"name"`
+		Kind              int            `json:"kind"`
+		StartTimeUnixNano string         `json:"startTimeUnixNano"`
+		EndTimeUnixNano   string         `json:"endTimeUnixNano"`
+		Attributes        []otlpKeyValue `json:"attributes,omitempty"`
+		Status            otlpStatus     `json:"status"`
+	}
+	otlpStatus struct {
+		Code int `json:"code"`
+	}
+	otlpKeyValue struct {
+		Key   string    `json:"key"`
+		Value otlpValue `json:"value"`
+	}
+	otlpValue struct {
+		StringValue *string  `json:"stringValue,omitempty"`
+		BoolValue   *bool    `json:"boolValue,omitempty"`
+		IntValue    *string  `json:"intValue,omitempty"`
+		DoubleValue *float64 `json:"doubleValue,omitempty"`
+	}
+)
+
+const (
+	otlpSpanKindServer  = 2
+	otlpStatusCodeUnset = 0
+	otlpStatusCodeError = 2
+)
+
+func (e *OTLPExporter) request(spans []*Span) otlpRequest {
+	scopeSpans := otlpScopeSpans{
+		Scope: otlpScope{Name: "go.sdls.io/beehive/pkg/beehive-trace"},
+		Spans: make([]otlpSpan, len(spans)),
+	}
+
+	for idx, span := range spans {
+		s := otlpSpan{
+			TraceID:           span.Context.TraceID.String(),
+			SpanID:            span.Context.SpanID.String(),
+			TraceState:        span.Context.State.String(),
+			Flags:             uint32(span.Context.Flags),
+			Name:              span.Name,
+			Kind:              otlpSpanKindServer,
+			StartTimeUnixNano: strconv.FormatInt(span.Start.UnixNano(), 10),
+			EndTimeUnixNano:   strconv.FormatInt(span.End.UnixNano(), 10),
+			Status:            otlpStatus{Code: otlpStatusCodeUnset},
+		}
+		if span.Parent.IsValid() {
+			s.ParentSpanID = span.Parent.String()
+		}
+		if span.Error {
+			s.Status.Code = otlpStatusCodeError
+		}
+
+		span.mu.Lock()
+		s.Attributes = otlpAttributes(span.Attributes)
+		span.mu.Unlock()
+
+		scopeSpans.Spans[idx] = s
+	}
+
+	var resource otlpResource
+	if e.ServiceName != "" {
+		resource.Attributes = otlpAttributes(map[string]any{"service.name": e.ServiceName})
+	}
+
+	return otlpRequest{
+		ResourceSpans: []otlpResourceSpans{{
+			Resource:   resource,
+			ScopeSpans: []otlpScopeSpans{scopeSpans},
+		}},
+	}
+}
+
+func otlpAttributes(attributes map[string]any) []otlpKeyValue {
+	kvs := make([]otlpKeyValue, 0, len(attributes))
+	for key, value := range attributes {
+		kv := otlpKeyValue{Key: key}
+
+		switch v := value.(type) {
+		case string:
+			kv.Value.StringValue = &v
+		case bool:
+			kv.Value.BoolValue = &v
+		case int64:
+			s := strconv.FormatInt(v, 10)
+			kv.Value.IntValue = &s
+		case float64:
+			kv.Value.DoubleValue = &v
+		default:
+			s := fmt.Sprint(v)
+			kv.Value.StringValue = &s
+		}
+
+		kvs = append(kvs, kv)
+	}
+
+	sort.Slice(kvs, func(i, j int) bool {
+		return kvs[i].Key < kvs[j].Key
+	})
+
+	return kvs
+}
@@ -0,0 +1,213 @@
+package beehive_trace
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+type testCollector struct {
+	server *httptest.Server
+
+	mu       sync.Mutex
+	requests []map[string]any
+	header   http.Header
+}
+
+func newTestCollector(t *testing.T) *testCollector {
+	c := &testCollector{}
+	c.server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+
+		var req map[string]any
+		if err := json.Unmarshal(body, &req); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		c.mu.Lock()
+		c.requests = append(c.requests, req)
+		c.header = r.Header
+		c.mu.Unlock()
+	}))
+	t.Cleanup(c.server.Close)
+
+	return c
+}
+
+func (c *testCollector) len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.requests)
+}
+
+func testSpan(name string) *Span {
+	sc, _ := ParseTraceparent("00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	span := &Span{
+		Name:    name,
+		Context: SpanContext{TraceID: sc.TraceID, SpanID: newSpanID(), Flags: FlagSampled},
+		Parent:  sc.SpanID,
+		Start:   time.Unix(1700000000, 0),
+		End:     time.Unix(1700000000, 5000000),
+		Status:  http.StatusInternalServerError,
+		Error:   true,
+	}
+	span.SetAttribute("http.route", name)
+	span.SetAttribute("http.response.status_code", 500)
+	span.SetAttribute("cache.hit", false)
+	span.SetAttribute("ratio", 0.5)
+
+	return span
+}
+
+func TestOTLPExporter(t *testing.T) {
+	t.Parallel()
+
+	collector := newTestCollector(t)
+	exporter := &OTLPExporter{
+		Endpoint:    collector.server.URL,
+		Header:      http.Header{"Authorization": {"Bearer token"}},
+		ServiceName: "api",
+		BatchSize:   2,
+		Interval:    time.Hour,
+	}
+
+	if err := exporter.ExportSpans(t.Context(), []*Span{testSpan("/a")}); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if collector.len() != 0 {
+		t.Errorf("expected span to be buffered")
+	}
+
+	if err := exporter.ExportSpans(t.Context(), []*Span{testSpan("/b")}); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+
+	// the full batch is sent in the background, Flush waits for it
+	if err := exporter.Flush(t.Context()); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if collector.len() != 1 {
+		t.Fatalf("expected full batch to be sent, got %d requests", collector.len())
+	}
+
+	if collector.header.Get("Authorization") != "Bearer token" || collector.header.Get("Content-Type") != "application/json" {
+		t.Errorf("unexpected headers %v", collector.header)
+	}
+
+	// the span IDs are random, check and remove them before comparing the request
+	spans := collector.requests[0]["resourceSpans"].([]any)[0].(map[string]any)["scopeSpans"].([]any)[0].(map[string]any)["spans"].([]any)
+	if len(spans) != 2 {
+		t.Fatalf("expected %d spans, got %d", 2, len(spans))
+	}
+	for _, span := range spans {
+		if spanID, _ := span.(map[string]any)["spanId"].(string); len(spanID) != 16 {
+			t.Errorf("unexpected span ID %q", spanID)
+		}
+		delete(span.(map[string]any), "spanId")
+	}
+	spans[1] = nil
+
+	got, _ := json.Marshal(collector.requests[0])
+	want := `{"resourceSpans":[{"resource":{"attributes":[{"key":"service.name","value":{"stringValue":"api"}}]},` +
+		`"scopeSpans":[{"scope":{"name":"go.sdls.io/beehive/pkg/beehive-trace"},"spans":[` +
+		`{"attributes":[{"key":"cache.hit","value":{"boolValue":false}},{"key":"http.response.status_code","value":{"intValue":"500"}},` +
+		`{"key":"http.route","value":{"stringValue":"/a"}},{"key":"ratio","value":{"doubleValue":0.5}}],` +
+		`"endTimeUnixNano":"1700000000005000000","flags":1,"kind":2,"name":"/a","parentSpanId":"00f067aa0ba902b7",` +
+		`"startTimeUnixNano":"1700000000000000000","status":{"code":2},"traceId":"4bf92f3577b34da6a3ce929d0e0e4736"},null]}]}]}`
+
+	if string(got) != want {
+		t.Errorf("expected request\n%s\ngot\n%s", want, got)
+	}
+}
+
+func TestOTLPExporter_interval(t *testing.T) {
+	t.Parallel()
+
+	collector := newTestCollector(t)
+	exporter := &OTLPExporter{
+		Endpoint: collector.server.URL,
+		Interval: 10 * time.Millisecond,
+	}
+
+	_ = exporter.ExportSpans(t.Context(), []*Span{testSpan("/a")})
+
+	deadline := time.Now().Add(time.Second)
+	for collector.len() == 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("expected buffered span to be sent after the interval")
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	if err := exporter.Flush(t.Context()); err != nil {
+		t.Errorf("unexpected error %v", err)
+	}
+	if collector.len() != 1 {
+		t.Errorf("expected nothing left to flush, got %d requests", collector.len())
+	}
+}
+
+func TestOTLPExporter_error(t *testing.T) {
+	t.Parallel()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	exporter := &OTLPExporter{Endpoint: server.URL, Interval: time.Hour}
+	_ = exporter.ExportSpans(t.Context(), []*Span{testSpan("/a")})
+
+	if err := exporter.Flush(t.Context()); err == nil {
+		t.Errorf("expected error")
+	}
+}
+
+func TestOTLPExporter_timeout(t *testing.T) {
+	t.Parallel()
+
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(_ http.ResponseWriter, r *http.Request) {
+		select {
+		case <-release:
+		case <-r.Context().Done():
+		}
+	}))
+	defer server.Close()
+	defer close(release)
+
+	errs := make(chan error, 1)
+	exporter := &OTLPExporter{
+		Endpoint:  server.URL,
+		BatchSize: 1,
+		Interval:  time.Hour,
+		Timeout:   20 * time.Millisecond,
+		OnError: func(err error) {
+			errs <- err
+		},
+	}
+
+	start := time.Now()
+	if err := exporter.ExportSpans(t.Context(), []*Span{testSpan("/a")}); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if elapsed := time.Since(start); elapsed >= exporter.Timeout {
+		t.Errorf("expected the full batch not to block, took %s", elapsed)
+	}
+
+	select {
+	case err := <-errs:
+		if !errors.Is(err, context.DeadlineExceeded) {
+			t.Errorf("expected %v, got %v", context.DeadlineExceeded, err)
+		}
+	case <-time.After(time.Second):
+		t.Errorf("expected the hanging request to time out")
+	}
+}
@@ -0,0 +1,91 @@
+package beehive_trace
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// Span is a finished request span, as given to the Exporter.
+type Span struct {
+	// Name is the matched route of the request, see beehive.Context.Route.
+	Name string
+
+	// Context is the SpanContext of the span, its TraceID is inherited from the parent when there is one.
+	Context SpanContext
+
+	// Parent is the SpanID of the remote parent span, invalid for root spans.
+	Parent SpanID
+
+	Start time.Time
+	End   time.Time
+
+	// Status is the HTTP status code of the response, zero when it is not known.
+	Status int
+
+	// Error reports whether the request failed, either with a server error status code or a panic.
+	Error bool
+
+	// Attributes are the span attributes, values are strings, bools, int64 or float64.
+	Attributes map[string]any
+
+	mu sync.Mutex
+}
+
+// SetAttribute sets a span attribute. It can be called by handlers on the span obtained with FromContext while the
+// request is handled. Values that are not strings, bools, int64 or float64 are converted: ints to int64 and
+// everything else to a string.
+func (s *Span) SetAttribute(key string, value any) {
+	switch v := value.(type) {
+	case string, bool, int64, float64:
+	case int:
+		value = int64(v)
+	default:
+		value = fmt.Sprint(v)
+	}
+
+	s.mu.Lock()
+	if s.Attributes == nil {
+		s.Attributes = make(map[string]any)
+	}
+	s.Attributes[key] = value
+	s.mu.Unlock()
+}
+
+// Exporter reports finished spans, for example to a tracing backend. ExportSpans is called concurrently, after the
+// response is sent, and must not keep the spans slice.
+type Exporter interface {
+	ExportSpans(ctx context.Context, spans []*Span) error
+}
+
+// test that MemoryExporter implements Exporter.
+var _ Exporter = &MemoryExporter{}
+
+// MemoryExporter is an Exporter keeping all the spans in memory, meant for tests.
+type MemoryExporter struct {
+	mu    sync.Mutex
+	spans []*Span
+}
+
+// ExportSpans satisfies the Exporter interface.
+func (e *MemoryExporter) ExportSpans(_ context.Context, spans []*Span) error {
+	e.mu.Lock()
+	e.spans = append(e.spans, spans...)
+	e.mu.Unlock()
+	return nil
+}
+
+// Spans returns the exported spans, in export order.
+func (e *MemoryExporter) Spans() []*Span {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return append([]*Span(nil), e.spans...)
+}
+
+// Reset removes all the exported spans.
+func (e *MemoryExporter) Reset() {
+	e.mu.Lock()
+	e.spans = nil
+	e.mu.Unlock()
+}
@@ -0,0 +1,130 @@
+package beehive_trace
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"time"
+
+	"go.sdls.io/beehive/pkg/beehive"
+)
+
+type contextKey struct{}
+
+// Config describes the tracing middleware.
+type Config struct {
+	// Exporter receives the sampled spans. It is required.
+	Exporter Exporter
+
+	// Sample decides whether a request starting a new trace is sampled. Requests with a valid traceparent follow the
+	// sampling decision of the caller. If nil, every new trace is sampled.
+	Sample func(ctx *beehive.Context) bool
+
+	// OnError is called with the errors returned by the Exporter. If nil, errors are ignored.
+	OnError func(err error)
+
+	// Now is the clock used for the span timestamps. If nil, time.Now is used.
+	Now func() time.Time
+}
+
+// HandlerFunc returns the tracing beehive.HandlerFunc. Every request gets a span, as a child of the traceparent sent
+// by the caller if any, stored on the beehive.Context (see FromContext and Inject). The span covers the rest of the
+// handler chain, and is exported after the response is sent.
+func (c *Config) HandlerFunc() beehive.HandlerFunc {
+	if c.Exporter == nil {
+		panic("beehive-trace: config has no exporter")
+	}
+
+	now := c.Now
+	if now == nil {
+		now = time.Now
+	}
+
+	return func(ctx *beehive.Context) beehive.Responder {
+		r := ctx.Request
+
+		span := &Span{
+			Name:  ctx.Route(),
+			Start: now(),
+		}
+
+		parent, err := ParseTraceparent(r.Header.Get("Traceparent"))
+		if err == nil {
+			span.Parent = parent.SpanID
+			span.Context = SpanContext{
+				TraceID: parent.TraceID,
+				Flags:   parent.Flags,
+			}
+			// an invalid tracestate is discarded, but does not invalidate the traceparent
+			span.Context.State, _ = ParseTracestate(strings.Join(r.Header.Values("Tracestate"), ","))
+		} else {
+			span.Context.TraceID = newTraceID()
+			if c.Sample == nil || c.Sample(ctx) {
+				span.Context.Flags = FlagSampled
+			}
+		}
+		span.Context.SpanID = newSpanID()
+
+		span.SetAttribute("http.request.method", r.Method)
+		span.SetAttribute("http.route", span.Name)
+		span.SetAttribute("url.path", r.URL.Path)
+
+		ctx.WithValue(contextKey{}, span)
+
+		finished := false
+		ctx.After(func() {
+			if !finished {
+				// the chain panicked
+				span.End = now()
+				span.Status = http.StatusInternalServerError
+				span.Error = true
+			}
+
+			if span.Status != 0 {
+				span.SetAttribute("http.response.status_code", span.Status)
+			}
+
+			if !span.Context.Sampled() {
+				return
+			}
+
+			if err := c.Exporter.ExportSpans(ctx, []*Span{span}); err != nil && c.OnError != nil {
+				c.OnError(err)
+			}
+		})
+
+		res := ctx.Next()
+
+		span.End = now()
+		if res != nil {
+			span.Status = res.StatusCode(ctx)
+			span.Error = span.Status >= 500
+		}
+		finished = true
+
+		return res
+	}
+}
+
+// FromContext returns the request span stored by the middleware. The beehive.Context, and any context.Context
+// derived from it, can be used.
+func FromContext(ctx context.Context) (*Span, bool) {
+	span, ok := ctx.Value(contextKey{}).(*Span)
+	return span, ok
+}
+
+// Inject sets the traceparent and tracestate headers of an outbound request from the span found in ctx, such that
+// the downstream service continues the trace. Inject does nothing when ctx has no span.
+func Inject(ctx context.Context, header http.Header) {
+	span, ok := FromContext(ctx)
+	if !ok {
+		return
+	}
+
+	header.Set("Traceparent", span.Context.Traceparent())
+	if len(span.Context.State) != 0 {
+		header.Set("Tracestate", span.Context.State.String())
+	} else {
+		header.Del("Tracestate")
+	}
+}
@@ -0,0 +1,156 @@
+package beehive_trace
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"go.sdls.io/beehive/pkg/beehive"
+)
+
+func newTestRouter(config *Config) *beehive.Router {
+	router := beehive.NewRouter()
+	router.With(config.HandlerFunc())
+
+	router.Handle("GET", "/users/*", func(ctx *beehive.Context) beehive.Responder {
+		span, _ := FromContext(ctx)
+		span.SetAttribute("user.id", 42)
+
+		header := http.Header{}
+		Inject(ctx, header)
+		return &beehive.DefaultResponder{Message: header.Get("Traceparent"), Status: http.StatusOK}
+	})
+	router.Handle("GET", "/fail", func(_ *beehive.Context) beehive.Responder {
+		return &beehive.DefaultResponder{Message: "fail", Status: http.StatusBadGateway}
+	})
+	router.Handle("GET", "/panic", func(_ *beehive.Context) beehive.Responder {
+		panic("boom")
+	})
+
+	return router
+}
+
+func TestConfig_HandlerFunc(t *testing.T) {
+	t.Parallel()
+
+	exporter := &MemoryExporter{}
+	now := time.Unix(1700000000, 0)
+	router := newTestRouter(&Config{
+		Exporter: exporter,
+		Now: func() time.Time {
+			now = now.Add(time.Millisecond)
+			return now
+		},
+	})
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", "/users/42", nil))
+
+	spans := exporter.Spans()
+	if len(spans) != 1 {
+		t.Fatalf("expected %d span, got %d", 1, len(spans))
+	}
+
+	span := spans[0]
+	if span.Name != "/users/*" || span.Status != http.StatusOK || span.Error || span.Parent.IsValid() {
+		t.Errorf("unexpected span %+v", span)
+	}
+	if !span.Context.IsValid() || !span.Context.Sampled() {
+		t.Errorf("expected a valid sampled span context, got %+v", span.Context)
+	}
+	if span.End.Sub(span.Start) != time.Millisecond {
+		t.Errorf("expected duration %s, got %s", time.Millisecond, span.End.Sub(span.Start))
+	}
+	if w.Body.String() != span.Context.Traceparent() {
+		t.Errorf("expected injected traceparent %s, got %s", span.Context.Traceparent(), w.Body.String())
+	}
+
+	attributes := map[string]any{
+		"http.request.method":       "GET",
+		"http.route":                "/users/*",
+		"url.path":                  "/users/42",
+		"http.response.status_code": int64(200),
+		"user.id":                   int64(42),
+	}
+	for k, v := range attributes {
+		if span.Attributes[k] != v {
+			t.Errorf("expected attribute %s %v, got %v", k, v, span.Attributes[k])
+		}
+	}
+}
+
+func TestConfig_HandlerFunc_parent(t *testing.T) {
+	t.Parallel()
+
+	exporter := &MemoryExporter{}
+	router := newTestRouter(&Config{Exporter: exporter})
+
+	r := httptest.NewRequest("GET", "/users/42", nil)
+	r.Header.Set("Traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	r.Header.Add("Tracestate", "rojo=00f067aa0ba902b7")
+	r.Header.Add("Tracestate", "congo=t61rcWkgMzE")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, r)
+
+	spans := exporter.Spans()
+	if len(spans) != 1 {
+		t.Fatalf("expected %d span, got %d", 1, len(spans))
+	}
+
+	span := spans[0]
+	if span.Context.TraceID.String() != "4bf92f3577b34da6a3ce929d0e0e4736" || span.Parent.String() != "00f067aa0ba902b7" {
+		t.Errorf("expected span to continue the trace, got %+v", span)
+	}
+	if span.Context.SpanID == span.Parent {
+		t.Errorf("expected a new span ID")
+	}
+	if span.Context.State.String() != "rojo=00f067aa0ba902b7,congo=t61rcWkgMzE" {
+		t.Errorf("unexpected tracestate %s", span.Context.State)
+	}
+
+	// the caller decided not to sample
+	r.Header.Set("Traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-00")
+	router.ServeHTTP(httptest.NewRecorder(), r)
+	if len(exporter.Spans()) != 1 {
+		t.Errorf("expected unsampled span not to be exported")
+	}
+}
+
+func TestConfig_HandlerFunc_errors(t *testing.T) {
+	t.Parallel()
+
+	exporter := &MemoryExporter{}
+	router := newTestRouter(&Config{
+		Exporter: exporter,
+		Sample: func(ctx *beehive.Context) bool {
+			return ctx.Route() != "/users/*"
+		},
+	})
+
+	for _, path := range []string{"/users/1", "/fail", "/panic"} {
+		router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", path, nil))
+	}
+
+	spans := exporter.Spans()
+	if len(spans) != 2 {
+		t.Fatalf("expected %d spans, got %d", 2, len(spans))
+	}
+	for idx, status := range []int{http.StatusBadGateway, http.StatusInternalServerError} {
+		if spans[idx].Status != status || !spans[idx].Error || spans[idx].End.IsZero() {
+			t.Errorf("expected failed span with status %d, got %+v", status, spans[idx])
+		}
+	}
+}
+
+func TestConfig_HandlerFunc_noExporter(t *testing.T) {
+	t.Parallel()
+
+	defer func() {
+		if recover() == nil {
+			t.Errorf("expected panic")
+		}
+	}()
+
+	(&Config{}).HandlerFunc()
+}
@@ -0,0 +1,136 @@
+package beehive_trace
+
+import (
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"math/rand/v2"
+)
+
+// ErrInvalidTraceparent is returned by ParseTraceparent for malformed traceparent headers.
+var ErrInvalidTraceparent = errors.New("beehive-trace: invalid traceparent")
+
+// TraceID identifies a trace, it is valid when it is not all zeros.
+type TraceID [16]byte
+
+// String returns the lower case hex encoding of the TraceID.
+func (id TraceID) String() string {
+	return hex.EncodeToString(id[:])
+}
+
+// IsValid reports whether the TraceID is not all zeros.
+func (id TraceID) IsValid() bool {
+	return id != TraceID{}
+}
+
+// SpanID identifies a span, it is valid when it is not all zeros.
+type SpanID [8]byte
+
+// String returns the lower case hex encoding of the SpanID.
+func (id SpanID) String() string {
+	return hex.EncodeToString(id[:])
+}
+
+// IsValid reports whether the SpanID is not all zeros.
+func (id SpanID) IsValid() bool {
+	return id != SpanID{}
+}
+
+// FlagSampled is the trace flag set when the caller may have recorded the trace.
+const FlagSampled byte = 0x01
+
+// SpanContext is the part of a span propagated between services, as carried by the traceparent and tracestate
+// headers.
+type SpanContext struct {
+	TraceID TraceID
+	SpanID  SpanID
+	Flags   byte
+	State   Tracestate
+}
+
+// IsValid reports whether both the TraceID and the SpanID are valid.
+func (sc SpanContext) IsValid() bool {
+	return sc.TraceID.IsValid() && sc.SpanID.IsValid()
+}
+
+// Sampled reports whether FlagSampled is set.
+func (sc SpanContext) Sampled() bool {
+	return sc.Flags&FlagSampled != 0
+}
+
+// Traceparent returns the version 00 traceparent header value of the SpanContext.
+func (sc SpanContext) Traceparent() string {
+	const hexDigits = "0123456789abcdef"
+
+	b := make([]byte, 0, 55)
+	b = append(b, "00-"...)
+	b = hex.AppendEncode(b, sc.TraceID[:])
+	b = append(b, '-')
+	b = hex.AppendEncode(b, sc.SpanID[:])
+	b = append(b, '-', hexDigits[sc.Flags>>4], hexDigits[sc.Flags&0xf])
+
+	return string(b)
+}
+
+// ParseTraceparent parses a traceparent header value as defined by the W3C Trace Context recommendation. Versions
+// higher than 00 are parsed as far as version 00 is defined, as required for forward compatibility.
+func ParseTraceparent(s string) (SpanContext, error) {
+	var sc SpanContext
+
+	if len(s) < 55 || s[2] != '-' || s[35] != '-' || s[52] != '-' {
+		return sc, ErrInvalidTraceparent
+	}
+
+	var version [1]byte
+	if !decodeHex(version[:], s[0:2]) || version[0] == 0xff {
+		return sc, ErrInvalidTraceparent
+	}
+	if version[0] == 0 && len(s) != 55 {
+		return sc, ErrInvalidTraceparent
+	}
+	if version[0] != 0 && len(s) > 55 && s[55] != '-' {
+		return sc, ErrInvalidTraceparent
+	}
+
+	var flags [1]byte
+	if !decodeHex(sc.TraceID[:], s[3:35]) || !decodeHex(sc.SpanID[:], s[36:52]) || !decodeHex(flags[:], s[53:55]) {
+		return sc, ErrInvalidTraceparent
+	}
+	sc.Flags = flags[0]
+
+	if !sc.IsValid() {
+		return sc, ErrInvalidTraceparent
+	}
+
+	return sc, nil
+}
+
+// decodeHex decodes lower case hex only, as required by the traceparent format.
+func decodeHex(dst []byte, s string) bool {
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if ('0' > c || c > '9') && ('a' > c || c > 'f') {
+			return false
+		}
+	}
+
+	_, err := hex.Decode(dst, []byte(s))
+	return err == nil
+}
+
+func newTraceID() TraceID {
+	var id TraceID
+	for !id.IsValid() {
+		binary.BigEndian.PutUint64(id[0:8], rand.Uint64())  //nolint:gosec
+		binary.BigEndian.PutUint64(id[8:16], rand.Uint64()) //nolint:gosec
+	}
+	return id
+}
+
+func newSpanID() SpanID {
+	var id SpanID
+	for !id.IsValid() {
+		binary.BigEndian.PutUint64(id[:], rand.Uint64()) //nolint:gosec
+	}
+	return id
+}
@@ -0,0 +1,60 @@
+package beehive_trace
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestParseTraceparent(t *testing.T) {
+	t.Parallel()
+
+	valid := map[string]string{
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01":       "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-00":       "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-00",
+		"01-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-09-extra": "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-09",
+	}
+
+	for s, want := range valid {
+		sc, err := ParseTraceparent(s)
+		if err != nil {
+			t.Errorf("%s: unexpected error %v", s, err)
+			continue
+		}
+		if sc.Traceparent() != want {
+			t.Errorf("%s: expected %s, got %s", s, want, sc.Traceparent())
+		}
+	}
+
+	invalid := []string{
+		"",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-extra",
+		"ff-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01",
+		"01-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01extra",
+		"00-4BF92F3577B34DA6A3CE929D0E0E4736-00f067aa0ba902b7-01",
+		"00-00000000000000000000000000000000-00f067aa0ba902b7-01",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-0000000000000000-01",
+		"00_4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-0g",
+	}
+
+	for _, s := range invalid {
+		if _, err := ParseTraceparent(s); !errors.Is(err, ErrInvalidTraceparent) {
+			t.Errorf("%q: expected %v, got %v", s, ErrInvalidTraceparent, err)
+		}
+	}
+}
+
+func TestSpanContext_Sampled(t *testing.T) {
+	t.Parallel()
+
+	sc, _ := ParseTraceparent("00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-09")
+	if !sc.Sampled() {
+		t.Errorf("expected sampled flag to be set")
+	}
+
+	sc, _ = ParseTraceparent("00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-02")
+	if sc.Sampled() {
+		t.Errorf("expected sampled flag not to be set")
+	}
+}
@@ -0,0 +1,139 @@
+package beehive_trace
+
+import (
+	"errors"
+	"strings"
+)
+
+// ErrInvalidTracestate is returned by ParseTracestate for malformed tracestate headers.
+var ErrInvalidTracestate = errors.New("beehive-trace: invalid tracestate")
+
+// maxTracestateMembers is the maximum number of list members allowed in a tracestate.
+const maxTracestateMembers = 32
+
+// TracestateMember is a single key value pair of a Tracestate.
+type TracestateMember struct {
+	Key   string
+	Value string
+}
+
+// Tracestate is the vendor specific trace information carried by the tracestate header, ordered from the most
+// recently updated member.
+type Tracestate []TracestateMember
+
+// ParseTracestate parses a tracestate header value. Multiple header values must be joined with a comma first. Empty
+// list members are ignored.
+func ParseTracestate(s string) (Tracestate, error) {
+	var ts Tracestate
+
+	for member := range strings.SplitSeq(s, ",") {
+		member = strings.Trim(member, " \t")
+		if member == "" {
+			continue
+		}
+
+		key, value, found := strings.Cut(member, "=")
+		if !found || !validTracestateKey(key) || !validTracestateValue(value) {
+			return nil, ErrInvalidTracestate
+		}
+
+		if ts.Get(key) != "" {
+			return nil, ErrInvalidTracestate
+		}
+
+		ts = append(ts, TracestateMember{Key: key, Value: value})
+		if len(ts) > maxTracestateMembers {
+			return nil, ErrInvalidTracestate
+		}
+	}
+
+	return ts, nil
+}
+
+// Get returns the value of the given key, or an empty string if the key is not present.
+func (ts Tracestate) Get(key string) string {
+	for _, member := range ts {
+		if member.Key == key {
+			return member.Value
+		}
+	}
+	return ""
+}
+
+// Insert returns a new Tracestate with key set to value as the first member, as expected when a vendor updates its
+// entry. The last member is dropped when the Tracestate is full.
+func (ts Tracestate) Insert(key, value string) (Tracestate, error) {
+	if !validTracestateKey(key) || !validTracestateValue(value) {
+		return nil, ErrInvalidTracestate
+	}
+
+	res := make(Tracestate, 1, len(ts)+1)
+	res[0] = TracestateMember{Key: key, Value: value}
+	for _, member := range ts {
+		if member.Key != key && len(res) < maxTracestateMembers {
+			res = append(res, member)
+		}
+	}
+
+	return res, nil
+}
+
+// String returns the tracestate header value.
+func (ts Tracestate) String() string {
+	var sb strings.Builder
+	for idx, member := range ts {
+		if idx > 0 {
+			sb.WriteByte(',')
+		}
+		sb.WriteString(member.Key)
+		sb.WriteByte('=')
+		sb.WriteString(member.Value)
+	}
+	return sb.String()
+}
+
+// validTracestateKey reports whether key is a simple-key or a multi-tenant tenant-id@system-id key.
+func validTracestateKey(key string) bool {
+	tenant, system, multi := strings.Cut(key, "@")
+	if !multi {
+		return len(key) <= 256 && validKeyPart(key, true)
+	}
+
+	return len(tenant) <= 241 && len(system) <= 14 && validKeyPart(tenant, false) && validKeyPart(system, true)
+}
+
+func validKeyPart(s string, lcalphaFirst bool) bool {
+	if s == "" {
+		return false
+	}
+
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case 'a' <= c && c <= 'z':
+		case '0' <= c && c <= '9', c == '_', c == '-', c == '*', c == '/':
+			if i == 0 && (lcalphaFirst || c == '_' || c == '-' || c == '*' || c == '/') {
+				return false
+			}
+		default:
+			return false
+		}
+	}
+
+	return true
+}
+
+func validTracestateValue(value string) bool {
+	if value == "" || len(value) > 256 || value[len(value)-1] == ' ' {
+		return false
+	}
+
+	for i := 0; i < len(value); i++ {
+		c := value[i]
+		if c < 0x20 || c > 0x7e || c == ',' || c == '=' {
+			return false
+		}
+	}
+
+	return true
+}
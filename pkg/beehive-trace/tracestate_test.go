@@ -0,0 +1,65 @@
+package beehive_trace
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestParseTracestate(t *testing.T) {
+	t.Parallel()
+
+	ts, err := ParseTracestate("rojo=00f067aa0ba902b7, ,congo=t61rcWkgMzE,tenant@vendor=a b")
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if ts.String() != "rojo=00f067aa0ba902b7,congo=t61rcWkgMzE,tenant@vendor=a b" {
+		t.Errorf("unexpected tracestate %s", ts)
+	}
+	if ts.Get("congo") != "t61rcWkgMzE" {
+		t.Errorf("expected %s, got %s", "t61rcWkgMzE", ts.Get("congo"))
+	}
+
+	invalid := []string{
+		"Rojo=1",
+		"rojo",
+		"rojo=",
+		"rojo=a,rojo=b",
+		"rojo=a=b",
+		"@vendor=1",
+		"tenant@toolongsystemid=1",
+	}
+
+	for _, s := range invalid {
+		if _, err := ParseTracestate(s); !errors.Is(err, ErrInvalidTracestate) {
+			t.Errorf("%q: expected %v, got %v", s, ErrInvalidTracestate, err)
+		}
+	}
+
+	members := make([]string, 33)
+	for idx := range members {
+		members[idx] = fmt.Sprintf("k%d=v", idx)
+	}
+	if _, err := ParseTracestate(strings.Join(members, ",")); !errors.Is(err, ErrInvalidTracestate) {
+		t.Errorf("expected too many members to be rejected, got %v", err)
+	}
+}
+
+func TestTracestate_Insert(t *testing.T) {
+	t.Parallel()
+
+	ts, _ := ParseTracestate("rojo=1,congo=2")
+
+	ts, err := ts.Insert("congo", "3")
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if ts.String() != "congo=3,rojo=1" {
+		t.Errorf("expected %s, got %s", "congo=3,rojo=1", ts)
+	}
+
+	if _, err := ts.Insert("Bad", "1"); !errors.Is(err, ErrInvalidTracestate) {
+		t.Errorf("expected %v, got %v", ErrInvalidTracestate, err)
+	}
+}
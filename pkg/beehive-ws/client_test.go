@@ -0,0 +1,230 @@
+package beehive_ws
+
+import (
+	"bufio"
+	"bytes"
+	"compress/flate"
+	"encoding/binary"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"go.sdls.io/beehive/pkg/beehive"
+)
+
+const testKey = "dGhlIHNhbXBsZSBub25jZQ=="
+
+// testClient is a minimal WebSocket client writing raw frames, such that protocol violations can be tested.
+type testClient struct {
+	t    *testing.T
+	conn net.Conn
+	br   *bufio.Reader
+	res  *http.Response
+}
+
+type testFrame struct {
+	fin     bool
+	rsv     byte
+	opcode  byte
+	payload []byte
+}
+
+// newTestServer serves the Upgrader on /ws with an echo handler.
+func newTestServer(t *testing.T, u *Upgrader) *httptest.Server {
+	return newTestServerFunc(t, u, func(conn *Conn) {
+		for {
+			typ, data, err := conn.ReadMessage()
+			if err != nil {
+				return
+			}
+			if err := conn.WriteMessage(typ, data); err != nil {
+				return
+			}
+		}
+	})
+}
+
+func newTestServerFunc(t *testing.T, u *Upgrader, f func(conn *Conn)) *httptest.Server {
+	router := beehive.NewRouter()
+	router.Handle("GET", "/ws", u.HandlerFunc(f))
+
+	server := httptest.NewServer(router)
+	t.Cleanup(server.Close)
+
+	return server
+}
+
+// dial performs the handshake with the given extra headers.
+func dial(t *testing.T, server *httptest.Server, header map[string]string) *testClient {
+	t.Helper()
+
+	conn, err := net.Dial("tcp", server.Listener.Addr().String())
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	t.Cleanup(func() { _ = conn.Close() })
+	_ = conn.SetDeadline(time.Now().Add(5 * time.Second))
+
+	req, _ := http.NewRequest("GET", server.URL+"/ws", nil)
+	req.Header.Set("Connection", "Upgrade")
+	req.Header.Set("Upgrade", "websocket")
+	req.Header.Set("Sec-WebSocket-Version", "13")
+	req.Header.Set("Sec-WebSocket-Key", testKey)
+	for key, value := range header {
+		if value == "" {
+			req.Header.Del(key)
+		} else {
+			req.Header.Set(key, value)
+		}
+	}
+
+	if err := req.Write(conn); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+
+	br := bufio.NewReader(conn)
+	res, err := http.ReadResponse(br, req)
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+
+	return &testClient{t: t, conn: conn, br: br, res: res}
+}
+
+func (c *testClient) writeFrame(f testFrame) {
+	c.writeRawFrame(f, true)
+}
+
+func (c *testClient) writeRawFrame(f testFrame, masked bool) {
+	c.t.Helper()
+
+	first := f.rsv | f.opcode
+	if f.fin {
+		first |= bitFin
+	}
+
+	var maskBit byte
+	if masked {
+		maskBit = bitMask
+	}
+
+	b := []byte{first}
+	switch n := len(f.payload); {
+	case n <= 125:
+		b = append(b, maskBit|byte(n))
+	case n <= 0xffff:
+		b = binary.BigEndian.AppendUint16(append(b, maskBit|126), uint16(n))
+	default:
+		b = binary.BigEndian.AppendUint64(append(b, maskBit|127), uint64(n))
+	}
+
+	payload := append([]byte(nil), f.payload...)
+	if masked {
+		mask := [4]byte{0x12, 0x34, 0x56, 0x78}
+		b = append(b, mask[:]...)
+		maskBytes(mask, 0, payload)
+	}
+
+	if _, err := c.conn.Write(append(b, payload...)); err != nil {
+		c.t.Fatalf("unexpected error %v", err)
+	}
+}
+
+func (c *testClient) readFrame() (testFrame, error) {
+	h, err := readFrameHeader(c.br)
+	if err != nil {
+		return testFrame{}, err
+	}
+	if h.masked {
+		c.t.Errorf("expected server frames to be unmasked")
+	}
+
+	payload := make([]byte, h.length)
+	if _, err := io.ReadFull(c.br, payload); err != nil {
+		return testFrame{}, err
+	}
+
+	return testFrame{fin: h.fin, rsv: h.rsv, opcode: h.opcode, payload: payload}, nil
+}
+
+// readMessage reads a data message, reassembling fragments and decompressing it.
+func (c *testClient) readMessage() (byte, []byte) {
+	c.t.Helper()
+
+	var (
+		opcode     byte
+		message    []byte
+		compressed bool
+	)
+
+	for {
+		f, err := c.readFrame()
+		if err != nil {
+			c.t.Fatalf("unexpected error %v", err)
+		}
+
+		if f.opcode != opContinuation {
+			opcode, compressed = f.opcode, f.rsv&bitRsv1 != 0
+		}
+		message = append(message, f.payload...)
+
+		if f.fin {
+			break
+		}
+	}
+
+	if compressed {
+		data, err := io.ReadAll(flate.NewReader(io.MultiReader(bytes.NewReader(message), bytes.NewReader(deflateTail))))
+		if err != nil {
+			c.t.Fatalf("unexpected error %v", err)
+		}
+		message = data
+	}
+
+	return opcode, message
+}
+
+// expectClose reads the close frame of the server and expects the connection to be closed.
+func (c *testClient) expectClose(code StatusCode) {
+	c.t.Helper()
+
+	for {
+		f, err := c.readFrame()
+		if err != nil {
+			c.t.Fatalf("expected close %d, got %v", code, err)
+		}
+		if f.opcode != opClose {
+			continue
+		}
+
+		got := StatusCode(0)
+		if len(f.payload) >= 2 {
+			got = StatusCode(binary.BigEndian.Uint16(f.payload))
+		}
+		if got != code {
+			c.t.Errorf("expected close %d, got %d", code, got)
+		}
+		break
+	}
+
+	if _, err := c.br.ReadByte(); err != io.EOF {
+		c.t.Errorf("expected the server to close the connection, got %v", err)
+	}
+}
+
+func compressTest(t *testing.T, data []byte) []byte {
+	var buf bytes.Buffer
+	w, _ := flate.NewWriter(&buf, flate.BestCompression)
+	_, _ = w.Write(data)
+	if err := w.Flush(); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	return bytes.TrimSuffix(buf.Bytes(), deflateTail[:4])
+}
+
+func closePayload(code StatusCode, reason string) []byte {
+	return append(binary.BigEndian.AppendUint16(nil, uint16(code)), reason...)
+}
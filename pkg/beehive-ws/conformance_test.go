@@ -0,0 +1,366 @@
+package beehive_ws
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+// The conformance cases follow the sections of the Autobahn test suite, they run against the echo server of
+// newTestServer with a raw frame client.
+
+func TestConformance_Framing(t *testing.T) {
+	t.Parallel()
+
+	server := newTestServer(t, &Upgrader{ReadLimit: -1})
+
+	for _, size := range []int{0, 125, 126, 127, 128, 65535, 65536, 1 << 20} {
+		for _, opcode := range []byte{opText, opBinary} {
+			c := dial(t, server, nil)
+			payload := bytes.Repeat([]byte{'*'}, size)
+
+			c.writeFrame(testFrame{fin: true, opcode: opcode, payload: payload})
+
+			gotOpcode, got := c.readMessage()
+			if gotOpcode != opcode || !bytes.Equal(got, payload) {
+				t.Errorf("1.%d.%d: expected echo of %d bytes, got opcode %d and %d bytes", opcode, size, size, gotOpcode, len(got))
+			}
+		}
+	}
+}
+
+func TestConformance_PingPong(t *testing.T) {
+	t.Parallel()
+
+	server := newTestServer(t, &Upgrader{})
+
+	tests := []struct {
+		id      string
+		payload []byte
+	}{
+		{"2.1", nil},
+		{"2.2", []byte("hello")},
+		{"2.3", []byte{0x00, 0xff, 0xfe, 0xfd}},
+		{"2.4", bytes.Repeat([]byte{0xfe}, 125)},
+	}
+
+	for _, test := range tests {
+		c := dial(t, server, nil)
+		c.writeFrame(testFrame{fin: true, opcode: opPing, payload: test.payload})
+
+		f, err := c.readFrame()
+		if err != nil || f.opcode != opPong || !bytes.Equal(f.payload, test.payload) {
+			t.Errorf("%s: expected pong with the ping payload, got %+v %v", test.id, f, err)
+		}
+	}
+
+	t.Run("2.5 ping too large", func(t *testing.T) {
+		c := dial(t, server, nil)
+		c.writeFrame(testFrame{fin: true, opcode: opPing, payload: bytes.Repeat([]byte{0xfe}, 126)})
+		c.expectClose(StatusProtocolError)
+	})
+
+	t.Run("2.6 unsolicited pong", func(t *testing.T) {
+		c := dial(t, server, nil)
+		c.writeFrame(testFrame{fin: true, opcode: opPong, payload: []byte("unsolicited")})
+		c.writeFrame(testFrame{fin: true, opcode: opText, payload: []byte("after")})
+
+		if _, got := c.readMessage(); string(got) != "after" {
+			t.Errorf("expected %q, got %q", "after", got)
+		}
+	})
+
+	t.Run("2.10 ping flood", func(t *testing.T) {
+		c := dial(t, server, nil)
+		for range 10 {
+			c.writeFrame(testFrame{fin: true, opcode: opPing, payload: []byte("ping")})
+		}
+		for range 10 {
+			if f, err := c.readFrame(); err != nil || f.opcode != opPong {
+				t.Fatalf("expected pong, got %+v %v", f, err)
+			}
+		}
+	})
+}
+
+func TestConformance_ProtocolErrors(t *testing.T) {
+	t.Parallel()
+
+	server := newTestServer(t, &Upgrader{})
+
+	tests := []struct {
+		id     string
+		frames []testFrame
+		masked bool
+	}{
+		{"3.1 rsv1 without extension", []testFrame{{fin: true, rsv: bitRsv1, opcode: opText, payload: []byte("x")}}, true},
+		{"3.2 rsv2", []testFrame{{fin: true, rsv: bitRsv2, opcode: opText, payload: []byte("x")}}, true},
+		{"3.3 rsv3", []testFrame{{fin: true, rsv: bitRsv3, opcode: opBinary}}, true},
+		{"3.4 rsv on ping", []testFrame{{fin: true, rsv: bitRsv1 | bitRsv2 | bitRsv3, opcode: opPing}}, true},
+		{"4.1.1 reserved opcode 3", []testFrame{{fin: true, opcode: 0x3}}, true},
+		{"4.1.5 reserved opcode 7", []testFrame{{fin: true, opcode: 0x7, payload: []byte("x")}}, true},
+		{"4.2.1 reserved opcode 11", []testFrame{{fin: true, opcode: 0xb}}, true},
+		{"4.2.5 reserved opcode 15", []testFrame{{fin: true, opcode: 0xf, payload: []byte("x")}}, true},
+		{"5.1 fragmented ping", []testFrame{{opcode: opPing, payload: []byte("a")}, {fin: true, opcode: opContinuation, payload: []byte("b")}}, true},
+		{"5.2 fragmented pong", []testFrame{{opcode: opPong, payload: []byte("a")}}, true},
+		{"5.9 continuation without message", []testFrame{{fin: true, opcode: opContinuation, payload: []byte("x")}}, true},
+		{"5.18 text during fragmented text", []testFrame{{opcode: opText, payload: []byte("a")}, {fin: true, opcode: opText, payload: []byte("b")}}, true},
+		{"unmasked frame", []testFrame{{fin: true, opcode: opText, payload: []byte("x")}}, false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.id, func(t *testing.T) {
+			c := dial(t, server, nil)
+			for _, f := range test.frames {
+				c.writeRawFrame(f, test.masked)
+			}
+			c.expectClose(StatusProtocolError)
+		})
+	}
+}
+
+func TestConformance_Fragmentation(t *testing.T) {
+	t.Parallel()
+
+	server := newTestServer(t, &Upgrader{})
+
+	t.Run("5.3 fragmented text", func(t *testing.T) {
+		c := dial(t, server, nil)
+		c.writeFrame(testFrame{opcode: opText, payload: []byte("frag")})
+		c.writeFrame(testFrame{opcode: opContinuation, payload: nil})
+		c.writeFrame(testFrame{fin: true, opcode: opContinuation, payload: []byte("ment")})
+
+		if opcode, got := c.readMessage(); opcode != opText || string(got) != "fragment" {
+			t.Errorf("expected %q, got %q", "fragment", got)
+		}
+	})
+
+	t.Run("5.6 ping between fragments", func(t *testing.T) {
+		c := dial(t, server, nil)
+		c.writeFrame(testFrame{opcode: opBinary, payload: []byte("frag")})
+		c.writeFrame(testFrame{fin: true, opcode: opPing, payload: []byte("ping")})
+		c.writeFrame(testFrame{fin: true, opcode: opContinuation, payload: []byte("ment")})
+
+		if f, err := c.readFrame(); err != nil || f.opcode != opPong || string(f.payload) != "ping" {
+			t.Fatalf("expected pong, got %+v %v", f, err)
+		}
+		if opcode, got := c.readMessage(); opcode != opBinary || string(got) != "fragment" {
+			t.Errorf("expected %q, got %q", "fragment", got)
+		}
+	})
+
+	t.Run("server fragmentation", func(t *testing.T) {
+		server := newTestServer(t, &Upgrader{FragmentSize: 3})
+		c := dial(t, server, nil)
+		c.writeFrame(testFrame{fin: true, opcode: opText, payload: []byte("fragment")})
+
+		var fragments []string
+		for {
+			f, err := c.readFrame()
+			if err != nil {
+				t.Fatalf("unexpected error %v", err)
+			}
+			fragments = append(fragments, string(f.payload))
+			if f.fin {
+				break
+			}
+		}
+
+		if strings.Join(fragments, "|") != "fra|gme|nt" {
+			t.Errorf("expected %q, got %q", "fra|gme|nt", strings.Join(fragments, "|"))
+		}
+	})
+}
+
+func TestConformance_UTF8(t *testing.T) {
+	t.Parallel()
+
+	server := newTestServer(t, &Upgrader{})
+
+	valid := []byte("κόσμε €𝄞")
+
+	t.Run("6.2 valid utf-8 split across fragments", func(t *testing.T) {
+		c := dial(t, server, nil)
+		for idx := range valid {
+			opcode := byte(opContinuation)
+			if idx == 0 {
+				opcode = opText
+			}
+			c.writeFrame(testFrame{fin: idx == len(valid)-1, opcode: opcode, payload: valid[idx : idx+1]})
+		}
+
+		if _, got := c.readMessage(); !bytes.Equal(got, valid) {
+			t.Errorf("expected %q, got %q", valid, got)
+		}
+	})
+
+	for _, test := range []struct {
+		id      string
+		payload []byte
+	}{
+		{"6.3.1 invalid sequence", []byte{0xce, 0xba, 0xe1, 0xbd, 0xb9, 0xcf, 0x83, 0xce, 0xbc, 0xce, 0xb5, 0xed, 0xa0, 0x80}},
+		{"6.6.1 lone continuation byte", []byte{0x80}},
+		{"6.8.1 truncated sequence", []byte{0xf4, 0x90, 0x80}},
+	} {
+		t.Run(test.id, func(t *testing.T) {
+			c := dial(t, server, nil)
+			c.writeFrame(testFrame{fin: true, opcode: opText, payload: test.payload})
+			c.expectClose(StatusInvalidFramePayloadData)
+		})
+	}
+
+	t.Run("binary messages are not validated", func(t *testing.T) {
+		c := dial(t, server, nil)
+		c.writeFrame(testFrame{fin: true, opcode: opBinary, payload: []byte{0x80}})
+		if _, got := c.readMessage(); !bytes.Equal(got, []byte{0x80}) {
+			t.Errorf("expected %q, got %q", []byte{0x80}, got)
+		}
+	})
+}
+
+func TestConformance_Close(t *testing.T) {
+	t.Parallel()
+
+	server := newTestServer(t, &Upgrader{})
+
+	t.Run("7.1.1 close after echo", func(t *testing.T) {
+		c := dial(t, server, nil)
+		c.writeFrame(testFrame{fin: true, opcode: opText, payload: []byte("hello")})
+		c.writeFrame(testFrame{fin: true, opcode: opClose, payload: closePayload(StatusNormalClosure, "")})
+
+		if _, got := c.readMessage(); string(got) != "hello" {
+			t.Errorf("expected %q, got %q", "hello", got)
+		}
+		c.expectClose(StatusNormalClosure)
+	})
+
+	t.Run("7.1.3 data after close", func(t *testing.T) {
+		c := dial(t, server, nil)
+		c.writeFrame(testFrame{fin: true, opcode: opClose, payload: closePayload(StatusNormalClosure, "bye")})
+		c.writeFrame(testFrame{fin: true, opcode: opText, payload: []byte("ignored")})
+		c.expectClose(StatusNormalClosure)
+	})
+
+	t.Run("7.3.1 empty close", func(t *testing.T) {
+		c := dial(t, server, nil)
+		c.writeFrame(testFrame{fin: true, opcode: opClose})
+
+		f, err := c.readFrame()
+		if err != nil || f.opcode != opClose || len(f.payload) != 0 {
+			t.Errorf("expected an empty close, got %+v %v", f, err)
+		}
+	})
+
+	t.Run("7.3.2 one byte close", func(t *testing.T) {
+		c := dial(t, server, nil)
+		c.writeFrame(testFrame{fin: true, opcode: opClose, payload: []byte{0x03}})
+		c.expectClose(StatusProtocolError)
+	})
+
+	t.Run("7.5.1 invalid utf-8 reason", func(t *testing.T) {
+		c := dial(t, server, nil)
+		c.writeFrame(testFrame{fin: true, opcode: opClose, payload: append(closePayload(StatusNormalClosure, ""), 0xce, 0xba, 0xed, 0xa0, 0x80)})
+		c.expectClose(StatusInvalidFramePayloadData)
+	})
+
+	for _, code := range []StatusCode{1000, 1001, 1002, 1003, 1007, 1008, 1009, 1010, 1011, 3000, 3999, 4000, 4999} {
+		c := dial(t, server, nil)
+		c.writeFrame(testFrame{fin: true, opcode: opClose, payload: closePayload(code, "reason")})
+		c.expectClose(code)
+	}
+
+	for _, code := range []StatusCode{0, 999, 1004, 1005, 1006, 1012, 1015, 1016, 2000, 2999, 5000, 65535} {
+		c := dial(t, server, nil)
+		c.writeFrame(testFrame{fin: true, opcode: opClose, payload: closePayload(code, "")})
+		c.expectClose(StatusProtocolError)
+	}
+}
+
+func TestConformance_ReadLimit(t *testing.T) {
+	t.Parallel()
+
+	server := newTestServer(t, &Upgrader{ReadLimit: 16})
+
+	t.Run("single frame", func(t *testing.T) {
+		c := dial(t, server, nil)
+		c.writeFrame(testFrame{fin: true, opcode: opBinary, payload: make([]byte, 17)})
+		c.expectClose(StatusMessageTooBig)
+	})
+
+	t.Run("fragments", func(t *testing.T) {
+		c := dial(t, server, nil)
+		c.writeFrame(testFrame{opcode: opBinary, payload: make([]byte, 10)})
+		c.writeFrame(testFrame{fin: true, opcode: opContinuation, payload: make([]byte, 10)})
+		c.expectClose(StatusMessageTooBig)
+	})
+
+	t.Run("at the limit", func(t *testing.T) {
+		c := dial(t, server, nil)
+		c.writeFrame(testFrame{fin: true, opcode: opBinary, payload: make([]byte, 16)})
+		if _, got := c.readMessage(); len(got) != 16 {
+			t.Errorf("expected %d bytes, got %d", 16, len(got))
+		}
+	})
+}
+
+func TestConformance_Compression(t *testing.T) {
+	t.Parallel()
+
+	server := newTestServer(t, &Upgrader{Compression: true, ReadLimit: 1 << 16})
+	offer := map[string]string{"Sec-WebSocket-Extensions": "permessage-deflate; client_max_window_bits"}
+
+	t.Run("12.1 echo", func(t *testing.T) {
+		for _, size := range []int{0, 16, 127, 128, 4096, 65536} {
+			c := dial(t, server, offer)
+			payload := []byte(strings.Repeat("compress me ", size/12+1)[:size])
+
+			c.writeFrame(testFrame{fin: true, rsv: bitRsv1, opcode: opText, payload: compressTest(t, payload)})
+
+			if _, got := c.readMessage(); !bytes.Equal(got, payload) {
+				t.Errorf("%d: expected echo of %d bytes, got %d", size, size, len(got))
+			}
+		}
+	})
+
+	t.Run("13.1 uncompressed messages", func(t *testing.T) {
+		c := dial(t, server, offer)
+		c.writeFrame(testFrame{fin: true, opcode: opText, payload: []byte("plain")})
+
+		f, err := c.readFrame()
+		if err != nil || f.rsv != 0 || string(f.payload) != "plain" {
+			t.Errorf("expected an uncompressed small echo, got %+v %v", f, err)
+		}
+	})
+
+	t.Run("compressed fragments", func(t *testing.T) {
+		c := dial(t, server, offer)
+		compressed := compressTest(t, []byte(strings.Repeat("abc", 100)))
+		half := len(compressed) / 2
+
+		c.writeFrame(testFrame{rsv: bitRsv1, opcode: opText, payload: compressed[:half]})
+		c.writeFrame(testFrame{fin: true, opcode: opContinuation, payload: compressed[half:]})
+
+		if _, got := c.readMessage(); string(got) != strings.Repeat("abc", 100) {
+			t.Errorf("expected the decompressed message, got %q", got)
+		}
+	})
+
+	t.Run("rsv1 on continuation", func(t *testing.T) {
+		c := dial(t, server, offer)
+		c.writeFrame(testFrame{rsv: bitRsv1, opcode: opText, payload: compressTest(t, []byte("a"))})
+		c.writeFrame(testFrame{fin: true, rsv: bitRsv1, opcode: opContinuation})
+		c.expectClose(StatusProtocolError)
+	})
+
+	t.Run("decompression bomb", func(t *testing.T) {
+		c := dial(t, server, offer)
+		c.writeFrame(testFrame{fin: true, rsv: bitRsv1, opcode: opBinary, payload: compressTest(t, make([]byte, 1<<20))})
+		c.expectClose(StatusMessageTooBig)
+	})
+
+	t.Run("invalid deflate data", func(t *testing.T) {
+		c := dial(t, server, offer)
+		c.writeFrame(testFrame{fin: true, rsv: bitRsv1, opcode: opBinary, payload: []byte{0xff, 0xff, 0xff}})
+		c.expectClose(StatusProtocolError)
+	})
+}
@@ -0,0 +1,424 @@
+package beehive_ws
+
+import (
+	"bufio"
+	"bytes"
+	"compress/flate"
+	"context"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+// Conn is a server side WebSocket connection, obtained from Upgrader.Upgrade. A Conn supports one concurrent reader
+// and any number of concurrent writers. Control frames are handled by ReadMessage: pings are answered, and the close
+// handshake is completed, so the connection must be read for them to be processed.
+type Conn struct {
+	conn         net.Conn
+	br           *bufio.Reader
+	bw           *bufio.Writer
+	subprotocol  string
+	deflate      bool
+	readLimit    int64
+	fragmentSize int
+	closeTimeout time.Duration
+
+	ctx    context.Context //nolint:containedctx
+	cancel context.CancelFunc
+
+	readMu  sync.Mutex
+	readErr error
+
+	writeMu       sync.Mutex
+	writeBuf      []byte
+	closeSent     bool
+	deflateWriter *flate.Writer
+	deflateBuf    bytes.Buffer
+
+	pongMu sync.Mutex
+	onPong func(data []byte)
+
+	closeOnce sync.Once
+}
+
+func newConn(
+	ctx context.Context, conn net.Conn, brw *bufio.ReadWriter, u *Upgrader, subprotocol string, deflate bool,
+) *Conn {
+	c := &Conn{
+		conn:         conn,
+		br:           brw.Reader,
+		bw:           brw.Writer,
+		subprotocol:  subprotocol,
+		deflate:      deflate,
+		readLimit:    u.ReadLimit,
+		fragmentSize: u.FragmentSize,
+		closeTimeout: u.CloseTimeout,
+	}
+
+	if c.readLimit == 0 {
+		c.readLimit = DefaultReadLimit
+	}
+	if c.closeTimeout <= 0 {
+		c.closeTimeout = DefaultCloseTimeout
+	}
+
+	c.ctx, c.cancel = context.WithCancel(context.WithoutCancel(ctx))
+
+	// the connection goes away with the request context
+	context.AfterFunc(ctx, func() {
+		_ = c.Close(StatusGoingAway, "")
+	})
+
+	return c
+}
+
+// Context returns a context canceled when the connection is closed. It carries the values of the request context.
+func (c *Conn) Context() context.Context {
+	return c.ctx
+}
+
+// Subprotocol returns the negotiated subprotocol, or an empty string.
+func (c *Conn) Subprotocol() string {
+	return c.subprotocol
+}
+
+// Compressed reports whether the permessage-deflate extension was negotiated.
+func (c *Conn) Compressed() bool {
+	return c.deflate
+}
+
+// NetConn returns the underlying connection, for example to set deadlines.
+func (c *Conn) NetConn() net.Conn {
+	return c.conn
+}
+
+// SetReadLimit sets the maximum size of a message (after decompression), a negative limit disables it. Larger
+// messages close the connection with StatusMessageTooBig.
+func (c *Conn) SetReadLimit(limit int64) {
+	c.readMu.Lock()
+	c.readLimit = limit
+	c.readMu.Unlock()
+}
+
+// OnPong sets the function called with the payload of the received pongs, from ReadMessage.
+func (c *Conn) OnPong(f func(data []byte)) {
+	c.pongMu.Lock()
+	c.onPong = f
+	c.pongMu.Unlock()
+}
+
+// ReadMessage reads the next data message, reassembling fragmented messages. It returns a *CloseError when the peer
+// closed the connection, and ErrClosed once the connection is closed.
+func (c *Conn) ReadMessage() (MessageType, []byte, error) {
+	c.readMu.Lock()
+	defer c.readMu.Unlock()
+
+	if c.readErr != nil {
+		return 0, nil, c.readErr
+	}
+
+	typ, data, err := c.readMessage()
+	if err != nil {
+		c.readErr = ErrClosed
+	}
+
+	return typ, data, err
+}
+
+func (c *Conn) readMessage() (MessageType, []byte, error) {
+	var (
+		message    []byte
+		typ        MessageType
+		compressed bool
+		started    bool
+	)
+
+	for {
+		h, err := readFrameHeader(c.br)
+		if err != nil {
+			return 0, nil, c.abort(err)
+		}
+
+		if err := c.validate(&h, started); err != nil {
+			return 0, nil, err
+		}
+
+		// the read limit applies to the compressed payload as well, as it is buffered too
+		if !h.control() && c.readLimit > 0 && uint64(len(message))+h.length > uint64(c.readLimit) {
+			return 0, nil, c.fail(StatusMessageTooBig, ErrReadLimit)
+		}
+
+		payload := make([]byte, h.length)
+		if _, err := io.ReadFull(c.br, payload); err != nil {
+			return 0, nil, c.abort(err)
+		}
+		maskBytes(h.mask, 0, payload)
+
+		switch h.opcode {
+		case opPing:
+			if err := c.writeFrame(true, 0, opPong, payload); err != nil && !errors.Is(err, ErrClosed) {
+				return 0, nil, c.abort(err)
+			}
+			continue
+		case opPong:
+			c.pongMu.Lock()
+			onPong := c.onPong
+			c.pongMu.Unlock()
+
+			if onPong != nil {
+				onPong(payload)
+			}
+			continue
+		case opClose:
+			return 0, nil, c.receiveClose(payload)
+		case opText, opBinary:
+			typ = MessageType(h.opcode)
+			compressed = h.rsv&bitRsv1 != 0
+			started = true
+		}
+
+		message = append(message, payload...)
+		if !h.fin {
+			continue
+		}
+
+		if compressed {
+			if message, err = decompress(message, c.readLimit); err != nil {
+				if errors.Is(err, ErrReadLimit) {
+					return 0, nil, c.fail(StatusMessageTooBig, err)
+				}
+				return 0, nil, c.fail(StatusProtocolError, err)
+			}
+		}
+
+		if typ == TextMessage && !utf8.Valid(message) {
+			return 0, nil, c.fail(StatusInvalidFramePayloadData, ErrInvalidUTF8)
+		}
+
+		return typ, message, nil
+	}
+}
+
+// validate checks the frame header against the protocol, started reporting whether a fragmented message is being
+// read.
+func (c *Conn) validate(h *frameHeader, started bool) error {
+	switch {
+	case !h.masked:
+		// client frames must be masked
+		return c.fail(StatusProtocolError, ErrProtocol)
+	case h.rsv&(bitRsv2|bitRsv3) != 0:
+		return c.fail(StatusProtocolError, ErrProtocol)
+	case h.rsv&bitRsv1 != 0 && (!c.deflate || h.control() || h.opcode == opContinuation):
+		// the compression bit is only valid on the first frame of a data message
+		return c.fail(StatusProtocolError, ErrProtocol)
+	case h.length > 1<<63-1:
+		return c.fail(StatusProtocolError, ErrProtocol)
+	}
+
+	switch h.opcode {
+	case opPing, opPong, opClose:
+		if !h.fin || h.length > maxControlPayload {
+			return c.fail(StatusProtocolError, ErrProtocol)
+		}
+	case opText, opBinary:
+		if started {
+			return c.fail(StatusProtocolError, ErrProtocol)
+		}
+	case opContinuation:
+		if !started {
+			return c.fail(StatusProtocolError, ErrProtocol)
+		}
+	default:
+		return c.fail(StatusProtocolError, ErrProtocol)
+	}
+
+	return nil
+}
+
+// receiveClose answers the close frame of the peer and closes the connection.
+func (c *Conn) receiveClose(payload []byte) error {
+	closeErr := &CloseError{Code: StatusNoStatusReceived}
+
+	switch {
+	case len(payload) == 1:
+		return c.fail(StatusProtocolError, ErrProtocol)
+	case len(payload) >= 2:
+		closeErr.Code = StatusCode(binary.BigEndian.Uint16(payload))
+		closeErr.Reason = string(payload[2:])
+
+		if !closeErr.Code.validReceived() {
+			return c.fail(StatusProtocolError, ErrProtocol)
+		}
+		if !utf8.ValidString(closeErr.Reason) {
+			return c.fail(StatusInvalidFramePayloadData, ErrInvalidUTF8)
+		}
+	}
+
+	// the status code is echoed, an empty close frame is answered with an empty close frame
+	var echo []byte
+	if len(payload) >= 2 {
+		echo = payload[:2]
+	}
+	_ = c.writeFrame(true, 0, opClose, echo)
+
+	c.closeConn()
+
+	return closeErr
+}
+
+// fail closes the connection with the given status, after a protocol violation of the peer.
+func (c *Conn) fail(code StatusCode, err error) error {
+	_ = c.writeClose(code, "")
+	c.closeConn()
+	return err
+}
+
+// abort closes the connection after a read error, returning ErrClosed if the connection was closed locally.
+func (c *Conn) abort(err error) error {
+	c.closeConn()
+
+	if errors.Is(err, net.ErrClosed) || errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+		return ErrClosed
+	}
+	return err
+}
+
+// WriteMessage writes a data message, compressed if permessage-deflate was negotiated and fragmented if
+// Upgrader.FragmentSize is set.
+func (c *Conn) WriteMessage(typ MessageType, data []byte) error {
+	if typ != TextMessage && typ != BinaryMessage {
+		return errors.New("beehive-ws: invalid message type")
+	}
+
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+
+	if c.closeSent {
+		return ErrClosed
+	}
+
+	var rsv byte
+	if c.deflate && len(data) >= compressionThreshold {
+		compressed, err := c.compress(data)
+		if err != nil {
+			return err
+		}
+		data, rsv = compressed, bitRsv1
+	}
+
+	opcode := byte(typ)
+	for {
+		fragment := data
+		if c.fragmentSize > 0 && len(fragment) > c.fragmentSize {
+			fragment = data[:c.fragmentSize]
+		}
+		data = data[len(fragment):]
+
+		if err := c.writeFrameLocked(len(data) == 0, rsv, opcode, fragment); err != nil {
+			return err
+		}
+		if len(data) == 0 {
+			return nil
+		}
+
+		opcode, rsv = opContinuation, 0
+	}
+}
+
+// Ping sends a ping with the given payload, of at most 125 bytes. The pong is reported to the OnPong function.
+func (c *Conn) Ping(data []byte) error {
+	if len(data) > maxControlPayload {
+		return errors.New("beehive-ws: control frame payload exceeds 125 bytes")
+	}
+	return c.writeFrame(true, 0, opPing, data)
+}
+
+func (c *Conn) writeFrame(fin bool, rsv, opcode byte, payload []byte) error {
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+
+	if c.closeSent {
+		return ErrClosed
+	}
+
+	return c.writeFrameLocked(fin, rsv, opcode, payload)
+}
+
+func (c *Conn) writeFrameLocked(fin bool, rsv, opcode byte, payload []byte) error {
+	if opcode == opClose {
+		c.closeSent = true
+	}
+
+	c.writeBuf = appendFrameHeader(c.writeBuf[:0], fin, rsv, opcode, len(payload))
+	if _, err := c.bw.Write(c.writeBuf); err != nil {
+		return err
+	}
+	if _, err := c.bw.Write(payload); err != nil {
+		return err
+	}
+
+	return c.bw.Flush()
+}
+
+// writeClose sends a close frame, unless one was already sent.
+func (c *Conn) writeClose(code StatusCode, reason string) error {
+	if len(reason) > maxControlPayload-2 {
+		return errors.New("beehive-ws: close reason exceeds 123 bytes")
+	}
+
+	payload := binary.BigEndian.AppendUint16(make([]byte, 0, 2+len(reason)), uint16(code))
+	payload = append(payload, reason...)
+
+	return c.writeFrame(true, 0, opClose, payload)
+}
+
+// Close starts the close handshake with the given status and reason (of at most 123 bytes), waits for the close
+// frame of the peer, for at most Upgrader.CloseTimeout, and closes the connection. Data messages received in the
+// meantime are discarded. Closing a closed connection does nothing.
+func (c *Conn) Close(code StatusCode, reason string) error {
+	if c.ctx.Err() != nil {
+		return nil
+	}
+
+	if err := c.writeClose(code, reason); err != nil {
+		if errors.Is(err, ErrClosed) {
+			return nil
+		}
+		c.closeConn()
+		return err
+	}
+
+	_ = c.conn.SetReadDeadline(time.Now().Add(c.closeTimeout))
+
+	if c.readMu.TryLock() {
+		// no reader is running, read until the close frame of the peer
+		for c.readErr == nil {
+			if _, _, err := c.readMessage(); err != nil {
+				c.readErr = ErrClosed
+			}
+		}
+		c.readMu.Unlock()
+	} else {
+		// the running reader closes the connection when it receives the close frame of the peer
+		select {
+		case <-c.ctx.Done():
+		case <-time.After(c.closeTimeout):
+		}
+	}
+
+	c.closeConn()
+
+	return nil
+}
+
+// closeConn closes the underlying connection and cancels the Context.
+func (c *Conn) closeConn() {
+	c.closeOnce.Do(func() {
+		_ = c.conn.Close()
+		c.cancel()
+	})
+}
@@ -0,0 +1,128 @@
+package beehive_ws
+
+import (
+	"bytes"
+	"compress/flate"
+	"io"
+	"net/http"
+	"strings"
+)
+
+const extensionDeflate = "permessage-deflate"
+
+// deflateResponse is the accepted permessage-deflate extension (RFC 7692). Without context takeover, every message is
+// compressed independently, such that no compression state is kept between messages.
+const deflateResponse = extensionDeflate + "; server_no_context_takeover; client_no_context_takeover"
+
+// compressionThreshold is the size under which messages are sent uncompressed, as compression only adds overhead.
+const compressionThreshold = 128
+
+// deflateTail terminates a message compressed with a sync flush: the removed 0x00 0x00 0xff 0xff, then an empty final
+// stored block, such that the flate reader ends with io.EOF.
+var deflateTail = []byte{0x00, 0x00, 0xff, 0xff, 0x01, 0x00, 0x00, 0xff, 0xff}
+
+// negotiateDeflate reports whether one of the permessage-deflate offers of the request can be accepted. Offers asking
+// to reduce the server window are declined, as compress/flate always uses the maximum window.
+func negotiateDeflate(header http.Header) bool {
+	for _, value := range header.Values("Sec-WebSocket-Extensions") {
+		for offer := range strings.SplitSeq(value, ",") {
+			params := strings.Split(offer, ";")
+			if strings.TrimSpace(params[0]) != extensionDeflate {
+				continue
+			}
+
+			if acceptDeflateParams(params[1:]) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+func acceptDeflateParams(params []string) bool {
+	seen := make(map[string]bool, len(params))
+	for _, param := range params {
+		key, value, hasValue := strings.Cut(strings.TrimSpace(param), "=")
+		key = strings.TrimSpace(key)
+		value = strings.Trim(strings.TrimSpace(value), `"`)
+
+		if seen[key] {
+			return false
+		}
+		seen[key] = true
+
+		switch key {
+		case "server_no_context_takeover", "client_no_context_takeover":
+			if hasValue {
+				return false
+			}
+		case "server_max_window_bits":
+			if value != "15" {
+				return false
+			}
+		case "client_max_window_bits":
+			// a hint the server may ignore, the client then uses the maximum window
+			if hasValue && !validWindowBits(value) {
+				return false
+			}
+		default:
+			return false
+		}
+	}
+
+	return true
+}
+
+func validWindowBits(value string) bool {
+	switch value {
+	case "8", "9", "10", "11", "12", "13", "14", "15":
+		return true
+	default:
+		return false
+	}
+}
+
+// compress compresses a message payload into buf, without the trailing 0x00 0x00 0xff 0xff.
+func (c *Conn) compress(data []byte) ([]byte, error) {
+	c.deflateBuf.Reset()
+	if c.deflateWriter == nil {
+		w, err := flate.NewWriter(&c.deflateBuf, flate.BestSpeed)
+		if err != nil {
+			return nil, err
+		}
+		c.deflateWriter = w
+	} else {
+		c.deflateWriter.Reset(&c.deflateBuf)
+	}
+
+	if _, err := c.deflateWriter.Write(data); err != nil {
+		return nil, err
+	}
+	if err := c.deflateWriter.Flush(); err != nil {
+		return nil, err
+	}
+
+	return bytes.TrimSuffix(c.deflateBuf.Bytes(), deflateTail[:4]), nil
+}
+
+// decompress decompresses a message payload, failing with ErrReadLimit beyond limit bytes (if positive).
+func decompress(data []byte, limit int64) ([]byte, error) {
+	r := flate.NewReader(io.MultiReader(bytes.NewReader(data), bytes.NewReader(deflateTail)))
+	defer r.Close()
+
+	var src io.Reader = r
+	if limit > 0 {
+		src = io.LimitReader(r, limit+1)
+	}
+
+	out, err := io.ReadAll(src)
+	if err != nil {
+		return nil, ErrProtocol
+	}
+	if limit > 0 && int64(len(out)) > limit {
+		return nil, ErrReadLimit
+	}
+
+	return out, nil
+}
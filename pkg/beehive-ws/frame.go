@@ -0,0 +1,99 @@
+package beehive_ws
+
+import (
+	"encoding/binary"
+	"io"
+)
+
+const (
+	opContinuation = 0x0
+	opText         = 0x1
+	opBinary       = 0x2
+	opClose        = 0x8
+	opPing         = 0x9
+	opPong         = 0xa
+
+	bitFin  = 0x80
+	bitRsv1 = 0x40
+	bitRsv2 = 0x20
+	bitRsv3 = 0x10
+	bitMask = 0x80
+
+	// maxControlPayload is the maximum payload of control frames.
+	maxControlPayload = 125
+)
+
+type frameHeader struct {
+	fin    bool
+	rsv    byte
+	opcode byte
+	masked bool
+	length uint64
+	mask   [4]byte
+}
+
+func (h *frameHeader) control() bool {
+	return h.opcode&0x8 != 0
+}
+
+// readFrameHeader reads a frame header, the length is validated by the caller.
+func readFrameHeader(r io.Reader) (frameHeader, error) {
+	var b [8]byte
+	if _, err := io.ReadFull(r, b[:2]); err != nil {
+		return frameHeader{}, err
+	}
+
+	h := frameHeader{
+		fin:    b[0]&bitFin != 0,
+		rsv:    b[0] & (bitRsv1 | bitRsv2 | bitRsv3),
+		opcode: b[0] & 0xf,
+		masked: b[1]&bitMask != 0,
+		length: uint64(b[1] & 0x7f),
+	}
+
+	switch h.length {
+	case 126:
+		if _, err := io.ReadFull(r, b[:2]); err != nil {
+			return frameHeader{}, err
+		}
+		h.length = uint64(binary.BigEndian.Uint16(b[:2]))
+	case 127:
+		if _, err := io.ReadFull(r, b[:8]); err != nil {
+			return frameHeader{}, err
+		}
+		h.length = binary.BigEndian.Uint64(b[:8])
+	}
+
+	if h.masked {
+		if _, err := io.ReadFull(r, h.mask[:]); err != nil {
+			return frameHeader{}, err
+		}
+	}
+
+	return h, nil
+}
+
+// appendFrameHeader appends an unmasked (server) frame header.
+func appendFrameHeader(b []byte, fin bool, rsv, opcode byte, length int) []byte {
+	first := rsv | opcode
+	if fin {
+		first |= bitFin
+	}
+
+	switch {
+	case length <= 125:
+		return append(b, first, byte(length))
+	case length <= 0xffff:
+		return binary.BigEndian.AppendUint16(append(b, first, 126), uint16(length))
+	default:
+		return binary.BigEndian.AppendUint64(append(b, first, 127), uint64(length))
+	}
+}
+
+// maskBytes applies the mask to b, starting at the given position of the payload, and returns the next position.
+func maskBytes(mask [4]byte, pos int, b []byte) int {
+	for i := range b {
+		b[i] ^= mask[(pos+i)&3]
+	}
+	return (pos + len(b)) & 3
+}
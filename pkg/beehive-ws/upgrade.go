@@ -0,0 +1,209 @@
+package beehive_ws
+
+import (
+	"crypto/sha1" //nolint:gosec
+	"encoding/base64"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"go.sdls.io/beehive/pkg/beehive"
+)
+
+const (
+	// DefaultReadLimit is the default maximum size of a received message.
+	DefaultReadLimit = 1 << 20
+
+	// DefaultCloseTimeout is the default time waited for the close frame of the peer.
+	DefaultCloseTimeout = 5 * time.Second
+)
+
+// acceptGUID is appended to the Sec-WebSocket-Key to compute the Sec-WebSocket-Accept.
+const acceptGUID = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+
+// Upgrader describes how requests are upgraded to WebSocket connections.
+type Upgrader struct {
+	// Subprotocols are the supported subprotocols, in order of preference. The first one offered by the client in
+	// Sec-WebSocket-Protocol is selected, no subprotocol is selected otherwise.
+	Subprotocols []string
+
+	// CheckOrigin reports whether the Origin of the request is allowed. If nil, requests without Origin (non browser
+	// clients) and requests whose Origin host equals the request Host are allowed, preventing cross-site WebSocket
+	// hijacking.
+	CheckOrigin func(ctx *beehive.Context, origin string) bool
+
+	// ReadLimit is the maximum size of a received message, after decompression. If zero, DefaultReadLimit is used,
+	// negative values disable the limit.
+	ReadLimit int64
+
+	// FragmentSize fragments the sent messages in frames of at most FragmentSize bytes. If zero, messages are sent in
+	// a single frame.
+	FragmentSize int
+
+	// Compression negotiates the permessage-deflate extension (RFC 7692) when offered by the client, without context
+	// takeover.
+	Compression bool
+
+	// CloseTimeout is the time Conn.Close waits for the close frame of the peer. If zero, DefaultCloseTimeout is used.
+	CloseTimeout time.Duration
+}
+
+// HandshakeError is returned by Upgrade when the request is not a valid WebSocket handshake, nothing is written to
+// the response. Responder returns the matching error response.
+type HandshakeError struct {
+	Status  int
+	Message string
+}
+
+func (e *HandshakeError) Error() string {
+	return "beehive-ws: " + e.Message
+}
+
+// Responder returns a beehive.DefaultResponder with the Status and Message of the error.
+func (e *HandshakeError) Responder() beehive.Responder {
+	return &beehive.DefaultResponder{Message: e.Message, Status: e.Status}
+}
+
+// Upgrade validates the WebSocket handshake, hijacks the connection and writes the 101 Switching Protocols response.
+// After a successful Upgrade, the handler must return the Responder of SwitchingProtocols, as the connection does not
+// belong to the http.Server anymore. The connection is closed with StatusGoingAway when the request context is done,
+// which happens when the handler returns.
+func (u *Upgrader) Upgrade(ctx *beehive.Context) (*Conn, error) {
+	r := ctx.Request
+	h := r.Header
+
+	switch {
+	case r.Method != http.MethodGet:
+		return nil, &HandshakeError{Status: http.StatusMethodNotAllowed, Message: "websocket handshake requires GET"}
+	case !r.ProtoAtLeast(1, 1):
+		return nil, &HandshakeError{Status: http.StatusBadRequest, Message: "websocket handshake requires HTTP/1.1"}
+	case !headerContainsToken(h, "Connection", "upgrade") || !headerContainsToken(h, "Upgrade", "websocket"):
+		return nil, &HandshakeError{Status: http.StatusUpgradeRequired, Message: "websocket upgrade required"}
+	case h.Get("Sec-WebSocket-Version") != "13":
+		ctx.ResponseWriter.Header().Set("Sec-WebSocket-Version", "13")
+		return nil, &HandshakeError{Status: http.StatusUpgradeRequired, Message: "unsupported websocket version"}
+	}
+
+	key := h.Get("Sec-WebSocket-Key")
+	if decoded, err := base64.StdEncoding.DecodeString(key); err != nil || len(decoded) != 16 {
+		return nil, &HandshakeError{Status: http.StatusBadRequest, Message: "invalid websocket key"}
+	}
+
+	checkOrigin := u.CheckOrigin
+	if checkOrigin == nil {
+		checkOrigin = sameOrigin
+	}
+	if origin := h.Get("Origin"); origin != "" && !checkOrigin(ctx, origin) {
+		return nil, &HandshakeError{Status: http.StatusForbidden, Message: "websocket origin not allowed"}
+	}
+
+	subprotocol := u.selectSubprotocol(h)
+	deflate := u.Compression && negotiateDeflate(h)
+
+	conn, brw, err := http.NewResponseController(ctx.ResponseWriter).Hijack()
+	if err != nil {
+		return nil, &HandshakeError{Status: http.StatusInternalServerError, Message: "websocket upgrade not supported"}
+	}
+
+	// the handshake deadlines of the http.Server do not apply to the hijacked connection
+	_ = conn.SetDeadline(time.Time{})
+
+	var b strings.Builder
+	b.WriteString("HTTP/1.1 101 Switching Protocols\r\nUpgrade: websocket\r\nConnection: Upgrade\r\n")
+	b.WriteString("Sec-WebSocket-Accept: " + acceptKey(key) + "\r\n")
+	if subprotocol != "" {
+		b.WriteString("Sec-WebSocket-Protocol: " + subprotocol + "\r\n")
+	}
+	if deflate {
+		b.WriteString("Sec-WebSocket-Extensions: " + deflateResponse + "\r\n")
+	}
+	b.WriteString("\r\n")
+
+	if _, err := brw.WriteString(b.String()); err != nil {
+		_ = conn.Close()
+		return nil, err
+	}
+	if err := brw.Flush(); err != nil {
+		_ = conn.Close()
+		return nil, err
+	}
+
+	return newConn(ctx.Context, conn, brw, u, subprotocol, deflate), nil
+}
+
+// HandlerFunc returns a beehive.HandlerFunc upgrading the requests and serving the connections with f. The
+// connection is closed when f returns, with StatusNormalClosure unless it was already closed. Failed handshakes are
+// answered with HandshakeError.Responder.
+func (u *Upgrader) HandlerFunc(f func(conn *Conn)) beehive.HandlerFunc {
+	return func(ctx *beehive.Context) beehive.Responder {
+		conn, err := u.Upgrade(ctx)
+		if err != nil {
+			if handshakeErr, ok := err.(*HandshakeError); ok {
+				return handshakeErr.Responder()
+			}
+
+			ctx.ReportError(err)
+			return SwitchingProtocols
+		}
+
+		f(conn)
+		_ = conn.Close(StatusNormalClosure, "")
+
+		return SwitchingProtocols
+	}
+}
+
+// SwitchingProtocols is the Responder returned after a successful Upgrade. It writes nothing, the 101 Switching
+// Protocols response being written by Upgrade, and reports the status to the Router.After hook and middleware.
+var SwitchingProtocols beehive.Responder = switchingProtocols{}
+
+type switchingProtocols struct{}
+
+func (switchingProtocols) StatusCode(_ *beehive.Context) int {
+	return http.StatusSwitchingProtocols
+}
+
+func (switchingProtocols) Respond(_ *beehive.Context) {}
+
+func (u *Upgrader) selectSubprotocol(h http.Header) string {
+	var offered []string
+	for _, value := range h.Values("Sec-WebSocket-Protocol") {
+		for protocol := range strings.SplitSeq(value, ",") {
+			offered = append(offered, strings.TrimSpace(protocol))
+		}
+	}
+
+	for _, protocol := range u.Subprotocols {
+		for _, o := range offered {
+			if o == protocol {
+				return protocol
+			}
+		}
+	}
+
+	return ""
+}
+
+// headerContainsToken reports whether the comma separated header contains the token, case-insensitively.
+func headerContainsToken(h http.Header, name, token string) bool {
+	for _, value := range h.Values(name) {
+		for t := range strings.SplitSeq(value, ",") {
+			if strings.EqualFold(strings.TrimSpace(t), token) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func sameOrigin(ctx *beehive.Context, origin string) bool {
+	u, err := url.Parse(origin)
+	return err == nil && strings.EqualFold(u.Host, ctx.Request.Host)
+}
+
+func acceptKey(key string) string {
+	h := sha1.New() //nolint:gosec
+	h.Write([]byte(key + acceptGUID))
+	return base64.StdEncoding.EncodeToString(h.Sum(nil))
+}
@@ -0,0 +1,333 @@
+package beehive_ws
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"go.sdls.io/beehive/pkg/beehive"
+)
+
+func TestAcceptKey(t *testing.T) {
+	t.Parallel()
+
+	// RFC 6455 section 1.3
+	if got := acceptKey(testKey); got != "s3pPLMBiTxaQ9kYGzzhZRbK+xOo=" {
+		t.Errorf("expected %q, got %q", "s3pPLMBiTxaQ9kYGzzhZRbK+xOo=", got)
+	}
+}
+
+func TestUpgrader_Upgrade(t *testing.T) {
+	t.Parallel()
+
+	server := newTestServer(t, &Upgrader{
+		Subprotocols: []string{"v2.chat", "v1.chat"},
+		Compression:  true,
+	})
+
+	tests := []struct {
+		name        string
+		header      map[string]string
+		code        int
+		subprotocol string
+		extensions  string
+	}{
+		{"valid", nil, http.StatusSwitchingProtocols, "", ""},
+		{"connection tokens", map[string]string{"Connection": "keep-alive, Upgrade", "Upgrade": "WebSocket"}, http.StatusSwitchingProtocols, "", ""},
+		{"subprotocol", map[string]string{"Sec-WebSocket-Protocol": "v1.chat, v2.chat"}, http.StatusSwitchingProtocols, "v2.chat", ""},
+		{"unknown subprotocol", map[string]string{"Sec-WebSocket-Protocol": "v3.chat"}, http.StatusSwitchingProtocols, "", ""},
+		{"deflate", map[string]string{"Sec-WebSocket-Extensions": "permessage-deflate"}, http.StatusSwitchingProtocols, "", deflateResponse},
+		{"deflate window hint", map[string]string{"Sec-WebSocket-Extensions": "permessage-deflate; client_max_window_bits"}, http.StatusSwitchingProtocols, "", deflateResponse},
+		{"deflate second offer", map[string]string{"Sec-WebSocket-Extensions": "permessage-deflate; server_max_window_bits=10, permessage-deflate"}, http.StatusSwitchingProtocols, "", deflateResponse},
+		{"deflate declined", map[string]string{"Sec-WebSocket-Extensions": "permessage-deflate; server_max_window_bits=10"}, http.StatusSwitchingProtocols, "", ""},
+		{"unknown extension", map[string]string{"Sec-WebSocket-Extensions": "x-webkit-deflate-frame"}, http.StatusSwitchingProtocols, "", ""},
+		{"same origin", map[string]string{"Origin": "http://" + server.Listener.Addr().String()}, http.StatusSwitchingProtocols, "", ""},
+		{"cross origin", map[string]string{"Origin": "https://evil.example.com"}, http.StatusForbidden, "", ""},
+		{"no upgrade", map[string]string{"Upgrade": ""}, http.StatusUpgradeRequired, "", ""},
+		{"no connection upgrade", map[string]string{"Connection": "keep-alive"}, http.StatusUpgradeRequired, "", ""},
+		{"version", map[string]string{"Sec-WebSocket-Version": "8"}, http.StatusUpgradeRequired, "", ""},
+		{"no key", map[string]string{"Sec-WebSocket-Key": ""}, http.StatusBadRequest, "", ""},
+		{"short key", map[string]string{"Sec-WebSocket-Key": "c2hvcnQ="}, http.StatusBadRequest, "", ""},
+	}
+
+	for _, test := range tests {
+		c := dial(t, server, test.header)
+
+		if c.res.StatusCode != test.code {
+			t.Errorf("%s: expected %d, got %d", test.name, test.code, c.res.StatusCode)
+			continue
+		}
+
+		if test.code != http.StatusSwitchingProtocols {
+			continue
+		}
+
+		if accept := c.res.Header.Get("Sec-WebSocket-Accept"); accept != "s3pPLMBiTxaQ9kYGzzhZRbK+xOo=" {
+			t.Errorf("%s: expected %q, got %q", test.name, "s3pPLMBiTxaQ9kYGzzhZRbK+xOo=", accept)
+		}
+		if subprotocol := c.res.Header.Get("Sec-WebSocket-Protocol"); subprotocol != test.subprotocol {
+			t.Errorf("%s: expected %q, got %q", test.name, test.subprotocol, subprotocol)
+		}
+		if extensions := c.res.Header.Get("Sec-WebSocket-Extensions"); extensions != test.extensions {
+			t.Errorf("%s: expected %q, got %q", test.name, test.extensions, extensions)
+		}
+	}
+
+	c := dial(t, server, map[string]string{"Sec-WebSocket-Version": "8"})
+	if version := c.res.Header.Get("Sec-WebSocket-Version"); version != "13" {
+		t.Errorf("expected %q, got %q", "13", version)
+	}
+}
+
+func TestUpgrader_Upgrade_method(t *testing.T) {
+	t.Parallel()
+
+	u := &Upgrader{}
+	router := beehive.NewRouter()
+	router.Handle("POST", "/ws", u.HandlerFunc(func(_ *Conn) {}))
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("POST", "/ws", nil))
+
+	if w.Code != http.StatusMethodNotAllowed {
+		t.Errorf("expected %d, got %d", http.StatusMethodNotAllowed, w.Code)
+	}
+}
+
+func TestUpgrader_CheckOrigin(t *testing.T) {
+	t.Parallel()
+
+	server := newTestServer(t, &Upgrader{
+		CheckOrigin: func(_ *beehive.Context, origin string) bool {
+			return origin == "https://app.example.com"
+		},
+	})
+
+	if c := dial(t, server, map[string]string{"Origin": "https://app.example.com"}); c.res.StatusCode != http.StatusSwitchingProtocols {
+		t.Errorf("expected %d, got %d", http.StatusSwitchingProtocols, c.res.StatusCode)
+	}
+	if c := dial(t, server, map[string]string{"Origin": "https://other.example.com"}); c.res.StatusCode != http.StatusForbidden {
+		t.Errorf("expected %d, got %d", http.StatusForbidden, c.res.StatusCode)
+	}
+}
+
+func TestUpgrader_HandlerFunc_After(t *testing.T) {
+	t.Parallel()
+
+	statuses := make(chan int, 1)
+
+	u := &Upgrader{}
+	router := beehive.NewRouter()
+	router.After = func(ctx *beehive.Context, res beehive.Responder) {
+		statuses <- res.StatusCode(ctx)
+	}
+	router.Handle("GET", "/ws", u.HandlerFunc(func(conn *Conn) {
+		_ = conn.WriteMessage(TextMessage, []byte("hello"))
+	}))
+
+	server := httptest.NewServer(router)
+	defer server.Close()
+
+	c := dial(t, server, nil)
+	if _, got := c.readMessage(); string(got) != "hello" {
+		t.Errorf("expected %q, got %q", "hello", got)
+	}
+
+	// the handler returned, the server closes the connection
+	c.writeFrame(testFrame{fin: true, opcode: opClose, payload: closePayload(StatusNormalClosure, "")})
+	c.expectClose(StatusNormalClosure)
+
+	select {
+	case status := <-statuses:
+		if status != http.StatusSwitchingProtocols {
+			t.Errorf("expected %d, got %d", http.StatusSwitchingProtocols, status)
+		}
+	case <-time.After(time.Second):
+		t.Errorf("expected After to be called")
+	}
+}
+
+func TestConn_Close(t *testing.T) {
+	t.Parallel()
+
+	done := make(chan error, 1)
+	server := newTestServerFunc(t, &Upgrader{CloseTimeout: time.Second}, func(conn *Conn) {
+		done <- conn.Close(StatusGoingAway, "restarting")
+
+		if conn.Context().Err() == nil {
+			t.Errorf("expected the context to be canceled")
+		}
+		if _, _, err := conn.ReadMessage(); err != ErrClosed {
+			t.Errorf("expected %v, got %v", ErrClosed, err)
+		}
+		if err := conn.WriteMessage(TextMessage, []byte("late")); err != ErrClosed {
+			t.Errorf("expected %v, got %v", ErrClosed, err)
+		}
+	})
+
+	c := dial(t, server, nil)
+
+	f, err := c.readFrame()
+	if err != nil || f.opcode != opClose || string(f.payload) != string(closePayload(StatusGoingAway, "restarting")) {
+		t.Fatalf("expected a close frame, got %+v %v", f, err)
+	}
+
+	// data sent before the close frame is discarded
+	c.writeFrame(testFrame{fin: true, opcode: opText, payload: []byte("discarded")})
+	c.writeFrame(testFrame{fin: true, opcode: opClose, payload: closePayload(StatusGoingAway, "")})
+
+	if err := <-done; err != nil {
+		t.Errorf("unexpected error %v", err)
+	}
+}
+
+func TestConn_Close_timeout(t *testing.T) {
+	t.Parallel()
+
+	done := make(chan time.Duration, 1)
+	server := newTestServerFunc(t, &Upgrader{CloseTimeout: 50 * time.Millisecond}, func(conn *Conn) {
+		start := time.Now()
+		_ = conn.Close(StatusNormalClosure, "")
+		done <- time.Since(start)
+	})
+
+	c := dial(t, server, nil)
+	if f, err := c.readFrame(); err != nil || f.opcode != opClose {
+		t.Fatalf("expected a close frame, got %+v %v", f, err)
+	}
+
+	// the client never answers
+	select {
+	case elapsed := <-done:
+		if elapsed < 50*time.Millisecond {
+			t.Errorf("expected Close to wait for the close frame, returned after %v", elapsed)
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("expected Close to time out")
+	}
+}
+
+func TestConn_ReadMessage_CloseError(t *testing.T) {
+	t.Parallel()
+
+	errs := make(chan error, 1)
+	server := newTestServerFunc(t, &Upgrader{}, func(conn *Conn) {
+		_, _, err := conn.ReadMessage()
+		errs <- err
+	})
+
+	c := dial(t, server, nil)
+	c.writeFrame(testFrame{fin: true, opcode: opClose, payload: closePayload(4000, "custom")})
+	c.expectClose(4000)
+
+	err := <-errs
+	closeErr, ok := err.(*CloseError)
+	if !ok || closeErr.Code != 4000 || closeErr.Reason != "custom" {
+		t.Errorf("expected a close error, got %v", err)
+	}
+}
+
+func TestConn_PingPong(t *testing.T) {
+	t.Parallel()
+
+	pongs := make(chan string, 1)
+	server := newTestServerFunc(t, &Upgrader{}, func(conn *Conn) {
+		conn.OnPong(func(data []byte) {
+			pongs <- string(data)
+		})
+
+		if err := conn.Ping([]byte("are you there")); err != nil {
+			t.Errorf("unexpected error %v", err)
+		}
+		if err := conn.Ping(make([]byte, 126)); err == nil {
+			t.Errorf("expected an error for a large ping")
+		}
+
+		_, _, _ = conn.ReadMessage()
+	})
+
+	c := dial(t, server, nil)
+	f, err := c.readFrame()
+	if err != nil || f.opcode != opPing {
+		t.Fatalf("expected a ping, got %+v %v", f, err)
+	}
+	c.writeFrame(testFrame{fin: true, opcode: opPong, payload: f.payload})
+
+	select {
+	case pong := <-pongs:
+		if pong != "are you there" {
+			t.Errorf("expected %q, got %q", "are you there", pong)
+		}
+	case <-time.After(time.Second):
+		t.Errorf("expected a pong")
+	}
+}
+
+func TestConn_context(t *testing.T) {
+	t.Parallel()
+
+	cancelRequest := make(chan struct{})
+	closed := make(chan struct{})
+
+	// the request context of the http.Server is only canceled when the handler returns, a middleware cancels it
+	// earlier, like a timeout middleware would
+	middleware := func(ctx *beehive.Context) beehive.Responder {
+		requestCtx, cancel := context.WithCancel(ctx.Context)
+		defer cancel()
+
+		ctx.Context = requestCtx
+		go func() {
+			<-cancelRequest
+			cancel()
+		}()
+
+		return ctx.Next()
+	}
+
+	u := &Upgrader{CloseTimeout: 50 * time.Millisecond}
+	router := beehive.NewRouter()
+	router.Handle("GET", "/ws", middleware, u.HandlerFunc(func(conn *Conn) {
+		defer close(closed)
+		<-conn.Context().Done()
+	}))
+
+	server := httptest.NewServer(router)
+	defer server.Close()
+
+	c := dial(t, server, nil)
+	close(cancelRequest)
+
+	c.expectClose(StatusGoingAway)
+
+	select {
+	case <-closed:
+	case <-time.After(time.Second):
+		t.Errorf("expected the connection context to be canceled")
+	}
+}
+
+func TestConn_WriteMessage_concurrent(t *testing.T) {
+	t.Parallel()
+
+	server := newTestServerFunc(t, &Upgrader{}, func(conn *Conn) {
+		var wg sync.WaitGroup
+		for range 10 {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				_ = conn.WriteMessage(BinaryMessage, make([]byte, 1000))
+			}()
+		}
+		wg.Wait()
+	})
+
+	c := dial(t, server, nil)
+	for range 10 {
+		if opcode, got := c.readMessage(); opcode != opBinary || len(got) != 1000 {
+			t.Fatalf("expected a binary message of %d bytes, got %d", 1000, len(got))
+		}
+	}
+}
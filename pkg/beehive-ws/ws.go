@@ -0,0 +1,74 @@
+package beehive_ws
+
+import (
+	"errors"
+	"strconv"
+)
+
+// MessageType is the type of a data message.
+type MessageType int
+
+const (
+	TextMessage   MessageType = 1
+	BinaryMessage MessageType = 2
+)
+
+// StatusCode is a close status code (RFC 6455 section 7.4).
+type StatusCode int
+
+const (
+	StatusNormalClosure           StatusCode = 1000
+	StatusGoingAway               StatusCode = 1001
+	StatusProtocolError           StatusCode = 1002
+	StatusUnsupportedData         StatusCode = 1003
+	StatusNoStatusReceived        StatusCode = 1005
+	StatusAbnormalClosure         StatusCode = 1006
+	StatusInvalidFramePayloadData StatusCode = 1007
+	StatusPolicyViolation         StatusCode = 1008
+	StatusMessageTooBig           StatusCode = 1009
+	StatusMandatoryExtension      StatusCode = 1010
+	StatusInternalError           StatusCode = 1011
+)
+
+// validReceived reports whether the code can be received in a close frame. The codes 1005, 1006 and 1015 are
+// reserved for reporting, and must never be sent.
+func (s StatusCode) validReceived() bool {
+	switch {
+	case s >= 1000 && s <= 1003, s >= 1007 && s <= 1011:
+		return true
+	default:
+		return s >= 3000 && s <= 4999
+	}
+}
+
+var (
+	// ErrClosed is returned when reading or writing a closed connection.
+	ErrClosed = errors.New("beehive-ws: connection closed")
+
+	// ErrProtocol is returned when the peer violated the protocol, the connection is closed with
+	// StatusProtocolError.
+	ErrProtocol = errors.New("beehive-ws: protocol error")
+
+	// ErrReadLimit is returned when a message exceeds the read limit, the connection is closed with
+	// StatusMessageTooBig.
+	ErrReadLimit = errors.New("beehive-ws: message exceeds read limit")
+
+	// ErrInvalidUTF8 is returned when a text message or a close reason is not valid UTF-8, the connection is closed
+	// with StatusInvalidFramePayloadData.
+	ErrInvalidUTF8 = errors.New("beehive-ws: invalid utf-8")
+)
+
+// CloseError is returned by Conn.ReadMessage when the peer closed the connection.
+type CloseError struct {
+	// Code is StatusNoStatusReceived when the peer sent no status code.
+	Code   StatusCode
+	Reason string
+}
+
+func (e *CloseError) Error() string {
+	s := "beehive-ws: closed with status " + strconv.Itoa(int(e.Code))
+	if e.Reason != "" {
+		s += ": " + e.Reason
+	}
+	return s
+}
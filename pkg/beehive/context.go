@@ -20,10 +20,15 @@ type Context struct {
 	Context        context.Context //nolint:containedctx
 
 	router      *Router
+	route       string
 	handlers    []HandlerFunc
 	handlersIdx int
 
-	afters []func()
+	afters     []func()
+	responding bool
+
+	start   time.Time
+	timings []ServerTiming
 }
 
 // String returns a formatted string with the contents of the context. This method has no guarantee of compatibility
@@ -33,6 +38,26 @@ func (c *Context) String() string {
 		c.handlersIdx, c.handlers, c.Context)
 }
 
+// Route returns the path pattern the request was matched against, as it was given to Handle (including any group
+// prefix and the trailing '*' for wildcard routes). Route returns an empty string when no route was matched, for
+// example in Router.WhenNotFound.
+func (c *Context) Route() string {
+	return c.route
+}
+
+// Router returns the Router handling the request.
+func (c *Context) Router() *Router {
+	return c.router
+}
+
+// ReportError reports an error that cannot be sent to the client anymore to Router.WhenError, for example a Responder
+// failing to encode the body after the headers were written.
+func (c *Context) ReportError(err error) {
+	if c.router != nil && c.router.WhenError != nil {
+		c.router.WhenError(c, err)
+	}
+}
+
 // Deadline calls the underlying context.Context.Deadline() method.
 func (c *Context) Deadline() (deadline time.Time, ok bool) {
 	return c.Context.Deadline()
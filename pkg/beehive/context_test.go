@@ -191,6 +191,63 @@ func TestContextAfter(t *testing.T) {
 	}
 }
 
+func TestContext_Route(t *testing.T) {
+	t.Parallel()
+
+	var routes []string
+	handler := func(ctx *Context) Responder {
+		routes = append(routes, ctx.Route())
+		return &DefaultResponder{Status: http.StatusOK}
+	}
+
+	router := NewRouter()
+	router.WhenNotFound = func(ctx *Context) Responder {
+		routes = append(routes, ctx.Route())
+		return nil
+	}
+	router.Handle("GET", "/foo/bar", handler)
+	router.Group("/api").Handle("GET", "/files/*", handler)
+
+	for _, path := range []string{"/foo/bar", "/api/files/a/b/c", "/api/nope"} {
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest("GET", path, nil)
+		router.ServeHTTP(w, r)
+	}
+
+	want := []string{"/foo/bar", "/api/files/*", ""}
+	if !reflect.DeepEqual(want, routes) {
+		t.Errorf("wanted %v, got %v", want, routes)
+	}
+}
+
+func TestContext_ReportError(t *testing.T) {
+	t.Parallel()
+
+	errTest := errors.New("test")
+	var reported []error
+
+	router := NewRouter()
+	router.WhenError = func(ctx *Context, err error) {
+		if ctx.Route() != "/foo" {
+			t.Errorf("expected route %s, got %s", "/foo", ctx.Route())
+		}
+		reported = append(reported, err)
+	}
+	router.Handle("GET", "/foo", func(ctx *Context) Responder {
+		ctx.ReportError(errTest)
+		return &DefaultResponder{Message: "ok", Status: http.StatusOK}
+	})
+
+	router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/foo", nil))
+
+	if len(reported) != 1 || !errors.Is(reported[0], errTest) {
+		t.Errorf("expected %v to be reported, got %v", errTest, reported)
+	}
+
+	// without a router or a hook, errors are ignored
+	(&Context{}).ReportError(errTest)
+}
+
 func TestContext_goPropagation(t *testing.T) {
 	t.Parallel()
 
@@ -3,13 +3,19 @@ package beehive
 import (
 	"context"
 	"net/http"
+	"time"
 
 	"go.sdls.io/beehive/internal/trie"
 )
 
+type route struct {
+	path     string
+	handlers []HandlerFunc
+}
+
 type methodGroup struct {
 	Name  string
-	radix trie.Radix[[]HandlerFunc]
+	radix trie.Radix[route]
 }
 
 // Router is the core of the beehive package. It implements the Grouper interface for creating route groups or
@@ -30,6 +36,19 @@ type Router struct {
 	// used to do any cleanup without delaying the response.
 	After func(ctx *Context, res Responder)
 
+	// WhenError is called with the errors reported by Context.ReportError, typically by a Responder failing after the
+	// response was started, when the client cannot be told anymore. If nil, the errors are ignored.
+	WhenError func(ctx *Context, err error)
+
+	// BeforeRespond is called with the Responder right before Responder.Respond, once the handler chain (or
+	// WhenNotFound, or Recover) is done and before any header is written. It is not called when there is no Responder,
+	// and it is called at most once per request: when Responder.Respond panics, the Recover response is sent without it.
+	BeforeRespond func(ctx *Context, res Responder)
+
+	// ServerTiming emits the timings recorded with Context.Timing, and the total duration of the request until
+	// Responder.Respond, in the Server-Timing response header.
+	ServerTiming bool
+
 	// AllowRouteOverwrite allows setting the same route multiple times. Not recommended.
 	AllowRouteOverwrite bool
 
@@ -57,6 +76,20 @@ func DefaultContext(req *http.Request) context.Context {
 	return req.Context()
 }
 
+// Methods returns the methods that have a route matching the given request path, in the order the methods were first
+// registered on the Router.
+func (router *Router) Methods(path string) []string {
+	var methods []string
+	for _, method := range router.methods {
+		data, found := method.radix.Get(path)
+		if found && len(data.handlers) != 0 {
+			methods = append(methods, method.Name)
+		}
+	}
+
+	return methods
+}
+
 func (router *Router) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	c := router.Context(r) //nolint:contextcheck
 	if c == nil {
@@ -83,11 +116,15 @@ func (router *Router) serveHTTP(ctx *Context) {
 	var res Responder
 	r := ctx.Request
 
+	if router.ServerTiming {
+		ctx.start = time.Now()
+	}
+
 	defer func() {
 		if err := recover(); err != nil {
 			res = router.Recover(ctx, err)
 			if res != nil {
-				res.Respond(ctx)
+				router.respond(ctx, res)
 			}
 		}
 
@@ -102,7 +139,7 @@ func (router *Router) serveHTTP(ctx *Context) {
 		}
 	}()
 
-	var radix *trie.Radix[[]HandlerFunc]
+	var radix *trie.Radix[route]
 	for idx, method := range router.methods {
 		if method.Name == r.Method {
 			radix = &router.methods[idx].radix
@@ -112,7 +149,7 @@ func (router *Router) serveHTTP(ctx *Context) {
 
 	if radix == nil {
 		if res = router.WhenNotFound(ctx); res != nil {
-			res.Respond(ctx)
+			router.respond(ctx, res)
 		}
 		return
 	}
@@ -120,23 +157,41 @@ func (router *Router) serveHTTP(ctx *Context) {
 	data, found := radix.Get(r.URL.Path)
 	if !found {
 		if res = router.WhenNotFound(ctx); res != nil {
-			res.Respond(ctx)
+			router.respond(ctx, res)
 		}
 		return
 	}
 
-	ctx.handlers = data
+	ctx.route = data.path
+	ctx.handlers = data.handlers
 	if len(ctx.handlers) == 0 {
 		if res = router.WhenNotFound(ctx); res != nil {
-			res.Respond(ctx)
+			router.respond(ctx, res)
 		}
 		return
 	}
 
 	res = router.next(ctx)
 	if res != nil {
-		res.Respond(ctx)
+		router.respond(ctx, res)
+	}
+}
+
+func (router *Router) respond(ctx *Context, res Responder) {
+	// the hooks already ran when Respond (or BeforeRespond) panicked and Recover responds
+	if !ctx.responding {
+		ctx.responding = true
+
+		if router.BeforeRespond != nil {
+			router.BeforeRespond(ctx, res)
+		}
+
+		if router.ServerTiming {
+			ctx.writeServerTiming(time.Since(ctx.start))
+		}
 	}
+
+	res.Respond(ctx)
 }
 
 func (router *Router) next(ctx *Context) Responder {
@@ -33,7 +33,7 @@ func (router *Router) Handle(method, path string, handlers ...HandlerFunc) Group
 		panic("beehive: router handler is empty")
 	}
 
-	var radix *trie.Radix[[]HandlerFunc]
+	var radix *trie.Radix[route]
 	for idx, m := range router.methods {
 		if m.Name == method {
 			radix = &router.methods[idx].radix
@@ -44,7 +44,7 @@ func (router *Router) Handle(method, path string, handlers ...HandlerFunc) Group
 	if radix == nil {
 		router.methods = append(router.methods, methodGroup{
 			Name:  method,
-			radix: trie.Radix[[]HandlerFunc]{},
+			radix: trie.Radix[route]{},
 		})
 		radix = &router.methods[len(router.methods)-1].radix
 	}
@@ -56,7 +56,10 @@ func (router *Router) Handle(method, path string, handlers ...HandlerFunc) Group
 		}
 	}
 
-	radix.Add(path, allHandlers)
+	radix.Add(path, route{
+		path:     path,
+		handlers: allHandlers,
+	})
 
 	return router
 }
@@ -367,6 +367,31 @@ func TestRouter_Handle(t *testing.T) {
 	})
 }
 
+func TestRouter_Methods(t *testing.T) {
+	t.Parallel()
+
+	handler := func(_ *Context) Responder {
+		return nil
+	}
+
+	router := NewRouter()
+	router.Handle("GET", "/foo/bar", handler)
+	router.Handle("PUT", "/foo/*", handler)
+	router.Handle("POST", "/foo/bar", handler)
+
+	tests := map[string][]string{
+		"/foo/bar": {"GET", "PUT", "POST"},
+		"/foo/baz": {"PUT"},
+		"/nope":    nil,
+	}
+
+	for path, want := range tests {
+		if got := router.Methods(path); !reflect.DeepEqual(got, want) {
+			t.Errorf("%s: expected %v, got %v", path, want, got)
+		}
+	}
+}
+
 func Test_ResponseWriter(t *testing.T) {
 	t.Parallel()
 
@@ -0,0 +1,68 @@
+package beehive
+
+import (
+	"strconv"
+	"time"
+)
+
+// ServerTiming is a single named timing of the Server-Timing header.
+type ServerTiming struct {
+	Name        string
+	Description string
+	Duration    time.Duration
+}
+
+// Timing records a named timing, for example the time spent in the database. The timings are emitted in the
+// Server-Timing header when Router.ServerTiming is set. The name must be a token, and should not be "total" which is
+// used by the Router.
+func (c *Context) Timing(name, description string, duration time.Duration) {
+	c.timings = append(c.timings, ServerTiming{
+		Name:        name,
+		Description: description,
+		Duration:    duration,
+	})
+}
+
+// StartTiming starts a named timing and returns the function recording it, to be used as
+// defer ctx.StartTiming("db", "")().
+func (c *Context) StartTiming(name, description string) func() {
+	start := time.Now()
+	return func() {
+		c.Timing(name, description, time.Since(start))
+	}
+}
+
+// Timings returns the timings recorded with Timing, in recording order.
+func (c *Context) Timings() []ServerTiming {
+	return c.timings
+}
+
+func (c *Context) writeServerTiming(total time.Duration) {
+	b := make([]byte, 0, 32+len(c.timings)*32)
+	for _, timing := range c.timings {
+		b = appendServerTiming(b, timing)
+		b = append(b, ", "...)
+	}
+	b = appendServerTiming(b, ServerTiming{Name: "total", Duration: total})
+
+	c.ResponseWriter.Header().Add("Server-Timing", string(b))
+}
+
+func appendServerTiming(b []byte, timing ServerTiming) []byte {
+	b = append(b, timing.Name...)
+	b = append(b, ";dur="...)
+	b = strconv.AppendFloat(b, float64(timing.Duration)/float64(time.Millisecond), 'f', -1, 64)
+
+	if timing.Description != "" {
+		b = append(b, `;desc="`...)
+		for i := 0; i < len(timing.Description); i++ {
+			if ch := timing.Description[i]; ch == '"' || ch == '\\' {
+				b = append(b, '\\')
+			}
+			b = append(b, timing.Description[i])
+		}
+		b = append(b, '"')
+	}
+
+	return b
+}
@@ -0,0 +1,152 @@
+package beehive
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"testing"
+	"time"
+)
+
+func TestRouter_ServerTiming(t *testing.T) {
+	t.Parallel()
+
+	router := NewRouter()
+	router.ServerTiming = true
+	router.Handle("GET", "/foo", func(ctx *Context) Responder {
+		ctx.Timing("db", `users "a\b"`, 12500*time.Microsecond)
+		return ctx.Next()
+	}, func(ctx *Context) Responder {
+		defer ctx.StartTiming("cache", "")()
+		return &DefaultResponder{Message: "ok", Status: http.StatusOK}
+	})
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", "/foo", nil))
+
+	header := w.Header().Get("Server-Timing")
+	if !regexp.MustCompile(`^db;dur=12\.5;desc="users \\"a\\\\b\\"", cache;dur=[0-9.]+, total;dur=[0-9.]+$`).MatchString(header) {
+		t.Errorf("unexpected Server-Timing %s", header)
+	}
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", "/nope", nil))
+
+	if !regexp.MustCompile(`^total;dur=[0-9.]+$`).MatchString(w.Header().Get("Server-Timing")) {
+		t.Errorf("expected total timing on not found, got %s", w.Header().Get("Server-Timing"))
+	}
+}
+
+func TestRouter_ServerTiming_disabled(t *testing.T) {
+	t.Parallel()
+
+	var timings []ServerTiming
+
+	router := NewRouter()
+	router.After = func(ctx *Context, _ Responder) {
+		timings = ctx.Timings()
+	}
+	router.Handle("GET", "/foo", func(ctx *Context) Responder {
+		ctx.Timing("db", "", time.Millisecond)
+		return &DefaultResponder{Message: "ok", Status: http.StatusOK}
+	})
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", "/foo", nil))
+
+	if w.Header().Get("Server-Timing") != "" {
+		t.Errorf("expected no Server-Timing, got %s", w.Header().Get("Server-Timing"))
+	}
+	if len(timings) != 1 || timings[0] != (ServerTiming{Name: "db", Duration: time.Millisecond}) {
+		t.Errorf("unexpected timings %v", timings)
+	}
+}
+
+func TestRouter_BeforeRespond(t *testing.T) {
+	t.Parallel()
+
+	var trace []string
+
+	router := NewRouter()
+	router.BeforeRespond = func(ctx *Context, res Responder) {
+		trace = append(trace, "before "+http.StatusText(res.StatusCode(ctx)))
+		ctx.ResponseWriter.Header().Set("X-Before", "yes")
+	}
+	router.After = func(_ *Context, _ Responder) {
+		trace = append(trace, "after")
+	}
+	router.Handle("GET", "/foo", func(ctx *Context) Responder {
+		trace = append(trace, "handler")
+		return &DefaultResponder{Message: "ok", Status: http.StatusOK}
+	})
+	router.Handle("GET", "/panic", func(ctx *Context) Responder {
+		panic("boom")
+	})
+	router.Handle("GET", "/written", func(ctx *Context) Responder {
+		ctx.WriteHeader(http.StatusNoContent)
+		return nil
+	})
+
+	for _, path := range []string{"/foo", "/panic", "/nope", "/written"} {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest("GET", path, nil))
+
+		if want := path != "/written"; (w.Header().Get("X-Before") == "yes") != want {
+			t.Errorf("%s: expected header set before respond %t, got %q", path, want, w.Header().Get("X-Before"))
+		}
+	}
+
+	want := []string{
+		"handler", "before OK", "after",
+		"before Internal Server Error", "after",
+		"before Not Found", "after",
+		"after",
+	}
+	if len(trace) != len(want) {
+		t.Fatalf("expected %v, got %v", want, trace)
+	}
+	for idx := range want {
+		if trace[idx] != want[idx] {
+			t.Errorf("expected %v, got %v", want, trace)
+			break
+		}
+	}
+}
+
+type testPanicResponder struct{}
+
+func (testPanicResponder) StatusCode(_ *Context) int {
+	return http.StatusOK
+}
+
+func (testPanicResponder) Respond(_ *Context) {
+	panic("boom")
+}
+
+func TestRouter_BeforeRespond_respondPanic(t *testing.T) {
+	t.Parallel()
+
+	var calls int
+
+	router := NewRouter()
+	router.ServerTiming = true
+	router.BeforeRespond = func(_ *Context, _ Responder) {
+		calls++
+	}
+	router.Handle("GET", "/foo", func(_ *Context) Responder {
+		return testPanicResponder{}
+	})
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", "/foo", nil))
+
+	if calls != 1 {
+		t.Errorf("expected %d, got %d", 1, calls)
+	}
+	if timings := w.Header().Values("Server-Timing"); len(timings) != 1 {
+		t.Errorf("expected a single Server-Timing, got %v", timings)
+	}
+	if w.Code != http.StatusInternalServerError {
+		t.Errorf("expected %d, got %d", http.StatusInternalServerError, w.Code)
+	}
+}